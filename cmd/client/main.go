@@ -1,46 +1,181 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 
+	"concurrency_go_course/internal/compute"
+	"concurrency_go_course/internal/config"
 	"concurrency_go_course/internal/network"
 )
 
-var address string
+var (
+	address     string
+	command     string
+	scriptPath  string
+	format      string
+	timing      bool
+	historyPath string
+
+	user     string
+	password string
+
+	useTLS        bool
+	tlsCert       string
+	tlsKey        string
+	tlsCA         string
+	tlsServerName string
+)
+
+// passwordEnv is used if password flag is not set, so password is not
+// visible in process list
+const passwordEnv = "DB_PASSWORD"
 
 func init() {
 	flag.StringVar(&address, "addr", "127.0.0.1:3223", "database server address")
+	flag.StringVar(&command, "c", "", "execute command and exit")
+	flag.StringVar(&scriptPath, "f", "", "execute commands from file and exit, - reads stdin")
+	flag.StringVar(&format, "format", formatRaw, "output format: raw or json")
+	flag.BoolVar(&timing, "timing", false, "print time of each command")
+	flag.StringVar(&historyPath, "history", defaultHistoryPath(),
+		"file of interactive history, empty disables history")
+
+	flag.StringVar(&user, "user", "", "user name for AUTH")
+	flag.StringVar(&password, "password", "", "password for AUTH, "+passwordEnv+" is used by default")
+
+	flag.BoolVar(&useTLS, "tls", false, "connect with TLS, it is enabled by other TLS flags")
+	flag.StringVar(&tlsCert, "tls-cert", "", "client certificate file")
+	flag.StringVar(&tlsKey, "tls-key", "", "client key file")
+	flag.StringVar(&tlsCA, "tls-ca", "", "CA file to verify server certificate")
+	flag.StringVar(&tlsServerName, "tls-server-name", "", "server name to verify server certificate")
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags]\n\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(),
+			"Commands are read from -c, -f, piped stdin or interactive prompt.")
+		fmt.Fprintln(flag.CommandLine.Output(),
+			"Exit codes: 0 ok, 1 command failed, 2 usage error, 3 connection or authentication error.")
+		fmt.Fprintln(flag.CommandLine.Output())
+		flag.PrintDefaults()
+	}
 }
 
 func main() {
+	os.Exit(run())
+}
+
+func run() int {
 	flag.Parse()
 
-	client, err := network.NewClient(address)
+	if format != formatRaw && format != formatJSON {
+		fmt.Fprintf(os.Stderr, "unknown format %q, expected %s or %s\n", format, formatRaw, formatJSON)
+		return exitUsage
+	}
+
+	if command != "" && scriptPath != "" {
+		fmt.Fprintln(os.Stderr, "-c and -f are mutually exclusive")
+		return exitUsage
+	}
+
+	var script io.Reader
+	switch {
+	case command != "":
+		script = strings.NewReader(command)
+	case scriptPath == "-":
+		script = os.Stdin
+	case scriptPath != "":
+		f, err := os.Open(scriptPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "unable to open script:", err.Error())
+			return exitUsage
+		}
+		defer f.Close()
+		script = f
+	case !isTerminal(os.Stdin):
+		script = os.Stdin
+	}
+
+	var tlsCfg *config.TLSConfig
+	if useTLS || tlsCert != "" || tlsKey != "" || tlsCA != "" || tlsServerName != "" {
+		tlsCfg = &config.TLSConfig{
+			CertFile:   tlsCert,
+			KeyFile:    tlsKey,
+			CAFile:     tlsCA,
+			ServerName: tlsServerName,
+		}
+	}
+
+	client, err := network.NewClientWithTLS(address, tlsCfg)
 	if err != nil {
-		fmt.Println("Error starting client:", err.Error())
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, "unable to connect:", err.Error())
+		return exitConnection
 	}
 	defer client.Close()
 
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Println("Enter request:")
-	for {
-		request, err := reader.ReadBytes('\n')
-		if err != nil {
-			fmt.Printf("Error reading request: %v\n", err)
-			continue
+	r := &runner{
+		client: client,
+		out:    os.Stdout,
+		errOut: os.Stderr,
+		format: format,
+		timing: timing,
+	}
+	client.SetPushHandler(r.printPush)
+
+	if user != "" {
+		if password == "" {
+			password = os.Getenv(passwordEnv)
 		}
 
-		resp, err := client.Send(request)
+		_, err := client.Query([]byte(compute.CommandAuth + " " + user + " " + password))
 		if err != nil {
-			fmt.Printf("unable to send request: %v\n", err)
-			continue
+			fmt.Fprintln(os.Stderr, "unable to authenticate:", err.Error())
+			return exitConnection
+		}
+	}
+
+	if script != nil {
+		code := r.runScript(script)
+		if code == exitConnection || !r.subscribed() {
+			return code
+		}
+
+		// subscribed client prints messages until it is interrupted
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		select {
+		case <-ctx.Done():
+			return code
+		case <-client.Done():
+			fmt.Fprintln(os.Stderr, "connection was closed by server")
+			return exitConnection
 		}
+	}
+
+	return r.runREPL(address+"> ", historyPath)
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
 
-		fmt.Println("Server response: ", string(resp))
-		fmt.Println("Enter request:")
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
 	}
+
+	return filepath.Join(home, ".concurrency_go_course_history")
 }
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/peterh/liner"
+
+	"concurrency_go_course/internal/compute"
+	"concurrency_go_course/internal/info"
+)
+
+const (
+	replHelp = "help"
+	replQuit = "quit"
+	replExit = "exit"
+)
+
+// replWords are commands of REPL itself, they are not sent to server
+var replWords = []string{replHelp, replQuit, replExit}
+
+// runREPL reads commands from terminal with line editing, history and
+// completion until EOF or quit
+func (r *runner) runREPL(prompt, historyPath string) int {
+	line := liner.NewLiner()
+	defer line.Close()
+
+	line.SetCtrlCAborts(true)
+	line.SetCompleter(complete)
+
+	if historyPath != "" {
+		if f, err := os.Open(historyPath); err == nil {
+			_, _ = line.ReadHistory(f)
+			f.Close()
+		}
+
+		defer func() {
+			f, err := os.Create(historyPath)
+			if err != nil {
+				fmt.Fprintln(r.errOut, "unable to save history:", err.Error())
+				return
+			}
+			defer f.Close()
+
+			_, _ = line.WriteHistory(f)
+		}()
+	}
+
+	for {
+		command, err := line.Prompt(prompt)
+		if errors.Is(err, liner.ErrPromptAborted) {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			fmt.Fprintln(r.out)
+			return exitOK
+		}
+		if err != nil {
+			fmt.Fprintln(r.errOut, "unable to read command:", err.Error())
+			return exitUsage
+		}
+
+		command = strings.TrimSpace(command)
+		if command == "" {
+			continue
+		}
+		// passwords are not saved in history
+		if fields := strings.Fields(command); !strings.EqualFold(fields[0], compute.CommandAuth) {
+			line.AppendHistory(command)
+		}
+
+		switch strings.ToLower(command) {
+		case replQuit, replExit:
+			return exitOK
+		case replHelp:
+			fmt.Fprintln(r.out, "Commands:")
+			fmt.Fprintf(r.out, "  %s key [%s n] [%s duration]\n",
+				compute.CommandGet, compute.OptionMinLSN, compute.OptionMaxLag)
+			fmt.Fprintf(r.out, "  %s key value\n", compute.CommandSet)
+			fmt.Fprintf(r.out, "  %s key\n", compute.CommandDelete)
+			fmt.Fprintf(r.out, "  %s key field value [field value ...]\n", compute.CommandHSet)
+			fmt.Fprintf(r.out, "  %s key field\n", compute.CommandHGet)
+			fmt.Fprintf(r.out, "  %s key field [field ...]\n", compute.CommandHDel)
+			fmt.Fprintf(r.out, "  %s key\n", compute.CommandHGetAll)
+			fmt.Fprintf(r.out, "  %s | %s key value [value ...]\n", compute.CommandLPush, compute.CommandRPush)
+			fmt.Fprintf(r.out, "  %s | %s | %s key\n", compute.CommandLPop, compute.CommandRPop, compute.CommandLLen)
+			fmt.Fprintf(r.out, "  %s key start stop\n", compute.CommandLRange)
+			fmt.Fprintf(r.out, "  %s key timeout\n", compute.CommandBLPop)
+			fmt.Fprintf(r.out, "  %s key score member [score member ...]\n", compute.CommandZAdd)
+			fmt.Fprintf(r.out, "  %s key member [member ...]\n", compute.CommandZRem)
+			fmt.Fprintf(r.out, "  %s key member\n", compute.CommandZScore)
+			fmt.Fprintf(r.out, "  %s key start stop [%s]\n", compute.CommandZRange, compute.OptionWithScores)
+			fmt.Fprintf(r.out, "  %s key min max [%s]\n", compute.CommandZRangeByScore, compute.OptionWithScores)
+			fmt.Fprintf(r.out, "  %s key min max\n", compute.CommandZRemRangeByScore)
+			fmt.Fprintf(r.out, "  %s key\n", compute.CommandZCard)
+			fmt.Fprintf(r.out, "  %s key seconds\n", compute.CommandExpire)
+			fmt.Fprintf(r.out, "  %s key\n", compute.CommandTTL)
+			fmt.Fprintf(r.out, "  %s user password\n", compute.CommandAuth)
+			fmt.Fprintf(r.out, "  %s [message]\n", compute.CommandPing)
+			fmt.Fprintf(r.out, "  %s channel message\n", compute.CommandPublish)
+			fmt.Fprintf(r.out, "  %s | %s channel [channel ...]\n",
+				compute.CommandSubscribe, compute.CommandUnsubscribe)
+			fmt.Fprintf(r.out, "  %s | %s pattern [pattern ...]\n",
+				compute.CommandPSubscribe, compute.CommandPUnsubscribe)
+			fmt.Fprintln(r.out, "  help, quit, exit")
+			continue
+		}
+
+		if _, err := r.exec(command); err != nil {
+			return exitConnection
+		}
+	}
+}
+
+// complete completes command name, and options of GET, ZRANGE and
+// ZRANGEBYSCORE in place of option name
+func complete(line string) []string {
+	fields := strings.Fields(line)
+
+	// position of completed word, it is empty after trailing space
+	word := ""
+	position := len(fields)
+	if len(fields) > 0 && !strings.HasSuffix(line, " ") {
+		position--
+		word = fields[position]
+	}
+
+	var candidates []string
+	switch {
+	case position == 0:
+		candidates = append(compute.Commands(), compute.CommandAuth, compute.CommandPing,
+			compute.CommandSubscribe, compute.CommandUnsubscribe, compute.CommandPSubscribe, compute.CommandPUnsubscribe)
+		candidates = append(candidates, replWords...)
+	case strings.EqualFold(fields[0], compute.CommandGet) && (position == 2 || position == 4):
+		candidates = []string{compute.OptionMinLSN, compute.OptionMaxLag}
+	case strings.EqualFold(fields[0], compute.CommandInfo) && position == 1:
+		candidates = info.Sections()
+	case strings.EqualFold(fields[0], compute.CommandSlowLog) && position == 1:
+		candidates = []string{compute.SlowLogGet, compute.SlowLogLen, compute.SlowLogReset}
+	case (strings.EqualFold(fields[0], compute.CommandZRange) ||
+		strings.EqualFold(fields[0], compute.CommandZRangeByScore)) && position == 4:
+		candidates = []string{compute.OptionWithScores}
+	default:
+		return nil
+	}
+
+	head := line[:len(line)-len(word)]
+
+	var completions []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(strings.ToUpper(candidate), strings.ToUpper(word)) {
+			completions = append(completions, head+candidate)
+		}
+	}
+
+	return completions
+}
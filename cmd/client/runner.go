@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"concurrency_go_course/internal/compute"
+	"concurrency_go_course/internal/network"
+)
+
+// Exit codes of client, scripts may check them
+const (
+	exitOK = iota
+	// exitCommandFailed means server returned error for some command
+	exitCommandFailed
+	// exitUsage means invalid flags or unreadable script, flag package
+	// uses the same code
+	exitUsage
+	// exitConnection means connection failed or was broken, or
+	// authentication failed
+	exitConnection
+)
+
+const (
+	formatRaw  = "raw"
+	formatJSON = "json"
+)
+
+// querier sends request and returns payload of response envelope
+type querier interface {
+	Query(request []byte) ([]byte, error)
+}
+
+// runner executes commands and prints results in chosen format, values
+// go to out and errors to errOut in raw format, pushed messages are
+// printed between results
+type runner struct {
+	client querier
+	out    io.Writer
+	errOut io.Writer
+	format string
+	timing bool
+
+	// mutex guards output and subscriptions, pushes are printed by reader
+	// of client
+	mutex         sync.Mutex
+	subscriptions int
+}
+
+// jsonResult is one line of json output
+type jsonResult struct {
+	Command    string   `json:"command"`
+	OK         bool     `json:"ok"`
+	Value      string   `json:"value,omitempty"`
+	Code       string   `json:"code,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	DurationMS *float64 `json:"duration_ms,omitempty"`
+}
+
+// jsonPush is one line of json output for pushed message
+type jsonPush struct {
+	Push string `json:"push"`
+}
+
+// exec executes one command, error is returned only if connection is
+// broken, server errors are printed and reported by failed flag
+func (r *runner) exec(command string) (failed bool, err error) {
+	start := time.Now()
+	value, err := r.client.Query([]byte(command))
+	duration := time.Since(start)
+
+	var respErr *network.ResponseError
+	if err != nil && !errors.As(err, &respErr) {
+		r.print(command, nil, nil, err, duration)
+		return true, err
+	}
+
+	r.print(command, value, respErr, nil, duration)
+	if respErr == nil {
+		r.countSubscriptions(command, value)
+	}
+
+	return respErr != nil, nil
+}
+
+// countSubscriptions keeps number of subscriptions of connection, it is
+// the last field of reply to subscribe commands
+func (r *runner) countSubscriptions(command string, value []byte) {
+	fields := strings.Fields(command)
+	switch fields[0] {
+	case compute.CommandSubscribe, compute.CommandUnsubscribe, compute.CommandPSubscribe, compute.CommandPUnsubscribe:
+	default:
+		return
+	}
+
+	reply := strings.Fields(string(value))
+	if len(reply) == 0 {
+		return
+	}
+
+	if n, err := strconv.Atoi(reply[len(reply)-1]); err == nil {
+		r.mutex.Lock()
+		r.subscriptions = n
+		r.mutex.Unlock()
+	}
+}
+
+// subscribed returns true if connection has subscriptions
+func (r *runner) subscribed() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.subscriptions != 0
+}
+
+// printPush prints message pushed by server
+func (r *runner) printPush(payload []byte) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.format == formatJSON {
+		data, _ := json.Marshal(jsonPush{Push: string(payload)})
+		fmt.Fprintln(r.out, string(data))
+		return
+	}
+
+	fmt.Fprintln(r.out, string(payload))
+}
+
+func (r *runner) print(command string, value []byte, respErr *network.ResponseError,
+	connErr error, duration time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.format == formatJSON {
+		result := jsonResult{Command: command, OK: respErr == nil && connErr == nil}
+		switch {
+		case respErr != nil:
+			result.Code = respErr.Code.String()
+			result.Error = respErr.Message
+		case connErr != nil:
+			result.Error = connErr.Error()
+		default:
+			result.Value = string(value)
+		}
+
+		if r.timing {
+			ms := float64(duration.Microseconds()) / 1000
+			result.DurationMS = &ms
+		}
+
+		data, _ := json.Marshal(result)
+		fmt.Fprintln(r.out, string(data))
+		return
+	}
+
+	switch {
+	case respErr != nil:
+		fmt.Fprintln(r.errOut, "(error)", respErr.Error())
+	case connErr != nil:
+		fmt.Fprintln(r.errOut, "(error) connection:", connErr.Error())
+	default:
+		fmt.Fprintln(r.out, string(value))
+	}
+
+	if r.timing {
+		fmt.Fprintf(r.errOut, "(%s)\n", duration.Round(time.Microsecond))
+	}
+}
+
+// runScript executes commands line by line, empty lines and lines starting
+// with # are skipped, execution stops only if connection is broken
+func (r *runner) runScript(script io.Reader) int {
+	code := exitOK
+
+	scanner := bufio.NewScanner(script)
+	scanner.Buffer(nil, network.ClientDefaultMaxMessageSize)
+	for scanner.Scan() {
+		command := strings.TrimSpace(scanner.Text())
+		if command == "" || strings.HasPrefix(command, "#") {
+			continue
+		}
+
+		failed, err := r.exec(command)
+		if err != nil {
+			return exitConnection
+		}
+
+		if failed {
+			code = exitCommandFailed
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(r.errOut, "unable to read script:", err.Error())
+		return exitUsage
+	}
+
+	return code
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"concurrency_go_course/internal/network"
+)
+
+type fakeQuerier map[string]error
+
+func (f fakeQuerier) Query(request []byte) ([]byte, error) {
+	if err, ok := f[string(request)]; ok {
+		return nil, err
+	}
+
+	return []byte("OK"), nil
+}
+
+func TestRunScript(t *testing.T) {
+	t.Parallel()
+
+	client := fakeQuerier{
+		"GET missing": &network.ResponseError{Code: network.CodeNotFound, Message: "value not found"},
+		"GET broken":  errors.New("connection reset"),
+	}
+
+	tests := map[string]struct {
+		script string
+		format string
+		code   int
+		out    string
+		errOut string
+	}{
+		"all succeeded": {
+			script: "SET a 1\n\n# comment\n  DEL a  \n",
+			format: formatRaw,
+			code:   exitOK,
+			out:    "OK\nOK\n",
+		},
+		"server error does not stop script": {
+			script: "GET missing\nSET a 1",
+			format: formatRaw,
+			code:   exitCommandFailed,
+			out:    "OK\n",
+			errOut: "(error) NOT_FOUND: value not found\n",
+		},
+		"connection error stops script": {
+			script: "GET broken\nSET a 1",
+			format: formatRaw,
+			code:   exitConnection,
+			errOut: "(error) connection: connection reset\n",
+		},
+		"json": {
+			script: "SET a 1\nGET missing",
+			format: formatJSON,
+			code:   exitCommandFailed,
+			out: `{"command":"SET a 1","ok":true,"value":"OK"}` + "\n" +
+				`{"command":"GET missing","ok":false,"code":"NOT_FOUND","error":"value not found"}` + "\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out, errOut bytes.Buffer
+			r := &runner{client: client, out: &out, errOut: &errOut, format: test.format}
+
+			code := r.runScript(strings.NewReader(test.script))
+			assert.Equal(t, test.code, code)
+			assert.Equal(t, test.out, out.String())
+			assert.Equal(t, test.errOut, errOut.String())
+		})
+	}
+}
+
+// fakeSubscriber replies to subscribe commands with number of channels
+type fakeSubscriber struct {
+	channels int
+}
+
+func (f *fakeSubscriber) Query(request []byte) ([]byte, error) {
+	fields := strings.Fields(string(request))
+	if fields[0] == "SUBSCRIBE" {
+		f.channels++
+		return []byte("subscribe " + fields[1] + " " + strconv.Itoa(f.channels)), nil
+	}
+
+	f.channels = 0
+	return []byte("unsubscribe 0"), nil
+}
+
+func TestRunnerPush(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	r := &runner{client: &fakeSubscriber{}, out: &out, errOut: &out, format: formatRaw}
+
+	assert.Equal(t, exitOK, r.runScript(strings.NewReader("SUBSCRIBE news")))
+	assert.True(t, r.subscribed())
+
+	r.printPush([]byte("message news hello"))
+	assert.Equal(t, "subscribe news 1\nmessage news hello\n", out.String())
+
+	assert.Equal(t, exitOK, r.runScript(strings.NewReader("UNSUBSCRIBE")))
+	assert.False(t, r.subscribed())
+
+	out.Reset()
+	r.format = formatJSON
+	r.printPush([]byte("message news hello"))
+	assert.Equal(t, `{"push":"message news hello"}`+"\n", out.String())
+}
+
+func TestComplete(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		line     string
+		expected []string
+	}{
+		"empty line":           {line: "", expected: []string{"GET", "SET", "DEL", "INFO", "SLOWLOG", "PUBLISH", "HSET", "HGET", "HDEL", "HGETALL", "LPUSH", "RPUSH", "LPOP", "RPOP", "LRANGE", "LLEN", "BLPOP", "ZADD", "ZREM", "ZSCORE", "ZRANGE", "ZRANGEBYSCORE", "ZREMRANGEBYSCORE", "ZCARD", "EXPIRE", "TTL", "AUTH", "PING", "SUBSCRIBE", "UNSUBSCRIBE", "PSUBSCRIBE", "PUNSUBSCRIBE", "help", "quit", "exit"}},
+		"command prefix":       {line: "g", expected: []string{"GET"}},
+		"key is not completed": {line: "GET ", expected: nil},
+		"GET option":           {line: "GET key m", expected: []string{"GET key MINLSN", "GET key MAXLAG"}},
+		"option value":         {line: "GET key MINLSN ", expected: nil},
+		"second GET option":    {line: "get key MINLSN 1 MA", expected: []string{"get key MINLSN 1 MAXLAG"}},
+		"SET has no options":   {line: "SET key ", expected: nil},
+		"INFO section":         {line: "INFO re", expected: []string{"INFO replication"}},
+		"SLOWLOG subcommand":   {line: "SLOWLOG R", expected: []string{"SLOWLOG RESET"}},
+		"subscribe commands":   {line: "PS", expected: []string{"PSUBSCRIBE"}},
+		"hash commands":        {line: "HG", expected: []string{"HGET", "HGETALL"}},
+		"list commands":        {line: "L", expected: []string{"LPUSH", "LPOP", "LRANGE", "LLEN"}},
+		"ZRANGE option":        {line: "ZRANGE board 0 -1 w", expected: []string{"ZRANGE board 0 -1 WITHSCORES"}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, complete(test.line))
+		})
+	}
+}
@@ -0,0 +1,36 @@
+// Command passwd prints entry of users file, password is read from stdin
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"concurrency_go_course/internal/auth"
+)
+
+func main() {
+	user := flag.String("user", "", "user name")
+	flag.Parse()
+
+	if *user == "" {
+		fmt.Fprintln(os.Stderr, "user name is required")
+		os.Exit(2)
+	}
+
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		fmt.Fprintln(os.Stderr, "unable to read password:", err.Error())
+		os.Exit(1)
+	}
+
+	hash, err := auth.HashPassword(strings.TrimRight(password, "\r\n"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "unable to hash password:", err.Error())
+		os.Exit(1)
+	}
+
+	fmt.Printf("  - name: %s\n    password_hash: %q\n", *user, hash)
+}
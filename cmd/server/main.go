@@ -4,20 +4,37 @@ import (
 	"context"
 	"flag"
 	"log"
+	"net/http"
+	"os"
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
 	"concurrency_go_course/internal/app"
+	"concurrency_go_course/internal/auth"
+	"concurrency_go_course/internal/cdc"
 	"concurrency_go_course/internal/config"
+	"concurrency_go_course/internal/database"
+	"concurrency_go_course/internal/grpcapi"
+	"concurrency_go_course/internal/httpapi"
+	"concurrency_go_course/internal/info"
 	"concurrency_go_course/internal/network"
+	"concurrency_go_course/internal/pubsub"
 	"concurrency_go_course/internal/replication"
+	"concurrency_go_course/internal/resp"
+	"concurrency_go_course/internal/slowlog"
+	"concurrency_go_course/internal/watch"
 	"concurrency_go_course/pkg/logger"
+	"concurrency_go_course/pkg/metrics"
+	"concurrency_go_course/pkg/trace"
 )
 
 var configPathMaster = "config.yaml"
 
 func main() {
+	started := time.Now()
+
 	configPath := flag.String("config-path", configPathMaster, "path to config file")
 	flag.Parse()
 
@@ -33,12 +50,74 @@ func main() {
 	logger.InitLogger(cfg.Logging.Level, cfg.Logging.Output)
 	logger.Debug("init logger")
 
+	var users *auth.Users
+	if cfg.Network.UsersFile != "" {
+		users, err = auth.LoadUsers(cfg.Network.UsersFile)
+		if err != nil {
+			log.Fatalf("unable to start server: %v", err)
+		}
+
+		go reloadOnHangup(ctx, users)
+	}
+
+	if cfg.Tracing != nil {
+		tracer, err := app.NewTracer(cfg.Tracing)
+		if err != nil {
+			log.Fatalf("unable to start server: %v", err)
+		}
+
+		trace.SetDefault(tracer)
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			if err := tracer.Shutdown(shutdownCtx); err != nil {
+				logger.ErrorWithMsg("unable to export spans:", err)
+			}
+		}()
+	}
+
 	walCfg, err := config.NewWALConfig(*configPath)
 	if err != nil {
 		logger.Info("unable to set WAL settings, WAL is disabled")
 	}
 
-	db, wal, repl, err := app.Init(cfg, walCfg)
+	// changes are published only if there is a gateway to watch them
+	var hub *watch.Hub
+	if cfg.Network.HTTPAddress != "" || cfg.Network.GRPCAddress != "" {
+		hub = watch.NewHub()
+	}
+
+	// pub/sub commands are enabled by pubsub config, keyspace notifications
+	// are published only if they are requested
+	var broker, keyspace *pubsub.Broker
+	if cfg.PubSub != nil {
+		policy, err := pubsub.ParsePolicy(cfg.PubSub.SlowSubscriberPolicy)
+		if err != nil {
+			log.Fatalf("unable to start server: %v", err)
+		}
+
+		broker = pubsub.NewBroker(pubsub.WithBufferSize(cfg.PubSub.BufferSize), pubsub.WithPolicy(policy))
+		if cfg.PubSub.KeyspaceEvents {
+			keyspace = broker
+		}
+	}
+
+	var dbOptions []database.Option
+	if users != nil {
+		dbOptions = append(dbOptions, database.WithAuthorizer(users))
+	}
+	if cfg.SlowLog != nil {
+		dbOptions = append(dbOptions, database.WithSlowLog(slowlog.New(cfg.SlowLog)))
+	}
+	if broker != nil {
+		dbOptions = append(dbOptions, database.WithPublisher(broker))
+	}
+
+	nodeInfo := info.New()
+	nodeInfo.Add(info.SectionServer, app.ServerSection(*configPath, started))
+
+	db, wal, repl, err := app.Init(ctx, cfg, walCfg, hub, keyspace, nodeInfo, dbOptions...)
 	if err != nil {
 		log.Fatal("unable to init app")
 	}
@@ -74,22 +153,144 @@ func main() {
 
 				repl.Slave.Start(ctx)
 			}()
+		} else if repl.Raft != nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				repl.Raft.Start(ctx)
+			}()
 		}
 	}
 
-	server, err := network.NewServer(cfg, cfg.Network.Address)
+	if cfg.CDC != nil {
+		var walDirectory string
+		if wal != nil && walCfg != nil && walCfg.WalConfig != nil {
+			walDirectory = walCfg.WalConfig.DataDirectory
+		}
+
+		tailer, err := cdc.New(cfg.CDC, walDirectory)
+		if err != nil {
+			log.Fatalf("unable to start CDC: %v", err)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			tailer.Start(ctx)
+		}()
+	}
+
+	var respOptions []resp.Option
+	if users != nil {
+		respOptions = append(respOptions, resp.WithUsers(users))
+	}
+
+	respHandler, err := resp.NewHandler(cfg.Network, db, broker, respOptions...)
 	if err != nil {
-		log.Fatal("unable to start server")
+		log.Fatal("unable to create RESP handler")
 	}
 
-	server.Run(ctx, func(_ context.Context, s []byte) []byte {
-		response, err := db.Handle(string(s) + "\n")
+	if cfg.Network.RESPAddress != "" {
+		respServer, err := network.NewServer(cfg, cfg.Network.RESPAddress)
 		if err != nil {
-			logger.ErrorWithMsg("unable to handle query:", err)
-			response = err.Error()
+			log.Fatal("unable to start RESP server")
 		}
-		return []byte(response)
-	})
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			respServer.Serve(ctx, respHandler.ServeConn)
+		}()
+	}
+
+	if cfg.Network.HTTPAddress != "" {
+		var httpOptions []httpapi.Option
+		if users != nil {
+			httpOptions = append(httpOptions, httpapi.WithUsers(users))
+		}
+
+		httpHandler, err := httpapi.NewHandler(cfg.Network, db, hub, httpOptions...)
+		if err != nil {
+			log.Fatal("unable to create HTTP handler")
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			httpapi.Serve(ctx, cfg.Network.HTTPAddress, httpHandler)
+		}()
+	}
+
+	if cfg.Network.GRPCAddress != "" {
+		var grpcOptions []grpcapi.Option
+		if users != nil {
+			grpcOptions = append(grpcOptions, grpcapi.WithUsers(users))
+		}
+
+		grpcServer, err := grpcapi.NewServer(db, hub, grpcOptions...)
+		if err != nil {
+			log.Fatal("unable to create gRPC server")
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			grpcapi.Serve(ctx, cfg.Network.GRPCAddress, grpcServer)
+		}()
+	}
+
+	if cfg.Network.MetricsAddress != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			httpapi.Serve(ctx, cfg.Network.MetricsAddress, mux)
+		}()
+	}
+
+	server, err := network.NewServerWithTLS(cfg, cfg.Network.Address, cfg.Network.TLS)
+	if err != nil {
+		log.Fatal("unable to start server")
+	}
+
+	nodeInfo.Add(info.SectionClients, app.ClientsSection(server))
+
+	if cfg.Network.Protocol == resp.Protocol {
+		server.Serve(ctx, respHandler.ServeConn)
+	} else {
+		server.SetTooLargeResponse(app.TooLargeResponse())
+		server.Run(ctx, app.ConnectionHandler(users, app.PubSubHandler(broker, users, app.QueryHandler(db))))
+	}
 
 	wg.Wait()
 }
+
+// reloadOnHangup reloads users and their roles on SIGHUP, so access rules
+// are changed without restart
+func reloadOnHangup(ctx context.Context, users *auth.Users) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			if err := users.Reload(); err != nil {
+				logger.ErrorWithMsg("unable to reload users file, previous users are kept:", err)
+				continue
+			}
+
+			logger.Info("users file was reloaded")
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"concurrency_go_course/internal/auth"
+	"concurrency_go_course/internal/compute"
+	"concurrency_go_course/internal/network"
+	"concurrency_go_course/pkg/logger"
+)
+
+// ConnectionHandler handles AUTH and PING, other requests are passed to
+// next handler, if users are set only authenticated sessions may send them
+// unless users allow anonymous clients
+func ConnectionHandler(users *auth.Users, next network.TCPHandler) network.TCPHandler {
+	return func(ctx context.Context, request []byte) []byte {
+		session := network.SessionFromContext(ctx)
+
+		fields := strings.Fields(string(request))
+		if len(fields) > 0 {
+			switch fields[0] {
+			case compute.CommandPing:
+				return handlePing(fields[1:])
+			case compute.CommandAuth:
+				return handleAuth(users, session, fields[1:])
+			}
+		}
+
+		if users != nil && !users.AllowsAnonymous() && (session == nil || session.User == "") {
+			return network.NewErrorResponse(network.CodeAuthRequired, "authentication required").Encode()
+		}
+
+		return next(ctx, request)
+	}
+}
+
+func handlePing(args []string) []byte {
+	if len(args) == 0 {
+		return network.NewResponse([]byte("PONG")).Encode()
+	}
+
+	return network.NewResponse([]byte(strings.Join(args, " "))).Encode()
+}
+
+func handleAuth(users *auth.Users, session *network.Session, args []string) []byte {
+	if len(args) != 2 {
+		return network.NewErrorResponse(network.CodeParseError,
+			fmt.Sprintf("for command %s expected 2 arguments, got %d", compute.CommandAuth, len(args))).Encode()
+	}
+
+	if users == nil {
+		return network.NewErrorResponse(network.CodeAuthFailed, "authentication is disabled").Encode()
+	}
+
+	if session == nil {
+		return network.NewErrorResponse(network.CodeInternal, "connection has no session").Encode()
+	}
+
+	name := args[0]
+	if err := users.Authenticate(name, args[1]); err != nil {
+		logger.Warn("authentication failed", zap.String("user", name),
+			zap.String("remote_addr", session.RemoteAddr))
+		return network.NewErrorResponse(network.CodeAuthFailed, err.Error()).Encode()
+	}
+
+	session.User = name
+	logger.Info("authenticated", zap.String("user", name),
+		zap.String("remote_addr", session.RemoteAddr))
+
+	return network.NewResponse([]byte("OK")).Encode()
+}
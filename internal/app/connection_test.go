@@ -0,0 +1,114 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"concurrency_go_course/internal/auth"
+	"concurrency_go_course/internal/network"
+	"concurrency_go_course/pkg/logger"
+)
+
+func TestConnectionHandler(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	hash, err := auth.HashPassword("secret")
+	require.NoError(t, err)
+
+	users, err := auth.NewUsers(auth.Config{Users: []auth.User{{Name: "alice", PasswordHash: hash}}})
+	require.NoError(t, err)
+
+	next := func(_ context.Context, request []byte) []byte {
+		return network.NewResponse(append([]byte("next "), request...)).Encode()
+	}
+
+	session := &network.Session{RemoteAddr: "127.0.0.1:1234"}
+	ctx := network.ContextWithSession(context.Background(), session)
+	handler := ConnectionHandler(users, next)
+
+	// steps share session of one connection
+	steps := []struct {
+		name     string
+		request  string
+		expected network.Response
+	}{
+		{
+			name:     "ping without authentication",
+			request:  "PING",
+			expected: network.NewResponse([]byte("PONG")),
+		},
+		{
+			name:     "ping with message",
+			request:  "PING hello",
+			expected: network.NewResponse([]byte("hello")),
+		},
+		{
+			name:     "query without authentication",
+			request:  "GET key",
+			expected: network.NewErrorResponse(network.CodeAuthRequired, "authentication required"),
+		},
+		{
+			name:     "wrong password",
+			request:  "AUTH alice wrong",
+			expected: network.NewErrorResponse(network.CodeAuthFailed, "invalid username or password"),
+		},
+		{
+			name:     "invalid auth",
+			request:  "AUTH alice",
+			expected: network.NewErrorResponse(network.CodeParseError, "for command AUTH expected 2 arguments, got 1"),
+		},
+		{
+			name:     "auth",
+			request:  "AUTH alice secret",
+			expected: network.NewResponse([]byte("OK")),
+		},
+		{
+			name:     "query after authentication",
+			request:  "GET key",
+			expected: network.NewResponse([]byte("next GET key")),
+		},
+	}
+
+	for _, step := range steps {
+		response, err := network.DecodeResponse(handler(ctx, []byte(step.request)))
+		require.NoError(t, err, step.name)
+		assert.Equal(t, step.expected, response, step.name)
+	}
+	assert.Equal(t, "alice", session.User)
+
+	t.Run("anonymous roles", func(t *testing.T) {
+		users, err := auth.NewUsers(auth.Config{
+			Users:          []auth.User{{Name: "alice", PasswordHash: hash}},
+			Roles:          []auth.Role{{Name: "reader", Allow: []auth.Rule{{Categories: []auth.Category{auth.CategoryRead}}}}},
+			AnonymousRoles: []string{"reader"},
+		})
+		require.NoError(t, err)
+
+		session := &network.Session{RemoteAddr: "127.0.0.1:1234"}
+		ctx := network.ContextWithSession(context.Background(), session)
+		handler := ConnectionHandler(users, next)
+
+		// access of anonymous session is checked by database
+		response, err := network.DecodeResponse(handler(ctx, []byte("GET key")))
+		require.NoError(t, err)
+		assert.Equal(t, network.NewResponse([]byte("next GET key")), response)
+		assert.Empty(t, session.User)
+	})
+
+	t.Run("authentication disabled", func(t *testing.T) {
+		handler := ConnectionHandler(nil, next)
+
+		response, err := network.DecodeResponse(handler(context.Background(), []byte("GET key")))
+		require.NoError(t, err)
+		assert.Equal(t, network.NewResponse([]byte("next GET key")), response)
+
+		response, err = network.DecodeResponse(handler(context.Background(), []byte("AUTH alice secret")))
+		require.NoError(t, err)
+		assert.Equal(t, network.NewErrorResponse(network.CodeAuthFailed, "authentication is disabled"), response)
+	})
+}
@@ -0,0 +1,167 @@
+package app
+
+import (
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"concurrency_go_course/internal/info"
+	"concurrency_go_course/internal/network"
+	"concurrency_go_course/internal/replication"
+	"concurrency_go_course/internal/storage"
+	"concurrency_go_course/internal/storage/wal"
+)
+
+// Version is a version of server, it may be set by linker flags, version
+// of main module is used otherwise
+var Version = ""
+
+// roleStandalone is a replication role of node without replication
+const roleStandalone = "standalone"
+
+// ServerSection returns INFO section with process state
+func ServerSection(configPath string, started time.Time) info.Section {
+	version := Version
+	if version == "" {
+		version = "unknown"
+		if build, ok := debug.ReadBuildInfo(); ok {
+			version = build.Main.Version
+		}
+	}
+
+	return func() []info.Field {
+		return []info.Field{
+			info.String("version", version),
+			info.String("go_version", runtime.Version()),
+			info.Int("process_id", int64(os.Getpid())),
+			info.Unix("started_at_unix", started),
+			info.Int("uptime_seconds", int64(time.Since(started).Seconds())),
+			info.String("config_path", configPath),
+		}
+	}
+}
+
+// ClientsSection returns INFO section with connections of server
+func ClientsSection(server *network.TCPServer) info.Section {
+	return func() []info.Field {
+		stats := server.ClientStats()
+
+		return []info.Field{
+			info.Int("connected_clients", stats.Connected),
+			info.Int("blocked_clients", stats.Blocked),
+			info.Int("rejected_connections", stats.Rejected),
+		}
+	}
+}
+
+// memorySection returns INFO section with approximate size of partitions
+func memorySection(engine storage.Engine) info.Section {
+	return func() []info.Field {
+		partitions := engine.Partitions()
+
+		var keys, bytes int
+		fields := make([]info.Field, 0, len(partitions)+3)
+		for i, partition := range partitions {
+			keys += partition.Keys
+			bytes += partition.Bytes
+			fields = append(fields, info.Attributes("partition_"+strconv.Itoa(i),
+				info.Int("keys", int64(partition.Keys)), info.Int("bytes", int64(partition.Bytes))))
+		}
+
+		return append([]info.Field{
+			info.Int("partitions", int64(len(partitions))),
+			info.Int("keys", int64(keys)),
+			info.Int("used_bytes_approx", int64(bytes)),
+		}, fields...)
+	}
+}
+
+// persistenceSection returns INFO section with WAL state, wal may be nil
+func persistenceSection(walObj *wal.WAL) info.Section {
+	return func() []info.Field {
+		if walObj == nil {
+			return []info.Field{info.Int("wal_enabled", 0)}
+		}
+
+		stats, err := walObj.Stats()
+		if err != nil {
+			return []info.Field{info.Int("wal_enabled", 1), info.String("wal_error", err.Error())}
+		}
+
+		return []info.Field{
+			info.Int("wal_enabled", 1),
+			info.Unix("last_flush_unix", stats.LastFlush),
+			info.String("active_segment", stats.ActiveSegment),
+			info.Int("segment_count", int64(stats.Segments)),
+			info.Uint("last_lsn", stats.LastLSN),
+		}
+	}
+}
+
+// replicationSection returns INFO section with role of node and its
+// replication position, offset is a log sequence number
+func replicationSection(repl *replication.Replication, walObj *wal.WAL) info.Section {
+	return func() []info.Field {
+		switch {
+		case repl.Master != nil:
+			fields := []info.Field{
+				info.String("role", replication.ReplicaTypeMaster),
+				info.String("master_address", repl.Master.Address()),
+				info.Uint("offset", walObj.LastLSN()),
+			}
+
+			replicas := repl.Master.Replicas()
+			fields = append(fields, info.Int("connected_replicas", int64(len(replicas))))
+			for i, replica := range replicas {
+				fields = append(fields, info.Attributes("replica_"+strconv.Itoa(i),
+					info.String("id", replica.ID),
+					info.Int("lag_bytes", replica.LagBytes),
+					info.Unix("last_sync_unix", replica.LastSync)))
+			}
+
+			return fields
+		case repl.Slave != nil:
+			address, connected := repl.Slave.MasterStatus()
+			status := "down"
+			if connected {
+				status = "up"
+			}
+
+			lagMs := int64(-1)
+			if lag, ok := repl.Slave.Lag(); ok {
+				lagMs = lag.Milliseconds()
+			}
+
+			return []info.Field{
+				info.String("role", replication.ReplicaTypeSlave),
+				info.String("master_address", address),
+				info.String("master_link_status", status),
+				info.Uint("offset", repl.Slave.AppliedLSN()),
+				info.Int("lag_ms", lagMs),
+			}
+		case repl.Raft != nil:
+			status := repl.Raft.Status()
+
+			return []info.Field{
+				info.String("role", replication.ReplicaTypeRaft),
+				info.String("raft_state", status.State.String()),
+				info.String("master_address", status.Leader),
+				info.Uint("term", status.Term),
+				info.Uint("offset", status.Applied),
+				info.Uint("lag_entries", status.LastIndex-min(status.Applied, status.LastIndex)),
+			}
+		default:
+			var offset uint64
+			if walObj != nil {
+				offset = walObj.LastLSN()
+			}
+
+			return []info.Field{
+				info.String("role", roleStandalone),
+				info.Uint("offset", offset),
+			}
+		}
+	}
+}
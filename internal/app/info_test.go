@@ -0,0 +1,97 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"concurrency_go_course/internal/config"
+	"concurrency_go_course/internal/info"
+	"concurrency_go_course/pkg/logger"
+)
+
+func TestInitInfo(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	cfg := &config.Config{
+		Engine: &config.EngineConfig{Type: "in_memory", PartitionsNumber: 2},
+		Network: &config.NetworkConfig{
+			MaxConnections: 1,
+			MaxMessageSize: "4KB",
+			IdleTimeout:    "5m",
+		},
+		Replication: &config.ReplicationConfig{
+			ReplicaType:   "master",
+			MasterAddress: "127.0.0.1:0",
+		},
+	}
+	walCfg := &config.WALCfg{
+		WalConfig: &config.WALSettings{
+			FlushingBatchSize:    1,
+			FlushingBatchTimeout: "10ms",
+			MaxSegmentSize:       "1MB",
+			DataDirectory:        t.TempDir(),
+		},
+	}
+
+	nodeInfo := info.New()
+	nodeInfo.Add(info.SectionServer, ServerSection("config.yaml", time.Now()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db, wal, repl, err := Init(ctx, cfg, walCfg, nil, nil, nodeInfo)
+	require.NoError(t, err)
+	require.NotNil(t, repl.Master)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		wal.Start(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	_, err = db.Handle(context.Background(), "SET key value")
+	require.NoError(t, err)
+
+	result, err := db.Handle(context.Background(), "INFO")
+	require.NoError(t, err)
+
+	fields := make(map[string]string)
+	var sections []string
+	for _, line := range strings.Split(result, "\n") {
+		if name, ok := strings.CutPrefix(line, "# "); ok {
+			sections = append(sections, name)
+			continue
+		}
+
+		if name, value, ok := strings.Cut(line, ":"); ok {
+			fields[name] = value
+		}
+	}
+
+	assert.Equal(t, []string{"server", "memory", "persistence", "replication"}, sections)
+	assert.Equal(t, "config.yaml", fields["config_path"])
+	assert.Equal(t, "2", fields["partitions"])
+	assert.Equal(t, "1", fields["keys"])
+	assert.Equal(t, "56", fields["used_bytes_approx"])
+	assert.Equal(t, "1", fields["wal_enabled"])
+	assert.Equal(t, "1", fields["segment_count"])
+	assert.Equal(t, "1", fields["last_lsn"])
+	assert.Equal(t, "master", fields["role"])
+	assert.Equal(t, "1", fields["offset"])
+
+	result, err = db.Handle(context.Background(), "INFO replication")
+	require.NoError(t, err)
+	assert.Equal(t, "# replication\nrole:master\nmaster_address:"+repl.Master.Address()+
+		"\noffset:1\nconnected_replicas:0", result)
+}
@@ -1,19 +1,29 @@
 package app
 
 import (
+	"context"
 	"fmt"
 
 	"concurrency_go_course/internal/compute"
 	"concurrency_go_course/internal/config"
 	"concurrency_go_course/internal/database"
+	"concurrency_go_course/internal/info"
+	"concurrency_go_course/internal/pubsub"
 	"concurrency_go_course/internal/replication"
 	"concurrency_go_course/internal/storage"
 	"concurrency_go_course/internal/storage/wal"
+	"concurrency_go_course/internal/watch"
 	"concurrency_go_course/pkg/logger"
 )
 
-// Init initializes new database and wal service and other objects
-func Init(cfg *config.Config, walCfg *config.WALCfg) (
+// Init initializes new database and wal service and other objects, changes
+// applied to engine are published to hub and keyspace notifications are
+// published to keyspace broker if they are set, node sections are added to
+// info and INFO command is enabled if info is set. Expired keys are deleted
+// in background until context is done
+func Init(ctx context.Context, cfg *config.Config, walCfg *config.WALCfg, hub *watch.Hub, keyspace *pubsub.Broker,
+	nodeInfo *info.Info, options ...database.Option,
+) (
 	database.Database, *wal.WAL, *replication.Replication, error,
 ) {
 	var err error
@@ -27,6 +37,10 @@ func Init(cfg *config.Config, walCfg *config.WALCfg) (
 		return nil, nil, nil, fmt.Errorf("config is empty")
 	}
 
+	if replicaType == replication.ReplicaTypeRaft {
+		return initRaft(ctx, cfg, hub, keyspace, nodeInfo, options...)
+	}
+
 	var walObj *wal.WAL
 
 	if walCfg == nil || walCfg.WalConfig == nil {
@@ -41,7 +55,12 @@ func Init(cfg *config.Config, walCfg *config.WALCfg) (
 	repl := &replication.Replication{}
 
 	if replicaType == replication.ReplicaTypeMaster {
-		replServer, err := replication.NewReplicationServer(cfg, walCfg)
+		var position replication.WALPosition
+		if walObj != nil {
+			position = walObj.Position()
+		}
+
+		replServer, err := replication.NewReplicationServer(cfg, walCfg, position)
 		if err != nil {
 			logger.ErrorWithMsg("unable to create replication master server:", err)
 		} else {
@@ -57,22 +76,83 @@ func Init(cfg *config.Config, walCfg *config.WALCfg) (
 		}
 	}
 
-	var replStream chan []wal.Request
+	var replStream chan replication.Batch
 	if repl.Slave != nil {
 		replStream = repl.Slave.ReplicationStream()
 	}
 
-	engine := storage.NewEngine(cfg.Engine.PartitionsNumber)
+	engine := newEngine(cfg, hub, keyspace)
 
 	storage, err := storage.New(engine, walObj, replicaType, replStream)
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("unable to init storage: %v", err)
 	}
 
+	go storage.RunExpiration(ctx)
+
+	if nodeInfo != nil {
+		nodeInfo.Add(info.SectionMemory, memorySection(engine))
+		nodeInfo.Add(info.SectionPersistence, persistenceSection(walObj))
+		nodeInfo.Add(info.SectionReplication, replicationSection(repl, walObj))
+		options = append(options, database.WithInfo(nodeInfo))
+	}
+
 	requestParser := compute.NewRequestParser()
 	compute := compute.NewCompute(requestParser)
 
-	db := database.NewDatabase(storage, compute)
+	var db database.Database
+	if repl.Slave != nil {
+		db = database.NewReplicaDatabase(storage, compute, repl.Slave, options...)
+	} else {
+		db = database.NewDatabase(storage, compute, options...)
+	}
 
 	return db, walObj, repl, nil
 }
+
+func initRaft(ctx context.Context, cfg *config.Config, hub *watch.Hub, keyspace *pubsub.Broker, nodeInfo *info.Info,
+	options ...database.Option,
+) (
+	database.Database, *wal.WAL, *replication.Replication, error,
+) {
+	engine := newEngine(cfg, hub, keyspace)
+
+	replRaft, err := replication.NewRaft(cfg, storage.NewStateMachine(engine))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("unable to create raft node: %v", err)
+	}
+
+	storage, err := storage.NewConsensus(engine, replRaft, replRaft.ProposeTimeout())
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("unable to init storage: %v", err)
+	}
+
+	go storage.RunExpiration(ctx)
+
+	repl := &replication.Replication{Raft: replRaft}
+	if nodeInfo != nil {
+		nodeInfo.Add(info.SectionMemory, memorySection(engine))
+		nodeInfo.Add(info.SectionPersistence, persistenceSection(nil))
+		nodeInfo.Add(info.SectionReplication, replicationSection(repl, nil))
+		options = append(options, database.WithInfo(nodeInfo))
+	}
+
+	requestParser := compute.NewRequestParser()
+	compute := compute.NewCompute(requestParser)
+
+	db := database.NewDatabase(storage, compute, options...)
+
+	return db, nil, repl, nil
+}
+
+func newEngine(cfg *config.Config, hub *watch.Hub, keyspace *pubsub.Broker) storage.Engine {
+	var engine storage.Engine = storage.NewEngine(cfg.Engine.PartitionsNumber)
+	if keyspace != nil {
+		engine = pubsub.NewKeyspaceEngine(engine, keyspace)
+	}
+	if hub != nil {
+		engine = watch.NewEngine(engine, hub)
+	}
+
+	return engine
+}
@@ -0,0 +1,202 @@
+package app
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"concurrency_go_course/internal/auth"
+	"concurrency_go_course/internal/compute"
+	"concurrency_go_course/internal/network"
+	"concurrency_go_course/internal/pubsub"
+	"concurrency_go_course/pkg/logger"
+)
+
+// PubSubHandler handles SUBSCRIBE, UNSUBSCRIBE, PSUBSCRIBE and PUNSUBSCRIBE,
+// messages of subscriptions are pushed to connection, other requests are
+// passed to next handler and are allowed while connection is subscribed,
+// if users are set subscriptions are checked by access rules of session
+// user
+func PubSubHandler(broker *pubsub.Broker, users *auth.Users, next network.TCPHandler) network.TCPHandler {
+	subscribers := &sessionSubscribers{
+		broker:      broker,
+		subscribers: make(map[*network.Session]*pubsub.Subscriber),
+	}
+
+	return func(ctx context.Context, request []byte) []byte {
+		fields := strings.Fields(string(request))
+		if len(fields) == 0 {
+			return next(ctx, request)
+		}
+
+		switch fields[0] {
+		case compute.CommandSubscribe, compute.CommandPSubscribe:
+			if len(fields) < 2 {
+				return network.NewErrorResponse(network.CodeParseError,
+					"for command "+fields[0]+" expected at least 1 argument, got 0").Encode()
+			}
+		case compute.CommandUnsubscribe, compute.CommandPUnsubscribe:
+		default:
+			return next(ctx, request)
+		}
+
+		if broker == nil {
+			return network.NewErrorResponse(network.CodeInternal, "pub/sub is not enabled").Encode()
+		}
+
+		session := network.SessionFromContext(ctx)
+		if session == nil {
+			return network.NewErrorResponse(network.CodeInternal, "connection has no session").Encode()
+		}
+
+		if users != nil {
+			if err := authorizeSubscriptions(users, session, fields[0], fields[1:]); err != nil {
+				return network.NewErrorResponse(network.CodePermissionDenied, err.Error()).Encode()
+			}
+		}
+
+		return network.NewResponse([]byte(subscribers.handle(session, fields[0], fields[1:]))).Encode()
+	}
+}
+
+// authorizeSubscriptions checks access to all channels or patterns of
+// subscribe command, so denied command does not change subscriptions
+func authorizeSubscriptions(users *auth.Users, session *network.Session, command string, names []string) error {
+	pattern := command == compute.CommandPSubscribe
+	if !pattern && command != compute.CommandSubscribe {
+		return nil
+	}
+
+	for _, name := range names {
+		if err := pubsub.AuthorizeSubscription(users, session.User, name, pattern); err != nil {
+			logger.Warn("permission denied", zap.String("user", session.User),
+				zap.Bool("authenticated", session.User != ""), zap.String("command", command),
+				zap.String("channel", name))
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sessionSubscribers keeps subscriber of every subscribed connection
+type sessionSubscribers struct {
+	broker *pubsub.Broker
+
+	mutex       sync.Mutex
+	subscribers map[*network.Session]*pubsub.Subscriber
+}
+
+// handle changes subscriptions of session, reply contains line with
+// channel or pattern and number of subscriptions for every argument
+func (s *sessionSubscribers) handle(session *network.Session, command string, names []string) string {
+	kind := strings.ToLower(command)
+
+	subscribe := command == compute.CommandSubscribe || command == compute.CommandPSubscribe
+	sub := s.subscriber(session, subscribe)
+	if sub == nil {
+		return kind + " 0"
+	}
+
+	var change func(string) int
+	switch command {
+	case compute.CommandSubscribe:
+		change = sub.Subscribe
+	case compute.CommandPSubscribe:
+		change = sub.PSubscribe
+	case compute.CommandUnsubscribe:
+		change = sub.Unsubscribe
+		if len(names) == 0 {
+			names = sub.Channels()
+		}
+	case compute.CommandPUnsubscribe:
+		change = sub.PUnsubscribe
+		if len(names) == 0 {
+			names = sub.Patterns()
+		}
+	}
+
+	if len(names) == 0 {
+		return kind + " " + strconv.Itoa(sub.Count())
+	}
+
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		lines = append(lines, kind+" "+name+" "+strconv.Itoa(change(name)))
+	}
+
+	// idle subscribed connection waits for messages
+	session.KeepAlive = sub.Count() != 0
+
+	return strings.Join(lines, "\n")
+}
+
+// subscriber returns subscriber of session, it is created if create is
+// set, new subscriber forwards messages to session until connection is
+// closed
+func (s *sessionSubscribers) subscriber(session *network.Session, create bool) *pubsub.Subscriber {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if sub, ok := s.subscribers[session]; ok || !create {
+		return sub
+	}
+
+	sub := s.broker.NewSubscriber()
+	s.subscribers[session] = sub
+
+	go func() {
+		defer s.release(session, sub)
+
+		forward(session, sub)
+	}()
+
+	return sub
+}
+
+func (s *sessionSubscribers) release(session *network.Session, sub *pubsub.Subscriber) {
+	s.mutex.Lock()
+	if s.subscribers[session] == sub {
+		delete(s.subscribers, session)
+	}
+	s.mutex.Unlock()
+
+	sub.Close()
+}
+
+// forward pushes messages of subscriber to session, slow subscriber
+// closed by broker closes connection
+func forward(session *network.Session, sub *pubsub.Subscriber) {
+	for {
+		select {
+		case <-session.Done():
+			return
+		case message, ok := <-sub.Messages():
+			if !ok {
+				if sub.Disconnected() {
+					logger.Warn("subscriber is too slow, connection is closed",
+						zap.String("remote_addr", session.RemoteAddr))
+					session.Close()
+				}
+				return
+			}
+
+			if !session.Push(network.NewPushResponse([]byte(encodeMessage(message))).Encode()) {
+				return
+			}
+		}
+	}
+}
+
+// encodeMessage returns push payload, message of pattern subscription
+// contains pattern before channel
+func encodeMessage(message pubsub.Message) string {
+	if message.Pattern != "" {
+		return "pmessage " + message.Pattern + " " + message.Channel + " " + message.Payload
+	}
+
+	return "message " + message.Channel + " " + message.Payload
+}
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"concurrency_go_course/internal/auth"
+	"concurrency_go_course/internal/config"
+	"concurrency_go_course/internal/network"
+	"concurrency_go_course/internal/pubsub"
+	"concurrency_go_course/pkg/logger"
+)
+
+func TestPubSubHandler(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	broker := pubsub.NewBroker()
+	next := func(_ context.Context, request []byte) []byte {
+		return network.NewResponse(append([]byte("next "), request...)).Encode()
+	}
+
+	addr := "127.0.0.1:5564"
+	server, err := network.NewServer(&config.Config{Network: &config.NetworkConfig{
+		MaxConnections: 10,
+		MaxMessageSize: "1KB",
+		IdleTimeout:    "5m",
+	}}, addr)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Run(ctx, PubSubHandler(broker, nil, next))
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	client, err := network.NewClient(addr)
+	require.NoError(t, err)
+	defer client.Close()
+
+	pushes := make(chan string, 10)
+	client.SetPushHandler(func(payload []byte) {
+		pushes <- string(payload)
+	})
+
+	steps := []struct {
+		request  string
+		expected string
+	}{
+		{request: "UNSUBSCRIBE", expected: "unsubscribe 0"},
+		{request: "SUBSCRIBE news sport", expected: "subscribe news 1\nsubscribe sport 2"},
+		{request: "PSUBSCRIBE user:*", expected: "psubscribe user:* 3"},
+		{request: "GET key", expected: "next GET key"},
+	}
+
+	for _, step := range steps {
+		response, err := client.Query([]byte(step.request))
+		require.NoError(t, err, step.request)
+		assert.Equal(t, step.expected, string(response), step.request)
+	}
+
+	assert.Equal(t, 1, broker.Publish("news", "hello"))
+	assert.Equal(t, 1, broker.Publish("user:1", "alice"))
+	assert.Equal(t, "message news hello", <-pushes)
+	assert.Equal(t, "pmessage user:* user:1 alice", <-pushes)
+
+	response, err := client.Query([]byte("UNSUBSCRIBE"))
+	require.NoError(t, err)
+	assert.Equal(t, "unsubscribe news 2\nunsubscribe sport 1", string(response))
+
+	response, err = client.Query([]byte("PUNSUBSCRIBE"))
+	require.NoError(t, err)
+	assert.Equal(t, "punsubscribe user:* 0", string(response))
+
+	assert.Equal(t, 0, broker.Publish("news", "hello"))
+
+	t.Run("access rules", func(t *testing.T) {
+		hash, err := auth.HashPassword("secret")
+		require.NoError(t, err)
+
+		users, err := auth.NewUsers(auth.Config{
+			Roles: []auth.Role{{
+				Name:  "billing",
+				Allow: []auth.Rule{{Categories: []auth.Category{auth.CategoryRead}, Keys: []string{"billing:*"}}},
+			}},
+			Users: []auth.User{{Name: "alice", PasswordHash: hash, Roles: []string{"billing"}}},
+		})
+		require.NoError(t, err)
+
+		session := &network.Session{User: "alice"}
+		ctx := network.ContextWithSession(context.Background(), session)
+		handler := PubSubHandler(broker, users, next)
+
+		for _, request := range []string{
+			"PSUBSCRIBE __keyevent__:*",
+			"PSUBSCRIBE *",
+			"SUBSCRIBE __keyspace__:billing:1 __keyspace__:shop:1",
+		} {
+			response, err := network.DecodeResponse(handler(ctx, []byte(request)))
+			require.NoError(t, err, request)
+			assert.Equal(t, network.CodePermissionDenied, response.Code, request)
+		}
+
+		// denied command does not subscribe to any of its channels
+		assert.Equal(t, 0, broker.Publish("__keyspace__:billing:1", "set"))
+		assert.Equal(t, 0, broker.Publish("__keyevent__:set", "billing:1"))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		response := PubSubHandler(nil, nil, next)(context.Background(), []byte("SUBSCRIBE news"))
+		assert.Equal(t, network.NewErrorResponse(network.CodeInternal, "pub/sub is not enabled").Encode(), response)
+	})
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"context"
+	"errors"
+
+	"concurrency_go_course/internal/auth"
+	"concurrency_go_course/internal/database"
+	"concurrency_go_course/internal/network"
+	"concurrency_go_course/internal/slowlog"
+	"concurrency_go_course/pkg/logger"
+)
+
+// QueryHandler returns handler of text protocol, responses are encoded
+// in envelope with error code and position of replica
+func QueryHandler(db database.Database) network.TCPHandler {
+	return func(ctx context.Context, request []byte) []byte {
+		if session := network.SessionFromContext(ctx); session != nil {
+			ctx = slowlog.ContextWithClient(ctx, session.RemoteAddr)
+			if session.User != "" {
+				ctx = auth.ContextWithUser(ctx, session.User)
+			}
+		}
+
+		var response network.Response
+		result, err := db.Handle(ctx, string(request)+"\n")
+		if err != nil {
+			logger.ErrorWithMsg("unable to handle query:", err)
+			response = network.NewErrorResponse(errorCode(err), err.Error())
+		} else {
+			response = network.NewResponse([]byte(result))
+		}
+
+		// replica reports its position in every response
+		if position, ok := db.Position(); ok {
+			response = response.WithPosition(network.Position{
+				AppliedLSN: position.AppliedLSN,
+				Lag:        position.Lag,
+				LagKnown:   position.LagKnown,
+			})
+		}
+
+		return response.Encode()
+	}
+}
+
+// TooLargeResponse is sent for requests exceeding max message size
+func TooLargeResponse() []byte {
+	return network.NewErrorResponse(network.CodeTooLarge,
+		"request exceeds max message size").Encode()
+}
+
+func errorCode(err error) network.ErrorCode {
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		return network.CodeNotFound
+	case errors.Is(err, database.ErrReadOnlyReplica):
+		return network.CodeReadOnlyReplica
+	case errors.Is(err, database.ErrParse):
+		return network.CodeParseError
+	case errors.Is(err, database.ErrPermissionDenied):
+		return network.CodePermissionDenied
+	case errors.Is(err, database.ErrWrongType):
+		return network.CodeWrongType
+	default:
+		return network.CodeInternal
+	}
+}
@@ -0,0 +1,161 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"concurrency_go_course/internal/auth"
+	"concurrency_go_course/internal/compute"
+	"concurrency_go_course/internal/database"
+	"concurrency_go_course/internal/network"
+	"concurrency_go_course/internal/replication"
+	"concurrency_go_course/internal/storage"
+	"concurrency_go_course/pkg/logger"
+)
+
+func TestQueryHandler(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	newHandler := func(replicaType string) network.TCPHandler {
+		store, err := storage.New(storage.NewEngine(4), nil, replicaType, nil)
+		require.NoError(t, err)
+
+		return QueryHandler(database.NewDatabase(store, compute.NewCompute(compute.NewRequestParser())))
+	}
+
+	master := newHandler(replication.ReplicaTypeMaster)
+	slave := newHandler(replication.ReplicaTypeSlave)
+
+	store, err := storage.New(storage.NewEngine(4), nil, replication.ReplicaTypeSlave, nil)
+	require.NoError(t, err)
+	replica := QueryHandler(database.NewReplicaDatabase(store, compute.NewCompute(compute.NewRequestParser()),
+		staticReplica{lsn: 42}))
+
+	tests := []struct {
+		name     string
+		handler  network.TCPHandler
+		request  string
+		expected network.Response
+	}{
+		{
+			name:     "get missing key",
+			handler:  master,
+			request:  "GET key",
+			expected: network.NewErrorResponse(network.CodeNotFound, "value not found"),
+		},
+		{
+			name:     "set",
+			handler:  master,
+			request:  "SET key value",
+			expected: network.NewResponse([]byte("OK")),
+		},
+		{
+			name:     "get",
+			handler:  master,
+			request:  "GET key",
+			expected: network.NewResponse([]byte("value")),
+		},
+		{
+			name:     "hash command on string",
+			handler:  master,
+			request:  "HGET key field",
+			expected: network.NewErrorResponse(network.CodeWrongType, storage.ErrWrongType.Error()),
+		},
+		{
+			name:     "parse error",
+			handler:  master,
+			request:  "INCR key",
+			expected: network.NewErrorResponse(network.CodeParseError, "unable to parse query: invalid command INCR"),
+		},
+		{
+			name:    "write on slave",
+			handler: slave,
+			request: "DEL key",
+			expected: network.NewErrorResponse(network.CodeReadOnlyReplica,
+				"unable to execute delete command on slave: replica is read-only"),
+		},
+		{
+			name:    "replica position",
+			handler: replica,
+			request: "GET key",
+			expected: network.NewErrorResponse(network.CodeNotFound, "value not found").
+				WithPosition(network.Position{AppliedLSN: 42}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := network.DecodeResponse(tt.handler(context.Background(), []byte(tt.request)))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, response)
+		})
+	}
+}
+
+// staticReplica is a replica which applied lsn and was not synced with
+// master, so its lag is not known
+type staticReplica struct {
+	lsn uint64
+}
+
+func (r staticReplica) AppliedLSN() uint64 {
+	return r.lsn
+}
+
+func (r staticReplica) Lag() (time.Duration, bool) {
+	return 0, false
+}
+
+func (r staticReplica) WaitLSN(context.Context, uint64) error {
+	return nil
+}
+
+func (r staticReplica) WaitLag(context.Context, time.Duration) error {
+	return nil
+}
+
+func TestQueryHandlerPermission(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	users, err := auth.NewUsers(auth.Config{
+		Roles: []auth.Role{{
+			Name:  "writer",
+			Allow: []auth.Rule{{Categories: []auth.Category{auth.CategoryWrite}, Keys: []string{"team:*"}}},
+		}},
+		Users: []auth.User{{Name: "alice", PasswordHash: testHash(t), Roles: []string{"writer"}}},
+	})
+	require.NoError(t, err)
+
+	store, err := storage.New(storage.NewEngine(4), nil, replication.ReplicaTypeMaster, nil)
+	require.NoError(t, err)
+	handler := QueryHandler(database.NewDatabase(store, compute.NewCompute(compute.NewRequestParser()),
+		database.WithAuthorizer(users)))
+
+	session := &network.Session{User: "alice"}
+	ctx := network.ContextWithSession(context.Background(), session)
+
+	response, err := network.DecodeResponse(handler(ctx, []byte("SET team:1 value")))
+	require.NoError(t, err)
+	assert.Equal(t, network.NewResponse([]byte("OK")), response)
+
+	response, err = network.DecodeResponse(handler(ctx, []byte("SET other:1 value")))
+	require.NoError(t, err)
+	assert.Equal(t, network.NewErrorResponse(network.CodePermissionDenied,
+		"permission denied: no write access to key other:1"), response)
+}
+
+func testHash(t *testing.T) string {
+	t.Helper()
+
+	hash, err := auth.HashPassword("secret")
+	require.NoError(t, err)
+	return hash
+}
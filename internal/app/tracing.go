@@ -0,0 +1,59 @@
+package app
+
+import (
+	"fmt"
+
+	"concurrency_go_course/internal/config"
+	"concurrency_go_course/pkg/trace"
+)
+
+// Exporters of tracing config
+const (
+	TraceExporterFile     = "file"
+	TraceExporterOTLPHTTP = "otlp_http"
+)
+
+const (
+	defaultTraceServiceName = "concurrency_go_course"
+	defaultTraceFilePath    = "log/traces.json"
+	defaultTraceEndpoint    = "http://127.0.0.1:4318/v1/traces"
+)
+
+// NewTracer returns tracer of config, spans are written to file by default
+func NewTracer(cfg *config.TracingConfig) (*trace.Tracer, error) {
+	service := cfg.ServiceName
+	if service == "" {
+		service = defaultTraceServiceName
+	}
+
+	sampleRatio := 1.0
+	if cfg.SampleRatio != nil {
+		sampleRatio = *cfg.SampleRatio
+	}
+
+	var exporter trace.Exporter
+	switch cfg.Exporter {
+	case "", TraceExporterFile:
+		path := cfg.FilePath
+		if path == "" {
+			path = defaultTraceFilePath
+		}
+
+		fileExporter, err := trace.NewFileExporter(service, path)
+		if err != nil {
+			return nil, err
+		}
+		exporter = fileExporter
+	case TraceExporterOTLPHTTP:
+		endpoint := cfg.Endpoint
+		if endpoint == "" {
+			endpoint = defaultTraceEndpoint
+		}
+
+		exporter = trace.NewHTTPExporter(service, endpoint)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+
+	return trace.NewTracer(exporter, sampleRatio), nil
+}
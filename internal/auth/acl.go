@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"concurrency_go_course/pkg/glob"
+)
+
+// ErrPermissionDenied is returned if user has no access to command or key
+var ErrPermissionDenied = errors.New("permission denied")
+
+// Category is a category of commands in rules of roles
+type Category string
+
+// Command categories
+const (
+	CategoryRead  Category = "read"
+	CategoryWrite Category = "write"
+	CategoryAdmin Category = "admin"
+)
+
+// Role is a named set of rules, deny rules take precedence over allow
+// rules and commands without matching allow rule are denied
+type Role struct {
+	Name  string `yaml:"name"`
+	Allow []Rule `yaml:"allow"`
+	Deny  []Rule `yaml:"deny"`
+}
+
+// Rule matches commands of categories on keys, empty keys match all keys,
+// commands without key are matched by category only
+type Rule struct {
+	Categories []Category `yaml:"categories"`
+	// Keys are patterns, * matches any sequence and ? matches one character
+	Keys []string `yaml:"keys"`
+}
+
+func (r Rule) validate() error {
+	if len(r.Categories) == 0 {
+		return errors.New("rule has no categories")
+	}
+
+	for _, category := range r.Categories {
+		switch category {
+		case CategoryRead, CategoryWrite, CategoryAdmin:
+		default:
+			return fmt.Errorf("unknown category %s", category)
+		}
+	}
+
+	return nil
+}
+
+func (r Rule) matches(category Category, key string) bool {
+	if !slices.Contains(r.Categories, category) {
+		return false
+	}
+
+	if len(r.Keys) == 0 || key == "" {
+		return true
+	}
+
+	for _, pattern := range r.Keys {
+		if glob.Match(pattern, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesPattern matches commands of category on keys matched by key
+// pattern, all of them must match rule if every is set, otherwise any of
+// them
+func (r Rule) matchesPattern(category Category, pattern string, every bool) bool {
+	if !slices.Contains(r.Categories, category) {
+		return false
+	}
+
+	if len(r.Keys) == 0 {
+		return true
+	}
+
+	for _, rulePattern := range r.Keys {
+		if every && glob.Covers(rulePattern, pattern) || !every && glob.Intersects(rulePattern, pattern) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// allowed checks rules of roles, deny rule of any role denies command
+func allowed(roles []Role, category Category, key string) bool {
+	return allowedBy(roles, func(rule Rule) bool {
+		return rule.matches(category, key)
+	}, func(rule Rule) bool {
+		return rule.matches(category, key)
+	})
+}
+
+// allowedPattern checks rules of roles for all keys matched by pattern,
+// allow rule must match all of them and deny rule none of them
+func allowedPattern(roles []Role, category Category, pattern string) bool {
+	return allowedBy(roles, func(rule Rule) bool {
+		return rule.matchesPattern(category, pattern, true)
+	}, func(rule Rule) bool {
+		return rule.matchesPattern(category, pattern, false)
+	})
+}
+
+func allowedBy(roles []Role, allows, denies func(Rule) bool) bool {
+	allow := false
+	for _, role := range roles {
+		for _, rule := range role.Deny {
+			if denies(rule) {
+				return false
+			}
+		}
+
+		for _, rule := range role.Allow {
+			if allows(rule) {
+				allow = true
+			}
+		}
+	}
+
+	return allow
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorize(t *testing.T) {
+	t.Parallel()
+
+	hash, err := HashPassword("secret")
+	require.NoError(t, err)
+
+	users, err := NewUsers(Config{
+		Roles: []Role{
+			{
+				Name:  "billing",
+				Allow: []Rule{{Categories: []Category{CategoryRead, CategoryWrite}, Keys: []string{"billing:*"}}},
+				Deny:  []Rule{{Categories: []Category{CategoryWrite}, Keys: []string{"billing:locked:*"}}},
+			},
+			{
+				Name:  "reader",
+				Allow: []Rule{{Categories: []Category{CategoryRead}}},
+			},
+			{
+				Name:  "admin",
+				Allow: []Rule{{Categories: []Category{CategoryRead, CategoryWrite, CategoryAdmin}}},
+			},
+		},
+		Users: []User{
+			{Name: "alice", PasswordHash: hash, Roles: []string{"billing"}},
+			{Name: "bob", PasswordHash: hash, Roles: []string{"billing", "reader"}},
+			{Name: "root", PasswordHash: hash, Roles: []string{"admin"}},
+			{Name: "nobody", PasswordHash: hash},
+		},
+		AnonymousRoles: []string{"reader"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		user     string
+		category Category
+		key      string
+		allowed  bool
+	}{
+		{name: "read of own prefix", user: "alice", category: CategoryRead, key: "billing:1", allowed: true},
+		{name: "write of own prefix", user: "alice", category: CategoryWrite, key: "billing:1", allowed: true},
+		{name: "read of other prefix", user: "alice", category: CategoryRead, key: "shop:1"},
+		{name: "denied write", user: "alice", category: CategoryWrite, key: "billing:locked:1"},
+		{name: "denied read is allowed", user: "alice", category: CategoryRead, key: "billing:locked:1", allowed: true},
+		{name: "admin without rule", user: "alice", category: CategoryAdmin},
+		{name: "roles are combined", user: "bob", category: CategoryRead, key: "shop:1", allowed: true},
+		{name: "deny of any role wins", user: "bob", category: CategoryWrite, key: "billing:locked:1"},
+		{name: "admin", user: "root", category: CategoryAdmin, allowed: true},
+		{name: "user without roles", user: "nobody", category: CategoryRead, key: "billing:1"},
+		{name: "unknown user", user: "mallory", category: CategoryRead, key: "billing:1"},
+		{name: "anonymous read", category: CategoryRead, key: "shop:1", allowed: true},
+		{name: "anonymous write", category: CategoryWrite, key: "shop:1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := users.Authorize(test.user, test.category, test.key)
+			if test.allowed {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrPermissionDenied)
+		})
+	}
+
+	patterns := []struct {
+		name     string
+		user     string
+		category Category
+		pattern  string
+		allowed  bool
+	}{
+		{name: "own prefix", user: "alice", category: CategoryRead, pattern: "billing:*", allowed: true},
+		{name: "part of own prefix", user: "alice", category: CategoryRead, pattern: "billing:eu:?", allowed: true},
+		{name: "wider than own prefix", user: "alice", category: CategoryRead, pattern: "bill*"},
+		{name: "all keys", user: "alice", category: CategoryRead, pattern: "*"},
+		{name: "overlaps denied keys", user: "alice", category: CategoryWrite, pattern: "billing:*"},
+		{name: "rule without keys", user: "bob", category: CategoryRead, pattern: "*", allowed: true},
+		{name: "anonymous", category: CategoryRead, pattern: "*", allowed: true},
+	}
+
+	for _, test := range patterns {
+		t.Run("pattern "+test.name, func(t *testing.T) {
+			err := users.AuthorizePattern(test.user, test.category, test.pattern)
+			if test.allowed {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrPermissionDenied)
+		})
+	}
+
+	assert.True(t, users.AllowsAnonymous())
+
+	t.Run("no roles", func(t *testing.T) {
+		users, err := NewUsers(Config{Users: []User{{Name: "alice", PasswordHash: hash}}})
+		require.NoError(t, err)
+
+		assert.NoError(t, users.Authorize("alice", CategoryAdmin, ""))
+		assert.NoError(t, users.Authorize("", CategoryWrite, "key"))
+		// anonymous clients would have full access
+		assert.False(t, users.AllowsAnonymous())
+	})
+
+	t.Run("no anonymous roles", func(t *testing.T) {
+		users, err := NewUsers(Config{
+			Users: []User{{Name: "alice", PasswordHash: hash, Roles: []string{"reader"}}},
+			Roles: []Role{{Name: "reader", Allow: []Rule{{Categories: []Category{CategoryRead}}}}},
+		})
+		require.NoError(t, err)
+
+		assert.False(t, users.AllowsAnonymous())
+	})
+}
@@ -0,0 +1,17 @@
+package auth
+
+import "context"
+
+type userKey struct{}
+
+// ContextWithUser returns context with name of authenticated user
+func ContextWithUser(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, userKey{}, name)
+}
+
+// UserFromContext returns name of authenticated user, it is empty for
+// anonymous requests
+func UserFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(userKey{}).(string)
+	return name
+}
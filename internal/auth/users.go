@@ -0,0 +1,274 @@
+// Package auth authenticates clients by users file with hashed passwords
+// and checks access of users by rules of their roles
+package auth
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v2"
+)
+
+// ErrInvalidCredentials is returned for unknown user or wrong password
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// Config is a content of users file
+type Config struct {
+	Users []User `yaml:"users"`
+	// Roles enable access control, users have full access if there are
+	// no roles
+	Roles []Role `yaml:"roles"`
+	// AnonymousRoles are roles of clients which did not authenticate,
+	// without them clients must authenticate before other commands. They
+	// require roles, so anonymous clients never get full access
+	AnonymousRoles []string `yaml:"anonymous_roles"`
+}
+
+// User is a user of users file
+type User struct {
+	Name string `yaml:"name"`
+	// PasswordHash is a bcrypt hash of password
+	PasswordHash string   `yaml:"password_hash"`
+	Roles        []string `yaml:"roles"`
+}
+
+// Users is a set of users which may authenticate and their access rules,
+// it may be reloaded while in use
+type Users struct {
+	path  string
+	state atomic.Pointer[usersState]
+}
+
+type usersState struct {
+	users          map[string]User
+	userRoles      map[string][]Role
+	anonymousRoles []Role
+	acl            bool
+	// dummyHash is compared for unknown users, so response time does not
+	// reveal whether user exists
+	dummyHash []byte
+	// verified keeps successful checks by user and hash of password, so
+	// clients which authenticate every request, such as HTTP and gRPC
+	// clients, do not wait for bcrypt. It is dropped with state on reload
+	verified sync.Map
+}
+
+type credentials struct {
+	name     string
+	password [sha256.Size]byte
+}
+
+// LoadUsers reads users file, it is read again on Reload
+func LoadUsers(path string) (*Users, error) {
+	users := &Users{path: path}
+	if err := users.Reload(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// NewUsers returns set of users from config, it may not be reloaded
+func NewUsers(cfg Config) (*Users, error) {
+	state, err := newUsersState(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	users := &Users{}
+	users.state.Store(state)
+	return users, nil
+}
+
+// Reload reads users file again, previous users are kept if file is invalid
+func (u *Users) Reload() error {
+	if u.path == "" {
+		return errors.New("users were not loaded from file")
+	}
+
+	data, err := os.ReadFile(filepath.Clean(u.path))
+	if err != nil {
+		return fmt.Errorf("unable to read users file: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return fmt.Errorf("unable to parse users file: %w", err)
+	}
+
+	state, err := newUsersState(cfg)
+	if err != nil {
+		return err
+	}
+
+	u.state.Store(state)
+	return nil
+}
+
+// newUsersState validates config, names must be unique, password hashes
+// must be bcrypt hashes and roles must be defined
+func newUsersState(cfg Config) (*usersState, error) {
+	roles := make(map[string]Role, len(cfg.Roles))
+	for _, role := range cfg.Roles {
+		if role.Name == "" {
+			return nil, errors.New("role name is empty")
+		}
+
+		if _, ok := roles[role.Name]; ok {
+			return nil, fmt.Errorf("duplicate role %s", role.Name)
+		}
+
+		for _, rule := range append(slices.Clone(role.Allow), role.Deny...) {
+			if err := rule.validate(); err != nil {
+				return nil, fmt.Errorf("invalid rule of role %s: %w", role.Name, err)
+			}
+		}
+
+		roles[role.Name] = role
+	}
+
+	resolve := func(names []string) ([]Role, error) {
+		resolved := make([]Role, 0, len(names))
+		for _, name := range names {
+			role, ok := roles[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown role %s", name)
+			}
+			resolved = append(resolved, role)
+		}
+		return resolved, nil
+	}
+
+	state := &usersState{
+		users:     make(map[string]User, len(cfg.Users)),
+		userRoles: make(map[string][]Role, len(cfg.Users)),
+		acl:       len(roles) > 0,
+	}
+
+	var err error
+	if state.anonymousRoles, err = resolve(cfg.AnonymousRoles); err != nil {
+		return nil, fmt.Errorf("invalid anonymous roles: %w", err)
+	}
+
+	for _, user := range cfg.Users {
+		if user.Name == "" {
+			return nil, errors.New("user name is empty")
+		}
+
+		if _, ok := state.users[user.Name]; ok {
+			return nil, fmt.Errorf("duplicate user %s", user.Name)
+		}
+
+		if _, err := bcrypt.Cost([]byte(user.PasswordHash)); err != nil {
+			return nil, fmt.Errorf("invalid password hash of user %s: %w", user.Name, err)
+		}
+
+		if state.userRoles[user.Name], err = resolve(user.Roles); err != nil {
+			return nil, fmt.Errorf("invalid roles of user %s: %w", user.Name, err)
+		}
+
+		state.users[user.Name] = user
+	}
+
+	state.dummyHash, err = bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// Authenticate checks password of user
+func (u *Users) Authenticate(name, password string) error {
+	state := u.state.Load()
+
+	key := credentials{name: name, password: sha256.Sum256([]byte(password))}
+	if _, ok := state.verified.Load(key); ok {
+		return nil
+	}
+
+	hash := state.dummyHash
+	user, ok := state.users[name]
+	if ok {
+		hash = []byte(user.PasswordHash)
+	}
+
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || !ok {
+		return ErrInvalidCredentials
+	}
+
+	state.verified.Store(key, struct{}{})
+	return nil
+}
+
+// Authorize checks access of user to command category on key, empty name
+// means anonymous user, key is empty for commands without key
+func (u *Users) Authorize(name string, category Category, key string) error {
+	roles, acl := u.roles(name)
+	if !acl {
+		return nil
+	}
+
+	if !allowed(roles, category, key) {
+		if key == "" {
+			return fmt.Errorf("%w: no %s access", ErrPermissionDenied, category)
+		}
+		return fmt.Errorf("%w: no %s access to key %s", ErrPermissionDenied, category, key)
+	}
+
+	return nil
+}
+
+// AuthorizePattern checks access of user to command category on every key
+// matched by pattern, empty name means anonymous user
+func (u *Users) AuthorizePattern(name string, category Category, pattern string) error {
+	roles, acl := u.roles(name)
+	if !acl {
+		return nil
+	}
+
+	if !allowedPattern(roles, category, pattern) {
+		return fmt.Errorf("%w: no %s access to keys %s", ErrPermissionDenied, category, pattern)
+	}
+
+	return nil
+}
+
+// AllowsAnonymous returns true if clients may run commands without
+// authentication, their commands are checked with anonymous roles
+func (u *Users) AllowsAnonymous() bool {
+	state := u.state.Load()
+	return state.acl && len(state.anonymousRoles) > 0
+}
+
+// roles returns roles of user, false if access rules are not set
+func (u *Users) roles(name string) ([]Role, bool) {
+	state := u.state.Load()
+	if !state.acl {
+		return nil, false
+	}
+
+	if name == "" {
+		return state.anonymousRoles, true
+	}
+
+	// user may be removed by reload after authentication
+	return state.userRoles[name], true
+}
+
+// HashPassword returns bcrypt hash of password for users file
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
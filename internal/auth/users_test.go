@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadUsers(t *testing.T) {
+	t.Parallel()
+
+	hash, err := HashPassword("secret")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "users.yaml")
+	data := "users:\n  - name: alice\n    password_hash: \"" + hash + "\"\n"
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+
+	users, err := LoadUsers(path)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		name     string
+		password string
+		err      error
+	}{
+		"valid password": {name: "alice", password: "secret"},
+		"wrong password": {name: "alice", password: "wrong", err: ErrInvalidCredentials},
+		"unknown user":   {name: "bob", password: "secret", err: ErrInvalidCredentials},
+		"empty password": {name: "alice", password: "", err: ErrInvalidCredentials},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := users.Authenticate(test.name, test.password)
+			if test.err != nil {
+				assert.ErrorIs(t, err, test.err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestLoadUsersErr(t *testing.T) {
+	t.Parallel()
+
+	hash, err := HashPassword("secret")
+	require.NoError(t, err)
+
+	tests := map[string]string{
+		"plain password": "users:\n  - name: alice\n    password_hash: secret\n",
+		"empty name":     "users:\n  - password_hash: \"" + hash + "\"\n",
+		"duplicate user": "users:\n  - name: alice\n    password_hash: \"" + hash + "\"\n" +
+			"  - name: alice\n    password_hash: \"" + hash + "\"\n",
+		"unknown field":          "users:\n  - name: alice\n    password: secret\n",
+		"unknown role":           "users:\n  - name: alice\n    password_hash: \"" + hash + "\"\n    roles: [admin]\n",
+		"unknown category":       "roles:\n  - name: admin\n    allow:\n      - categories: [all]\n",
+		"unknown anonymous role": "anonymous_roles: [reader]\n",
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "users.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+
+			_, err := LoadUsers(path)
+			assert.Error(t, err)
+		})
+	}
+
+	_, err = LoadUsers(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestReloadUsers(t *testing.T) {
+	t.Parallel()
+
+	hash, err := HashPassword("secret")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "users.yaml")
+	write := func(data string) {
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+	}
+
+	write("roles:\n  - name: reader\n    allow:\n      - categories: [read]\n" +
+		"users:\n  - name: alice\n    password_hash: \"" + hash + "\"\n    roles: [reader]\n")
+
+	users, err := LoadUsers(path)
+	require.NoError(t, err)
+	require.NoError(t, users.Authenticate("alice", "secret"))
+	require.ErrorIs(t, users.Authorize("alice", CategoryWrite, "key"), ErrPermissionDenied)
+
+	// role gets write access, user bob is added
+	write("roles:\n  - name: reader\n    allow:\n      - categories: [read, write]\n" +
+		"users:\n  - name: alice\n    password_hash: \"" + hash + "\"\n    roles: [reader]\n" +
+		"  - name: bob\n    password_hash: \"" + hash + "\"\n    roles: [reader]\n")
+	require.NoError(t, users.Reload())
+	assert.NoError(t, users.Authorize("alice", CategoryWrite, "key"))
+	assert.NoError(t, users.Authenticate("bob", "secret"))
+
+	// invalid file keeps previous users
+	write("users:\n  - name: alice\n    password_hash: secret\n")
+	assert.Error(t, users.Reload())
+	assert.NoError(t, users.Authenticate("bob", "secret"))
+
+	// removed user is not authenticated by checks before reload
+	write("users:\n  - name: alice\n    password_hash: \"" + hash + "\"\n")
+	require.NoError(t, users.Reload())
+	assert.ErrorIs(t, users.Authenticate("bob", "secret"), ErrInvalidCredentials)
+	assert.ErrorIs(t, users.Authenticate("alice", "wrong"), ErrInvalidCredentials)
+
+	static, err := NewUsers(Config{})
+	require.NoError(t, err)
+	assert.Error(t, static.Reload())
+}
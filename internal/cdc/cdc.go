@@ -0,0 +1,299 @@
+// Package cdc captures changes of database by tailing committed WAL
+// segments and delivers them to sink at least once
+package cdc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+
+	"concurrency_go_course/internal/config"
+	"concurrency_go_course/internal/filesystem"
+	"concurrency_go_course/internal/storage/wal"
+	"concurrency_go_course/pkg/logger"
+	"concurrency_go_course/pkg/metrics"
+	"concurrency_go_course/pkg/parser"
+)
+
+// Sinks of events
+const (
+	SinkFile = "file"
+	SinkTCP  = "tcp"
+	SinkUnix = "unix"
+)
+
+const (
+	defaultFilePath     = "log/cdc.ndjson"
+	defaultMaxFileSize  = "64MB"
+	defaultAckTimeout   = 5 * time.Second
+	defaultOffsetFile   = "cdc.offset"
+	defaultPollInterval = 100 * time.Millisecond
+	defaultBatchSize    = 512
+)
+
+var (
+	eventsDelivered = metrics.Register(metrics.NewCounterVec("cdc_events_delivered_total",
+		"Number of change events delivered to sink, redelivered events are counted again."))
+	deliveryErrors = metrics.Register(metrics.NewCounterVec("cdc_delivery_errors_total",
+		"Number of failed attempts to deliver change events."))
+	deliveredLSN = metrics.Register(metrics.NewGaugeVec("cdc_delivered_lsn",
+		"Log sequence number of the last delivered change event."))
+)
+
+// Tailer reads requests appended to WAL segments and delivers them to
+// sink, offset is stored after every delivered batch, so events are
+// delivered again only if server stops between delivery and store
+type Tailer struct {
+	walDirectory string
+	offsetPath   string
+	pollInterval time.Duration
+	batchSize    int
+
+	sink    Sink
+	fileLib filesystem.FileLib
+	offset  Offset
+}
+
+// New returns tailer of WAL directory, delivery starts from stored offset
+// or from the first segment
+func New(cfg *config.CDCConfig, walDirectory string) (*Tailer, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("CDC config is empty")
+	}
+
+	if walDirectory == "" {
+		return nil, fmt.Errorf("CDC requires WAL")
+	}
+
+	sink, err := newSink(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Tailer{
+		walDirectory: walDirectory,
+		offsetPath:   cfg.OffsetPath,
+		pollInterval: cfg.PollInterval,
+		batchSize:    cfg.BatchSize,
+		sink:         sink,
+		fileLib:      filesystem.NewFileLib(),
+	}
+
+	if t.offsetPath == "" {
+		t.offsetPath = filepath.Join(walDirectory, defaultOffsetFile)
+	}
+	if t.pollInterval <= 0 {
+		t.pollInterval = defaultPollInterval
+	}
+	if t.batchSize <= 0 {
+		t.batchSize = defaultBatchSize
+	}
+
+	if t.offset, err = LoadOffset(t.offsetPath); err != nil {
+		_ = sink.Close()
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func newSink(cfg *config.CDCConfig) (Sink, error) {
+	switch cfg.Sink {
+	case "", SinkFile:
+		path := cfg.FilePath
+		if path == "" {
+			path = defaultFilePath
+		}
+
+		maxSize := cfg.MaxFileSize
+		if maxSize == "" {
+			maxSize = defaultMaxFileSize
+		}
+
+		size, err := parser.ParseSize(maxSize)
+		if err != nil || size == 0 {
+			return nil, fmt.Errorf("unable to set CDC max file size: incorrect value %q", maxSize)
+		}
+
+		return NewFileSink(path, int64(size))
+	case SinkTCP, SinkUnix:
+		if cfg.Address == "" {
+			return nil, fmt.Errorf("CDC consumer address is empty")
+		}
+
+		ackTimeout := cfg.AckTimeout
+		if ackTimeout <= 0 {
+			ackTimeout = defaultAckTimeout
+		}
+
+		return NewSocketSink(cfg.Sink, cfg.Address, ackTimeout), nil
+	default:
+		return nil, fmt.Errorf("unknown CDC sink %q", cfg.Sink)
+	}
+}
+
+// Offset returns position of delivered events
+func (t *Tailer) Offset() Offset {
+	return t.offset
+}
+
+// Start delivers new requests until context is done, failed delivery is
+// retried on next poll
+func (t *Tailer) Start(ctx context.Context) {
+	logger.Info("Starting CDC", zap.String("wal_directory", t.walDirectory),
+		zap.Uint64("lsn", t.offset.LSN), zap.String("segment", t.offset.Segment))
+
+	ticker := time.NewTicker(t.pollInterval)
+	defer ticker.Stop()
+
+	defer func() {
+		if err := t.sink.Close(); err != nil {
+			logger.ErrorWithMsg("unable to close CDC sink:", err)
+		}
+	}()
+
+	for {
+		if err := t.Poll(); err != nil {
+			deliveryErrors.WithLabelValues().Inc()
+			logger.ErrorWithMsg("unable to deliver CDC events:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll delivers requests appended after offset, segments are read in
+// order until the last one
+func (t *Tailer) Poll() error {
+	for {
+		filenames, err := t.fileLib.FilenamesFromDir(t.walDirectory)
+		if err != nil {
+			return err
+		}
+
+		segment, next := segmentToRead(filenames, t.offset.Segment)
+		if segment == "" {
+			return nil
+		}
+
+		position := t.offset.Position
+		if segment != t.offset.Segment {
+			position = 0
+		}
+
+		complete, err := t.deliverSegment(segment, position)
+		if err != nil {
+			return err
+		}
+
+		if next == "" {
+			return nil
+		}
+
+		// segments before the last one are not written anymore
+		if !complete {
+			logger.Error("WAL segment is corrupted, rest of it is skipped by CDC", zap.String("segment", segment))
+		}
+
+		if err := t.commit(Offset{LSN: t.offset.LSN, Segment: next}); err != nil {
+			return err
+		}
+	}
+}
+
+// deliverSegment delivers requests of segment after position, returns
+// true if segment was decoded to the end
+func (t *Tailer) deliverSegment(segment string, position int) (bool, error) {
+	data, err := os.ReadFile(filepath.Join(t.walDirectory, segment))
+	if err != nil {
+		return false, fmt.Errorf("read WAL segment: %w", err)
+	}
+
+	// segment is shorter than offset if it was replaced
+	if position > len(data) {
+		position = 0
+	}
+
+	// segment may be written concurrently, so decoded prefix is delivered
+	requests, n, decodeErr := wal.DecodeRequests(data[position:])
+
+	events := make([]Event, 0, len(requests))
+	for _, request := range requests {
+		// requests without position were written before LSN was introduced
+		if request.LSN == 0 || request.LSN > t.offset.LSN {
+			events = append(events, NewEvent(request))
+		}
+	}
+
+	for len(events) > 0 {
+		batch := events[:min(len(events), t.batchSize)]
+		events = events[len(batch):]
+
+		if err := t.sink.Write(batch); err != nil {
+			return false, err
+		}
+		eventsDelivered.WithLabelValues().Add(float64(len(batch)))
+
+		// requests of segment after position are filtered by LSN until
+		// the whole segment is delivered
+		if err := t.commit(Offset{
+			LSN:      max(t.offset.LSN, lastLSN(batch)),
+			Segment:  segment,
+			Position: position,
+		}); err != nil {
+			return false, err
+		}
+	}
+
+	if t.offset.Segment != segment || t.offset.Position != position+n {
+		if err := t.commit(Offset{LSN: t.offset.LSN, Segment: segment, Position: position + n}); err != nil {
+			return false, err
+		}
+	}
+
+	return decodeErr == nil, nil
+}
+
+func (t *Tailer) commit(offset Offset) error {
+	if err := SaveOffset(t.offsetPath, offset); err != nil {
+		return err
+	}
+
+	t.offset = offset
+	deliveredLSN.WithLabelValues().Set(float64(offset.LSN))
+	return nil
+}
+
+// segmentToRead returns segment of offset or the first segment after it,
+// and next segment, names are sorted
+func segmentToRead(filenames []string, offsetSegment string) (string, string) {
+	for i, filename := range filenames {
+		if filename < offsetSegment {
+			continue
+		}
+
+		if i+1 < len(filenames) {
+			return filename, filenames[i+1]
+		}
+		return filename, ""
+	}
+
+	return "", ""
+}
+
+// lastLSN returns the greatest log sequence number of events
+func lastLSN(events []Event) uint64 {
+	var lsn uint64
+	for _, event := range events {
+		lsn = max(lsn, event.LSN)
+	}
+	return lsn
+}
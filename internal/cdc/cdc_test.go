@@ -0,0 +1,200 @@
+package cdc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"concurrency_go_course/internal/config"
+	"concurrency_go_course/internal/storage/wal"
+	"concurrency_go_course/pkg/logger"
+)
+
+func appendRequests(t *testing.T, path string, requests ...wal.Request) {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	for _, request := range requests {
+		require.NoError(t, request.Encode(&buffer))
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	defer file.Close()
+
+	_, err = file.Write(buffer.Bytes())
+	require.NoError(t, err)
+}
+
+func readEvents(t *testing.T, path string) []Event {
+	t.Helper()
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+	require.NoError(t, scanner.Err())
+
+	return events
+}
+
+func lsnsOf(events []Event) []uint64 {
+	lsns := make([]uint64, 0, len(events))
+	for _, event := range events {
+		lsns = append(lsns, event.LSN)
+	}
+	return lsns
+}
+
+func TestTailerPoll(t *testing.T) {
+	logger.MockLogger()
+
+	walDir := t.TempDir()
+	output := filepath.Join(t.TempDir(), "cdc.ndjson")
+	cfg := &config.CDCConfig{FilePath: output, BatchSize: 2}
+
+	appendRequests(t, filepath.Join(walDir, "wal_1.log"),
+		wal.Request{LSN: 1, Command: "SET", Args: []string{"a", "1"}, Timestamp: 1700000000000000000},
+		wal.Request{LSN: 2, Command: "SET", Args: []string{"b", "2"}, Timestamp: 1700000000000000000},
+		wal.Request{LSN: 3, Command: "DEL", Args: []string{"a"}, Timestamp: 1700000000000000000},
+	)
+
+	tailer, err := New(cfg, walDir)
+	require.NoError(t, err)
+	require.NoError(t, tailer.Poll())
+
+	events := readEvents(t, output)
+	require.Len(t, events, 3)
+	assert.Equal(t, Event{
+		LSN:       1,
+		Timestamp: "2023-11-14T22:13:20Z",
+		Command:   "SET",
+		Key:       "a",
+		Value:     "1",
+	}, events[0])
+	assert.Equal(t, Event{LSN: 3, Timestamp: "2023-11-14T22:13:20Z", Command: "DEL", Key: "a"}, events[2])
+
+	offset, err := LoadOffset(filepath.Join(walDir, defaultOffsetFile))
+	require.NoError(t, err)
+	assert.Equal(t, tailer.Offset(), offset)
+	assert.Equal(t, uint64(3), offset.LSN)
+	assert.Equal(t, "wal_1.log", offset.Segment)
+
+	// delivered requests are not delivered again
+	require.NoError(t, tailer.Poll())
+	assert.Len(t, readEvents(t, output), 3)
+
+	appendRequests(t, filepath.Join(walDir, "wal_1.log"),
+		wal.Request{LSN: 4, Command: "SET", Args: []string{"c", "3"}})
+	appendRequests(t, filepath.Join(walDir, "wal_2.log"),
+		wal.Request{LSN: 5, Command: "SET", Args: []string{"d", "4"}})
+
+	require.NoError(t, tailer.sink.Close())
+
+	// new tailer resumes from stored offset
+	tailer, err = New(cfg, walDir)
+	require.NoError(t, err)
+	require.NoError(t, tailer.Poll())
+
+	assert.Equal(t, []uint64{1, 2, 3, 4, 5}, lsnsOf(readEvents(t, output)))
+	assert.Equal(t, Offset{LSN: 5, Segment: "wal_2.log", Position: tailer.Offset().Position}, tailer.Offset())
+}
+
+func TestTailerRedelivery(t *testing.T) {
+	logger.MockLogger()
+
+	walDir := t.TempDir()
+	output := filepath.Join(t.TempDir(), "cdc.ndjson")
+	cfg := &config.CDCConfig{FilePath: output, BatchSize: 1}
+
+	appendRequests(t, filepath.Join(walDir, "wal_1.log"),
+		wal.Request{LSN: 1, Command: "SET", Args: []string{"a", "1"}},
+		wal.Request{LSN: 2, Command: "SET", Args: []string{"b", "2"}},
+	)
+
+	// offset of server stopped after delivery of the first batch
+	require.NoError(t, SaveOffset(filepath.Join(walDir, defaultOffsetFile), Offset{LSN: 1, Segment: "wal_1.log"}))
+
+	tailer, err := New(cfg, walDir)
+	require.NoError(t, err)
+	require.NoError(t, tailer.Poll())
+
+	assert.Equal(t, []uint64{2}, lsnsOf(readEvents(t, output)))
+}
+
+func TestNew(t *testing.T) {
+	tests := map[string]struct {
+		cfg          *config.CDCConfig
+		walDirectory string
+		expectedErr  string
+	}{
+		"empty config": {
+			walDirectory: "data",
+			expectedErr:  "CDC config is empty",
+		},
+		"without WAL": {
+			cfg:         &config.CDCConfig{},
+			expectedErr: "CDC requires WAL",
+		},
+		"unknown sink": {
+			cfg:          &config.CDCConfig{Sink: "kafka"},
+			walDirectory: "data",
+			expectedErr:  `unknown CDC sink "kafka"`,
+		},
+		"socket without address": {
+			cfg:          &config.CDCConfig{Sink: SinkUnix},
+			walDirectory: "data",
+			expectedErr:  "CDC consumer address is empty",
+		},
+		"invalid max file size": {
+			cfg:          &config.CDCConfig{MaxFileSize: "10PB"},
+			walDirectory: "data",
+			expectedErr:  "unable to set CDC max file size",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(test.cfg, test.walDirectory)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedErr)
+		})
+	}
+}
+
+func TestSegmentToRead(t *testing.T) {
+	filenames := []string{"wal_1.log", "wal_2.log", "wal_3.log"}
+
+	tests := map[string]struct {
+		offsetSegment string
+		segment       string
+		next          string
+	}{
+		"empty offset":    {segment: "wal_1.log", next: "wal_2.log"},
+		"middle segment":  {offsetSegment: "wal_2.log", segment: "wal_2.log", next: "wal_3.log"},
+		"last segment":    {offsetSegment: "wal_3.log", segment: "wal_3.log"},
+		"removed segment": {offsetSegment: "wal_0.log", segment: "wal_1.log", next: "wal_2.log"},
+		"after the last":  {offsetSegment: "wal_4.log"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			segment, next := segmentToRead(filenames, test.offsetSegment)
+			assert.Equal(t, test.segment, segment)
+			assert.Equal(t, test.next, next)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package cdc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Offset is a position of delivered events, requests of segment before
+// position are delivered, requests after it are delivered if their LSN is
+// not greater than offset LSN
+type Offset struct {
+	LSN      uint64 `json:"lsn"`
+	Segment  string `json:"segment"`
+	Position int    `json:"position"`
+}
+
+// LoadOffset reads offset from file, offset is empty if file does not exist
+func LoadOffset(path string) (Offset, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return Offset{}, nil
+	}
+	if err != nil {
+		return Offset{}, fmt.Errorf("read CDC offset: %w", err)
+	}
+
+	var offset Offset
+	if err := json.Unmarshal(data, &offset); err != nil {
+		return Offset{}, fmt.Errorf("parse CDC offset: %w", err)
+	}
+
+	return offset, nil
+}
+
+// SaveOffset writes offset to temporary file and renames it, so offset
+// file is never partially written. Directory is synced after rename, so
+// renamed file is not lost on crash
+func SaveOffset(path string, offset Offset) error {
+	data, err := json.Marshal(offset)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(filepath.Clean(tmp), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("write CDC offset: %w", err)
+	}
+
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("write CDC offset: %w", err)
+	}
+
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("write CDC offset: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("write CDC offset: %w", err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("write CDC offset: %w", err)
+	}
+
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir flushes directory entries to disk
+func syncDir(path string) error {
+	dir, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return fmt.Errorf("sync CDC offset directory: %w", err)
+	}
+
+	if err := dir.Sync(); err != nil {
+		_ = dir.Close()
+		return fmt.Errorf("sync CDC offset directory: %w", err)
+	}
+
+	return dir.Close()
+}
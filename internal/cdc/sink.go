@@ -0,0 +1,244 @@
+package cdc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"concurrency_go_course/internal/compute"
+	"concurrency_go_course/internal/storage/wal"
+)
+
+// Event is a committed mutation, it is encoded as one line of JSON
+type Event struct {
+	LSN uint64 `json:"lsn"`
+	// Timestamp is a time of WAL batch write, it is empty for requests
+	// written by older versions
+	Timestamp string `json:"timestamp,omitempty"`
+	Command   string `json:"command"`
+	Key       string `json:"key"`
+	Value     string `json:"value,omitempty"`
+	// Args are arguments after key of commands other than SET, they are
+	// fields and values of HSET and fields of HDEL
+	Args []string `json:"args,omitempty"`
+}
+
+// NewEvent returns event of WAL request
+func NewEvent(request wal.Request) Event {
+	event := Event{LSN: request.LSN, Command: request.Command}
+	if request.Timestamp != 0 {
+		event.Timestamp = time.Unix(0, request.Timestamp).UTC().Format(time.RFC3339Nano)
+	}
+
+	if len(request.Args) > 0 {
+		event.Key = request.Args[0]
+	}
+	switch {
+	case request.Command == compute.CommandSet && len(request.Args) > 1:
+		event.Value = request.Args[1]
+	case len(request.Args) > 1:
+		event.Args = request.Args[1:]
+	}
+
+	return event
+}
+
+// encodeEvents returns events as newline delimited JSON
+func encodeEvents(events []Event) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return nil, err
+		}
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// Sink delivers batches of events, batch is delivered when Write returns
+// without error, failed batch is written again
+type Sink interface {
+	Write(events []Event) error
+	Close() error
+}
+
+// FileSink appends events to file, file is renamed with suffix of rotation
+// time when it reaches max size
+type FileSink struct {
+	path    string
+	maxSize int64
+
+	file *os.File
+	size int64
+}
+
+// NewFileSink opens or creates file of events, zero max size disables
+// rotation
+func NewFileSink(path string, maxSize int64) (*FileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create CDC directory: %w", err)
+	}
+
+	sink := &FileSink{path: path, maxSize: maxSize}
+	if err := sink.open(); err != nil {
+		return nil, err
+	}
+
+	return sink, nil
+}
+
+// Write appends events and syncs file, so they are not lost after crash
+func (s *FileSink) Write(events []Event) error {
+	data, err := encodeEvents(events)
+	if err != nil {
+		return err
+	}
+
+	if s.maxSize != 0 && s.size != 0 && s.size+int64(len(data)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(data)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("write CDC file: %w", err)
+	}
+
+	return s.file.Sync()
+}
+
+// Close closes file
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open CDC file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("open CDC file: %w", err)
+	}
+
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("close CDC file: %w", err)
+	}
+
+	rotated := s.path + "." + strconv.FormatInt(time.Now().UnixMilli(), 10)
+	if err := os.Rename(s.path, rotated); err != nil {
+		return fmt.Errorf("rotate CDC file: %w", err)
+	}
+
+	return s.open()
+}
+
+// SocketSink sends events to consumer listening on TCP or unix socket,
+// consumer acknowledges every batch with line containing LSN of its last
+// event, connection is established again after failure
+type SocketSink struct {
+	network    string
+	address    string
+	ackTimeout time.Duration
+
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewSocketSink returns sink of consumer address, network is "tcp" or
+// "unix", connection is established by the first write
+func NewSocketSink(network, address string, ackTimeout time.Duration) *SocketSink {
+	return &SocketSink{
+		network:    network,
+		address:    address,
+		ackTimeout: ackTimeout,
+	}
+}
+
+// Write sends events and waits for acknowledgement
+func (s *SocketSink) Write(events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	if err := s.send(events); err != nil {
+		_ = s.Close()
+		return err
+	}
+
+	return nil
+}
+
+func (s *SocketSink) send(events []Event) error {
+	data, err := encodeEvents(events)
+	if err != nil {
+		return err
+	}
+
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.network, s.address, s.ackTimeout)
+		if err != nil {
+			return fmt.Errorf("connect to CDC consumer: %w", err)
+		}
+
+		s.conn = conn
+		s.reader = bufio.NewReader(conn)
+	}
+
+	if err := s.conn.SetDeadline(time.Now().Add(s.ackTimeout)); err != nil {
+		return err
+	}
+
+	if _, err := s.conn.Write(data); err != nil {
+		return fmt.Errorf("send CDC events: %w", err)
+	}
+
+	last := events[len(events)-1].LSN
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("read CDC acknowledgement: %w", err)
+		}
+
+		lsn, err := strconv.ParseUint(strings.TrimSpace(line), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid CDC acknowledgement %q", strings.TrimSpace(line))
+		}
+
+		// acknowledgements of previous batches are skipped
+		if lsn >= last {
+			return nil
+		}
+	}
+}
+
+// Close closes connection to consumer
+func (s *SocketSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+
+	err := s.conn.Close()
+	s.conn = nil
+	s.reader = nil
+	return err
+}
@@ -0,0 +1,119 @@
+package cdc
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"concurrency_go_course/internal/storage/wal"
+)
+
+func TestFileSinkRotate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cdc.ndjson")
+
+	sink, err := NewFileSink(path, 100)
+	require.NoError(t, err)
+	defer sink.Close()
+
+	event := Event{LSN: 1, Command: "SET", Key: "key", Value: "value"}
+	data, err := encodeEvents([]Event{event})
+	require.NoError(t, err)
+	require.Less(t, len(data), 100)
+
+	require.NoError(t, sink.Write([]Event{event}))
+	event.LSN = 2
+	require.NoError(t, sink.Write([]Event{event}))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, []uint64{2}, lsnsOf(readEvents(t, path)))
+	for _, entry := range entries {
+		if entry.Name() != "cdc.ndjson" {
+			assert.Equal(t, []uint64{1}, lsnsOf(readEvents(t, filepath.Join(dir, entry.Name()))))
+		}
+	}
+}
+
+func TestSocketSink(t *testing.T) {
+	address := filepath.Join(t.TempDir(), "cdc.sock")
+	listener, err := net.Listen(SinkUnix, address)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	received := make(chan Event, 10)
+	go func() {
+		// the first connection is closed without acknowledgement
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		_ = conn.Close()
+
+		conn, err = listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			var event Event
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+				return
+			}
+			received <- event
+
+			if _, err := conn.Write([]byte(strconv.FormatUint(event.LSN, 10) + "\n")); err != nil {
+				return
+			}
+		}
+	}()
+
+	sink := NewSocketSink(SinkUnix, address, time.Second)
+	defer sink.Close()
+
+	events := []Event{{LSN: 1, Command: "SET", Key: "a", Value: "1"}, {LSN: 2, Command: "DEL", Key: "a"}}
+
+	require.Error(t, sink.Write(events))
+	require.NoError(t, sink.Write(events))
+
+	assert.Equal(t, events[0], <-received)
+	assert.Equal(t, events[1], <-received)
+}
+
+func TestNewEvent(t *testing.T) {
+	tests := map[string]struct {
+		request  wal.Request
+		expected Event
+	}{
+		"set": {
+			request:  wal.Request{LSN: 1, Command: "SET", Args: []string{"key", "value"}},
+			expected: Event{LSN: 1, Command: "SET", Key: "key", Value: "value"},
+		},
+		"del": {
+			request:  wal.Request{LSN: 2, Command: "DEL", Args: []string{"key"}},
+			expected: Event{LSN: 2, Command: "DEL", Key: "key"},
+		},
+		"hset": {
+			request:  wal.Request{LSN: 3, Command: "HSET", Args: []string{"user", "name", "alice"}},
+			expected: Event{LSN: 3, Command: "HSET", Key: "user", Args: []string{"name", "alice"}},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, NewEvent(test.request))
+		})
+	}
+}
@@ -1,9 +1,12 @@
 package compute
 
 import (
+	"context"
+
 	"go.uber.org/zap"
 
 	"concurrency_go_course/pkg/logger"
+	"concurrency_go_course/pkg/trace"
 )
 
 const (
@@ -13,11 +16,107 @@ const (
 	CommandSet = "SET"
 	// CommandDelete is a delete command
 	CommandDelete = "DEL"
+	// CommandInfo returns state of node, INFO [section]
+	CommandInfo = "INFO"
+	// CommandSlowLog reads and resets slow log, SLOWLOG GET [n] | LEN | RESET
+	CommandSlowLog = "SLOWLOG"
+	// CommandPublish sends message to subscribers of channel, PUBLISH channel message
+	CommandPublish = "PUBLISH"
+	// CommandHSet sets fields of hash, HSET key field value [field value ...]
+	CommandHSet = "HSET"
+	// CommandHGet returns value of hash field, HGET key field
+	CommandHGet = "HGET"
+	// CommandHDel deletes fields of hash, HDEL key field [field ...]
+	CommandHDel = "HDEL"
+	// CommandHGetAll returns fields and values of hash, HGETALL key
+	CommandHGetAll = "HGETALL"
+	// CommandLPush inserts values at the head of list, LPUSH key value [value ...]
+	CommandLPush = "LPUSH"
+	// CommandRPush appends values to the tail of list, RPUSH key value [value ...]
+	CommandRPush = "RPUSH"
+	// CommandLPop removes and returns the first value of list, LPOP key
+	CommandLPop = "LPOP"
+	// CommandRPop removes and returns the last value of list, RPOP key
+	CommandRPop = "RPOP"
+	// CommandLRange returns values of list, LRANGE key start stop
+	CommandLRange = "LRANGE"
+	// CommandLLen returns length of list, LLEN key
+	CommandLLen = "LLEN"
+	// CommandBLPop removes and returns the first value of list waiting for
+	// it up to timeout in seconds, BLPOP key timeout
+	CommandBLPop = "BLPOP"
+	// CommandZAdd sets scores of sorted set members, ZADD key score member [score member ...]
+	CommandZAdd = "ZADD"
+	// CommandZRem removes members of sorted set, ZREM key member [member ...]
+	CommandZRem = "ZREM"
+	// CommandZScore returns score of sorted set member, ZSCORE key member
+	CommandZScore = "ZSCORE"
+	// CommandZRange returns members of sorted set by rank, ZRANGE key start stop [WITHSCORES]
+	CommandZRange = "ZRANGE"
+	// CommandZRangeByScore returns members of sorted set with score in range,
+	// ZRANGEBYSCORE key min max [WITHSCORES]
+	CommandZRangeByScore = "ZRANGEBYSCORE"
+	// CommandZRemRangeByScore removes members of sorted set with score in
+	// range, ZREMRANGEBYSCORE key min max
+	CommandZRemRangeByScore = "ZREMRANGEBYSCORE"
+	// CommandZCard returns number of sorted set members, ZCARD key
+	CommandZCard = "ZCARD"
+	// CommandExpire sets time to live of key in seconds, EXPIRE key seconds
+	CommandExpire = "EXPIRE"
+	// CommandTTL returns time to live of key in seconds, TTL key
+	CommandTTL = "TTL"
+)
+
+// Commands of WAL records written for changes made by server, they are not
+// accepted from clients
+const (
+	// CommandPExpireAt sets expiration time of key in unix milliseconds,
+	// PEXPIREAT key deadline
+	CommandPExpireAt = "PEXPIREAT"
+	// CommandExpired deletes key if it still expires at deadline, EXPIRED
+	// key deadline
+	CommandExpired = "EXPIRED"
 )
 
+// Subcommands of SLOWLOG
+const (
+	SlowLogGet   = "GET"
+	SlowLogLen   = "LEN"
+	SlowLogReset = "RESET"
+)
+
+// Connection commands are handled by server before parser
+const (
+	// CommandAuth authenticates connection
+	CommandAuth = "AUTH"
+	// CommandPing checks connection, it is allowed without authentication
+	CommandPing = "PING"
+	// CommandSubscribe subscribes connection to channels
+	CommandSubscribe = "SUBSCRIBE"
+	// CommandUnsubscribe unsubscribes connection from channels, all
+	// channels if no channel is set
+	CommandUnsubscribe = "UNSUBSCRIBE"
+	// CommandPSubscribe subscribes connection to channels matching patterns
+	CommandPSubscribe = "PSUBSCRIBE"
+	// CommandPUnsubscribe unsubscribes connection from patterns, all
+	// patterns if no pattern is set
+	CommandPUnsubscribe = "PUNSUBSCRIBE"
+)
+
+// Commands returns all commands known to parser
+func Commands() []string {
+	return []string{
+		CommandGet, CommandSet, CommandDelete, CommandInfo, CommandSlowLog, CommandPublish,
+		CommandHSet, CommandHGet, CommandHDel, CommandHGetAll,
+		CommandLPush, CommandRPush, CommandLPop, CommandRPop, CommandLRange, CommandLLen, CommandBLPop,
+		CommandZAdd, CommandZRem, CommandZScore, CommandZRange, CommandZRangeByScore, CommandZRemRangeByScore,
+		CommandZCard, CommandExpire, CommandTTL,
+	}
+}
+
 // Compute is interface for compute object
 type Compute interface {
-	Handle(request string) (Query, error)
+	Handle(ctx context.Context, request string) (Query, error)
 }
 
 // Comp is struct for compute object
@@ -35,13 +134,19 @@ func NewCompute(
 }
 
 // Handle handles requests
-func (c *Comp) Handle(request string) (Query, error) {
+func (c *Comp) Handle(ctx context.Context, request string) (Query, error) {
+	_, span := trace.Start(ctx, "Comp.Handle")
+	defer span.End()
+
 	query, err := c.requestParser.Parse(request)
 	if err != nil {
 		logger.Error("parsing request error", zap.Error(err))
+		span.RecordError(err)
 
 		return Query{}, err
 	}
 
+	span.SetAttributes(trace.String("db.command", query.Command))
+
 	return query, nil
 }
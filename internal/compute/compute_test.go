@@ -1,6 +1,7 @@
 package compute
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -41,7 +42,7 @@ func TestHandleNegCompute(t *testing.T) {
 
 	for name, test := range negTests {
 		t.Run(name, func(t *testing.T) {
-			res, err := compute.Handle(test.in)
+			res, err := compute.Handle(context.Background(), test.in)
 			assert.Equal(t, err, test.err)
 			assert.Equal(t, res, test.res)
 		})
@@ -80,7 +81,7 @@ func TestHandlePosCompute(t *testing.T) {
 
 	for name, test := range posTests {
 		t.Run(name, func(t *testing.T) {
-			res, _ := compute.Handle(test.in)
+			res, _ := compute.Handle(context.Background(), test.in)
 			assert.Equal(t, res, test.res)
 		})
 	}
@@ -2,8 +2,21 @@ package compute
 
 import (
 	"fmt"
+	"math"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
+)
+
+const (
+	// OptionMinLSN is GET option for minimal applied position on replica
+	OptionMinLSN = "MINLSN"
+	// OptionMaxLag is GET option for maximal replication lag on replica
+	OptionMaxLag = "MAXLAG"
+	// OptionWithScores is ZRANGE and ZRANGEBYSCORE option for returning
+	// scores after members
+	OptionWithScores = "WITHSCORES"
 )
 
 // Parser is interface for parser
@@ -21,16 +34,19 @@ func NewRequestParser() *RequestParser {
 
 // Parse parses request string
 func (r *RequestParser) Parse(query string) (Query, error) {
-	queryFields := strings.Fields(query)
+	return ParseFields(strings.Fields(query))
+}
 
+// ParseFields parses request split into command and arguments, arguments
+// may contain spaces
+func ParseFields(queryFields []string) (Query, error) {
 	if len(queryFields) == 0 {
 		return Query{}, fmt.Errorf("invalid query length (0)")
 	}
 
 	command := queryFields[0]
 
-	allCommands := []string{CommandGet, CommandSet, CommandDelete}
-	if !slices.Contains(allCommands, command) {
+	if !slices.Contains(Commands(), command) {
 		return Query{}, fmt.Errorf("invalid command %s", command)
 	}
 
@@ -38,21 +54,276 @@ func (r *RequestParser) Parse(query string) (Query, error) {
 
 	switch command {
 	case CommandGet:
-		if len(queryFields[1:]) != 1 {
+		if argsLen != 1 && argsLen != 3 && argsLen != 5 {
 			return Query{}, fmt.Errorf("for command %s expected 1 argument, got %d",
 				CommandGet, argsLen)
 		}
+
+		return parseGetOptions(queryFields[1:])
 	case CommandSet:
 		if len(queryFields[1:]) != 2 {
 			return Query{}, fmt.Errorf("for command %s expected 2 arguments, got %d",
 				CommandSet, argsLen)
 		}
+	case CommandPublish:
+		if argsLen != 2 {
+			return Query{}, fmt.Errorf("for command %s expected 2 arguments, got %d",
+				CommandPublish, argsLen)
+		}
 	case CommandDelete:
 		if len(queryFields[1:]) != 1 {
 			return Query{}, fmt.Errorf("for command %s expected 1 argument, got %d",
 				CommandDelete, argsLen)
 		}
+	case CommandInfo:
+		if argsLen > 1 {
+			return Query{}, fmt.Errorf("for command %s expected at most 1 argument, got %d",
+				CommandInfo, argsLen)
+		}
+	case CommandSlowLog:
+		return parseSlowLog(queryFields[1:])
+	case CommandHSet:
+		if argsLen < 3 || argsLen%2 != 1 {
+			return Query{}, fmt.Errorf("for command %s expected key and pairs of field and value, got %d arguments",
+				CommandHSet, argsLen)
+		}
+	case CommandHGet:
+		if argsLen != 2 {
+			return Query{}, fmt.Errorf("for command %s expected 2 arguments, got %d",
+				CommandHGet, argsLen)
+		}
+	case CommandHDel:
+		if argsLen < 2 {
+			return Query{}, fmt.Errorf("for command %s expected at least 2 arguments, got %d",
+				CommandHDel, argsLen)
+		}
+	case CommandHGetAll:
+		if argsLen != 1 {
+			return Query{}, fmt.Errorf("for command %s expected 1 argument, got %d",
+				CommandHGetAll, argsLen)
+		}
+	case CommandLPush, CommandRPush:
+		if argsLen < 2 {
+			return Query{}, fmt.Errorf("for command %s expected at least 2 arguments, got %d",
+				command, argsLen)
+		}
+	case CommandLPop, CommandRPop, CommandLLen:
+		if argsLen != 1 {
+			return Query{}, fmt.Errorf("for command %s expected 1 argument, got %d",
+				command, argsLen)
+		}
+	case CommandLRange:
+		if argsLen != 3 {
+			return Query{}, fmt.Errorf("for command %s expected 3 arguments, got %d",
+				CommandLRange, argsLen)
+		}
+
+		for _, index := range queryFields[2:] {
+			if _, err := strconv.Atoi(index); err != nil {
+				return Query{}, fmt.Errorf("invalid %s index %s", CommandLRange, index)
+			}
+		}
+	case CommandBLPop:
+		if argsLen != 2 {
+			return Query{}, fmt.Errorf("for command %s expected 2 arguments, got %d",
+				CommandBLPop, argsLen)
+		}
+
+		return parseBLPop(queryFields[1:])
+	case CommandZAdd:
+		if argsLen < 3 || argsLen%2 != 1 {
+			return Query{}, fmt.Errorf("for command %s expected key and pairs of score and member, got %d arguments",
+				CommandZAdd, argsLen)
+		}
+
+		for i := 2; i < len(queryFields); i += 2 {
+			if _, err := ParseScore(queryFields[i]); err != nil {
+				return Query{}, fmt.Errorf("invalid %s score %s", CommandZAdd, queryFields[i])
+			}
+		}
+	case CommandZRem:
+		if argsLen < 2 {
+			return Query{}, fmt.Errorf("for command %s expected at least 2 arguments, got %d",
+				CommandZRem, argsLen)
+		}
+	case CommandZScore:
+		if argsLen != 2 {
+			return Query{}, fmt.Errorf("for command %s expected 2 arguments, got %d",
+				CommandZScore, argsLen)
+		}
+	case CommandZRange, CommandZRangeByScore, CommandZRemRangeByScore:
+		return parseZRange(command, queryFields[1:])
+	case CommandZCard:
+		if argsLen != 1 {
+			return Query{}, fmt.Errorf("for command %s expected 1 argument, got %d",
+				CommandZCard, argsLen)
+		}
+	case CommandExpire:
+		if argsLen != 2 {
+			return Query{}, fmt.Errorf("for command %s expected 2 arguments, got %d",
+				CommandExpire, argsLen)
+		}
+
+		return parseExpire(queryFields[1:])
+	case CommandTTL:
+		if argsLen != 1 {
+			return Query{}, fmt.Errorf("for command %s expected 1 argument, got %d",
+				CommandTTL, argsLen)
+		}
 	}
 
 	return NewQuery(command, queryFields[1:]), nil
 }
+
+// parseBLPop parses BLPOP key timeout, timeout is a number of seconds
+func parseBLPop(args []string) (Query, error) {
+	seconds, err := strconv.ParseFloat(args[1], 64)
+	if err != nil || seconds < 0 || math.IsInf(seconds, 0) || math.IsNaN(seconds) {
+		return Query{}, fmt.Errorf("invalid %s timeout %s", CommandBLPop, args[1])
+	}
+
+	query := NewQuery(CommandBLPop, args)
+	query.Timeout = time.Duration(seconds * float64(time.Second))
+
+	return query, nil
+}
+
+// parseExpire parses EXPIRE key seconds, seconds must be positive
+func parseExpire(args []string) (Query, error) {
+	seconds, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || seconds <= 0 || seconds > int64(math.MaxInt64/time.Second) {
+		return Query{}, fmt.Errorf("invalid %s timeout %s", CommandExpire, args[1])
+	}
+
+	query := NewQuery(CommandExpire, args)
+	query.TTL = time.Duration(seconds) * time.Second
+
+	return query, nil
+}
+
+// parseZRange parses ZRANGE key start stop [WITHSCORES], ZRANGEBYSCORE key
+// min max [WITHSCORES] and ZREMRANGEBYSCORE key min max, option is case
+// insensitive
+func parseZRange(command string, args []string) (Query, error) {
+	maxArgs := 4
+	if command == CommandZRemRangeByScore {
+		maxArgs = 3
+	}
+
+	if len(args) < 3 || len(args) > maxArgs {
+		return Query{}, fmt.Errorf("for command %s expected 3 arguments, got %d", command, len(args))
+	}
+
+	if len(args) == 4 {
+		if !strings.EqualFold(args[3], OptionWithScores) {
+			return Query{}, fmt.Errorf("unknown option %s for command %s", args[3], command)
+		}
+		args = append(args[:3:3], OptionWithScores)
+	}
+
+	for _, arg := range args[1:3] {
+		var err error
+		if command == CommandZRange {
+			_, err = strconv.Atoi(arg)
+		} else {
+			_, _, err = ParseScoreBound(arg)
+		}
+
+		if err != nil {
+			return Query{}, fmt.Errorf("invalid %s range %s", command, arg)
+		}
+	}
+
+	return NewQuery(command, args), nil
+}
+
+// ParseScore parses score of sorted set member, infinity is written as
+// inf, +inf or -inf
+func ParseScore(s string) (float64, error) {
+	score, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if math.IsNaN(score) {
+		return 0, fmt.Errorf("score is not a number")
+	}
+
+	return score, nil
+}
+
+// ParseScoreBound parses bound of score range, bound starting with "(" is
+// exclusive
+func ParseScoreBound(s string) (score float64, exclusive bool, err error) {
+	if strings.HasPrefix(s, "(") {
+		s, exclusive = s[1:], true
+	}
+
+	score, err = ParseScore(s)
+	return score, exclusive, err
+}
+
+// FormatScore formats score of sorted set member, so it is parsed back by
+// ParseScore
+func FormatScore(score float64) string {
+	switch {
+	case math.IsInf(score, 1):
+		return "inf"
+	case math.IsInf(score, -1):
+		return "-inf"
+	default:
+		return strconv.FormatFloat(score, 'g', -1, 64)
+	}
+}
+
+// parseSlowLog parses SLOWLOG GET [n] | LEN | RESET, subcommand is case
+// insensitive
+func parseSlowLog(args []string) (Query, error) {
+	if len(args) == 0 {
+		return Query{}, fmt.Errorf("for command %s expected subcommand", CommandSlowLog)
+	}
+
+	subcommand := strings.ToUpper(args[0])
+	switch {
+	case subcommand == SlowLogGet && len(args) <= 2:
+		if len(args) == 2 {
+			if n, err := strconv.Atoi(args[1]); err != nil || n < 0 {
+				return Query{}, fmt.Errorf("invalid %s %s count %s", CommandSlowLog, SlowLogGet, args[1])
+			}
+		}
+	case (subcommand == SlowLogLen || subcommand == SlowLogReset) && len(args) == 1:
+	default:
+		return Query{}, fmt.Errorf("unknown subcommand or wrong number of arguments for %s %s",
+			CommandSlowLog, args[0])
+	}
+
+	return NewQuery(CommandSlowLog, append([]string{subcommand}, args[1:]...)), nil
+}
+
+// parseGetOptions parses GET key [MINLSN n] [MAXLAG duration]
+func parseGetOptions(args []string) (Query, error) {
+	query := NewQuery(CommandGet, args[:1])
+
+	for i := 1; i < len(args); i += 2 {
+		option, value := args[i], args[i+1]
+
+		switch option {
+		case OptionMinLSN:
+			lsn, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return Query{}, fmt.Errorf("invalid %s value %s", OptionMinLSN, value)
+			}
+			query.MinLSN = lsn
+		case OptionMaxLag:
+			lag, err := time.ParseDuration(value)
+			if err != nil || lag < 0 {
+				return Query{}, fmt.Errorf("invalid %s value %s", OptionMaxLag, value)
+			}
+			query.MaxLag = lag
+		default:
+			return Query{}, fmt.Errorf("unknown option %s for command %s", option, CommandGet)
+		}
+	}
+
+	return query, nil
+}
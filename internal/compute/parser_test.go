@@ -3,6 +3,7 @@ package compute
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -77,6 +78,166 @@ func TestParseRequestParserNeg(t *testing.T) {
 			query: Query{},
 			err:   fmt.Errorf("for command DEL expected 1 argument, got 2"),
 		},
+		"INFO: with 2 args": {
+			in:    "INFO server clients",
+			query: Query{},
+			err:   fmt.Errorf("for command INFO expected at most 1 argument, got 2"),
+		},
+		"PUBLISH: without message": {
+			in:    "PUBLISH channel",
+			query: Query{},
+			err:   fmt.Errorf("for command PUBLISH expected 2 arguments, got 1"),
+		},
+		"HSET: without value": {
+			in:    "HSET key field",
+			query: Query{},
+			err:   fmt.Errorf("for command HSET expected key and pairs of field and value, got 2 arguments"),
+		},
+		"HSET: field without value": {
+			in:    "HSET key field value field2",
+			query: Query{},
+			err:   fmt.Errorf("for command HSET expected key and pairs of field and value, got 4 arguments"),
+		},
+		"HGET: without field": {
+			in:    "HGET key",
+			query: Query{},
+			err:   fmt.Errorf("for command HGET expected 2 arguments, got 1"),
+		},
+		"HDEL: without field": {
+			in:    "HDEL key",
+			query: Query{},
+			err:   fmt.Errorf("for command HDEL expected at least 2 arguments, got 1"),
+		},
+		"HGETALL: with field": {
+			in:    "HGETALL key field",
+			query: Query{},
+			err:   fmt.Errorf("for command HGETALL expected 1 argument, got 2"),
+		},
+		"LPUSH: without values": {
+			in:    "LPUSH key",
+			query: Query{},
+			err:   fmt.Errorf("for command LPUSH expected at least 2 arguments, got 1"),
+		},
+		"RPOP: with count": {
+			in:    "RPOP key 2",
+			query: Query{},
+			err:   fmt.Errorf("for command RPOP expected 1 argument, got 2"),
+		},
+		"LRANGE: invalid index": {
+			in:    "LRANGE key 0 last",
+			query: Query{},
+			err:   fmt.Errorf("invalid LRANGE index last"),
+		},
+		"BLPOP: without timeout": {
+			in:    "BLPOP key",
+			query: Query{},
+			err:   fmt.Errorf("for command BLPOP expected 2 arguments, got 1"),
+		},
+		"EXPIRE: without timeout": {
+			in:    "EXPIRE key",
+			query: Query{},
+			err:   fmt.Errorf("for command EXPIRE expected 2 arguments, got 1"),
+		},
+		"EXPIRE: zero timeout": {
+			in:    "EXPIRE key 0",
+			query: Query{},
+			err:   fmt.Errorf("invalid EXPIRE timeout 0"),
+		},
+		"EXPIRE: fractional timeout": {
+			in:    "EXPIRE key 1.5",
+			query: Query{},
+			err:   fmt.Errorf("invalid EXPIRE timeout 1.5"),
+		},
+		"EXPIRE: too long timeout": {
+			in:    "EXPIRE key 9223372037",
+			query: Query{},
+			err:   fmt.Errorf("invalid EXPIRE timeout 9223372037"),
+		},
+		"TTL: without key": {
+			in:    "TTL",
+			query: Query{},
+			err:   fmt.Errorf("for command TTL expected 1 argument, got 0"),
+		},
+		"BLPOP: negative timeout": {
+			in:    "BLPOP key -1",
+			query: Query{},
+			err:   fmt.Errorf("invalid BLPOP timeout -1"),
+		},
+		"ZADD: without member": {
+			in:    "ZADD board 10",
+			query: Query{},
+			err:   fmt.Errorf("for command ZADD expected key and pairs of score and member, got 2 arguments"),
+		},
+		"ZADD: invalid score": {
+			in:    "ZADD board high alice",
+			query: Query{},
+			err:   fmt.Errorf("invalid ZADD score high"),
+		},
+		"ZADD: NaN score": {
+			in:    "ZADD board nan alice",
+			query: Query{},
+			err:   fmt.Errorf("invalid ZADD score nan"),
+		},
+		"ZRANGE: invalid rank": {
+			in:    "ZRANGE board 0 1.5",
+			query: Query{},
+			err:   fmt.Errorf("invalid ZRANGE range 1.5"),
+		},
+		"ZRANGE: unknown option": {
+			in:    "ZRANGE board 0 -1 REV",
+			query: Query{},
+			err:   fmt.Errorf("unknown option REV for command ZRANGE"),
+		},
+		"ZRANGEBYSCORE: invalid bound": {
+			in:    "ZRANGEBYSCORE board [1 2",
+			query: Query{},
+			err:   fmt.Errorf("invalid ZRANGEBYSCORE range [1"),
+		},
+		"ZREMRANGEBYSCORE: with WITHSCORES": {
+			in:    "ZREMRANGEBYSCORE board 1 2 WITHSCORES",
+			query: Query{},
+			err:   fmt.Errorf("for command ZREMRANGEBYSCORE expected 3 arguments, got 4"),
+		},
+		"SLOWLOG: without subcommand": {
+			in:    "SLOWLOG",
+			query: Query{},
+			err:   fmt.Errorf("for command SLOWLOG expected subcommand"),
+		},
+		"SLOWLOG: with unknown subcommand": {
+			in:    "SLOWLOG CLEAR",
+			query: Query{},
+			err:   fmt.Errorf("unknown subcommand or wrong number of arguments for SLOWLOG CLEAR"),
+		},
+		"SLOWLOG: RESET with count": {
+			in:    "SLOWLOG RESET 1",
+			query: Query{},
+			err:   fmt.Errorf("unknown subcommand or wrong number of arguments for SLOWLOG RESET"),
+		},
+		"SLOWLOG: GET with invalid count": {
+			in:    "SLOWLOG GET -1",
+			query: Query{},
+			err:   fmt.Errorf("invalid SLOWLOG GET count -1"),
+		},
+		"GET: with unknown option": {
+			in:    "GET key MINAGE 10",
+			query: Query{},
+			err:   fmt.Errorf("unknown option MINAGE for command GET"),
+		},
+		"GET: with invalid MINLSN": {
+			in:    "GET key MINLSN -1",
+			query: Query{},
+			err:   fmt.Errorf("invalid MINLSN value -1"),
+		},
+		"GET: with invalid MAXLAG": {
+			in:    "GET key MAXLAG 10",
+			query: Query{},
+			err:   fmt.Errorf("invalid MAXLAG value 10"),
+		},
+		"GET: with option without value": {
+			in:    "GET key MINLSN 1 MAXLAG",
+			query: Query{},
+			err:   fmt.Errorf("for command GET expected 1 argument, got 4"),
+		},
 	}
 
 	for name, test := range negTests {
@@ -108,6 +269,78 @@ func TestParseRequestParserPos(t *testing.T) {
 			in:    "DEL key",
 			query: Query{Command: "DEL", Args: []string{"key"}},
 		},
+		"correct INFO test": {
+			in:    "INFO",
+			query: Query{Command: "INFO", Args: []string{}},
+		},
+		"correct INFO with section test": {
+			in:    "INFO memory",
+			query: Query{Command: "INFO", Args: []string{"memory"}},
+		},
+		"correct PUBLISH test": {
+			in:    "PUBLISH news hello",
+			query: Query{Command: "PUBLISH", Args: []string{"news", "hello"}},
+		},
+		"correct HSET test": {
+			in:    "HSET key name alice age 30",
+			query: Query{Command: "HSET", Args: []string{"key", "name", "alice", "age", "30"}},
+		},
+		"correct HDEL test": {
+			in:    "HDEL key name age",
+			query: Query{Command: "HDEL", Args: []string{"key", "name", "age"}},
+		},
+		"correct HGETALL test": {
+			in:    "HGETALL key",
+			query: Query{Command: "HGETALL", Args: []string{"key"}},
+		},
+		"correct RPUSH test": {
+			in:    "RPUSH key a b",
+			query: Query{Command: "RPUSH", Args: []string{"key", "a", "b"}},
+		},
+		"correct LRANGE test": {
+			in:    "LRANGE key 0 -1",
+			query: Query{Command: "LRANGE", Args: []string{"key", "0", "-1"}},
+		},
+		"correct EXPIRE test": {
+			in:    "EXPIRE key 60",
+			query: Query{Command: "EXPIRE", Args: []string{"key", "60"}, TTL: time.Minute},
+		},
+		"correct TTL test": {
+			in:    "TTL key",
+			query: Query{Command: "TTL", Args: []string{"key"}},
+		},
+		"correct BLPOP test": {
+			in:    "BLPOP key 0.5",
+			query: Query{Command: "BLPOP", Args: []string{"key", "0.5"}, Timeout: 500 * time.Millisecond},
+		},
+		"correct ZADD test": {
+			in:    "ZADD board 10 alice -inf bob",
+			query: Query{Command: "ZADD", Args: []string{"board", "10", "alice", "-inf", "bob"}},
+		},
+		"correct ZRANGE test": {
+			in:    "ZRANGE board 0 -1 withscores",
+			query: Query{Command: "ZRANGE", Args: []string{"board", "0", "-1", "WITHSCORES"}},
+		},
+		"correct ZRANGEBYSCORE test": {
+			in:    "ZRANGEBYSCORE board (1.5 +inf",
+			query: Query{Command: "ZRANGEBYSCORE", Args: []string{"board", "(1.5", "+inf"}},
+		},
+		"correct SLOWLOG GET test": {
+			in:    "SLOWLOG get 5",
+			query: Query{Command: "SLOWLOG", Args: []string{"GET", "5"}},
+		},
+		"correct SLOWLOG RESET test": {
+			in:    "SLOWLOG RESET",
+			query: Query{Command: "SLOWLOG", Args: []string{"RESET"}},
+		},
+		"correct GET with MINLSN test": {
+			in:    "GET key MINLSN 42",
+			query: Query{Command: "GET", Args: []string{"key"}, MinLSN: 42},
+		},
+		"correct GET with MINLSN and MAXLAG test": {
+			in:    "GET key MAXLAG 500ms MINLSN 7",
+			query: Query{Command: "GET", Args: []string{"key"}, MinLSN: 7, MaxLag: 500 * time.Millisecond},
+		},
 	}
 
 	for name, test := range posTests {
@@ -117,3 +350,32 @@ func TestParseRequestParserPos(t *testing.T) {
 		})
 	}
 }
+
+func TestScore(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		in        string
+		formatted string
+		exclusive bool
+	}{
+		"integer":        {in: "10", formatted: "10"},
+		"fraction":       {in: "-0.25", formatted: "-0.25"},
+		"exponent":       {in: "1e21", formatted: "1e+21"},
+		"infinity":       {in: "+inf", formatted: "inf"},
+		"minus infinity": {in: "-inf", formatted: "-inf"},
+		"exclusive":      {in: "(2.5", formatted: "2.5", exclusive: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			score, exclusive, err := ParseScoreBound(test.in)
+			assert.NoError(t, err)
+			assert.Equal(t, test.exclusive, exclusive)
+			assert.Equal(t, test.formatted, FormatScore(score))
+		})
+	}
+
+	_, err := ParseScore("nan")
+	assert.Error(t, err)
+}
@@ -1,9 +1,21 @@
 package compute
 
+import "time"
+
 // Query is a struct for query
 type Query struct {
 	Command string
 	Args    []string
+
+	// MinLSN and MaxLag are consistency options of GET on replicas
+	MinLSN uint64
+	MaxLag time.Duration
+
+	// Timeout is a waiting time of BLPOP, zero means waiting without limit
+	Timeout time.Duration
+
+	// TTL is a time to live of EXPIRE
+	TTL time.Duration
 }
 
 // NewQuery returns new query object
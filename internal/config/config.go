@@ -36,6 +36,26 @@ type NetworkConfig struct {
 	MaxConnections int    `yaml:"max_connections"`
 	MaxMessageSize string `yaml:"max_message_size"`
 	IdleTimeout    string `yaml:"idle_timeout"`
+	// Protocol is a protocol of main address, "resp" enables RESP instead
+	// of framed text protocol
+	Protocol string `yaml:"protocol"`
+	// RESPAddress is an optional second address with RESP protocol
+	RESPAddress string `yaml:"resp_address"`
+	// HTTPAddress is an optional address of HTTP/JSON gateway
+	HTTPAddress string `yaml:"http_address"`
+	// GRPCAddress is an optional address of gRPC service
+	GRPCAddress string `yaml:"grpc_address"`
+	// MetricsAddress is an optional address of HTTP server with /metrics
+	// in Prometheus text format
+	MetricsAddress string `yaml:"metrics_address"`
+	// TLS enables TLS on main address, client certificates are required
+	// if CA file is set
+	TLS *TLSConfig `yaml:"tls"`
+	// UsersFile enables authentication on all addresses: AUTH of text and
+	// RESP protocols, basic authentication of HTTP and authorization
+	// metadata of gRPC. Unauthenticated clients may only authenticate and
+	// ping unless users file sets anonymous roles
+	UsersFile string `yaml:"users_file"`
 }
 
 // LoggingConfig is a struct for logging config
@@ -49,6 +69,95 @@ type ReplicationConfig struct {
 	ReplicaType   string        `yaml:"replica_type"`
 	MasterAddress string        `yaml:"master_address"`
 	SyncInterval  time.Duration `yaml:"sync_interval"`
+	// MasterAddresses are candidate masters for slave, they are tried
+	// in order after master address
+	MasterAddresses []string `yaml:"master_addresses"`
+	// ReconnectMinBackoff and ReconnectMaxBackoff limit delay between
+	// attempts to reach master
+	ReconnectMinBackoff time.Duration `yaml:"reconnect_min_backoff"`
+	ReconnectMaxBackoff time.Duration `yaml:"reconnect_max_backoff"`
+	// ReadWaitTimeout limits waiting for MINLSN/MAXLAG reads on slave
+	ReadWaitTimeout time.Duration `yaml:"read_wait_timeout"`
+	// ReplicaID identifies node in handshake, network address by default
+	ReplicaID string `yaml:"replica_id"`
+	ClusterID string `yaml:"cluster_id"`
+	// Capabilities limits negotiated replication features, all by default
+	Capabilities []string `yaml:"capabilities"`
+	// TLS enables mutual TLS between master and slaves
+	TLS *TLSConfig `yaml:"tls"`
+	// SharedSecret enables HMAC challenge in handshake
+	SharedSecret string      `yaml:"shared_secret"`
+	Raft         *RaftConfig `yaml:"raft"`
+}
+
+// TLSConfig is a struct for TLS config, peer certificates are verified
+// with CA if it is set
+type TLSConfig struct {
+	CertFile   string `yaml:"cert_file"`
+	KeyFile    string `yaml:"key_file"`
+	CAFile     string `yaml:"ca_file"`
+	ServerName string `yaml:"server_name"`
+}
+
+// RaftConfig is a struct for raft consensus config
+type RaftConfig struct {
+	NodeID            string            `yaml:"node_id"`
+	Peers             map[string]string `yaml:"peers"`
+	DataDirectory     string            `yaml:"data_directory"`
+	TickInterval      time.Duration     `yaml:"tick_interval"`
+	ElectionTicks     int               `yaml:"election_ticks"`
+	HeartbeatTicks    int               `yaml:"heartbeat_ticks"`
+	SnapshotThreshold int               `yaml:"snapshot_threshold"`
+	ProposeTimeout    time.Duration     `yaml:"propose_timeout"`
+}
+
+// TracingConfig is a struct for tracing config, spans are written to file
+// for "file" exporter and posted to OTLP/HTTP endpoint for "otlp_http"
+type TracingConfig struct {
+	ServiceName string `yaml:"service_name"`
+	Exporter    string `yaml:"exporter"`
+	FilePath    string `yaml:"file_path"`
+	Endpoint    string `yaml:"endpoint"`
+	// SampleRatio is a share of exported traces, all traces are exported
+	// if it is not set
+	SampleRatio *float64 `yaml:"sample_ratio"`
+}
+
+// SlowLogConfig is a struct for slow log config, commands which latency
+// exceeds threshold are recorded
+type SlowLogConfig struct {
+	Threshold time.Duration `yaml:"threshold"`
+	MaxLen    int           `yaml:"max_len"`
+	// RedactKeys replaces keys of entries with their hashes
+	RedactKeys bool `yaml:"redact_keys"`
+	// LogEntries writes entries to log too
+	LogEntries bool `yaml:"log_entries"`
+}
+
+// PubSubConfig is a struct for pub/sub config, slow subscriber policy is
+// "disconnect" or "drop"
+type PubSubConfig struct {
+	BufferSize           int    `yaml:"buffer_size"`
+	SlowSubscriberPolicy string `yaml:"slow_subscriber_policy"`
+	// KeyspaceEvents publishes changes of keys to keyspace channels
+	KeyspaceEvents bool `yaml:"keyspace_events"`
+}
+
+// CDCConfig is a struct for change data capture config, committed WAL
+// requests are appended to rotating file of "file" sink or sent to
+// consumer of "tcp" or "unix" sink
+type CDCConfig struct {
+	Sink        string `yaml:"sink"`
+	FilePath    string `yaml:"file_path"`
+	MaxFileSize string `yaml:"max_file_size"`
+	Address     string `yaml:"address"`
+	// AckTimeout limits waiting for consumer acknowledgement of batch
+	AckTimeout time.Duration `yaml:"ack_timeout"`
+	// OffsetPath is a file of the last delivered position, it is stored
+	// in WAL data directory by default
+	OffsetPath   string        `yaml:"offset_path"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+	BatchSize    int           `yaml:"batch_size"`
 }
 
 // Config is a struct for server config
@@ -57,6 +166,15 @@ type Config struct {
 	Network     *NetworkConfig     `yaml:"network"`
 	Logging     *LoggingConfig     `yaml:"logging"`
 	Replication *ReplicationConfig `yaml:"replication"`
+	// Tracing enables tracing of requests, it is disabled if it is not set
+	Tracing *TracingConfig `yaml:"tracing"`
+	// SlowLog enables SLOWLOG command, it is disabled if it is not set
+	SlowLog *SlowLogConfig `yaml:"slow_log"`
+	// PubSub enables PUBLISH and subscriptions, it is disabled if it is not set
+	PubSub *PubSubConfig `yaml:"pubsub"`
+	// CDC enables change data capture from WAL, it is disabled if it is
+	// not set
+	CDC *CDCConfig `yaml:"cdc"`
 }
 
 // WALSettings is a struct for WAL settings
@@ -1,54 +1,306 @@
 package database
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
+	"concurrency_go_course/internal/auth"
 	"concurrency_go_course/internal/compute"
+	"concurrency_go_course/internal/pubsub"
+	"concurrency_go_course/internal/slowlog"
 	"concurrency_go_course/internal/storage"
 	"concurrency_go_course/pkg/logger"
+	"concurrency_go_course/pkg/metrics"
 
 	"go.uber.org/zap"
 )
 
 var resultOK = "OK"
 
-// Database is interface for database
+// commandUnknown is a command label of requests which were not parsed
+const commandUnknown = "unknown"
+
+var (
+	commandsTotal = metrics.Register(metrics.NewCounterVec("db_commands_total",
+		"Number of handled commands by command and status.", "command", "status"))
+	commandDuration = metrics.Register(metrics.NewHistogramVec("db_command_duration_seconds",
+		"Duration of handled commands.", metrics.DurationBuckets, "command"))
+)
+
+var (
+	// ErrNotFound is returned by GET if key does not exist
+	ErrNotFound = errors.New("value not found")
+	// ErrReadOnlyReplica is returned for writes sent to slave replica
+	// or raft follower
+	ErrReadOnlyReplica = storage.ErrReadOnly
+	// ErrParse is returned by Handle for malformed request
+	ErrParse = errors.New("unable to parse query")
+	// ErrPermissionDenied is returned if user of request has no access
+	ErrPermissionDenied = auth.ErrPermissionDenied
+	// ErrWrongType is returned for command applied to key holding value
+	// of another type
+	ErrWrongType = storage.ErrWrongType
+)
+
+// Database is interface for database, context contains user of request
 type Database interface {
-	Handle(request string) (string, error)
+	Handle(ctx context.Context, request string) (string, error)
+	HandleQuery(ctx context.Context, query compute.Query) (string, error)
+	// Position returns applied position of slave replica, it returns false
+	// for master
+	Position() (Position, bool)
+}
+
+// Position is an applied position of slave replica, lag is not known
+// until replica synced with master
+type Position struct {
+	AppliedLSN uint64
+	Lag        time.Duration
+	LagKnown   bool
+}
+
+// Authorizer checks access of user to command category on key, empty
+// user means anonymous request
+type Authorizer interface {
+	Authorize(user string, category auth.Category, key string) error
+}
+
+// Option is an option of database
+type Option func(*database)
+
+// WithAuthorizer enables access checks of queries
+func WithAuthorizer(authorizer Authorizer) Option {
+	return func(db *database) {
+		db.authorizer = authorizer
+	}
+}
+
+// Info renders sections of node state for INFO command
+type Info interface {
+	Render(section string) (string, error)
+}
+
+// WithInfo enables INFO command
+func WithInfo(info Info) Option {
+	return func(db *database) {
+		db.info = info
+	}
+}
+
+// SlowLog records slow commands for SLOWLOG command
+type SlowLog interface {
+	Add(ctx context.Context, command, key string, parse, execute time.Duration)
+	Get(n int) []slowlog.Entry
+	Len() int
+	Reset()
+}
+
+// defaultSlowLogCount is a number of entries returned by SLOWLOG GET
+// without count
+const defaultSlowLogCount = 10
+
+// WithSlowLog enables recording of slow commands and SLOWLOG command
+func WithSlowLog(slowLog SlowLog) Option {
+	return func(db *database) {
+		db.slowLog = slowLog
+	}
+}
+
+// Publisher sends messages to subscribers of channel, it returns number
+// of subscribers which received message
+type Publisher interface {
+	Publish(channel, payload string) int
+}
+
+// WithPublisher enables PUBLISH command
+func WithPublisher(publisher Publisher) Option {
+	return func(db *database) {
+		db.publisher = publisher
+	}
+}
+
+// Replica is interface for slave replication state used by consistent reads
+type Replica interface {
+	AppliedLSN() uint64
+	Lag() (time.Duration, bool)
+	WaitLSN(ctx context.Context, lsn uint64) error
+	WaitLag(ctx context.Context, maxLag time.Duration) error
 }
 
 type database struct {
-	storage storage.Storage
-	compute compute.Compute
+	storage    storage.Storage
+	compute    compute.Compute
+	replica    Replica
+	authorizer Authorizer
+	info       Info
+	slowLog    SlowLog
+	publisher  Publisher
 }
 
 // NewDatabase returns new database
 func NewDatabase(
 	storage storage.Storage,
 	compute compute.Compute,
+	options ...Option,
 ) Database {
-	return &database{
+	db := &database{
 		storage: storage,
 		compute: compute,
 	}
+
+	for _, option := range options {
+		option(db)
+	}
+
+	return db
+}
+
+// NewReplicaDatabase returns new database for slave replica, it waits for
+// consistency options of reads and reports replica position
+func NewReplicaDatabase(
+	storage storage.Storage,
+	compute compute.Compute,
+	replica Replica,
+	options ...Option,
+) Database {
+	db := &database{
+		storage: storage,
+		compute: compute,
+		replica: replica,
+	}
+
+	for _, option := range options {
+		option(db)
+	}
+
+	return db
 }
 
 // Handle handles request
-func (s *database) Handle(request string) (string, error) {
-	query, err := s.compute.Handle(request)
+func (s *database) Handle(ctx context.Context, request string) (string, error) {
+	start := time.Now()
+
+	query, err := s.compute.Handle(ctx, request)
 	if err != nil {
 		logger.ErrorWithMsg("Parsing request error:", err)
+		commandsTotal.WithLabelValues(commandUnknown, "parse_error").Inc()
+
+		return "", fmt.Errorf("%w: %w", ErrParse, err)
+	}
+
+	return s.handle(ctx, query, time.Since(start))
+}
+
+// HandleQuery handles parsed query
+func (s *database) HandleQuery(ctx context.Context, query compute.Query) (string, error) {
+	return s.handle(ctx, query, 0)
+}
+
+// handle handles parsed query, parse is a duration of request parsing
+// which is a part of command latency in slow log
+func (s *database) handle(ctx context.Context, query compute.Query, parse time.Duration) (string, error) {
+	start := time.Now()
+
+	response, err := s.handleQuery(ctx, query)
+	execute := time.Since(start)
+
+	commandsTotal.WithLabelValues(query.Command, commandStatus(err)).Inc()
+	commandDuration.WithLabelValues(query.Command).Observe(execute.Seconds())
+
+	// BLPOP is slow by waiting for value, it is not a slow command
+	if s.slowLog != nil && query.Command != compute.CommandBLPop {
+		_, key := commandAccess(query)
+		s.slowLog.Add(ctx, query.Command, key, parse, execute)
+	}
+
+	return response, err
+}
 
+func (s *database) handleQuery(ctx context.Context, query compute.Query) (string, error) {
+	if err := s.authorize(ctx, query); err != nil {
 		return "", err
 	}
 
+	return s.execute(ctx, query)
+}
+
+// commandStatus returns status label of command result
+func commandStatus(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrPermissionDenied):
+		return "permission_denied"
+	case errors.Is(err, ErrReadOnlyReplica):
+		return "read_only"
+	case errors.Is(err, ErrWrongType):
+		return "wrong_type"
+	default:
+		return "error"
+	}
+}
+
+// authorize checks access of request user before dispatch
+func (s *database) authorize(ctx context.Context, query compute.Query) error {
+	if s.authorizer == nil {
+		return nil
+	}
+
+	category, key := commandAccess(query)
+	user := auth.UserFromContext(ctx)
+
+	if err := s.authorizer.Authorize(user, category, key); err != nil {
+		logger.Warn("permission denied", zap.String("user", user), zap.Bool("authenticated", user != ""),
+			zap.String("command", query.Command), zap.String("key", key))
+		return err
+	}
+
+	return nil
+}
+
+// commandAccess returns category and key of query for access checks,
+// channel of PUBLISH is checked as key, unknown commands require admin
+// access
+func commandAccess(query compute.Query) (auth.Category, string) {
+	switch query.Command {
+	case compute.CommandGet, compute.CommandHGet, compute.CommandHGetAll, compute.CommandLRange, compute.CommandLLen,
+		compute.CommandZScore, compute.CommandZRange, compute.CommandZRangeByScore, compute.CommandZCard,
+		compute.CommandTTL:
+		return auth.CategoryRead, query.Args[0]
+	case compute.CommandSet, compute.CommandDelete, compute.CommandPublish, compute.CommandHSet, compute.CommandHDel,
+		compute.CommandLPush, compute.CommandRPush, compute.CommandLPop, compute.CommandRPop, compute.CommandBLPop,
+		compute.CommandZAdd, compute.CommandZRem, compute.CommandZRemRangeByScore, compute.CommandExpire:
+		return auth.CategoryWrite, query.Args[0]
+	default:
+		return auth.CategoryAdmin, ""
+	}
+}
+
+func (s *database) execute(ctx context.Context, query compute.Query) (string, error) {
+	var err error
+
 	switch query.Command {
 	case compute.CommandGet:
-		v, ok := s.storage.Get(query.Args[0])
+		if err = s.waitReplica(ctx, query); err != nil {
+			logger.Error("get error: replica is stale", zap.Error(err))
+
+			return "", err
+		}
+
+		v, ok, err := s.storage.Get(query.Args[0])
+		if err != nil {
+			return "", err
+		}
 		if !ok {
 			logger.Error("get error: value not found")
 
-			return "", fmt.Errorf("value not found")
+			return "", ErrNotFound
 		}
 
 		logger.Debug("Value for key was found",
@@ -56,7 +308,7 @@ func (s *database) Handle(request string) (string, error) {
 
 		return v, nil
 	case compute.CommandSet:
-		err = s.storage.Set(query.Args[0], query.Args[1])
+		err = s.storage.Set(ctx, query.Args[0], query.Args[1])
 		if err != nil {
 			return "", err
 		}
@@ -66,7 +318,7 @@ func (s *database) Handle(request string) (string, error) {
 
 		return resultOK, nil
 	case compute.CommandDelete:
-		err = s.storage.Del(query.Args[0])
+		err = s.storage.Del(ctx, query.Args[0])
 		if err != nil {
 			return "", err
 		}
@@ -74,7 +326,337 @@ func (s *database) Handle(request string) (string, error) {
 		logger.Debug("Key was deleted", zap.String("key", query.Args[0]))
 
 		return resultOK, nil
+	case compute.CommandInfo:
+		if s.info == nil {
+			return "", errors.New("INFO is not enabled")
+		}
+
+		var section string
+		if len(query.Args) != 0 {
+			section = query.Args[0]
+		}
+
+		result, err := s.info.Render(section)
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", ErrParse, err)
+		}
+
+		return result, nil
+	case compute.CommandSlowLog:
+		return s.executeSlowLog(query)
+	case compute.CommandPublish:
+		if s.publisher == nil {
+			return "", errors.New("PUBLISH is not enabled")
+		}
+
+		// keyspace notifications are published only by server
+		channel := query.Args[0]
+		if strings.HasPrefix(channel, pubsub.KeyspacePrefix) || strings.HasPrefix(channel, pubsub.KeyeventPrefix) {
+			return "", fmt.Errorf("%w: channel %s is reserved for keyspace notifications", ErrParse, channel)
+		}
+
+		return strconv.Itoa(s.publisher.Publish(query.Args[0], query.Args[1])), nil
+	case compute.CommandHSet, compute.CommandHGet, compute.CommandHDel, compute.CommandHGetAll:
+		return s.executeHash(ctx, query)
+	case compute.CommandLPush, compute.CommandRPush, compute.CommandLPop, compute.CommandRPop,
+		compute.CommandLRange, compute.CommandLLen, compute.CommandBLPop:
+		return s.executeList(ctx, query)
+	case compute.CommandZAdd, compute.CommandZRem, compute.CommandZScore, compute.CommandZRange,
+		compute.CommandZRangeByScore, compute.CommandZRemRangeByScore, compute.CommandZCard:
+		return s.executeZSet(ctx, query)
+	case compute.CommandExpire, compute.CommandTTL:
+		return s.executeExpire(ctx, query)
 	}
 
 	return "", fmt.Errorf("unknown command: %s", query.Command)
 }
+
+// executeExpire handles EXPIRE which returns 1 if timeout is set and 0 if
+// key does not exist, and TTL which returns time to live in seconds, -2 if
+// key does not exist and -1 if key does not expire
+func (s *database) executeExpire(ctx context.Context, query compute.Query) (string, error) {
+	key := query.Args[0]
+
+	if query.Command == compute.CommandExpire {
+		ok, err := s.storage.Expire(ctx, key, query.TTL)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "0", nil
+		}
+
+		return "1", nil
+	}
+
+	deadline, ok := s.storage.ExpireTime(key)
+	switch {
+	case !ok:
+		return "-2", nil
+	case deadline.IsZero():
+		return "-1", nil
+	}
+
+	ttl := time.Until(deadline)
+	return strconv.FormatInt(int64((ttl+time.Second-1)/time.Second), 10), nil
+}
+
+// executeHash handles hash commands, HSET and HDEL return number of
+// changed fields, fields and values of HGETALL are returned one per line
+func (s *database) executeHash(ctx context.Context, query compute.Query) (string, error) {
+	key := query.Args[0]
+
+	switch query.Command {
+	case compute.CommandHSet:
+		added, err := s.storage.HSet(ctx, key, query.Args[1:])
+		if err != nil {
+			return "", err
+		}
+
+		return strconv.Itoa(added), nil
+	case compute.CommandHGet:
+		value, ok, err := s.storage.HGet(key, query.Args[1])
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", ErrNotFound
+		}
+
+		return value, nil
+	case compute.CommandHDel:
+		deleted, err := s.storage.HDel(ctx, key, query.Args[1:])
+		if err != nil {
+			return "", err
+		}
+
+		return strconv.Itoa(deleted), nil
+	default:
+		pairs, err := s.storage.HGetAll(key)
+		if err != nil {
+			return "", err
+		}
+
+		return strings.Join(pairs, "\n"), nil
+	}
+}
+
+// executeList handles list commands, LPUSH, RPUSH and LLEN return length
+// of list, values of LRANGE are returned one per line, BLPOP returns key
+// and value on separate lines
+func (s *database) executeList(ctx context.Context, query compute.Query) (string, error) {
+	key := query.Args[0]
+
+	switch query.Command {
+	case compute.CommandLPush, compute.CommandRPush:
+		push := s.storage.LPush
+		if query.Command == compute.CommandRPush {
+			push = s.storage.RPush
+		}
+
+		length, err := push(ctx, key, query.Args[1:])
+		if err != nil {
+			return "", err
+		}
+
+		return strconv.Itoa(length), nil
+	case compute.CommandLPop, compute.CommandRPop, compute.CommandBLPop:
+		var (
+			value string
+			ok    bool
+			err   error
+		)
+
+		switch query.Command {
+		case compute.CommandLPop:
+			value, ok, err = s.storage.LPop(ctx, key)
+		case compute.CommandRPop:
+			value, ok, err = s.storage.RPop(ctx, key)
+		default:
+			value, ok, err = s.storage.BLPop(ctx, key, query.Timeout)
+			value = key + "\n" + value
+		}
+
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", ErrNotFound
+		}
+
+		return value, nil
+	case compute.CommandLRange:
+		// indexes are validated by parser
+		start, _ := strconv.Atoi(query.Args[1])
+		stop, _ := strconv.Atoi(query.Args[2])
+
+		values, err := s.storage.LRange(key, start, stop)
+		if err != nil {
+			return "", err
+		}
+
+		return strings.Join(values, "\n"), nil
+	default:
+		length, err := s.storage.LLen(key)
+		if err != nil {
+			return "", err
+		}
+
+		return strconv.Itoa(length), nil
+	}
+}
+
+// executeZSet handles sorted set commands, ZADD, ZREM, ZREMRANGEBYSCORE and
+// ZCARD return number of members, members of ZRANGE and ZRANGEBYSCORE are
+// returned one per line, each followed by its score if WITHSCORES is set
+func (s *database) executeZSet(ctx context.Context, query compute.Query) (string, error) {
+	key := query.Args[0]
+	withScores := len(query.Args) == 4
+
+	switch query.Command {
+	case compute.CommandZAdd:
+		members, err := storage.ParseZMembers(query.Args[1:])
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", ErrParse, err)
+		}
+
+		added, err := s.storage.ZAdd(ctx, key, members)
+		if err != nil {
+			return "", err
+		}
+
+		return strconv.Itoa(added), nil
+	case compute.CommandZRem:
+		removed, err := s.storage.ZRem(ctx, key, query.Args[1:])
+		if err != nil {
+			return "", err
+		}
+
+		return strconv.Itoa(removed), nil
+	case compute.CommandZScore:
+		score, ok, err := s.storage.ZScore(key, query.Args[1])
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", ErrNotFound
+		}
+
+		return compute.FormatScore(score), nil
+	case compute.CommandZRange:
+		// ranks are validated by parser
+		start, _ := strconv.Atoi(query.Args[1])
+		stop, _ := strconv.Atoi(query.Args[2])
+
+		members, err := s.storage.ZRange(key, start, stop)
+		if err != nil {
+			return "", err
+		}
+
+		return joinZMembers(members, withScores), nil
+	case compute.CommandZRangeByScore:
+		r, err := storage.NewScoreRange(query.Args[1], query.Args[2])
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", ErrParse, err)
+		}
+
+		members, err := s.storage.ZRangeByScore(key, r)
+		if err != nil {
+			return "", err
+		}
+
+		return joinZMembers(members, withScores), nil
+	case compute.CommandZRemRangeByScore:
+		r, err := storage.NewScoreRange(query.Args[1], query.Args[2])
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", ErrParse, err)
+		}
+
+		removed, err := s.storage.ZRemRangeByScore(ctx, key, r)
+		if err != nil {
+			return "", err
+		}
+
+		return strconv.Itoa(removed), nil
+	default:
+		count, err := s.storage.ZCard(key)
+		if err != nil {
+			return "", err
+		}
+
+		return strconv.Itoa(count), nil
+	}
+}
+
+// joinZMembers returns members one per line, each followed by its score if
+// withScores is set
+func joinZMembers(members []storage.ZMember, withScores bool) string {
+	lines := make([]string, 0, 2*len(members))
+	for _, member := range members {
+		lines = append(lines, member.Member)
+		if withScores {
+			lines = append(lines, compute.FormatScore(member.Score))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// executeSlowLog handles subcommands of SLOWLOG, entries are returned one
+// per line from the newest one
+func (s *database) executeSlowLog(query compute.Query) (string, error) {
+	if s.slowLog == nil {
+		return "", errors.New("SLOWLOG is not enabled")
+	}
+
+	switch query.Args[0] {
+	case compute.SlowLogGet:
+		count := defaultSlowLogCount
+		if len(query.Args) > 1 {
+			count, _ = strconv.Atoi(query.Args[1])
+		}
+
+		entries := s.slowLog.Get(count)
+		lines := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			lines = append(lines, entry.String())
+		}
+
+		return strings.Join(lines, "\n"), nil
+	case compute.SlowLogLen:
+		return strconv.Itoa(s.slowLog.Len()), nil
+	default:
+		s.slowLog.Reset()
+		return resultOK, nil
+	}
+}
+
+func (s *database) waitReplica(ctx context.Context, query compute.Query) error {
+	if s.replica == nil {
+		return nil
+	}
+
+	if query.MinLSN != 0 {
+		if err := s.replica.WaitLSN(ctx, query.MinLSN); err != nil {
+			return err
+		}
+	}
+
+	if query.MaxLag != 0 {
+		if err := s.replica.WaitLag(ctx, query.MaxLag); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Position returns applied position of slave replica
+func (s *database) Position() (Position, bool) {
+	if s.replica == nil {
+		return Position{}, false
+	}
+
+	lag, ok := s.replica.Lag()
+	return Position{AppliedLSN: s.replica.AppliedLSN(), Lag: lag, LagKnown: ok}, true
+}
@@ -1,10 +1,16 @@
 package database
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	"concurrency_go_course/internal/auth"
 	"concurrency_go_course/internal/compute"
+	"concurrency_go_course/internal/info"
 	"concurrency_go_course/internal/storage"
 	"concurrency_go_course/internal/storage/mock"
 	"concurrency_go_course/pkg/logger"
@@ -42,14 +48,15 @@ func TestServiceHandleNeg(t *testing.T) {
 		"empty request": {
 			in:   "",
 			res:  "",
-			err:  fmt.Errorf("invalid query length (0)"),
+			err:  fmt.Errorf("%w: %w", ErrParse, fmt.Errorf("invalid query length (0)")),
 			exec: func() {},
 		},
 		"GET: no value": {
 			in:  "GET unknown",
 			res: "",
 			exec: func() {
-				mockEngine.EXPECT().Get("unknown").Return("", false)
+				mockEngine.EXPECT().Deadline("unknown").Return(int64(0))
+				mockEngine.EXPECT().Get("unknown").Return("", false, nil)
 			},
 			err: fmt.Errorf("value not found"),
 		},
@@ -58,7 +65,7 @@ func TestServiceHandleNeg(t *testing.T) {
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
 			test.exec()
-			res, err := service.Handle(test.in)
+			res, err := service.Handle(context.Background(), test.in)
 
 			assert.Equal(t, err, test.err)
 			assert.Equal(t, res, test.res)
@@ -97,7 +104,8 @@ func TestServiceHandlePos(t *testing.T) {
 			res: "value1",
 			err: nil,
 			exec: func() {
-				mockEngine.EXPECT().Get("key1").Return("value1", true)
+				mockEngine.EXPECT().Deadline("key1").Return(int64(0))
+				mockEngine.EXPECT().Get("key1").Return("value1", true, nil)
 			},
 		},
 		"SET: correct result": {
@@ -121,10 +129,515 @@ func TestServiceHandlePos(t *testing.T) {
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
 			test.exec()
-			res, err := service.Handle(test.in)
+			res, err := service.Handle(context.Background(), test.in)
 
 			assert.Equal(t, err, test.err)
 			assert.Equal(t, res, test.res)
 		})
 	}
 }
+
+type fakeReplica struct {
+	lsn uint64
+	lag time.Duration
+}
+
+func (r *fakeReplica) AppliedLSN() uint64 {
+	return r.lsn
+}
+
+func (r *fakeReplica) Lag() (time.Duration, bool) {
+	return r.lag, true
+}
+
+func (r *fakeReplica) WaitLSN(_ context.Context, lsn uint64) error {
+	if lsn > r.lsn {
+		return errors.New("replica too stale")
+	}
+	return nil
+}
+
+func (r *fakeReplica) WaitLag(_ context.Context, maxLag time.Duration) error {
+	if r.lag > maxLag {
+		return errors.New("replica too stale")
+	}
+	return nil
+}
+
+func TestReplicaHandle(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockEngine := mock.NewMockEngine(ctrl)
+
+	storage, err := storage.New(mockEngine, nil, "slave", nil)
+	if err != nil {
+		t.Errorf("unable to create storage")
+	}
+
+	parser := compute.NewRequestParser()
+	compute := compute.NewCompute(parser)
+
+	service := NewReplicaDatabase(storage, compute, &fakeReplica{lsn: 10, lag: 20 * time.Millisecond})
+
+	tests := map[string]struct {
+		in   string
+		res  string
+		err  error
+		exec func()
+	}{
+		"GET: value": {
+			in:  "GET key1",
+			res: "value1",
+			exec: func() {
+				mockEngine.EXPECT().Deadline("key1").Return(int64(0))
+				mockEngine.EXPECT().Get("key1").Return("value1", true, nil)
+			},
+		},
+		"GET: applied MINLSN": {
+			in:  "GET key1 MINLSN 10 MAXLAG 1s",
+			res: "value1",
+			exec: func() {
+				mockEngine.EXPECT().Deadline("key1").Return(int64(0))
+				mockEngine.EXPECT().Get("key1").Return("value1", true, nil)
+			},
+		},
+		"GET: not applied MINLSN": {
+			in:   "GET key1 MINLSN 11",
+			err:  fmt.Errorf("replica too stale"),
+			exec: func() {},
+		},
+		"GET: too big lag": {
+			in:   "GET key1 MAXLAG 10ms",
+			err:  fmt.Errorf("replica too stale"),
+			exec: func() {},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.exec()
+			res, err := service.Handle(context.Background(), test.in)
+
+			if test.err != nil {
+				assert.EqualError(t, err, test.err.Error())
+			} else {
+				assert.Nil(t, err)
+			}
+			assert.Equal(t, test.res, res)
+		})
+	}
+
+	// position is reported separately from value
+	position, ok := service.Position()
+	assert.True(t, ok)
+	assert.Equal(t, Position{AppliedLSN: 10, Lag: 20 * time.Millisecond, LagKnown: true}, position)
+
+	_, ok = NewDatabase(storage, compute).Position()
+	assert.False(t, ok)
+}
+
+// fakeAuthorizer allows only user alice to write keys with prefix allowed:
+type fakeAuthorizer struct{}
+
+func (fakeAuthorizer) Authorize(user string, category auth.Category, key string) error {
+	if category == auth.CategoryRead || user == "alice" && strings.HasPrefix(key, "allowed:") {
+		return nil
+	}
+	return auth.ErrPermissionDenied
+}
+
+func TestHandleAuthorize(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockEngine := mock.NewMockEngine(ctrl)
+
+	storage, err := storage.New(mockEngine, nil, "master", nil)
+	if err != nil {
+		t.Errorf("unable to create storage")
+	}
+
+	service := NewDatabase(storage, compute.NewCompute(compute.NewRequestParser()),
+		WithAuthorizer(fakeAuthorizer{}))
+
+	alice := auth.ContextWithUser(context.Background(), "alice")
+
+	tests := map[string]struct {
+		ctx  context.Context
+		in   string
+		res  string
+		err  error
+		exec func()
+	}{
+		"GET: read is allowed to anonymous": {
+			ctx: context.Background(),
+			in:  "GET key1",
+			res: "value1",
+			exec: func() {
+				mockEngine.EXPECT().Deadline("key1").Return(int64(0))
+				mockEngine.EXPECT().Get("key1").Return("value1", true, nil)
+			},
+		},
+		"SET: allowed key": {
+			ctx: alice,
+			in:  "SET allowed:1 value1",
+			res: "OK",
+			exec: func() {
+				mockEngine.EXPECT().Set("allowed:1", "value1").Return()
+			},
+		},
+		"DEL: denied key is not deleted": {
+			ctx:  alice,
+			in:   "DEL other:1",
+			err:  ErrPermissionDenied,
+			exec: func() {},
+		},
+		"SET: anonymous is denied": {
+			ctx:  context.Background(),
+			in:   "SET allowed:1 value1",
+			err:  ErrPermissionDenied,
+			exec: func() {},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.exec()
+			res, err := service.Handle(test.ctx, test.in)
+
+			assert.ErrorIs(t, err, test.err)
+			assert.Equal(t, test.res, res)
+		})
+	}
+
+	// parsed queries of gateways are checked too
+	_, err = service.HandleQuery(context.Background(), compute.NewQuery(compute.CommandDelete, []string{"key1"}))
+	assert.ErrorIs(t, err, ErrPermissionDenied)
+}
+
+func TestHandleInfo(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	storage, err := storage.New(storage.NewEngine(1), nil, "master", nil)
+	if err != nil {
+		t.Errorf("unable to create storage")
+	}
+
+	nodeInfo := info.New()
+	nodeInfo.Add(info.SectionServer, func() []info.Field {
+		return []info.Field{info.String("version", "dev")}
+	})
+
+	service := NewDatabase(storage, compute.NewCompute(compute.NewRequestParser()), WithInfo(nodeInfo))
+
+	tests := map[string]struct {
+		in  string
+		res string
+		err error
+	}{
+		"all sections":    {in: "INFO", res: "# server\nversion:dev"},
+		"section":         {in: "INFO server", res: "# server\nversion:dev"},
+		"unknown section": {in: "INFO memory", err: ErrParse},
+		"too many args":   {in: "INFO server memory", err: ErrParse},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			res, err := service.Handle(context.Background(), test.in)
+
+			assert.ErrorIs(t, err, test.err)
+			assert.Equal(t, test.res, res)
+		})
+	}
+
+	t.Run("admin access is required", func(t *testing.T) {
+		service := NewDatabase(storage, compute.NewCompute(compute.NewRequestParser()),
+			WithInfo(nodeInfo), WithAuthorizer(fakeAuthorizer{}))
+
+		_, err := service.Handle(auth.ContextWithUser(context.Background(), "alice"), "INFO")
+		assert.ErrorIs(t, err, ErrPermissionDenied)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		service := NewDatabase(storage, compute.NewCompute(compute.NewRequestParser()))
+
+		_, err := service.Handle(context.Background(), "INFO")
+		assert.Error(t, err)
+	})
+}
+
+// fakePublisher counts published messages, every channel has one subscriber
+type fakePublisher struct {
+	published []string
+}
+
+func (p *fakePublisher) Publish(channel, payload string) int {
+	p.published = append(p.published, channel+" "+payload)
+	return 1
+}
+
+func TestHandlePublish(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	storage, err := storage.New(storage.NewEngine(1), nil, "master", nil)
+	if err != nil {
+		t.Errorf("unable to create storage")
+	}
+
+	publisher := &fakePublisher{}
+	service := NewDatabase(storage, compute.NewCompute(compute.NewRequestParser()),
+		WithPublisher(publisher), WithAuthorizer(fakeAuthorizer{}))
+
+	alice := auth.ContextWithUser(context.Background(), "alice")
+
+	res, err := service.Handle(alice, "PUBLISH allowed:news hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", res)
+	assert.Equal(t, []string{"allowed:news hello"}, publisher.published)
+
+	_, err = service.Handle(alice, "PUBLISH news hello")
+	assert.ErrorIs(t, err, ErrPermissionDenied)
+
+	t.Run("disabled", func(t *testing.T) {
+		service := NewDatabase(storage, compute.NewCompute(compute.NewRequestParser()))
+
+		_, err := service.Handle(context.Background(), "PUBLISH news hello")
+		assert.Error(t, err)
+	})
+
+	t.Run("keyspace notifications", func(t *testing.T) {
+		publisher := &fakePublisher{}
+		service := NewDatabase(storage, compute.NewCompute(compute.NewRequestParser()), WithPublisher(publisher))
+
+		_, err := service.Handle(context.Background(), "PUBLISH __keyspace__:user:1 del")
+		assert.ErrorIs(t, err, ErrParse)
+		_, err = service.Handle(context.Background(), "PUBLISH __keyevent__:del user:1")
+		assert.ErrorIs(t, err, ErrParse)
+		assert.Empty(t, publisher.published)
+	})
+}
+
+func TestHandleExpire(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	storage, err := storage.New(storage.NewEngine(4), nil, "master", nil)
+	if err != nil {
+		t.Errorf("unable to create storage")
+	}
+
+	service := NewDatabase(storage, compute.NewCompute(compute.NewRequestParser()))
+
+	steps := []struct {
+		in  string
+		res string
+		err error
+	}{
+		{in: "TTL key", res: "-2"},
+		{in: "EXPIRE key 10", res: "0"},
+		{in: "SET key value", res: "OK"},
+		{in: "TTL key", res: "-1"},
+		{in: "EXPIRE key 10", res: "1"},
+		{in: "TTL key", res: "10"},
+		{in: "SET key value", res: "OK"},
+		{in: "TTL key", res: "-1"},
+		{in: "HSET user name alice", res: "1"},
+		{in: "EXPIRE user 100", res: "1"},
+		{in: "HSET user age 30", res: "1"},
+		{in: "TTL user", res: "100"},
+		{in: "DEL user", res: "OK"},
+		{in: "TTL user", res: "-2"},
+	}
+
+	for _, step := range steps {
+		res, err := service.Handle(context.Background(), step.in)
+		if step.err != nil {
+			assert.ErrorIs(t, err, step.err, step.in)
+			continue
+		}
+
+		assert.NoError(t, err, step.in)
+		assert.Equal(t, step.res, res, step.in)
+	}
+}
+
+func TestHandleHash(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	storage, err := storage.New(storage.NewEngine(4), nil, "master", nil)
+	if err != nil {
+		t.Errorf("unable to create storage")
+	}
+
+	service := NewDatabase(storage, compute.NewCompute(compute.NewRequestParser()))
+
+	steps := []struct {
+		in  string
+		res string
+		err error
+	}{
+		{in: "HSET user name alice age 30", res: "2"},
+		{in: "HSET user age 31 city paris", res: "1"},
+		{in: "HGET user age", res: "31"},
+		{in: "HGET user email", err: ErrNotFound},
+		{in: "HGETALL user", res: "age\n31\ncity\nparis\nname\nalice"},
+		{in: "HGETALL missing", res: ""},
+		{in: "GET user", err: ErrWrongType},
+		{in: "HDEL user age email", res: "1"},
+		{in: "HDEL user city name", res: "2"},
+		{in: "HGETALL user", res: ""},
+		{in: "SET key value", res: "OK"},
+		{in: "HSET key field value", err: ErrWrongType},
+		{in: "HGET key field", err: ErrWrongType},
+		{in: "HDEL key field", err: ErrWrongType},
+		{in: "HGETALL key", err: ErrWrongType},
+		{in: "DEL key", res: "OK"},
+		{in: "HSET key field value", res: "1"},
+		{in: "SET key value", res: "OK"},
+		{in: "GET key", res: "value"},
+	}
+
+	for _, step := range steps {
+		res, err := service.Handle(context.Background(), step.in)
+		if step.err != nil {
+			assert.ErrorIs(t, err, step.err, step.in)
+			continue
+		}
+
+		assert.NoError(t, err, step.in)
+		assert.Equal(t, step.res, res, step.in)
+	}
+}
+
+func TestHandleList(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	storage, err := storage.New(storage.NewEngine(4), nil, "master", nil)
+	if err != nil {
+		t.Errorf("unable to create storage")
+	}
+
+	service := NewDatabase(storage, compute.NewCompute(compute.NewRequestParser()))
+
+	steps := []struct {
+		in  string
+		res string
+		err error
+	}{
+		{in: "LPUSH jobs b a", res: "2"},
+		{in: "RPUSH jobs c d", res: "4"},
+		{in: "LLEN jobs", res: "4"},
+		{in: "LRANGE jobs 0 -1", res: "a\nb\nc\nd"},
+		{in: "LRANGE jobs -2 10", res: "c\nd"},
+		{in: "LPOP jobs", res: "a"},
+		{in: "RPOP jobs", res: "d"},
+		{in: "BLPOP jobs 1", res: "jobs\nb"},
+		{in: "LPOP jobs", res: "c"},
+		{in: "LPOP jobs", err: ErrNotFound},
+		{in: "BLPOP jobs 0.01", err: ErrNotFound},
+		{in: "LLEN jobs", res: "0"},
+		{in: "SET key value", res: "OK"},
+		{in: "LPUSH key a", err: ErrWrongType},
+		{in: "LRANGE key 0 -1", err: ErrWrongType},
+		{in: "BLPOP key 0", err: ErrWrongType},
+	}
+
+	for _, step := range steps {
+		res, err := service.Handle(context.Background(), step.in)
+		if step.err != nil {
+			assert.ErrorIs(t, err, step.err, step.in)
+			continue
+		}
+
+		assert.NoError(t, err, step.in)
+		assert.Equal(t, step.res, res, step.in)
+	}
+}
+
+func TestHandleZSet(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	storage, err := storage.New(storage.NewEngine(4), nil, "master", nil)
+	if err != nil {
+		t.Errorf("unable to create storage")
+	}
+
+	service := NewDatabase(storage, compute.NewCompute(compute.NewRequestParser()))
+
+	steps := []struct {
+		in  string
+		res string
+		err error
+	}{
+		{in: "ZADD board 30 alice 10 bob", res: "2"},
+		{in: "ZADD board 40 bob 20.5 carol", res: "1"},
+		{in: "ZSCORE board carol", res: "20.5"},
+		{in: "ZSCORE board dave", err: ErrNotFound},
+		{in: "ZRANGE board 0 -1", res: "carol\nalice\nbob"},
+		{in: "ZRANGE board -1 -1 WITHSCORES", res: "bob\n40"},
+		{in: "ZRANGEBYSCORE board (20.5 +inf WITHSCORES", res: "alice\n30\nbob\n40"},
+		{in: "ZRANGEBYSCORE board 50 60", res: ""},
+		{in: "ZCARD board", res: "3"},
+		{in: "ZREM board alice dave", res: "1"},
+		{in: "ZREMRANGEBYSCORE board -inf 25", res: "1"},
+		{in: "ZRANGE board 0 -1", res: "bob"},
+		{in: "ZCARD missing", res: "0"},
+		{in: "SET key value", res: "OK"},
+		{in: "ZADD key 1 a", err: ErrWrongType},
+		{in: "ZRANGE key 0 -1", err: ErrWrongType},
+		{in: "ZCARD key", err: ErrWrongType},
+	}
+
+	for _, step := range steps {
+		res, err := service.Handle(context.Background(), step.in)
+		if step.err != nil {
+			assert.ErrorIs(t, err, step.err, step.in)
+			continue
+		}
+
+		assert.NoError(t, err, step.in)
+		assert.Equal(t, step.res, res, step.in)
+	}
+}
+
+func TestCommandStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		err    error
+		status string
+	}{
+		"success":           {status: "ok"},
+		"not found":         {err: ErrNotFound, status: "not_found"},
+		"permission denied": {err: fmt.Errorf("%w: no write access", ErrPermissionDenied), status: "permission_denied"},
+		"read only replica": {err: ErrReadOnlyReplica, status: "read_only"},
+		"wrong type":        {err: ErrWrongType, status: "wrong_type"},
+		"other error":       {err: errors.New("disk is full"), status: "error"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.status, commandStatus(test.err))
+		})
+	}
+}
@@ -6,8 +6,22 @@ import (
 	"path/filepath"
 	"regexp"
 	"slices"
+	"time"
+
+	"concurrency_go_course/pkg/metrics"
 )
 
+var fsyncDuration = metrics.Register(metrics.NewHistogramVec("wal_fsync_duration_seconds",
+	"Duration of fsync of WAL segment files.", metrics.DurationBuckets))
+
+var segmentNameRe = regexp.MustCompile(`^wal_\d+\.log$`)
+
+// IsSegmentName reports whether name is a name of WAL segment file without
+// directory
+func IsSegmentName(name string) bool {
+	return segmentNameRe.MatchString(name)
+}
+
 // FileLib is interface for file management lib
 type FileLib interface {
 	CreateFile(filename string) (*os.File, error)
@@ -44,7 +58,10 @@ func (f *filelib) WriteFile(file *os.File, data []byte) (int, error) {
 		return 0, err
 	}
 
-	if err = file.Sync(); err != nil {
+	start := time.Now()
+	err = file.Sync()
+	fsyncDuration.WithLabelValues().ObserveSince(start)
+	if err != nil {
 		return 0, err
 	}
 
@@ -75,13 +92,12 @@ func (f *filelib) FilenamesFromDir(dir string) ([]string, error) {
 	}
 
 	fileNames := make([]string, 0, len(files))
-	re := regexp.MustCompile(`wal_\d+\.log`)
 
 	for _, file := range files {
 		if file.IsDir() {
 			continue
 		}
-		if !re.MatchString(file.Name()) {
+		if !IsSegmentName(file.Name()) {
 			continue
 		}
 		fileNames = append(fileNames, file.Name())
@@ -109,10 +125,10 @@ func (f *filelib) SegmentNext(dir, filename string) (string, error) {
 		return "", err
 	}
 
-	// Get newest WAL
-	for i := len(wals) - 1; i >= 0; i-- {
-		if wals[i] > filename {
-			return wals[i], nil
+	// Get the oldest WAL after filename, segments are sorted by name
+	for _, wal := range wals {
+		if wal > filename {
+			return wal, nil
 		}
 	}
 
@@ -106,10 +106,10 @@ func (f *mockfilelib) SegmentNext(dir, filename string) (string, error) {
 		return "", err
 	}
 
-	// Get newest WAL
-	for i := len(wals) - 1; i >= 0; i-- {
-		if wals[i] > filename {
-			return wals[i], nil
+	// Get the oldest WAL after filename, segments are sorted by name
+	for _, wal := range wals {
+		if wal > filename {
+			return wal, nil
 		}
 	}
 
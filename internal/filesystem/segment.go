@@ -1,19 +1,27 @@
 package filesystem
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"time"
+
+	"concurrency_go_course/pkg/trace"
 )
 
 // Segment is interface for segment
 type Segment interface {
-	Write(data []byte) error
+	Write(ctx context.Context, data []byte) error
 	ReadAll() ([][]byte, error)
+	// Name returns name of segment file which is written, it is empty
+	// before the first write
+	Name() string
 }
 
 type segment struct {
 	file      *os.File
+	name      string
 	directory string
 
 	segmentSize    int
@@ -32,11 +40,18 @@ func NewSegment(directory string, maxSegmentSize int, fileLib FileLib) Segment {
 }
 
 // Write writes bytes of segment
-func (s *segment) Write(data []byte) error {
+func (s *segment) Write(ctx context.Context, data []byte) (err error) {
+	_, span := trace.Start(ctx, "segment.Write", trace.WithAttributes(trace.Int("segment.bytes", len(data))))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
 	if s.file == nil || s.segmentSize >= s.maxSegmentSize {
 		if err := s.createSegment(); err != nil {
 			return fmt.Errorf("failed to create segment file: %w", err)
 		}
+		span.SetAttributes(trace.Bool("segment.rotated", true))
 	}
 
 	writtenBytes, err := s.fileLib.WriteFile(s.file, data)
@@ -63,10 +78,16 @@ func (s *segment) createSegment() error {
 	}
 
 	s.file = file
+	s.name = filepath.Base(segmentName)
 	s.segmentSize = 0
 	return nil
 }
 
+// Name returns name of segment file which is written
+func (s *segment) Name() string {
+	return s.name
+}
+
 // ReadAll reads all data from dir
 func (s *segment) ReadAll() ([][]byte, error) {
 	filenames, err := s.fileLib.FilenamesFromDir(s.directory)
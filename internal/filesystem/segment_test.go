@@ -1,6 +1,7 @@
 package filesystem
 
 import (
+	"context"
 	"os"
 	"strings"
 	"testing"
@@ -32,12 +33,12 @@ func TestSegmentWrite(t *testing.T) {
 
 	segment := NewSegment(testDataDir, 10, mockFileLib)
 
-	err = segment.Write([]byte("aaaaa"))
+	err = segment.Write(context.Background(), []byte("aaaaa"))
 	if err != nil {
 		t.Errorf("unable to write test data: %s", err)
 	}
 
-	err = segment.Write([]byte("bbbbb"))
+	err = segment.Write(context.Background(), []byte("bbbbb"))
 	if err != nil {
 		t.Errorf("unable to write test data: %s", err)
 	}
@@ -0,0 +1,396 @@
+// Package grpcapi implements gRPC service of key-value store
+package grpcapi
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"concurrency_go_course/internal/auth"
+	"concurrency_go_course/internal/compute"
+	"concurrency_go_course/internal/database"
+	"concurrency_go_course/internal/replication"
+	"concurrency_go_course/internal/watch"
+	kvv1 "concurrency_go_course/pkg/api/kv/v1"
+	"concurrency_go_course/pkg/logger"
+)
+
+const (
+	// maxBatchSize limits number of commands in one batch call
+	maxBatchSize = 1000
+	// shutdownTimeout limits graceful stop, calls in flight are canceled after it
+	shutdownTimeout = 5 * time.Second
+	// authorizationKey is a metadata key of credentials, its value is
+	// "Basic " and base64 of "user:password" like in HTTP
+	authorizationKey = "authorization"
+)
+
+// Server implements KV service over database queries, so values go through
+// the same storage path as text and RESP protocols
+type Server struct {
+	kvv1.UnimplementedKVServer
+
+	db    database.Database
+	hub   *watch.Hub
+	users *auth.Users
+
+	// done is closed on shutdown to end watch streams
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// Option is an option of KV service
+type Option func(*Server)
+
+// WithUsers enables authentication by authorization metadata, calls
+// without valid credentials fail with Unauthenticated, calls without
+// metadata are served if users allow anonymous clients. Calls and watch
+// streams are checked with roles of user
+func WithUsers(users *auth.Users) Option {
+	return func(s *Server) {
+		s.users = users
+	}
+}
+
+// NewServer returns new KV service, watch is enabled if hub is set
+func NewServer(db database.Database, hub *watch.Hub, options ...Option) (*Server, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database is empty")
+	}
+
+	s := &Server{
+		db:   db,
+		hub:  hub,
+		done: make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s, nil
+}
+
+// Get returns value by key
+func (s *Server) Get(ctx context.Context, request *kvv1.GetRequest) (*kvv1.GetResponse, error) {
+	value, err := s.execute(ctx, getFields(request))
+	if err != nil {
+		return nil, err
+	}
+
+	return &kvv1.GetResponse{Value: value}, nil
+}
+
+// Set sets value for key
+func (s *Server) Set(ctx context.Context, request *kvv1.SetRequest) (*kvv1.SetResponse, error) {
+	if _, err := s.execute(ctx, setFields(request)); err != nil {
+		return nil, err
+	}
+
+	return &kvv1.SetResponse{}, nil
+}
+
+// Delete deletes key
+func (s *Server) Delete(ctx context.Context, request *kvv1.DeleteRequest) (*kvv1.DeleteResponse, error) {
+	if _, err := s.execute(ctx, deleteFields(request)); err != nil {
+		return nil, err
+	}
+
+	return &kvv1.DeleteResponse{}, nil
+}
+
+// Batch executes commands in order, errors are returned per command
+func (s *Server) Batch(ctx context.Context, request *kvv1.BatchRequest) (*kvv1.BatchResponse, error) {
+	if len(request.GetCommands()) > maxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"batch contains %d commands, max is %d", len(request.GetCommands()), maxBatchSize)
+	}
+
+	response := &kvv1.BatchResponse{
+		Results: make([]*kvv1.CommandResult, 0, len(request.GetCommands())),
+	}
+
+	for _, command := range request.GetCommands() {
+		var fields []string
+		switch c := command.GetCommand().(type) {
+		case *kvv1.Command_Get:
+			fields = getFields(c.Get)
+		case *kvv1.Command_Set:
+			fields = setFields(c.Set)
+		case *kvv1.Command_Delete:
+			fields = deleteFields(c.Delete)
+		}
+
+		value, err := s.execute(ctx, fields)
+		if err != nil {
+			st := status.Convert(err)
+			response.Results = append(response.Results, &kvv1.CommandResult{
+				Code:    int32(st.Code()),
+				Message: st.Message(),
+			})
+			continue
+		}
+
+		result := &kvv1.CommandResult{Code: int32(codes.OK)}
+		if command.GetGet() != nil {
+			result.Value = value
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	return response, nil
+}
+
+// Watch streams changes of keys with prefix
+func (s *Server) Watch(request *kvv1.WatchRequest, stream grpc.ServerStreamingServer[kvv1.WatchEvent]) error {
+	if s.hub == nil {
+		return status.Error(codes.Unimplemented, "watch is disabled")
+	}
+
+	if s.users != nil {
+		// prefix is checked as pattern, so user needs read access to
+		// every key which may be streamed
+		user := auth.UserFromContext(stream.Context())
+		if err := s.users.AuthorizePattern(user, auth.CategoryRead, request.GetPrefix()+"*"); err != nil {
+			return status.Error(codes.PermissionDenied, err.Error())
+		}
+	}
+
+	sub := s.hub.Subscribe(request.GetPrefix())
+	defer sub.Close()
+
+	// headers tell client that subscription is registered
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.done:
+			return status.Error(codes.Unavailable, "server is stopping")
+		case event, ok := <-sub.Events():
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "watch stream is too slow")
+			}
+
+			if err := stream.Send(watchEvent(event)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// execute parses and handles query, errors are converted to gRPC status
+func (s *Server) execute(ctx context.Context, fields []string) (string, error) {
+	if len(fields) < 2 {
+		return "", status.Error(codes.InvalidArgument, "command is empty")
+	}
+
+	if fields[1] == "" {
+		return "", status.Error(codes.InvalidArgument, "key is empty")
+	}
+
+	query, err := compute.ParseFields(fields)
+	if err != nil {
+		return "", status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	result, err := s.db.HandleQuery(ctx, query)
+	if err != nil {
+		return "", toStatus(err)
+	}
+
+	return result, nil
+}
+
+func getFields(request *kvv1.GetRequest) []string {
+	fields := []string{compute.CommandGet, request.GetKey()}
+
+	if request.GetMinLsn() != 0 {
+		fields = append(fields, compute.OptionMinLSN, strconv.FormatUint(request.GetMinLsn(), 10))
+	}
+
+	if request.GetMaxLag() != nil {
+		fields = append(fields, compute.OptionMaxLag, request.GetMaxLag().AsDuration().String())
+	}
+
+	return fields
+}
+
+func setFields(request *kvv1.SetRequest) []string {
+	return []string{compute.CommandSet, request.GetKey(), request.GetValue()}
+}
+
+func deleteFields(request *kvv1.DeleteRequest) []string {
+	return []string{compute.CommandDelete, request.GetKey()}
+}
+
+func watchEvent(event watch.Event) *kvv1.WatchEvent {
+	eventType := kvv1.WatchEvent_TYPE_UNSPECIFIED
+	switch event.Type {
+	case watch.EventSet:
+		eventType = kvv1.WatchEvent_TYPE_SET
+	case watch.EventDelete:
+		eventType = kvv1.WatchEvent_TYPE_DELETE
+	}
+
+	return &kvv1.WatchEvent{
+		Type:  eventType,
+		Key:   event.Key,
+		Value: event.Value,
+	}
+}
+
+// toStatus maps database error to gRPC status
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, database.ErrReadOnlyReplica):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, database.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, database.ErrWrongType):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, replication.ErrReplicaTooStale):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		logger.Error("unable to handle gRPC request", zap.Error(err))
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// serverOptions returns options of gRPC server which authenticate calls
+func (s *Server) serverOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(s.unaryInterceptor),
+		grpc.StreamInterceptor(s.streamInterceptor),
+	}
+}
+
+func (s *Server) unaryInterceptor(ctx context.Context, request any, _ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+	ctx, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, request)
+}
+
+func (s *Server) streamInterceptor(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	ctx, err := s.authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+}
+
+// authenticate checks credentials of call and returns context with user,
+// context is returned as is if authentication is disabled
+func (s *Server) authenticate(ctx context.Context) (context.Context, error) {
+	if s.users == nil {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(authorizationKey)
+	if len(values) == 0 && s.users.AllowsAnonymous() {
+		return ctx, nil
+	}
+
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "authentication required")
+	}
+
+	name, password, ok := parseBasicAuth(values[0])
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "invalid authorization metadata")
+	}
+
+	if err := s.users.Authenticate(name, password); err != nil {
+		logger.Warn("gRPC authentication failed", zap.String("user", name))
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	return auth.ContextWithUser(ctx, name), nil
+}
+
+// parseBasicAuth parses "Basic " and base64 of "user:password"
+func parseBasicAuth(value string) (string, string, bool) {
+	const prefix = "Basic "
+	if len(value) < len(prefix) || !strings.EqualFold(value[:len(prefix)], prefix) {
+		return "", "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(value[len(prefix):])
+	if err != nil {
+		return "", "", false
+	}
+
+	return strings.Cut(string(decoded), ":")
+}
+
+// authenticatedStream is a server stream with context of authenticated user
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// stop ends watch streams
+func (s *Server) stop() {
+	s.stopOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// Serve listens on address until context is done
+func Serve(ctx context.Context, address string, server *Server) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		logger.ErrorWithMsg("unable to listen gRPC address:", err)
+		return
+	}
+
+	grpcServer := grpc.NewServer(server.serverOptions()...)
+	kvv1.RegisterKVServer(grpcServer, server)
+
+	go func() {
+		<-ctx.Done()
+
+		server.stop()
+
+		timer := time.AfterFunc(shutdownTimeout, grpcServer.Stop)
+		defer timer.Stop()
+
+		grpcServer.GracefulStop()
+	}()
+
+	logger.Info("gRPC server is listening", zap.String("address", address))
+
+	if err = grpcServer.Serve(listener); err != nil {
+		logger.ErrorWithMsg("gRPC server error:", err)
+	}
+}
@@ -0,0 +1,324 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"concurrency_go_course/internal/auth"
+	"concurrency_go_course/internal/compute"
+	"concurrency_go_course/internal/database"
+	"concurrency_go_course/internal/replication"
+	"concurrency_go_course/internal/storage"
+	"concurrency_go_course/internal/watch"
+	kvv1 "concurrency_go_course/pkg/api/kv/v1"
+	"concurrency_go_course/pkg/logger"
+)
+
+func newTestClient(t *testing.T, replicaType string) kvv1.KVClient {
+	t.Helper()
+
+	return newTestClientWithUsers(t, replicaType, nil)
+}
+
+// newTestClientWithUsers returns client of server which authenticates
+// calls and checks their access if users are set
+func newTestClientWithUsers(t *testing.T, replicaType string, users *auth.Users) kvv1.KVClient {
+	t.Helper()
+
+	logger.MockLogger()
+
+	hub := watch.NewHub()
+	store, err := storage.New(watch.NewEngine(storage.NewEngine(4), hub), nil, replicaType, nil)
+	require.NoError(t, err)
+
+	var dbOptions []database.Option
+	var options []Option
+	if users != nil {
+		dbOptions = append(dbOptions, database.WithAuthorizer(users))
+		options = append(options, WithUsers(users))
+	}
+
+	db := database.NewDatabase(store, compute.NewCompute(compute.NewRequestParser()), dbOptions...)
+	server, err := NewServer(db, hub, options...)
+	require.NoError(t, err)
+
+	listener := bufconn.Listen(1024 * 1024)
+	grpcServer := grpc.NewServer(server.serverOptions()...)
+	kvv1.RegisterKVServer(grpcServer, server)
+	go func() {
+		_ = grpcServer.Serve(listener)
+	}()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		conn.Close()
+		server.stop()
+		grpcServer.Stop()
+	})
+
+	return kvv1.NewKVClient(conn)
+}
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	client := newTestClient(t, replication.ReplicaTypeMaster)
+	ctx := context.Background()
+
+	_, err := client.Get(ctx, &kvv1.GetRequest{Key: "key"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.Set(ctx, &kvv1.SetRequest{Key: "key", Value: "value with spaces"})
+	require.NoError(t, err)
+
+	response, err := client.Get(ctx, &kvv1.GetRequest{Key: "key"})
+	require.NoError(t, err)
+	assert.Equal(t, "value with spaces", response.GetValue())
+
+	// read options are ignored on master
+	response, err = client.Get(ctx, &kvv1.GetRequest{Key: "key", MinLsn: 10, MaxLag: durationpb.New(time.Second)})
+	require.NoError(t, err)
+	assert.Equal(t, "value with spaces", response.GetValue())
+
+	_, err = client.Delete(ctx, &kvv1.DeleteRequest{Key: "key"})
+	require.NoError(t, err)
+
+	_, err = client.Get(ctx, &kvv1.GetRequest{Key: "key"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.Set(ctx, &kvv1.SetRequest{Value: "value"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServerSlave(t *testing.T) {
+	t.Parallel()
+
+	client := newTestClient(t, replication.ReplicaTypeSlave)
+	ctx := context.Background()
+
+	_, err := client.Set(ctx, &kvv1.SetRequest{Key: "key", Value: "value"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	_, err = client.Delete(ctx, &kvv1.DeleteRequest{Key: "key"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
+
+func TestServerBatch(t *testing.T) {
+	t.Parallel()
+
+	client := newTestClient(t, replication.ReplicaTypeMaster)
+
+	response, err := client.Batch(context.Background(), &kvv1.BatchRequest{Commands: []*kvv1.Command{
+		{Command: &kvv1.Command_Set{Set: &kvv1.SetRequest{Key: "a", Value: "1"}}},
+		{Command: &kvv1.Command_Get{Get: &kvv1.GetRequest{Key: "a"}}},
+		{Command: &kvv1.Command_Get{Get: &kvv1.GetRequest{Key: "b"}}},
+		{},
+		{Command: &kvv1.Command_Delete{Delete: &kvv1.DeleteRequest{Key: "a"}}},
+	}})
+	require.NoError(t, err)
+
+	results := response.GetResults()
+	require.Len(t, results, 5)
+
+	expected := []struct {
+		code  codes.Code
+		value string
+	}{
+		{code: codes.OK},
+		{code: codes.OK, value: "1"},
+		{code: codes.NotFound},
+		{code: codes.InvalidArgument},
+		{code: codes.OK},
+	}
+	for i, e := range expected {
+		assert.Equal(t, int32(e.code), results[i].GetCode(), i)
+		assert.Equal(t, e.value, results[i].GetValue(), i)
+	}
+}
+
+func TestServerWatch(t *testing.T) {
+	t.Parallel()
+
+	client := newTestClient(t, replication.ReplicaTypeMaster)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.Watch(ctx, &kvv1.WatchRequest{Prefix: "user:"})
+	require.NoError(t, err)
+
+	// subscription is registered before headers are sent
+	_, err = stream.Header()
+	require.NoError(t, err)
+
+	_, err = client.Set(ctx, &kvv1.SetRequest{Key: "order:1", Value: "book"})
+	require.NoError(t, err)
+	_, err = client.Set(ctx, &kvv1.SetRequest{Key: "user:1", Value: "alice"})
+	require.NoError(t, err)
+	_, err = client.Delete(ctx, &kvv1.DeleteRequest{Key: "user:1"})
+	require.NoError(t, err)
+
+	event, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, kvv1.WatchEvent_TYPE_SET, event.GetType())
+	assert.Equal(t, "user:1", event.GetKey())
+	assert.Equal(t, "alice", event.GetValue())
+
+	event, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, kvv1.WatchEvent_TYPE_DELETE, event.GetType())
+	assert.Equal(t, "user:1", event.GetKey())
+}
+
+// withCredentials returns context with authorization metadata of user
+func withCredentials(ctx context.Context, user, password string) context.Context {
+	credentials := base64.StdEncoding.EncodeToString([]byte(user + ":" + password))
+	return metadata.AppendToOutgoingContext(ctx, authorizationKey, "Basic "+credentials)
+}
+
+func TestServerAuth(t *testing.T) {
+	t.Parallel()
+
+	hash, err := auth.HashPassword("secret")
+	require.NoError(t, err)
+
+	users, err := auth.NewUsers(auth.Config{
+		Users: []auth.User{{Name: "alice", PasswordHash: hash, Roles: []string{"billing"}}},
+		Roles: []auth.Role{{
+			Name: "billing",
+			Allow: []auth.Rule{{
+				Categories: []auth.Category{auth.CategoryRead, auth.CategoryWrite},
+				Keys:       []string{"billing:*"},
+			}},
+		}},
+	})
+	require.NoError(t, err)
+
+	client := newTestClientWithUsers(t, replication.ReplicaTypeMaster, users)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		key  string
+		code codes.Code
+	}{
+		{
+			name: "without credentials",
+			ctx:  context.Background(),
+			key:  "billing:1",
+			code: codes.Unauthenticated,
+		},
+		{
+			name: "invalid metadata",
+			ctx:  metadata.AppendToOutgoingContext(context.Background(), authorizationKey, "Bearer token"),
+			key:  "billing:1",
+			code: codes.Unauthenticated,
+		},
+		{
+			name: "wrong password",
+			ctx:  withCredentials(context.Background(), "alice", "wrong"),
+			key:  "billing:1",
+			code: codes.Unauthenticated,
+		},
+		{
+			name: "allowed",
+			ctx:  withCredentials(context.Background(), "alice", "secret"),
+			key:  "billing:1",
+			code: codes.OK,
+		},
+		{
+			name: "denied key",
+			ctx:  withCredentials(context.Background(), "alice", "secret"),
+			key:  "other",
+			code: codes.PermissionDenied,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := client.Set(test.ctx, &kvv1.SetRequest{Key: test.key, Value: "10"})
+			assert.Equal(t, test.code, status.Code(err))
+		})
+	}
+
+	t.Run("anonymous roles", func(t *testing.T) {
+		users, err := auth.NewUsers(auth.Config{
+			Users: []auth.User{{Name: "alice", PasswordHash: hash, Roles: []string{"billing"}}},
+			Roles: []auth.Role{
+				{
+					Name:  "billing",
+					Allow: []auth.Rule{{Categories: []auth.Category{auth.CategoryWrite}, Keys: []string{"billing:*"}}},
+				},
+				{
+					Name:  "reader",
+					Allow: []auth.Rule{{Categories: []auth.Category{auth.CategoryRead}, Keys: []string{"billing:*"}}},
+				},
+			},
+			AnonymousRoles: []string{"reader"},
+		})
+		require.NoError(t, err)
+
+		client := newTestClientWithUsers(t, replication.ReplicaTypeMaster, users)
+		ctx := context.Background()
+
+		_, err = client.Get(ctx, &kvv1.GetRequest{Key: "billing:1"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+
+		_, err = client.Set(ctx, &kvv1.SetRequest{Key: "billing:1", Value: "10"})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		// wrong credentials are rejected even if anonymous clients are allowed
+		_, err = client.Set(withCredentials(ctx, "alice", "wrong"), &kvv1.SetRequest{Key: "billing:1", Value: "10"})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		_, err = client.Set(withCredentials(ctx, "alice", "secret"), &kvv1.SetRequest{Key: "billing:1", Value: "10"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("watch", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		stream, err := client.Watch(ctx, &kvv1.WatchRequest{Prefix: "billing:"})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		stream, err = client.Watch(withCredentials(ctx, "alice", "secret"), &kvv1.WatchRequest{Prefix: "bill"})
+		require.NoError(t, err)
+		_, err = stream.Recv()
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		stream, err = client.Watch(withCredentials(ctx, "alice", "secret"), &kvv1.WatchRequest{Prefix: "billing:"})
+		require.NoError(t, err)
+		_, err = stream.Header()
+		require.NoError(t, err)
+
+		_, err = client.Set(withCredentials(ctx, "alice", "secret"), &kvv1.SetRequest{Key: "billing:2", Value: "20"})
+		require.NoError(t, err)
+
+		event, err := stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, "billing:2", event.GetKey())
+	})
+}
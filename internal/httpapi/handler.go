@@ -0,0 +1,368 @@
+// Package httpapi implements HTTP/JSON gateway to database
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"concurrency_go_course/internal/auth"
+	"concurrency_go_course/internal/compute"
+	"concurrency_go_course/internal/config"
+	"concurrency_go_course/internal/database"
+	"concurrency_go_course/internal/replication"
+	"concurrency_go_course/internal/watch"
+	"concurrency_go_course/pkg/logger"
+	"concurrency_go_course/pkg/parser"
+)
+
+// maxBatchSize limits number of commands in one batch request
+const maxBatchSize = 1000
+
+// Query parameters of GET request, they map to GET options of text protocol
+const (
+	paramMinLSN = "min_lsn"
+	paramMaxLag = "max_lag"
+	paramPrefix = "prefix"
+)
+
+// realm is sent to clients without credentials
+const realm = `Basic realm="kv"`
+
+// Handler serves HTTP requests
+type Handler struct {
+	db             database.Database
+	hub            *watch.Hub
+	users          *auth.Users
+	maxMessageSize int
+	mux            *http.ServeMux
+}
+
+// Option is an option of HTTP handler
+type Option func(*Handler)
+
+// WithUsers enables HTTP basic authentication, requests without valid
+// credentials are rejected with 401, requests without credentials are
+// served if users allow anonymous clients. Requests and watch streams are
+// checked with roles of user
+func WithUsers(users *auth.Users) Option {
+	return func(h *Handler) {
+		h.users = users
+	}
+}
+
+// NewHandler returns new HTTP handler, request bodies are limited by max
+// message size of network config, watch endpoint is enabled if hub is set
+func NewHandler(cfg *config.NetworkConfig, db database.Database, hub *watch.Hub,
+	options ...Option,
+) (*Handler, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("network config is empty")
+	}
+
+	if db == nil {
+		return nil, fmt.Errorf("database is empty")
+	}
+
+	maxMessageSize, err := parser.ParseSize(cfg.MaxMessageSize)
+	if err != nil {
+		return nil, fmt.Errorf("unable to set max message size: %w", err)
+	}
+
+	h := &Handler{
+		db:             db,
+		hub:            hub,
+		maxMessageSize: maxMessageSize,
+		mux:            http.NewServeMux(),
+	}
+
+	for _, option := range options {
+		option(h)
+	}
+
+	h.mux.HandleFunc("GET /v1/keys/{key}", h.get)
+	h.mux.HandleFunc("PUT /v1/keys/{key}", h.set)
+	h.mux.HandleFunc("DELETE /v1/keys/{key}", h.del)
+	h.mux.HandleFunc("POST /v1/batch", h.batch)
+	if hub != nil {
+		h.mux.HandleFunc("GET /v1/watch", h.watch)
+	}
+
+	return h, nil
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.users != nil {
+		name, password, ok := r.BasicAuth()
+		switch {
+		case ok:
+			if err := h.users.Authenticate(name, password); err != nil {
+				logger.Warn("HTTP authentication failed", zap.String("user", name))
+				w.Header().Set("WWW-Authenticate", realm)
+				writeError(w, http.StatusUnauthorized, err)
+				return
+			}
+
+			r = r.WithContext(auth.ContextWithUser(r.Context(), name))
+		case !h.users.AllowsAnonymous():
+			w.Header().Set("WWW-Authenticate", realm)
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("authentication required"))
+			return
+		}
+	}
+
+	h.mux.ServeHTTP(w, r)
+}
+
+// keyResponse is a body of GET response
+type keyResponse struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// setRequest is a body of PUT request
+type setRequest struct {
+	Value string `json:"value"`
+}
+
+// errorResponse is a body of failed response
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	fields := []string{compute.CommandGet, key}
+
+	params := r.URL.Query()
+	if minLSN := params.Get(paramMinLSN); minLSN != "" {
+		fields = append(fields, compute.OptionMinLSN, minLSN)
+	}
+	if maxLag := params.Get(paramMaxLag); maxLag != "" {
+		fields = append(fields, compute.OptionMaxLag, maxLag)
+	}
+
+	value, status, err := h.execute(r.Context(), fields)
+	if err != nil {
+		writeError(w, status, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, keyResponse{Key: key, Value: value})
+}
+
+func (h *Handler) set(w http.ResponseWriter, r *http.Request) {
+	var request setRequest
+	if err := h.decode(w, r, &request); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	_, status, err := h.execute(r.Context(), []string{compute.CommandSet, r.PathValue("key"), request.Value})
+	if err != nil {
+		writeError(w, status, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) del(w http.ResponseWriter, r *http.Request) {
+	_, status, err := h.execute(r.Context(), []string{compute.CommandDelete, r.PathValue("key")})
+	if err != nil {
+		writeError(w, status, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// batchCommands are commands allowed in batch, the same as single key
+// endpoints
+var batchCommands = []string{compute.CommandGet, compute.CommandSet, compute.CommandDelete}
+
+// batchCommand is one command of batch request
+type batchCommand struct {
+	Command string `json:"command"`
+	Key     string `json:"key"`
+	Value   string `json:"value,omitempty"`
+}
+
+// batchRequest is a body of batch request, commands are executed in order
+// and are not atomic
+type batchRequest struct {
+	Commands []batchCommand `json:"commands"`
+}
+
+// batchResult is a result of one command with HTTP status of the same
+// single key request
+type batchResult struct {
+	Status int    `json:"status"`
+	Value  string `json:"value,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// batchResponse is a body of batch response
+type batchResponse struct {
+	Results []batchResult `json:"results"`
+}
+
+func (h *Handler) batch(w http.ResponseWriter, r *http.Request) {
+	var request batchRequest
+	if err := h.decode(w, r, &request); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if len(request.Commands) > maxBatchSize {
+		writeError(w, http.StatusBadRequest,
+			fmt.Errorf("batch contains %d commands, max is %d", len(request.Commands), maxBatchSize))
+		return
+	}
+
+	// whole batch is rejected, so no command runs before invalid one
+	for _, command := range request.Commands {
+		if !slices.Contains(batchCommands, command.Command) {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("command %q is not allowed in batch, allowed are %s",
+				command.Command, strings.Join(batchCommands, ", ")))
+			return
+		}
+	}
+
+	response := batchResponse{Results: make([]batchResult, 0, len(request.Commands))}
+	for _, command := range request.Commands {
+		fields := []string{command.Command, command.Key}
+		if command.Command == compute.CommandSet {
+			fields = append(fields, command.Value)
+		}
+
+		value, status, err := h.execute(r.Context(), fields)
+		if err != nil {
+			response.Results = append(response.Results, batchResult{Status: status, Error: err.Error()})
+			continue
+		}
+
+		result := batchResult{Status: http.StatusOK}
+		if command.Command == compute.CommandGet {
+			result.Value = value
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// watch streams changes of keys with prefix as JSON lines until client
+// disconnects, stream ends if client is too slow to read events
+func (h *Handler) watch(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming is not supported"))
+		return
+	}
+
+	prefix := r.URL.Query().Get(paramPrefix)
+	if h.users != nil {
+		// prefix is checked as pattern, so user needs read access to
+		// every key which may be streamed
+		err := h.users.AuthorizePattern(auth.UserFromContext(r.Context()), auth.CategoryRead, prefix+"*")
+		if err != nil {
+			writeError(w, http.StatusForbidden, err)
+			return
+		}
+	}
+
+	sub := h.hub.Subscribe(prefix)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				logger.Info("watch subscriber is too slow, stream is closed")
+				return
+			}
+
+			if err := encoder.Encode(event); err != nil {
+				logger.Debug("unable to write watch event", zap.Error(err))
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// execute parses and handles query, returns result or HTTP status for error
+func (h *Handler) execute(ctx context.Context, fields []string) (string, int, error) {
+	query, err := compute.ParseFields(fields)
+	if err != nil {
+		return "", http.StatusBadRequest, err
+	}
+
+	result, err := h.db.HandleQuery(ctx, query)
+	if err != nil {
+		return "", errorStatus(err), err
+	}
+
+	return result, http.StatusOK, nil
+}
+
+func (h *Handler) decode(w http.ResponseWriter, r *http.Request, v any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, int64(h.maxMessageSize)))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+
+	return nil
+}
+
+// errorStatus maps database error to HTTP status
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrReadOnlyReplica):
+		return http.StatusConflict
+	case errors.Is(err, database.ErrPermissionDenied):
+		return http.StatusForbidden
+	case errors.Is(err, database.ErrWrongType):
+		return http.StatusBadRequest
+	case errors.Is(err, replication.ErrReplicaTooStale):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	if status == http.StatusInternalServerError {
+		logger.Error("unable to handle HTTP request", zap.Error(err))
+	}
+
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Debug("unable to write HTTP response", zap.Error(err))
+	}
+}
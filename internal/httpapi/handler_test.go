@@ -0,0 +1,401 @@
+package httpapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"concurrency_go_course/internal/auth"
+	"concurrency_go_course/internal/compute"
+	"concurrency_go_course/internal/config"
+	"concurrency_go_course/internal/database"
+	"concurrency_go_course/internal/replication"
+	"concurrency_go_course/internal/storage"
+	"concurrency_go_course/internal/watch"
+	"concurrency_go_course/pkg/logger"
+)
+
+func newTestServer(t *testing.T, replicaType string) *httptest.Server {
+	t.Helper()
+
+	return newTestServerWithUsers(t, replicaType, nil)
+}
+
+// newTestServerWithUsers returns server which authenticates requests and
+// checks their access if users are set
+func newTestServerWithUsers(t *testing.T, replicaType string, users *auth.Users) *httptest.Server {
+	t.Helper()
+
+	logger.MockLogger()
+
+	hub := watch.NewHub()
+	store, err := storage.New(watch.NewEngine(storage.NewEngine(4), hub), nil, replicaType, nil)
+	require.NoError(t, err)
+
+	var dbOptions []database.Option
+	var options []Option
+	if users != nil {
+		dbOptions = append(dbOptions, database.WithAuthorizer(users))
+		options = append(options, WithUsers(users))
+	}
+
+	db := database.NewDatabase(store, compute.NewCompute(compute.NewRequestParser()), dbOptions...)
+	handler, err := NewHandler(&config.NetworkConfig{MaxMessageSize: "1KB"}, db, hub, options...)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func do(t *testing.T, method, url, body string) (int, string) {
+	t.Helper()
+
+	return doAs(t, "", "", method, url, body)
+}
+
+// doAs sends request with basic authentication if user is set
+func doAs(t *testing.T, user, password, method, url, body string) (int, string) {
+	t.Helper()
+
+	request, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+
+	if user != "" {
+		request.SetBasicAuth(user, password)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+
+	return response.StatusCode, strings.TrimSpace(string(data))
+}
+
+func TestHandlerKeys(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, replication.ReplicaTypeMaster)
+	keyURL := server.URL + "/v1/keys/key%20with%20space"
+
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "get missing key",
+			method:         http.MethodGet,
+			url:            keyURL,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"value not found"}`,
+		},
+		{
+			name:           "set",
+			method:         http.MethodPut,
+			url:            keyURL,
+			body:           `{"value":"value\nwith lines"}`,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "get",
+			method:         http.MethodGet,
+			url:            keyURL,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"key":"key with space","value":"value\nwith lines"}`,
+		},
+		{
+			name:           "delete",
+			method:         http.MethodDelete,
+			url:            keyURL,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "get deleted key",
+			method:         http.MethodGet,
+			url:            keyURL,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"value not found"}`,
+		},
+		{
+			name:           "invalid body",
+			method:         http.MethodPut,
+			url:            keyURL,
+			body:           `{"val":"value"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid request body: json: unknown field \"val\""}`,
+		},
+		{
+			name:           "too large body",
+			method:         http.MethodPut,
+			url:            keyURL,
+			body:           `{"value":"` + strings.Repeat("a", 1024) + `"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid request body: http: request body too large"}`,
+		},
+		{
+			name:           "invalid get option",
+			method:         http.MethodGet,
+			url:            keyURL + "?min_lsn=abc",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid MINLSN value abc"}`,
+		},
+		{
+			name:           "method not allowed",
+			method:         http.MethodPost,
+			url:            keyURL,
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedBody:   "Method Not Allowed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := do(t, tt.method, tt.url, tt.body)
+			assert.Equal(t, tt.expectedStatus, status)
+			assert.Equal(t, tt.expectedBody, body)
+		})
+	}
+}
+
+func TestHandlerSlave(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, replication.ReplicaTypeSlave)
+
+	status, body := do(t, http.MethodPut, server.URL+"/v1/keys/key", `{"value":"value"}`)
+	assert.Equal(t, http.StatusConflict, status)
+	assert.Contains(t, body, storage.ErrReadOnly.Error())
+
+	status, _ = do(t, http.MethodDelete, server.URL+"/v1/keys/key", "")
+	assert.Equal(t, http.StatusConflict, status)
+
+	status, _ = do(t, http.MethodGet, server.URL+"/v1/keys/key", "")
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestHandlerBatch(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, replication.ReplicaTypeMaster)
+
+	status, body := do(t, http.MethodPost, server.URL+"/v1/batch", `{"commands":[
+		{"command":"SET","key":"a","value":"1"},
+		{"command":"GET","key":"a"},
+		{"command":"GET","key":"b"},
+		{"command":"DEL","key":"a"}
+	]}`)
+	require.Equal(t, http.StatusOK, status)
+
+	var response batchResponse
+	require.NoError(t, json.Unmarshal([]byte(body), &response))
+	assert.Equal(t, []batchResult{
+		{Status: http.StatusOK},
+		{Status: http.StatusOK, Value: "1"},
+		{Status: http.StatusNotFound, Error: "value not found"},
+		{Status: http.StatusOK},
+	}, response.Results)
+}
+
+func TestHandlerBatchCommands(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, replication.ReplicaTypeMaster)
+
+	tests := map[string]string{
+		"INCR":           `{"command":"INCR","key":"a"}`,
+		"SLOWLOG":        `{"command":"SLOWLOG","key":"RESET"}`,
+		"INFO":           `{"command":"INFO","key":"server"}`,
+		"PUBLISH":        `{"command":"PUBLISH","key":"channel","value":"message"}`,
+		"lower case GET": `{"command":"get","key":"a"}`,
+	}
+
+	for name, command := range tests {
+		t.Run(name, func(t *testing.T) {
+			status, body := do(t, http.MethodPost, server.URL+"/v1/batch",
+				`{"commands":[{"command":"SET","key":"a","value":"1"},`+command+`]}`)
+			assert.Equal(t, http.StatusBadRequest, status)
+			assert.Contains(t, body, "is not allowed in batch")
+
+			// commands before rejected one are not executed
+			status, _ = do(t, http.MethodGet, server.URL+"/v1/keys/a", "")
+			assert.Equal(t, http.StatusNotFound, status)
+		})
+	}
+}
+
+func TestHandlerWatch(t *testing.T) {
+	t.Parallel()
+
+	server := newTestServer(t, replication.ReplicaTypeMaster)
+
+	response, err := http.Get(server.URL + "/v1/watch?prefix=user:")
+	require.NoError(t, err)
+	defer response.Body.Close()
+
+	require.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "application/x-ndjson", response.Header.Get("Content-Type"))
+
+	// subscription is registered before headers are sent
+	status, _ := do(t, http.MethodPut, server.URL+"/v1/keys/order:1", `{"value":"book"}`)
+	require.Equal(t, http.StatusNoContent, status)
+	status, _ = do(t, http.MethodPut, server.URL+"/v1/keys/user:1", `{"value":"alice"}`)
+	require.Equal(t, http.StatusNoContent, status)
+	status, _ = do(t, http.MethodDelete, server.URL+"/v1/keys/user:1", "")
+	require.Equal(t, http.StatusNoContent, status)
+
+	scanner := bufio.NewScanner(response.Body)
+	for _, expected := range []watch.Event{
+		{Type: watch.EventSet, Key: "user:1", Value: "alice"},
+		{Type: watch.EventDelete, Key: "user:1"},
+	} {
+		require.True(t, scanner.Scan())
+
+		var event watch.Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		assert.Equal(t, expected, event)
+	}
+}
+
+func TestHandlerAuth(t *testing.T) {
+	t.Parallel()
+
+	hash, err := auth.HashPassword("secret")
+	require.NoError(t, err)
+
+	users, err := auth.NewUsers(auth.Config{
+		Users: []auth.User{{Name: "alice", PasswordHash: hash, Roles: []string{"billing"}}},
+		Roles: []auth.Role{{
+			Name: "billing",
+			Allow: []auth.Rule{{
+				Categories: []auth.Category{auth.CategoryRead, auth.CategoryWrite},
+				Keys:       []string{"billing:*"},
+			}},
+		}},
+	})
+	require.NoError(t, err)
+
+	server := newTestServerWithUsers(t, replication.ReplicaTypeMaster, users)
+
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		method   string
+		path     string
+		body     string
+		status   int
+	}{
+		{
+			name:   "without credentials",
+			method: http.MethodPut,
+			path:   "/v1/keys/billing:1",
+			body:   `{"value":"10"}`,
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:     "wrong password",
+			user:     "alice",
+			password: "wrong",
+			method:   http.MethodPut,
+			path:     "/v1/keys/billing:1",
+			body:     `{"value":"10"}`,
+			status:   http.StatusUnauthorized,
+		},
+		{
+			name:     "allowed",
+			user:     "alice",
+			password: "secret",
+			method:   http.MethodPut,
+			path:     "/v1/keys/billing:1",
+			body:     `{"value":"10"}`,
+			status:   http.StatusNoContent,
+		},
+		{
+			name:     "denied key",
+			user:     "alice",
+			password: "secret",
+			method:   http.MethodPut,
+			path:     "/v1/keys/other",
+			body:     `{"value":"10"}`,
+			status:   http.StatusForbidden,
+		},
+		{
+			name:     "denied watch",
+			user:     "alice",
+			password: "secret",
+			method:   http.MethodGet,
+			path:     "/v1/watch?prefix=bill",
+			status:   http.StatusForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, _ := doAs(t, test.user, test.password, test.method, server.URL+test.path, test.body)
+			assert.Equal(t, test.status, status)
+		})
+	}
+
+	response, err := http.Get(server.URL + "/v1/keys/billing:1")
+	require.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, `Basic realm="kv"`, response.Header.Get("WWW-Authenticate"))
+
+	t.Run("anonymous roles", func(t *testing.T) {
+		users, err := auth.NewUsers(auth.Config{
+			Users: []auth.User{{Name: "alice", PasswordHash: hash, Roles: []string{"billing"}}},
+			Roles: []auth.Role{
+				{
+					Name:  "billing",
+					Allow: []auth.Rule{{Categories: []auth.Category{auth.CategoryWrite}, Keys: []string{"billing:*"}}},
+				},
+				{
+					Name:  "reader",
+					Allow: []auth.Rule{{Categories: []auth.Category{auth.CategoryRead}, Keys: []string{"billing:*"}}},
+				},
+			},
+			AnonymousRoles: []string{"reader"},
+		})
+		require.NoError(t, err)
+
+		server := newTestServerWithUsers(t, replication.ReplicaTypeMaster, users)
+
+		status, _ := do(t, http.MethodGet, server.URL+"/v1/keys/billing:1", "")
+		assert.Equal(t, http.StatusNotFound, status)
+
+		status, _ = do(t, http.MethodPut, server.URL+"/v1/keys/billing:1", `{"value":"10"}`)
+		assert.Equal(t, http.StatusForbidden, status)
+
+		// wrong credentials are rejected even if anonymous clients are allowed
+		status, _ = doAs(t, "alice", "wrong", http.MethodPut, server.URL+"/v1/keys/billing:1", `{"value":"10"}`)
+		assert.Equal(t, http.StatusUnauthorized, status)
+
+		status, _ = doAs(t, "alice", "secret", http.MethodPut, server.URL+"/v1/keys/billing:1", `{"value":"10"}`)
+		assert.Equal(t, http.StatusNoContent, status)
+	})
+
+	// watch stream of allowed keys is opened
+	request, err := http.NewRequest(http.MethodGet, server.URL+"/v1/watch?prefix=billing:", nil)
+	require.NoError(t, err)
+	request.SetBasicAuth("alice", "secret")
+
+	response, err = http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+}
@@ -0,0 +1,153 @@
+// Package info renders node state for INFO command, every section is
+// a header line "# name" followed by "field:value" lines
+package info
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Section names of server
+const (
+	SectionServer      = "server"
+	SectionClients     = "clients"
+	SectionMemory      = "memory"
+	SectionPersistence = "persistence"
+	SectionReplication = "replication"
+)
+
+// order is a render order of known sections, other sections follow them
+// in order of adding
+var order = []string{SectionServer, SectionClients, SectionMemory, SectionPersistence, SectionReplication}
+
+// Sections returns names of known sections in render order
+func Sections() []string {
+	return slices.Clone(order)
+}
+
+// ErrUnknownSection is returned for section which is not added
+var ErrUnknownSection = errors.New("unknown section")
+
+// Field is a line of section, value must not contain line breaks
+type Field struct {
+	Name  string
+	Value string
+}
+
+// String returns field with string value
+func String(name, value string) Field {
+	return Field{Name: name, Value: value}
+}
+
+// Int returns field with integer value
+func Int(name string, value int64) Field {
+	return Field{Name: name, Value: strconv.FormatInt(value, 10)}
+}
+
+// Uint returns field with unsigned integer value
+func Uint(name string, value uint64) Field {
+	return Field{Name: name, Value: strconv.FormatUint(value, 10)}
+}
+
+// Unix returns field with unix time in seconds, zero time is 0
+func Unix(name string, t time.Time) Field {
+	if t.IsZero() {
+		return Int(name, 0)
+	}
+
+	return Int(name, t.Unix())
+}
+
+// Attributes returns field which value is comma separated key=value
+// pairs, keys are in given order
+func Attributes(name string, pairs ...Field) Field {
+	values := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		values = append(values, pair.Name+"="+pair.Value)
+	}
+
+	return String(name, strings.Join(values, ","))
+}
+
+// Section returns current fields of section
+type Section func() []Field
+
+// Info is an ordered set of sections
+type Info struct {
+	mutex    sync.RWMutex
+	names    []string
+	sections map[string]Section
+}
+
+// New returns info without sections
+func New() *Info {
+	return &Info{sections: make(map[string]Section)}
+}
+
+// Add adds section, section with the same name is replaced
+func (i *Info) Add(name string, section Section) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if _, ok := i.sections[name]; !ok {
+		i.names = append(i.names, name)
+		slices.SortStableFunc(i.names, func(a, b string) int {
+			return rank(a) - rank(b)
+		})
+	}
+	i.sections[name] = section
+}
+
+func rank(name string) int {
+	if i := slices.Index(order, name); i >= 0 {
+		return i
+	}
+
+	return len(order)
+}
+
+// Render returns section by case-insensitive name, all sections are
+// returned for empty name or "all"
+func (i *Info) Render(name string) (string, error) {
+	name = strings.ToLower(name)
+
+	i.mutex.RLock()
+	names := slices.Clone(i.names)
+	if name != "" && name != "all" {
+		if _, ok := i.sections[name]; !ok {
+			i.mutex.RUnlock()
+			return "", fmt.Errorf("%w %s", ErrUnknownSection, name)
+		}
+		names = []string{name}
+	}
+
+	sections := make([]Section, 0, len(names))
+	for _, name := range names {
+		sections = append(sections, i.sections[name])
+	}
+	i.mutex.RUnlock()
+
+	var builder strings.Builder
+	for n, section := range sections {
+		if n != 0 {
+			builder.WriteString("\n")
+		}
+
+		builder.WriteString("# " + names[n] + "\n")
+		for _, field := range section() {
+			builder.WriteString(field.Name + ":" + sanitize(field.Value) + "\n")
+		}
+	}
+
+	return strings.TrimSuffix(builder.String(), "\n"), nil
+}
+
+// sanitize replaces line breaks, so every field stays on one line
+func sanitize(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
@@ -0,0 +1,78 @@
+package info
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+
+	info := New()
+	info.Add("custom", func() []Field {
+		return []Field{String("name", "value")}
+	})
+	info.Add(SectionServer, func() []Field {
+		return []Field{String("version", "dev"), Int("uptime_seconds", 12)}
+	})
+	info.Add(SectionMemory, func() []Field {
+		return []Field{
+			Uint("keys", 3),
+			Attributes("partition_0", Int("keys", 1), Int("bytes", 52)),
+		}
+	})
+	info.Add(SectionPersistence, func() []Field {
+		return []Field{Unix("last_flush_unix", time.Time{}), String("active_segment", "wal\n1.log")}
+	})
+	info.Add(SectionServer, func() []Field {
+		return []Field{String("version", "1.0.0")}
+	})
+
+	tests := map[string]struct {
+		section  string
+		expected string
+		err      error
+	}{
+		"all sections": {
+			expected: "# server\nversion:1.0.0\n\n" +
+				"# memory\nkeys:3\npartition_0:keys=1,bytes=52\n\n" +
+				"# persistence\nlast_flush_unix:0\nactive_segment:wal 1.log\n\n" +
+				"# custom\nname:value",
+		},
+		"all keyword": {
+			section: "all",
+			expected: "# server\nversion:1.0.0\n\n" +
+				"# memory\nkeys:3\npartition_0:keys=1,bytes=52\n\n" +
+				"# persistence\nlast_flush_unix:0\nactive_segment:wal 1.log\n\n" +
+				"# custom\nname:value",
+		},
+		"one section": {
+			section:  "memory",
+			expected: "# memory\nkeys:3\npartition_0:keys=1,bytes=52",
+		},
+		"case insensitive": {
+			section:  "Server",
+			expected: "# server\nversion:1.0.0",
+		},
+		"unknown section": {
+			section: "replication",
+			err:     ErrUnknownSection,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := info.Render(test.section)
+			if test.err != nil {
+				assert.ErrorIs(t, err, test.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, result)
+		})
+	}
+}
@@ -1,16 +1,52 @@
 package network
 
 import (
+	"bufio"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"concurrency_go_course/internal/config"
 )
 
-// ClientDefaultBufSize is default value for client max message size
-const ClientDefaultBufSize = 4096
+// ClientDefaultMaxMessageSize is default value for client max message size,
+// it is large enough for replication segments
+const ClientDefaultMaxMessageSize = 64 << 20
+
+// ErrClientClosed is returned for requests sent after client was closed
+// or connection was broken
+var ErrClientClosed = errors.New("client is closed")
 
-// TCPClient is a struct for TCP client
+// TCPClient is a struct for TCP client, requests may be pipelined with
+// SendAsync, responses are matched to requests in order
 type TCPClient struct {
-	conn net.Conn
+	conn           net.Conn
+	reader         *bufio.Reader
+	timeout        time.Duration
+	maxMessageSize int
+
+	onPush func(payload []byte)
+
+	startOnce sync.Once
+	closed    atomic.Bool
+	done      chan struct{}
+	// mutex guards writes, pending queue and error
+	mutex   sync.Mutex
+	pending []*Future
+	err     error
+}
+
+func newTCPClient(conn net.Conn) *TCPClient {
+	return &TCPClient{
+		conn:           conn,
+		reader:         bufio.NewReader(conn),
+		maxMessageSize: ClientDefaultMaxMessageSize,
+		done:           make(chan struct{}),
+	}
 }
 
 // NewClient returns new TCP client
@@ -20,30 +56,255 @@ func NewClient(serverAddress string) (*TCPClient, error) {
 		return nil, err
 	}
 
-	return &TCPClient{
-		conn: conn,
-	}, nil
+	return newTCPClient(conn), nil
 }
 
-// Send sends request
-func (c *TCPClient) Send(request []byte) ([]byte, error) {
-	_, err := c.conn.Write([]byte(request))
+// NewClientWithConn returns new TCP client over established connection
+func NewClientWithConn(conn net.Conn) *TCPClient {
+	return newTCPClient(conn)
+}
+
+// NewClientWithTLS returns new TCP client, connection is encrypted
+// if TLS config is set
+func NewClientWithTLS(serverAddress string, tlsCfg *config.TLSConfig) (*TCPClient, error) {
+	if tlsCfg == nil {
+		return NewClient(serverAddress)
+	}
+
+	tlsConfig, err := ClientTLSConfig(tlsCfg)
 	if err != nil {
-		return nil, fmt.Errorf("unable to send request: %v", err)
+		return nil, err
 	}
 
-	response := make([]byte, ClientDefaultBufSize)
-	cnt, err := c.conn.Read(response)
+	conn, err := tls.Dial("tcp", serverAddress, tlsConfig)
 	if err != nil {
-		return nil, fmt.Errorf("unable to read response: %v", err)
+		return nil, err
 	}
 
-	return response[:cnt], nil
+	return newTCPClient(conn), nil
+}
+
+// SetTimeout sets timeout for response, zero means no timeout, it must be
+// called before the first request
+func (c *TCPClient) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
+// SetMaxMessageSize sets max response size, zero means no limit, it must
+// be called before the first request
+func (c *TCPClient) SetMaxMessageSize(size int) {
+	c.maxMessageSize = size
+}
+
+// SetPushHandler sets handler of frames pushed by server, it receives
+// payload of push envelope and is called by reader in order of frames, so
+// it must not block, it must be called before the first request
+func (c *TCPClient) SetPushHandler(handler func(payload []byte)) {
+	c.onPush = handler
+}
+
+// Done returns channel which is closed when client is closed or
+// connection is broken
+func (c *TCPClient) Done() <-chan struct{} {
+	return c.done
+}
+
+// Future is a pending response of request
+type Future struct {
+	done     chan struct{}
+	response []byte
+	position *Position
+	err      error
+	decode   func([]byte) (Response, error)
+}
+
+// Done returns channel which is closed when response is received
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait waits for response
+func (f *Future) Wait() ([]byte, error) {
+	<-f.done
+	return f.response, f.err
+}
+
+// Position waits for response and returns applied position of replica
+// which handled query, it is nil for responses of master
+func (f *Future) Position() *Position {
+	<-f.done
+	return f.position
+}
+
+func (f *Future) complete(response []byte, err error) {
+	if err == nil && f.decode != nil {
+		var decoded Response
+		decoded, err = f.decode(response)
+		response, f.position = decoded.Payload, decoded.Position
+	}
+
+	f.response, f.err = response, err
+	close(f.done)
+}
+
+// Send sends request and waits for response
+func (c *TCPClient) Send(request []byte) ([]byte, error) {
+	return c.SendAsync(request).Wait()
+}
+
+// SendAsync sends request without waiting for response, so many requests
+// may be in flight on one connection
+func (c *TCPClient) SendAsync(request []byte) *Future {
+	return c.send(request, nil)
+}
+
+// Query sends request of query protocol and decodes response envelope,
+// failed response is returned as *ResponseError
+func (c *TCPClient) Query(request []byte) ([]byte, error) {
+	return c.QueryAsync(request).Wait()
 }
 
-// Close closes TCP client connection
+// QueryAsync sends request of query protocol without waiting for response
+func (c *TCPClient) QueryAsync(request []byte) *Future {
+	return c.send(request, decodeQuery)
+}
+
+func (c *TCPClient) send(request []byte, decode func([]byte) (Response, error)) *Future {
+	future := &Future{
+		done:   make(chan struct{}),
+		decode: decode,
+	}
+
+	c.startOnce.Do(func() {
+		go c.readLoop()
+	})
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.err != nil {
+		future.complete(nil, c.err)
+		return future
+	}
+
+	if c.timeout != 0 {
+		if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+			future.complete(nil, fmt.Errorf("unable to set deadline: %v", err))
+			return future
+		}
+	}
+
+	// future is queued before write, so reader never sees unknown response
+	c.pending = append(c.pending, future)
+
+	if err := WriteFrame(c.conn, request); err != nil {
+		c.failLocked(fmt.Errorf("unable to send request: %w", err))
+	}
+
+	return future
+}
+
+// readLoop completes pending futures in order of requests
+func (c *TCPClient) readLoop() {
+	for {
+		response, err := ReadFrame(c.reader, c.maxMessageSize)
+
+		c.mutex.Lock()
+		if err != nil && c.closed.Load() {
+			c.failLocked(ErrClientClosed)
+			c.mutex.Unlock()
+			return
+		}
+
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() && len(c.pending) == 0 {
+				// deadline of answered requests expired on idle connection
+				_ = c.conn.SetReadDeadline(time.Time{})
+				c.mutex.Unlock()
+				continue
+			}
+
+			c.failLocked(fmt.Errorf("unable to read response: %w", err))
+			c.mutex.Unlock()
+			return
+		}
+
+		if c.onPush != nil && IsPush(response) {
+			push, err := DecodeResponse(response)
+			if err != nil {
+				c.failLocked(fmt.Errorf("unable to read push: %w", err))
+				c.mutex.Unlock()
+				return
+			}
+			c.mutex.Unlock()
+
+			c.onPush(push.Payload)
+			continue
+		}
+
+		if len(c.pending) == 0 {
+			c.failLocked(fmt.Errorf("unable to read response: unexpected response"))
+			c.mutex.Unlock()
+			return
+		}
+
+		future := c.pending[0]
+		c.pending[0] = nil
+		c.pending = c.pending[1:]
+		if len(c.pending) == 0 && c.timeout != 0 {
+			_ = c.conn.SetDeadline(time.Time{})
+		}
+		c.mutex.Unlock()
+
+		future.complete(response, nil)
+	}
+}
+
+// failLocked breaks client, pending and later requests fail with error
+func (c *TCPClient) failLocked(err error) {
+	if c.err == nil {
+		c.err = err
+		close(c.done)
+	}
+
+	for _, future := range c.pending {
+		future.complete(nil, err)
+	}
+	c.pending = nil
+
+	_ = c.conn.Close()
+}
+
+// decodeQuery decodes response envelope, position of replica is kept for
+// failed responses too
+func decodeQuery(data []byte) (Response, error) {
+	response, err := DecodeResponse(data)
+	if err != nil {
+		return Response{}, err
+	}
+
+	if response.Status != StatusOK {
+		return Response{Position: response.Position},
+			&ResponseError{Code: response.Code, Message: string(response.Payload)}
+	}
+
+	return response, nil
+}
+
+// Close closes TCP client connection, pending requests fail, it may be
+// called concurrently with requests to interrupt them
 func (c *TCPClient) Close() {
-	if c.conn != nil {
-		_ = c.conn.Close()
+	if c.conn == nil {
+		return
 	}
+
+	// connection is closed before lock to interrupt blocked write
+	c.closed.Store(true)
+	_ = c.conn.Close()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.failLocked(ErrClientClosed)
 }
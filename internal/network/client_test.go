@@ -1,6 +1,7 @@
 package network
 
 import (
+	"errors"
 	"net"
 	"testing"
 
@@ -22,24 +23,36 @@ func TestClient(t *testing.T) {
 		t.Errorf("want nil error; got %+v", err)
 	}
 
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
+
 		for {
 			conn, err := listener.Accept()
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
 			if err != nil {
 				t.Errorf("want nil error; got %+v", err)
+				return
 			}
 
-			_, err = conn.Read(make([]byte, 1024))
+			_, err = ReadFrame(conn, 1024)
 			if err != nil {
 				t.Errorf("want nil error; got %+v", err)
 			}
 
-			_, err = conn.Write([]byte(serverResponse))
+			err = WriteFrame(conn, []byte(serverResponse))
 			if err != nil {
 				t.Errorf("want nil error; got %+v", err)
 			}
+			_ = conn.Close()
 		}
 	}()
+	t.Cleanup(func() {
+		_ = listener.Close()
+		<-done
+	})
 
 	client, err := NewClient(serverAddress)
 	if err != nil {
@@ -0,0 +1,52 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// frameHeaderSize is a size of big-endian frame length prefix
+const frameHeaderSize = 4
+
+// ErrFrameTooLarge is returned if frame length exceeds max size
+var ErrFrameTooLarge = errors.New("frame is too large")
+
+// WriteFrame writes payload prefixed with its length in a single write
+func WriteFrame(w io.Writer, payload []byte) error {
+	if uint64(len(payload)) > uint64(^uint32(0)) {
+		return ErrFrameTooLarge
+	}
+
+	frame := make([]byte, frameHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	copy(frame[frameHeaderSize:], payload)
+
+	_, err := w.Write(frame)
+	return err
+}
+
+// ReadFrame reads one frame, payloads larger than maxSize are rejected
+// before they are read, zero maxSize means no limit
+func ReadFrame(r io.Reader, maxSize int) ([]byte, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[:])
+	if maxSize > 0 && uint64(size) > uint64(maxSize) {
+		return nil, fmt.Errorf("%w: %d bytes, max %d bytes", ErrFrameTooLarge, size, maxSize)
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,186 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"concurrency_go_course/internal/config"
+	"concurrency_go_course/pkg/logger"
+)
+
+// chunkReader returns data in chunks of given sizes to emulate partial reads
+type chunkReader struct {
+	data   []byte
+	chunks []byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+
+	size := len(r.data)
+	if len(r.chunks) != 0 {
+		size = int(r.chunks[0])%len(r.data) + 1
+		r.chunks = r.chunks[1:]
+	}
+	size = min(size, len(p))
+
+	n := copy(p, r.data[:size])
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestFrame(t *testing.T) {
+	t.Parallel()
+
+	var buffer bytes.Buffer
+	require.NoError(t, WriteFrame(&buffer, []byte("SET key value")))
+	require.NoError(t, WriteFrame(&buffer, nil))
+
+	assert.Equal(t, []byte{0, 0, 0, 13}, buffer.Bytes()[:frameHeaderSize])
+
+	payload, err := ReadFrame(&buffer, 1024)
+	require.NoError(t, err)
+	assert.Equal(t, "SET key value", string(payload))
+
+	payload, err = ReadFrame(&buffer, 1024)
+	require.NoError(t, err)
+	assert.Empty(t, payload)
+
+	_, err = ReadFrame(&buffer, 1024)
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestReadFrameErr(t *testing.T) {
+	t.Parallel()
+
+	var buffer bytes.Buffer
+	require.NoError(t, WriteFrame(&buffer, []byte("SET key value")))
+	frame := buffer.Bytes()
+
+	_, err := ReadFrame(bytes.NewReader(frame), 4)
+	assert.ErrorIs(t, err, ErrFrameTooLarge)
+
+	_, err = ReadFrame(bytes.NewReader(frame[:2]), 1024)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	_, err = ReadFrame(bytes.NewReader(frame[:frameHeaderSize]), 1024)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	_, err = ReadFrame(bytes.NewReader(frame[:len(frame)-1]), 1024)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func FuzzFramePartialReads(f *testing.F) {
+	f.Add([]byte("GET key"), []byte{0})
+	f.Add([]byte("SET key value"), []byte{1, 2, 3})
+	f.Add([]byte{}, []byte{})
+
+	f.Fuzz(func(t *testing.T, payload []byte, chunks []byte) {
+		var buffer bytes.Buffer
+		require.NoError(t, WriteFrame(&buffer, payload))
+
+		reader := &chunkReader{data: buffer.Bytes(), chunks: chunks}
+		result, err := ReadFrame(reader, len(payload))
+		require.NoError(t, err)
+		assert.Equal(t, len(payload), len(result))
+		assert.True(t, bytes.Equal(payload, result))
+
+		_, err = ReadFrame(reader, len(payload))
+		assert.ErrorIs(t, err, io.EOF)
+	})
+}
+
+func FuzzFrameCoalescedWrites(f *testing.F) {
+	f.Add([]byte("GET a\x00SET b c\x00DEL d"), []byte{5})
+	f.Add([]byte("\x00\x00"), []byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte, chunks []byte) {
+		// zero byte separates payloads which are written into one buffer
+		payloads := bytes.Split(data, []byte{0})
+
+		var buffer bytes.Buffer
+		for _, payload := range payloads {
+			require.NoError(t, WriteFrame(&buffer, payload))
+		}
+
+		reader := &chunkReader{data: buffer.Bytes(), chunks: chunks}
+		for _, payload := range payloads {
+			result, err := ReadFrame(reader, len(data))
+			require.NoError(t, err)
+			assert.True(t, bytes.Equal(payload, result))
+		}
+
+		_, err := ReadFrame(reader, len(data))
+		assert.ErrorIs(t, err, io.EOF)
+	})
+}
+
+func TestServerFraming(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	addr := "127.0.0.1:5557"
+
+	cfg := &config.Config{
+		Network: &config.NetworkConfig{
+			MaxConnections: 100,
+			MaxMessageSize: "16B",
+			IdleTimeout:    "5m",
+		},
+	}
+
+	server, err := NewServer(cfg, addr)
+	require.NoError(t, err)
+
+	serveTest(t, server, func(_ context.Context, data []byte) []byte {
+		return append([]byte("echo "), data...)
+	})
+
+	t.Run("pipelined and split requests", func(t *testing.T) {
+		conn, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		var buffer bytes.Buffer
+		require.NoError(t, WriteFrame(&buffer, []byte("first")))
+		require.NoError(t, WriteFrame(&buffer, []byte("second")))
+		require.NoError(t, WriteFrame(&buffer, []byte("third")))
+		data := buffer.Bytes()
+
+		// two requests in one write, the third one is split
+		split := len(data) - 3
+		_, err = conn.Write(data[:split])
+		require.NoError(t, err)
+		time.Sleep(10 * time.Millisecond)
+		_, err = conn.Write(data[split:])
+		require.NoError(t, err)
+
+		for _, expected := range []string{"echo first", "echo second", "echo third"} {
+			response, err := ReadFrame(conn, 0)
+			require.NoError(t, err)
+			assert.Equal(t, expected, string(response))
+		}
+	})
+
+	t.Run("too large request", func(t *testing.T) {
+		conn, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		require.NoError(t, WriteFrame(conn, bytes.Repeat([]byte("a"), 17)))
+
+		_, err = ReadFrame(conn, 0)
+		assert.True(t, errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed), err)
+	})
+}
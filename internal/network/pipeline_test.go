@@ -0,0 +1,175 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"concurrency_go_course/internal/config"
+	"concurrency_go_course/pkg/logger"
+)
+
+func runTestServer(tb testing.TB, addr string, handler TCPHandler) {
+	tb.Helper()
+
+	logger.MockLogger()
+
+	cfg := &config.Config{
+		Network: &config.NetworkConfig{
+			MaxConnections: 100,
+			MaxMessageSize: "1KB",
+			IdleTimeout:    "5m",
+		},
+	}
+
+	server, err := NewServer(cfg, addr)
+	require.NoError(tb, err)
+
+	serveTest(tb, server, handler)
+}
+
+func TestPipelining(t *testing.T) {
+	t.Parallel()
+
+	addr := "127.0.0.1:5559"
+	runTestServer(t, addr, func(_ context.Context, data []byte) []byte {
+		// slow first request must not be overtaken by later ones
+		if string(data) == "0" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		return append([]byte("echo "), data...)
+	})
+
+	client, err := NewClient(addr)
+	require.NoError(t, err)
+	defer client.Close()
+
+	futures := make([]*Future, 0, 500)
+	for i := range 500 {
+		futures = append(futures, client.SendAsync([]byte(strconv.Itoa(i))))
+	}
+
+	for i, future := range futures {
+		response, err := future.Wait()
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("echo %d", i), string(response))
+	}
+
+	// synchronous request after pipeline
+	response, err := client.Send([]byte("last"))
+	require.NoError(t, err)
+	assert.Equal(t, "echo last", string(response))
+}
+
+func TestClientFailures(t *testing.T) {
+	t.Parallel()
+
+	addr := "127.0.0.1:5560"
+	runTestServer(t, addr, func(ctx context.Context, data []byte) []byte {
+		if string(data) == "slow" {
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+		}
+		return data
+	})
+
+	t.Run("close interrupts pending requests", func(t *testing.T) {
+		client, err := NewClient(addr)
+		require.NoError(t, err)
+
+		future := client.SendAsync([]byte("slow"))
+		client.Close()
+
+		select {
+		case <-future.Done():
+		case <-time.After(500 * time.Millisecond):
+			t.Fatal("pending request was not interrupted")
+		}
+
+		_, err = future.Wait()
+		assert.ErrorIs(t, err, ErrClientClosed)
+
+		_, err = client.Send([]byte("fast"))
+		assert.ErrorIs(t, err, ErrClientClosed)
+	})
+
+	t.Run("timeout breaks client", func(t *testing.T) {
+		client, err := NewClient(addr)
+		require.NoError(t, err)
+		defer client.Close()
+		client.SetTimeout(50 * time.Millisecond)
+
+		response, err := client.Send([]byte("fast"))
+		require.NoError(t, err)
+		assert.Equal(t, "fast", string(response))
+
+		// deadline of answered request does not break idle client
+		time.Sleep(100 * time.Millisecond)
+		response, err = client.Send([]byte("fast"))
+		require.NoError(t, err)
+		assert.Equal(t, "fast", string(response))
+
+		_, err = client.Send([]byte("slow"))
+		assert.Error(t, err)
+
+		_, err = client.Send([]byte("fast"))
+		assert.Error(t, err)
+	})
+}
+
+// benchmarkAddr is shared by benchmarks, they do not run in parallel
+const benchmarkAddr = "127.0.0.1:5561"
+
+func BenchmarkClientSend(b *testing.B) {
+	runTestServer(b, benchmarkAddr, func(_ context.Context, data []byte) []byte {
+		return data
+	})
+
+	client, err := NewClient(benchmarkAddr)
+	require.NoError(b, err)
+	defer client.Close()
+
+	request := []byte("GET key")
+
+	b.ResetTimer()
+	for range b.N {
+		if _, err := client.Send(request); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkClientSendAsync(b *testing.B) {
+	runTestServer(b, benchmarkAddr, func(_ context.Context, data []byte) []byte {
+		return data
+	})
+
+	client, err := NewClient(benchmarkAddr)
+	require.NoError(b, err)
+	defer client.Close()
+
+	request := []byte("GET key")
+	const window = 64
+	futures := make([]*Future, 0, window)
+
+	b.ResetTimer()
+	for i := range b.N {
+		futures = append(futures, client.SendAsync(request))
+
+		if len(futures) == window || i == b.N-1 {
+			for _, future := range futures {
+				if _, err := future.Wait(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			futures = futures[:0]
+		}
+	}
+}
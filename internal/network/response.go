@@ -0,0 +1,229 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Status is a status of query response
+type Status byte
+
+// Response statuses, push is a frame sent by server without request, so
+// it is not a response to any request
+const (
+	StatusOK    Status = 0
+	StatusError Status = 1
+	StatusPush  Status = 2
+)
+
+// ErrorCode is a code of failed query response
+type ErrorCode byte
+
+// Error codes, CodeNone is used for successful responses
+const (
+	CodeNone ErrorCode = iota
+	CodeNotFound
+	CodeReadOnlyReplica
+	CodeParseError
+	CodeTooLarge
+	CodeInternal
+	CodeAuthRequired
+	CodeAuthFailed
+	CodePermissionDenied
+	CodeWrongType
+)
+
+// String returns error code name
+func (c ErrorCode) String() string {
+	switch c {
+	case CodeNone:
+		return "NONE"
+	case CodeNotFound:
+		return "NOT_FOUND"
+	case CodeReadOnlyReplica:
+		return "READ_ONLY_REPLICA"
+	case CodeParseError:
+		return "PARSE_ERROR"
+	case CodeTooLarge:
+		return "TOO_LARGE"
+	case CodeInternal:
+		return "INTERNAL"
+	case CodeAuthRequired:
+		return "AUTH_REQUIRED"
+	case CodeAuthFailed:
+		return "AUTH_FAILED"
+	case CodePermissionDenied:
+		return "PERMISSION_DENIED"
+	case CodeWrongType:
+		return "WRONG_TYPE"
+	default:
+		return fmt.Sprintf("UNKNOWN(%d)", byte(c))
+	}
+}
+
+// responseHeaderSize is a size of status, error code and flags
+const responseHeaderSize = 3
+
+// Flags of response header, position is followed by applied LSN and lag
+// if it is known, they are encoded before payload as 8 byte big-endian
+// numbers, lag is encoded in nanoseconds
+const (
+	flagPosition byte = 1 << iota
+	flagLag
+)
+
+// ErrInvalidResponse is returned for malformed response envelope
+var ErrInvalidResponse = errors.New("invalid response")
+
+// Response is an envelope of query response, payload is a value for
+// successful response and an error message otherwise
+type Response struct {
+	Status Status
+	Code   ErrorCode
+	// Position is set by replica which handled query
+	Position *Position
+	Payload  []byte
+}
+
+// Position is an applied position of replica, lag is not known until
+// replica synced with master
+type Position struct {
+	AppliedLSN uint64
+	Lag        time.Duration
+	LagKnown   bool
+}
+
+// WithPosition returns response with applied position of replica
+func (r Response) WithPosition(position Position) Response {
+	r.Position = &position
+	return r
+}
+
+// NewResponse returns successful response
+func NewResponse(payload []byte) Response {
+	return Response{
+		Status:  StatusOK,
+		Code:    CodeNone,
+		Payload: payload,
+	}
+}
+
+// NewErrorResponse returns failed response
+func NewErrorResponse(code ErrorCode, message string) Response {
+	return Response{
+		Status:  StatusError,
+		Code:    code,
+		Payload: []byte(message),
+	}
+}
+
+// NewPushResponse returns push frame, it is sent to subscribed connection
+// between responses
+func NewPushResponse(payload []byte) Response {
+	return Response{
+		Status:  StatusPush,
+		Code:    CodeNone,
+		Payload: payload,
+	}
+}
+
+// IsPush returns true if frame payload is push envelope
+func IsPush(data []byte) bool {
+	return len(data) >= responseHeaderSize && Status(data[0]) == StatusPush
+}
+
+// Encode returns response as frame payload: status byte, error code byte,
+// flags byte, position if it is set and payload
+func (r Response) Encode() []byte {
+	var flags byte
+	if r.Position != nil {
+		flags |= flagPosition
+		if r.Position.LagKnown {
+			flags |= flagLag
+		}
+	}
+
+	data := make([]byte, 0, responseHeaderSize+16+len(r.Payload))
+	data = append(data, byte(r.Status), byte(r.Code), flags)
+	if flags&flagPosition != 0 {
+		data = binary.BigEndian.AppendUint64(data, r.Position.AppliedLSN)
+	}
+	if flags&flagLag != 0 {
+		data = binary.BigEndian.AppendUint64(data, uint64(r.Position.Lag))
+	}
+
+	return append(data, r.Payload...)
+}
+
+// DecodeResponse decodes frame payload into response
+func DecodeResponse(data []byte) (Response, error) {
+	if len(data) < responseHeaderSize {
+		return Response{}, fmt.Errorf("%w: envelope is too short", ErrInvalidResponse)
+	}
+
+	response := Response{
+		Status: Status(data[0]),
+		Code:   ErrorCode(data[1]),
+	}
+
+	flags := data[2]
+	data = data[responseHeaderSize:]
+	if flags&^(flagPosition|flagLag) != 0 || flags == flagLag {
+		return Response{}, fmt.Errorf("%w: invalid flags %d", ErrInvalidResponse, flags)
+	}
+
+	if flags&flagPosition != 0 {
+		if len(data) < 8 {
+			return Response{}, fmt.Errorf("%w: position is too short", ErrInvalidResponse)
+		}
+		response.Position = &Position{AppliedLSN: binary.BigEndian.Uint64(data)}
+		data = data[8:]
+	}
+
+	if flags&flagLag != 0 {
+		if len(data) < 8 {
+			return Response{}, fmt.Errorf("%w: lag is too short", ErrInvalidResponse)
+		}
+		response.Position.Lag = time.Duration(binary.BigEndian.Uint64(data))
+		response.Position.LagKnown = true
+		data = data[8:]
+	}
+
+	response.Payload = data
+
+	switch response.Status {
+	case StatusOK, StatusPush:
+		if response.Code != CodeNone {
+			return Response{}, fmt.Errorf("%w: error code %s in successful response",
+				ErrInvalidResponse, response.Code)
+		}
+	case StatusError:
+		if response.Code == CodeNone {
+			return Response{}, fmt.Errorf("%w: no error code in failed response", ErrInvalidResponse)
+		}
+	default:
+		return Response{}, fmt.Errorf("%w: unknown status %d", ErrInvalidResponse, response.Status)
+	}
+
+	return response, nil
+}
+
+// ResponseError is an error returned by server in failed response
+type ResponseError struct {
+	Code    ErrorCode
+	Message string
+}
+
+// Error returns error message
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Is reports whether target is response error with the same code, so
+// callers can match codes with errors.Is(err, &ResponseError{Code: code})
+func (e *ResponseError) Is(target error) bool {
+	t, ok := target.(*ResponseError)
+	return ok && t.Code == e.Code
+}
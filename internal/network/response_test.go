@@ -0,0 +1,153 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"concurrency_go_course/internal/config"
+	"concurrency_go_course/pkg/logger"
+)
+
+func TestResponse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		response Response
+		encoded  []byte
+	}{
+		{
+			name:     "value",
+			response: NewResponse([]byte("value not found")),
+			encoded:  append([]byte{0, 0, 0}, "value not found"...),
+		},
+		{
+			name:     "empty value",
+			response: NewResponse(nil),
+			encoded:  []byte{0, 0, 0},
+		},
+		{
+			name:     "error",
+			response: NewErrorResponse(CodeNotFound, "value not found"),
+			encoded:  append([]byte{1, 1, 0}, "value not found"...),
+		},
+		{
+			name:     "push",
+			response: NewPushResponse([]byte("message news hello")),
+			encoded:  append([]byte{2, 0, 0}, "message news hello"...),
+		},
+		{
+			name: "value of replica",
+			response: NewResponse([]byte("a\nb")).
+				WithPosition(Position{AppliedLSN: 10, Lag: 20 * time.Millisecond, LagKnown: true}),
+			encoded: append([]byte{0, 0, 3, 0, 0, 0, 0, 0, 0, 0, 10, 0, 0, 0, 0, 0x01, 0x31, 0x2d, 0x00},
+				"a\nb"...),
+		},
+		{
+			name:     "error of replica with unknown lag",
+			response: NewErrorResponse(CodeNotFound, "value not found").WithPosition(Position{AppliedLSN: 10}),
+			encoded:  append([]byte{1, 1, 1, 0, 0, 0, 0, 0, 0, 0, 10}, "value not found"...),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.encoded, tt.response.Encode())
+			assert.Equal(t, tt.response.Status == StatusPush, IsPush(tt.encoded))
+
+			response, err := DecodeResponse(tt.encoded)
+			require.NoError(t, err)
+			assert.Equal(t, tt.response.Status, response.Status)
+			assert.Equal(t, tt.response.Code, response.Code)
+			assert.Equal(t, tt.response.Position, response.Position)
+			assert.Equal(t, string(tt.response.Payload), string(response.Payload))
+		})
+	}
+}
+
+func TestDecodeResponseErr(t *testing.T) {
+	t.Parallel()
+
+	for _, data := range [][]byte{
+		nil,
+		{0, 0},
+		{0, byte(CodeInternal), 0},
+		{1, byte(CodeNone), 0},
+		{2, byte(CodeInternal), 0},
+		{3, 0, 0},
+		{0, 0, 4},
+		{0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0},
+		{0, 0, 1, 0, 0, 0},
+		{0, 0, 3, 0, 0, 0, 0, 0, 0, 0, 10, 0, 0},
+	} {
+		_, err := DecodeResponse(data)
+		assert.ErrorIs(t, err, ErrInvalidResponse, data)
+	}
+}
+
+func TestResponseErrorIs(t *testing.T) {
+	t.Parallel()
+
+	var err error = &ResponseError{Code: CodeReadOnlyReplica, Message: "replica is read-only"}
+
+	assert.True(t, errors.Is(err, &ResponseError{Code: CodeReadOnlyReplica}))
+	assert.False(t, errors.Is(err, &ResponseError{Code: CodeNotFound}))
+	assert.Equal(t, "READ_ONLY_REPLICA: replica is read-only", err.Error())
+}
+
+func TestClientQuery(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	addr := "127.0.0.1:5558"
+
+	cfg := &config.Config{
+		Network: &config.NetworkConfig{
+			MaxConnections: 100,
+			MaxMessageSize: "16B",
+			IdleTimeout:    "5m",
+		},
+	}
+
+	server, err := NewServer(cfg, addr)
+	require.NoError(t, err)
+	server.SetTooLargeResponse(NewErrorResponse(CodeTooLarge, "too large").Encode())
+
+	serveTest(t, server, func(_ context.Context, data []byte) []byte {
+		switch string(data) {
+		case "GET missing":
+			return NewErrorResponse(CodeNotFound, "value not found").Encode()
+		case "GET stale":
+			return NewErrorResponse(CodeNotFound, "value not found").WithPosition(Position{AppliedLSN: 7}).Encode()
+		}
+		return NewResponse([]byte("value not found")).Encode()
+	})
+
+	client, err := NewClient(addr)
+	require.NoError(t, err)
+	defer client.Close()
+
+	// value which looks like error message is not an error
+	value, err := client.Query([]byte("GET key"))
+	require.NoError(t, err)
+	assert.Equal(t, "value not found", string(value))
+
+	_, err = client.Query([]byte("GET missing"))
+	assert.ErrorIs(t, err, &ResponseError{Code: CodeNotFound})
+
+	// position of replica is returned with failed response
+	future := client.QueryAsync([]byte("GET stale"))
+	_, err = future.Wait()
+	assert.ErrorIs(t, err, &ResponseError{Code: CodeNotFound})
+	assert.Equal(t, &Position{AppliedLSN: 7}, future.Position())
+	assert.Nil(t, client.QueryAsync([]byte("GET key")).Position())
+
+	_, err = client.Query([]byte("SET key very long value"))
+	assert.ErrorIs(t, err, &ResponseError{Code: CodeTooLarge})
+}
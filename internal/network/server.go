@@ -1,24 +1,46 @@
 package network
 
 import (
+	"bufio"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
 
 	"concurrency_go_course/internal/config"
 	"concurrency_go_course/pkg/logger"
+	"concurrency_go_course/pkg/metrics"
 	"concurrency_go_course/pkg/parser"
 	"concurrency_go_course/pkg/sema"
+	"concurrency_go_course/pkg/trace"
 )
 
-// TCPHandler is a func for data handling
+// maxPipelineDepth limits number of handled requests which responses are
+// not written yet, reading of connection stops if it is reached
+const maxPipelineDepth = 128
+
+var (
+	activeConnections = metrics.Register(metrics.NewGaugeVec("network_connections_active",
+		"Number of connections which are handled by server.", "address"))
+	semaphoreWait = metrics.Register(metrics.NewHistogramVec("network_semaphore_wait_seconds",
+		"Time accepted connections wait for free slot of max connections.", metrics.DurationBuckets, "address"))
+)
+
+// TCPHandler is a func for data handling, context contains session of
+// connection
 type TCPHandler = func(context.Context, []byte) []byte
 
+// TCPConnHandler is a func for connection handling, it is used by protocols
+// with own framing
+type TCPConnHandler = func(context.Context, net.Conn)
+
 // TCPServer is a struct for TCP server
 type TCPServer struct {
 	listener net.Listener
@@ -26,10 +48,49 @@ type TCPServer struct {
 	cfg      *config.Config
 
 	semaphore *sema.Semaphore
+
+	tooLargeResponse []byte
+
+	connected atomic.Int64
+	blocked   atomic.Int64
+	rejected  atomic.Int64
+}
+
+// ClientStats is a state of server connections
+type ClientStats struct {
+	// Connected is a number of connections which are handled
+	Connected int64
+	// Blocked is a number of accepted connections which wait for free slot
+	// of max connections
+	Blocked int64
+	// Rejected is a total number of connections closed by server because
+	// request exceeded max message size
+	Rejected int64
+}
+
+// Address returns listen address of server, port is resolved if address
+// has zero port
+func (s *TCPServer) Address() string {
+	return s.listener.Addr().String()
+}
+
+// ClientStats returns current state of server connections
+func (s *TCPServer) ClientStats() ClientStats {
+	return ClientStats{
+		Connected: s.connected.Load(),
+		Blocked:   s.blocked.Load(),
+		Rejected:  s.rejected.Load(),
+	}
 }
 
 // NewServer returns new TCP server
 func NewServer(cfg *config.Config, address string) (*TCPServer, error) {
+	return NewServerWithTLS(cfg, address, nil)
+}
+
+// NewServerWithTLS returns new TCP server, connections are encrypted
+// if TLS config is set
+func NewServerWithTLS(cfg *config.Config, address string, tlsCfg *config.TLSConfig) (*TCPServer, error) {
 	if cfg == nil {
 		return nil, fmt.Errorf("config is empty")
 	}
@@ -38,11 +99,23 @@ func NewServer(cfg *config.Config, address string) (*TCPServer, error) {
 		return nil, fmt.Errorf("address is empty")
 	}
 
+	var tlsConfig *tls.Config
+	if tlsCfg != nil {
+		var err error
+		if tlsConfig, err = ServerTLSConfig(tlsCfg); err != nil {
+			return nil, err
+		}
+	}
+
 	listener, err := net.Listen("tcp", address)
 	if err != nil {
 		return nil, fmt.Errorf("failed to listen: %w", err)
 	}
 
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+
 	return &TCPServer{
 		listener: listener,
 		cfg:      cfg,
@@ -52,8 +125,22 @@ func NewServer(cfg *config.Config, address string) (*TCPServer, error) {
 	}, nil
 }
 
-// Run starts TCP server
+// SetTooLargeResponse sets response which is sent before connection is
+// closed if request exceeds max message size
+func (s *TCPServer) SetTooLargeResponse(response []byte) {
+	s.tooLargeResponse = response
+}
+
+// Run starts TCP server, every request and response is a frame
 func (s *TCPServer) Run(ctx context.Context, handler TCPHandler) {
+	s.Serve(ctx, func(ctx context.Context, conn net.Conn) {
+		s.handle(ctx, conn, handler)
+	})
+}
+
+// Serve starts TCP server, every connection is passed to handler and
+// closed after handler returns or server is stopped
+func (s *TCPServer) Serve(ctx context.Context, handler TCPConnHandler) {
 	fmt.Println("Server is running on", s.address)
 	logger.Debug("Start server on", zap.String("address", s.address),
 		zap.String("idle_timeout", s.cfg.Network.IdleTimeout),
@@ -80,9 +167,25 @@ func (s *TCPServer) Run(ctx context.Context, handler TCPHandler) {
 				continue
 			}
 
-			s.semaphore.Acquire()
+			waitStart := time.Now()
+			if !s.semaphore.TryAcquire() {
+				s.blocked.Add(1)
+				s.semaphore.Acquire()
+				s.blocked.Add(-1)
+			}
+			semaphoreWait.WithLabelValues(s.address).ObserveSince(waitStart)
+
+			s.connected.Add(1)
+			activeConnections.WithLabelValues(s.address).Inc()
+			// connections are waited on shutdown, so handlers do not outlive server
+			wg.Add(1)
 			go func(conn net.Conn) {
+				defer wg.Done()
 				defer s.semaphore.Release()
+				defer func() {
+					s.connected.Add(-1)
+					activeConnections.WithLabelValues(s.address).Dec()
+				}()
 
 				defer func() {
 					if r := recover(); r != nil {
@@ -90,7 +193,16 @@ func (s *TCPServer) Run(ctx context.Context, handler TCPHandler) {
 					}
 				}()
 
-				s.handle(ctx, conn, handler)
+				// connection is closed on shutdown, so clients do not wait for response
+				stop := context.AfterFunc(ctx, func() {
+					_ = conn.Close()
+				})
+				defer func() {
+					stop()
+					_ = conn.Close()
+				}()
+
+				handler(ctx, conn)
 			}(conn)
 		}
 	}()
@@ -102,10 +214,6 @@ func (s *TCPServer) Run(ctx context.Context, handler TCPHandler) {
 }
 
 func (s *TCPServer) handle(ctx context.Context, conn net.Conn, handler TCPHandler) {
-	defer func() {
-		_ = conn.Close()
-	}()
-
 	if handler == nil {
 		logger.Error("unable to handle request: no handler")
 		return
@@ -123,29 +231,125 @@ func (s *TCPServer) handle(ctx context.Context, conn net.Conn, handler TCPHandle
 		return
 	}
 
-	buf := make([]byte, maxMessageSize)
+	// requests are handled in order, responses are written by separate
+	// goroutine, so pipelined requests do not wait for previous writes,
+	// pushes of session are written between responses
+	responses := make(chan []byte, maxPipelineDepth)
+	pushes := make(chan []byte, maxPipelineDepth)
+	done := make(chan struct{})
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		s.writeResponses(conn, responses, pushes, idleTimeout)
+	}()
+
+	defer func() {
+		close(done)
+		close(responses)
+		<-writerDone
+	}()
+
+	session := &Session{
+		RemoteAddr: conn.RemoteAddr().String(),
+		pushes:     pushes,
+		done:       done,
+		closeConn: func() {
+			_ = conn.Close()
+		},
+	}
+	ctx = ContextWithSession(ctx, session)
+
+	reader := bufio.NewReader(conn)
 	for {
-		if idleTimeout != 0 {
-			if err := conn.SetDeadline(time.Now().Add(idleTimeout)); err != nil {
-				logger.ErrorWithMsg("unable to set deadline:", err)
+		deadline := time.Time{}
+		if idleTimeout != 0 && !session.KeepAlive {
+			deadline = time.Now().Add(idleTimeout)
+		}
+		if err := conn.SetReadDeadline(deadline); err != nil {
+			logger.ErrorWithMsg("unable to set deadline:", err)
+			return
+		}
+
+		request, err := ReadFrame(reader, maxMessageSize)
+		if err != nil {
+			if errors.Is(err, ErrFrameTooLarge) {
+				s.rejected.Add(1)
+
+				// rest of request is not read, so connection is closed after response
+				if s.tooLargeResponse != nil {
+					responses <- s.tooLargeResponse
+				}
+			}
+
+			if errors.Is(err, io.EOF) {
+				logger.Debug("connection was closed by client")
+			} else {
+				logger.ErrorWithMsg("unable to read request:", err)
+			}
+			return
+		}
+
+		requestCtx, span := trace.Start(ctx, "TCPServer.handle", trace.WithKind(trace.KindServer),
+			trace.WithAttributes(
+				trace.String("net.peer.address", conn.RemoteAddr().String()),
+				trace.Int("request.size", len(request)),
+			))
+		// blocked request is released if client disconnects
+		requestCtx, stopWatch := WatchClose(requestCtx, conn, func() error {
+			_, err := reader.Peek(1)
+			return err
+		})
+		response := handler(requestCtx, request)
+		stopWatch()
+		span.SetAttributes(trace.Int("response.size", len(response)))
+		span.End()
+
+		logger.Info("Sending response to client")
+		responses <- response
+	}
+}
+
+// writeResponses writes responses in order and pushes between them,
+// buffered frames are flushed together when there are no more ready frames
+func (s *TCPServer) writeResponses(conn net.Conn, responses, pushes <-chan []byte, idleTimeout time.Duration) {
+	writer := bufio.NewWriter(conn)
+	failed := false
+
+	for {
+		var frame []byte
+		select {
+		case response, ok := <-responses:
+			if !ok {
 				return
 			}
+			frame = response
+		case push := <-pushes:
+			frame = push
 		}
-		cnt, err := conn.Read(buf)
-		if err != nil {
-			logger.ErrorWithMsg("unable to read request:", err)
-			break
+
+		// frames are drained after failure, so reader is not blocked
+		if failed {
+			continue
 		}
-		if cnt >= maxMessageSize {
-			logger.Error("unable to handle query: too small buffer size")
-			break
+
+		if idleTimeout != 0 {
+			if err := conn.SetWriteDeadline(time.Now().Add(idleTimeout)); err != nil {
+				logger.ErrorWithMsg("unable to set deadline:", err)
+				failed = true
+				_ = conn.Close()
+				continue
+			}
+		}
+
+		err := WriteFrame(writer, frame)
+		if err == nil && len(responses) == 0 && len(pushes) == 0 {
+			err = writer.Flush()
 		}
-		query := string(buf[:cnt])
 
-		logger.Info("Sending response to client")
-		_, err = conn.Write(handler(ctx, []byte(query)))
 		if err != nil {
 			logger.ErrorWithMsg("unable to write response:", err)
+			failed = true
+			_ = conn.Close()
 		}
 	}
 }
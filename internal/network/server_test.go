@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"concurrency_go_course/internal/config"
 	"concurrency_go_course/pkg/logger"
@@ -116,7 +117,11 @@ func TestNewServer(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			server, err := NewServer(tt.cfg, serverAddr)
-			assert.Nil(t, err)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				_ = server.Close()
+			})
+
 			assert.Equal(t, tt.resultServer.cfg, server.cfg)
 		})
 	}
@@ -127,8 +132,6 @@ func TestRun(t *testing.T) {
 
 	logger.MockLogger()
 
-	ctx := context.Background()
-
 	db := NewMockDatabase()
 
 	addr := "127.0.0.1:5555"
@@ -156,7 +159,7 @@ func TestRun(t *testing.T) {
 
 	time.Sleep(100 * time.Millisecond)
 
-	go server.Run(ctx, func(_ context.Context, s []byte) []byte {
+	serveTest(t, server, func(_ context.Context, s []byte) []byte {
 		response, err := db.Handle(string(s))
 		if err != nil {
 			response = err.Error()
@@ -175,18 +178,17 @@ func TestRun(t *testing.T) {
 			t.Errorf("want nil error; got %+v", err)
 		}
 
-		_, err = conn.Write([]byte("first"))
+		err = WriteFrame(conn, []byte("first"))
 		if err != nil {
 			t.Errorf("want nil error; got %+v", err)
 		}
 
-		buffer := make([]byte, 1024)
-		size, err := conn.Read(buffer)
+		response, err := ReadFrame(conn, 1024)
 		if err != nil {
 			t.Errorf("want nil error; got %+v", err)
 		}
 
-		assert.Equal(t, "hello first", string(buffer[:size]))
+		assert.Equal(t, "hello first", string(response))
 	}()
 
 	go func() {
@@ -197,18 +199,17 @@ func TestRun(t *testing.T) {
 			t.Errorf("want nil error; got %+v", err)
 		}
 
-		_, err = conn.Write([]byte("second"))
+		err = WriteFrame(conn, []byte("second"))
 		if err != nil {
 			t.Errorf("want nil error; got %+v", err)
 		}
 
-		buffer := make([]byte, 1024)
-		size, err := conn.Read(buffer)
+		response, err := ReadFrame(conn, 1024)
 		if err != nil {
 			t.Errorf("want nil error; got %+v", err)
 		}
 
-		assert.Equal(t, "hello second", string(buffer[:size]))
+		assert.Equal(t, "hello second", string(response))
 	}()
 
 	wg.Wait()
@@ -217,3 +218,20 @@ func TestRun(t *testing.T) {
 		t.Errorf("unable to close listener %s", err.Error())
 	}
 }
+
+// serveTest runs server until the end of test
+func serveTest(tb testing.TB, server *TCPServer, handler TCPHandler) {
+	tb.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Run(ctx, handler)
+	}()
+
+	tb.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
@@ -0,0 +1,61 @@
+package network
+
+import "context"
+
+// Session is a state of client connection, requests of one connection are
+// handled sequentially, so handler may change session without locking
+type Session struct {
+	RemoteAddr string
+	// User is a name of authenticated user, it is empty until AUTH
+	User string
+	// KeepAlive disables idle timeout of connection, it is set for
+	// connections which wait for pushed frames
+	KeepAlive bool
+
+	pushes    chan<- []byte
+	done      <-chan struct{}
+	closeConn func()
+}
+
+// Push queues frame to be written to connection between responses, it
+// blocks while queue is full and returns false if connection is closed
+// or session does not support pushes
+func (s *Session) Push(frame []byte) bool {
+	if s.pushes == nil {
+		return false
+	}
+
+	select {
+	case s.pushes <- frame:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// Done returns channel which is closed when connection is closed, it is
+// nil for sessions without connection
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close closes connection, handling of its requests is stopped
+func (s *Session) Close() {
+	if s.closeConn != nil {
+		s.closeConn()
+	}
+}
+
+type sessionKey struct{}
+
+// ContextWithSession returns context with connection session
+func ContextWithSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+// SessionFromContext returns connection session, it is nil if request was
+// not received by TCP server
+func SessionFromContext(ctx context.Context) *Session {
+	session, _ := ctx.Value(sessionKey{}).(*Session)
+	return session
+}
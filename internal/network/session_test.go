@@ -0,0 +1,95 @@
+package network
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession(t *testing.T) {
+	t.Parallel()
+
+	addr := "127.0.0.1:5562"
+	runTestServer(t, addr, func(ctx context.Context, data []byte) []byte {
+		session := SessionFromContext(ctx)
+		if session == nil {
+			return []byte("no session")
+		}
+
+		// previous request of connection is visible to next one
+		previous := session.User
+		session.User = string(data)
+		return []byte(session.RemoteAddr + " " + previous)
+	})
+
+	assert.Nil(t, SessionFromContext(context.Background()))
+
+	first, err := NewClient(addr)
+	require.NoError(t, err)
+	defer first.Close()
+
+	second, err := NewClient(addr)
+	require.NoError(t, err)
+	defer second.Close()
+
+	response, err := first.Send([]byte("alice"))
+	require.NoError(t, err)
+	assert.Equal(t, first.conn.LocalAddr().String()+" ", string(response))
+
+	response, err = first.Send([]byte("bob"))
+	require.NoError(t, err)
+	assert.Equal(t, first.conn.LocalAddr().String()+" alice", string(response))
+
+	// sessions are not shared between connections
+	response, err = second.Send([]byte("carol"))
+	require.NoError(t, err)
+	assert.Equal(t, second.conn.LocalAddr().String()+" ", string(response))
+}
+
+func TestSessionPush(t *testing.T) {
+	t.Parallel()
+
+	addr := "127.0.0.1:5563"
+	runTestServer(t, addr, func(ctx context.Context, data []byte) []byte {
+		session := SessionFromContext(ctx)
+
+		if string(data) == "subscribe" {
+			go func() {
+				for _, message := range []string{"first", "second"} {
+					session.Push(NewPushResponse([]byte(message)).Encode())
+				}
+			}()
+		}
+
+		return NewResponse(data).Encode()
+	})
+
+	client, err := NewClient(addr)
+	require.NoError(t, err)
+	defer client.Close()
+
+	pushes := make(chan string, 2)
+	client.SetPushHandler(func(payload []byte) {
+		pushes <- string(payload)
+	})
+
+	response, err := client.Query([]byte("subscribe"))
+	require.NoError(t, err)
+	assert.Equal(t, "subscribe", string(response))
+
+	assert.Equal(t, "first", <-pushes)
+	assert.Equal(t, "second", <-pushes)
+
+	// responses are not mixed with pushes
+	response, err = client.Query([]byte("get"))
+	require.NoError(t, err)
+	assert.Equal(t, "get", string(response))
+
+	client.Close()
+	<-client.Done()
+
+	// session without connection does not accept pushes
+	assert.False(t, (&Session{}).Push([]byte("message")))
+}
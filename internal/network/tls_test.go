@@ -0,0 +1,98 @@
+package network
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"concurrency_go_course/internal/config"
+	"concurrency_go_course/internal/network/tlstest"
+	"concurrency_go_course/pkg/logger"
+)
+
+func TestTLSServer(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	serverAddr := "127.0.0.1:5556"
+	certs := tlstest.Generate(t)
+
+	cfg := &config.Config{
+		Network: &config.NetworkConfig{
+			MaxConnections: 100,
+			MaxMessageSize: "4KB",
+			IdleTimeout:    "5m",
+		},
+	}
+
+	server, err := NewServerWithTLS(cfg, serverAddr, certs.Server())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		server.Run(ctx, func(_ context.Context, data []byte) []byte {
+			return append([]byte("echo "), data...)
+		})
+	}()
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	t.Run("mutual TLS", func(t *testing.T) {
+		client, err := NewClientWithTLS(serverAddr, certs.Client())
+		require.NoError(t, err)
+		defer client.Close()
+
+		response, err := client.Send([]byte("hello"))
+		require.NoError(t, err)
+		assert.Equal(t, "echo hello", string(response))
+	})
+
+	t.Run("client without certificate", func(t *testing.T) {
+		client, err := NewClientWithTLS(serverAddr, &config.TLSConfig{CAFile: certs.CAFile})
+		if err != nil {
+			return
+		}
+		defer client.Close()
+
+		_, err = client.Send([]byte("hello"))
+		assert.Error(t, err)
+	})
+
+	t.Run("untrusted server", func(t *testing.T) {
+		other := tlstest.Generate(t)
+
+		_, err := NewClientWithTLS(serverAddr, other.Client())
+		assert.Error(t, err)
+	})
+
+	t.Run("plain client", func(t *testing.T) {
+		client, err := NewClient(serverAddr)
+		require.NoError(t, err)
+		defer client.Close()
+
+		_, err = client.Send([]byte("hello"))
+		assert.Error(t, err)
+	})
+}
+
+func TestTLSConfigErr(t *testing.T) {
+	t.Parallel()
+
+	_, err := ServerTLSConfig(nil)
+	assert.Error(t, err)
+
+	_, err = ServerTLSConfig(&config.TLSConfig{CAFile: "ca.pem"})
+	assert.EqualError(t, err, "TLS certificate and key are required")
+
+	_, err = ClientTLSConfig(&config.TLSConfig{CAFile: "missing.pem"})
+	assert.Error(t, err)
+}
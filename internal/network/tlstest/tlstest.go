@@ -0,0 +1,139 @@
+// Package tlstest generates certificates for tests
+package tlstest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"concurrency_go_course/internal/config"
+)
+
+// Certificates is a set of certificate files signed by one CA
+type Certificates struct {
+	CAFile     string
+	ServerCert string
+	ServerKey  string
+	ClientCert string
+	ClientKey  string
+}
+
+// Server returns server TLS config with client verification
+func (c Certificates) Server() *config.TLSConfig {
+	return &config.TLSConfig{
+		CertFile: c.ServerCert,
+		KeyFile:  c.ServerKey,
+		CAFile:   c.CAFile,
+	}
+}
+
+// Client returns client TLS config with client certificate
+func (c Certificates) Client() *config.TLSConfig {
+	return &config.TLSConfig{
+		CertFile: c.ClientCert,
+		KeyFile:  c.ClientKey,
+		CAFile:   c.CAFile,
+	}
+}
+
+// Generate writes CA, server and client certificates into temporary
+// directory, server certificate is valid for 127.0.0.1 and localhost
+func Generate(t testing.TB) Certificates {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate CA key: %v", err)
+	}
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("unable to create CA certificate: %v", err)
+	}
+
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatalf("unable to parse CA certificate: %v", err)
+	}
+
+	certs := Certificates{CAFile: filepath.Join(dir, "ca.pem")}
+	writePEM(t, certs.CAFile, "CERTIFICATE", caDER)
+
+	certs.ServerCert, certs.ServerKey = issue(t, dir, "server", ca, caKey, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "server"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+
+	certs.ClientCert, certs.ClientKey = issue(t, dir, "client", ca, caKey, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "client"},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+
+	return certs
+}
+
+func issue(
+	t testing.TB, dir, name string, ca *x509.Certificate, caKey *ecdsa.PrivateKey, template *x509.Certificate,
+) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate %s key: %v", name, err)
+	}
+
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("unable to create %s certificate: %v", name, err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("unable to marshal %s key: %v", name, err)
+	}
+
+	certFile := filepath.Join(dir, name+".pem")
+	keyFile := filepath.Join(dir, name+"-key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+
+	return certFile, keyFile
+}
+
+func writePEM(t testing.TB, filename, blockType string, data []byte) {
+	t.Helper()
+
+	err := os.WriteFile(filename, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), 0o600)
+	if err != nil {
+		t.Fatalf("unable to write %s: %v", filename, err)
+	}
+}
@@ -0,0 +1,89 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// closeCheckDelay is a duration of request handling after which connection
+// is watched for close, so short requests do not pay for watching
+const closeCheckDelay = 50 * time.Millisecond
+
+// closeWatcher reads connection while request is handled to find out that
+// client has disconnected
+type closeWatcher struct {
+	conn   net.Conn
+	peek   func() error
+	cancel context.CancelFunc
+	timer  *time.Timer
+
+	mutex   sync.Mutex
+	stopped bool
+	running chan struct{}
+}
+
+// WatchClose returns context which is canceled if client closes connection
+// while request is handled, so blocked request is released. Peek must wait
+// for data of the next request without consuming it, it is called only
+// after a short delay. Returned function stops watching, it must be called
+// before the next read of connection
+func WatchClose(ctx context.Context, conn net.Conn, peek func() error) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	w := &closeWatcher{
+		conn:   conn,
+		peek:   peek,
+		cancel: cancel,
+	}
+	w.timer = time.AfterFunc(closeCheckDelay, w.watch)
+
+	return ctx, w.stop
+}
+
+func (w *closeWatcher) watch() {
+	w.mutex.Lock()
+	if w.stopped {
+		w.mutex.Unlock()
+		return
+	}
+
+	w.running = make(chan struct{})
+	defer close(w.running)
+
+	// deadline is cleared under lock, so it does not override deadline of
+	// stop, connection is closed if deadline can not be set
+	err := w.conn.SetReadDeadline(time.Time{})
+	w.mutex.Unlock()
+	if err != nil {
+		w.cancel()
+		return
+	}
+
+	// data of the next request means that client is connected, timeout
+	// means that watching is stopped
+	var netErr net.Error
+	if err := w.peek(); err != nil && !(errors.As(err, &netErr) && netErr.Timeout()) {
+		w.cancel()
+	}
+}
+
+func (w *closeWatcher) stop() {
+	w.timer.Stop()
+
+	w.mutex.Lock()
+	w.stopped = true
+	running := w.running
+	w.mutex.Unlock()
+
+	if running != nil {
+		// pending read is interrupted, then connection is readable again
+		_ = w.conn.SetReadDeadline(time.Now())
+		<-running
+		_ = w.conn.SetReadDeadline(time.Time{})
+	}
+
+	w.cancel()
+}
@@ -0,0 +1,90 @@
+package network
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchClose(t *testing.T) {
+	t.Parallel()
+
+	t.Run("client closes connection", func(t *testing.T) {
+		server, client := net.Pipe()
+		defer server.Close()
+
+		reader := bufio.NewReader(server)
+		ctx, stop := WatchClose(context.Background(), server, func() error {
+			_, err := reader.Peek(1)
+			return err
+		})
+		defer stop()
+
+		require.NoError(t, client.Close())
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+			t.Fatal("context is not canceled after close of connection")
+		}
+	})
+
+	t.Run("next request is not consumed", func(t *testing.T) {
+		server, client := net.Pipe()
+		defer server.Close()
+		defer client.Close()
+
+		reader := bufio.NewReader(server)
+		ctx, stop := WatchClose(context.Background(), server, func() error {
+			_, err := reader.Peek(1)
+			return err
+		})
+
+		// watching is started and stopped while client is silent
+		time.Sleep(2 * closeCheckDelay)
+		stop()
+		assert.ErrorIs(t, ctx.Err(), context.Canceled)
+
+		go func() {
+			_, _ = client.Write([]byte("next\n"))
+		}()
+
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "next\n", line)
+	})
+}
+
+func TestServerReleasesRequestOfClosedConnection(t *testing.T) {
+	t.Parallel()
+
+	addr := "127.0.0.1:5565"
+	released := make(chan error, 1)
+	runTestServer(t, addr, func(ctx context.Context, _ []byte) []byte {
+		<-ctx.Done()
+		released <- ctx.Err()
+		return []byte("released")
+	})
+
+	client, err := NewClient(addr)
+	require.NoError(t, err)
+
+	future := client.SendAsync([]byte("BLPOP key 0"))
+	time.Sleep(2 * closeCheckDelay)
+	client.Close()
+
+	select {
+	case err := <-released:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("request is not released after close of connection")
+	}
+
+	_, err = future.Wait()
+	assert.Error(t, err)
+}
@@ -0,0 +1,40 @@
+package pubsub
+
+import (
+	"strings"
+
+	"concurrency_go_course/internal/auth"
+	"concurrency_go_course/pkg/glob"
+)
+
+// Authorizer checks access of user to command category on key or on every
+// key matched by pattern, empty name means anonymous user
+type Authorizer interface {
+	Authorize(name string, category auth.Category, key string) error
+	AuthorizePattern(name string, category auth.Category, pattern string) error
+}
+
+// AuthorizeSubscription checks access of user to messages of channel or of
+// channels matched by pattern. Keyspace channel is checked as read access
+// to its key, other channels are checked as keys like channel of PUBLISH.
+// Keyevent channels carry names of all changed keys, so they and patterns
+// which may match keyspace or keyevent channels of any key require admin
+// access
+func AuthorizeSubscription(authorizer Authorizer, user, name string, pattern bool) error {
+	key, keyspace := strings.CutPrefix(name, KeyspacePrefix)
+
+	switch {
+	case keyspace && pattern:
+		return authorizer.AuthorizePattern(user, auth.CategoryRead, key)
+	case keyspace:
+		return authorizer.Authorize(user, auth.CategoryRead, key)
+	case !pattern && strings.HasPrefix(name, KeyeventPrefix):
+		return authorizer.Authorize(user, auth.CategoryAdmin, "")
+	case !pattern:
+		return authorizer.Authorize(user, auth.CategoryRead, name)
+	case glob.Intersects(name, KeyspacePrefix+"*") || glob.Intersects(name, KeyeventPrefix+"*"):
+		return authorizer.Authorize(user, auth.CategoryAdmin, "")
+	default:
+		return authorizer.AuthorizePattern(user, auth.CategoryRead, name)
+	}
+}
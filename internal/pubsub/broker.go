@@ -0,0 +1,350 @@
+// Package pubsub delivers messages published to channels to subscribers
+// of these channels and of matching glob patterns
+package pubsub
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"concurrency_go_course/pkg/glob"
+	"concurrency_go_course/pkg/metrics"
+)
+
+var (
+	messagesPublished = metrics.Register(metrics.NewCounterVec("pubsub_messages_published_total",
+		"Number of messages published to channels."))
+	messagesDropped = metrics.Register(metrics.NewCounterVec("pubsub_messages_dropped_total",
+		"Number of messages not delivered because buffer of subscriber was full.", "policy"))
+	subscribersDisconnected = metrics.Register(metrics.NewCounterVec("pubsub_subscribers_disconnected_total",
+		"Number of subscribers disconnected because they were too slow."))
+	subscribersActive = metrics.Register(metrics.NewGaugeVec("pubsub_subscribers",
+		"Number of subscribers which are not closed."))
+)
+
+// Policy is a handling of subscriber which buffer is full
+type Policy string
+
+// Policies of slow subscribers
+const (
+	// PolicyDisconnect closes subscriber, so its connection is closed
+	PolicyDisconnect Policy = "disconnect"
+	// PolicyDrop drops messages which do not fit into buffer
+	PolicyDrop Policy = "drop"
+)
+
+// ParsePolicy returns policy by name, empty name is disconnect policy
+func ParsePolicy(name string) (Policy, error) {
+	switch Policy(name) {
+	case "", PolicyDisconnect:
+		return PolicyDisconnect, nil
+	case PolicyDrop:
+		return PolicyDrop, nil
+	default:
+		return "", fmt.Errorf("unknown slow subscriber policy %q", name)
+	}
+}
+
+// defaultBufferSize is a number of messages buffered for each subscriber
+const defaultBufferSize = 256
+
+// Message is a message received by subscriber, pattern is set if message
+// matched pattern subscription
+type Message struct {
+	Pattern string
+	Channel string
+	Payload string
+}
+
+// Broker fans out published messages to subscribers, publishers are
+// never blocked by subscribers
+type Broker struct {
+	bufferSize int
+	policy     Policy
+
+	mutex    sync.RWMutex
+	channels map[string]map[*Subscriber]struct{}
+	patterns map[string]map[*Subscriber]struct{}
+}
+
+// Option is an option of broker
+type Option func(*Broker)
+
+// WithBufferSize sets number of messages buffered for each subscriber
+func WithBufferSize(size int) Option {
+	return func(b *Broker) {
+		if size > 0 {
+			b.bufferSize = size
+		}
+	}
+}
+
+// WithPolicy sets policy of slow subscribers
+func WithPolicy(policy Policy) Option {
+	return func(b *Broker) {
+		b.policy = policy
+	}
+}
+
+// NewBroker returns broker without subscribers, slow subscribers are
+// disconnected by default
+func NewBroker(options ...Option) *Broker {
+	b := &Broker{
+		bufferSize: defaultBufferSize,
+		policy:     PolicyDisconnect,
+		channels:   make(map[string]map[*Subscriber]struct{}),
+		patterns:   make(map[string]map[*Subscriber]struct{}),
+	}
+
+	for _, option := range options {
+		option(b)
+	}
+
+	return b
+}
+
+// Publish sends message to subscribers of channel and of matching
+// patterns, returns number of subscribers which received message
+func (b *Broker) Publish(channel, payload string) int {
+	var slow []*Subscriber
+	received := 0
+
+	b.mutex.RLock()
+	for sub := range b.channels[channel] {
+		if sub.send(Message{Channel: channel, Payload: payload}) {
+			received++
+		} else {
+			slow = append(slow, sub)
+		}
+	}
+
+	for pattern, subs := range b.patterns {
+		if !glob.Match(pattern, channel) {
+			continue
+		}
+
+		for sub := range subs {
+			if sub.send(Message{Pattern: pattern, Channel: channel, Payload: payload}) {
+				received++
+			} else {
+				slow = append(slow, sub)
+			}
+		}
+	}
+	b.mutex.RUnlock()
+
+	messagesPublished.WithLabelValues().Inc()
+	if len(slow) != 0 {
+		messagesDropped.WithLabelValues(string(b.policy)).Add(float64(len(slow)))
+	}
+
+	if b.policy == PolicyDisconnect {
+		for _, sub := range slow {
+			sub.disconnect()
+		}
+	}
+
+	return received
+}
+
+// NewSubscriber returns subscriber without subscriptions, it must be
+// closed by caller
+func (b *Broker) NewSubscriber() *Subscriber {
+	subscribersActive.WithLabelValues().Inc()
+
+	return &Subscriber{
+		broker:   b,
+		messages: make(chan Message, b.bufferSize),
+		channels: make(map[string]struct{}),
+		patterns: make(map[string]struct{}),
+	}
+}
+
+// Subscriber receives messages of its channels and patterns
+type Subscriber struct {
+	broker   *Broker
+	messages chan Message
+
+	// mutex guards subscriptions and state of subscriber, broker mutex
+	// is taken first
+	mutex        sync.Mutex
+	channels     map[string]struct{}
+	patterns     map[string]struct{}
+	closed       bool
+	disconnected bool
+	dropped      uint64
+}
+
+// Messages returns channel of messages, it is closed when subscriber is
+// closed or disconnected
+func (s *Subscriber) Messages() <-chan Message {
+	return s.messages
+}
+
+// Subscribe subscribes to channel, returns number of subscriptions
+func (s *Subscriber) Subscribe(channel string) int {
+	return s.update(s.broker.channels, s.channels, channel, true)
+}
+
+// Unsubscribe unsubscribes from channel, returns number of subscriptions
+func (s *Subscriber) Unsubscribe(channel string) int {
+	return s.update(s.broker.channels, s.channels, channel, false)
+}
+
+// PSubscribe subscribes to channels matching pattern, returns number of
+// subscriptions
+func (s *Subscriber) PSubscribe(pattern string) int {
+	return s.update(s.broker.patterns, s.patterns, pattern, true)
+}
+
+// PUnsubscribe unsubscribes from pattern, returns number of subscriptions
+func (s *Subscriber) PUnsubscribe(pattern string) int {
+	return s.update(s.broker.patterns, s.patterns, pattern, false)
+}
+
+// Channels returns subscribed channels in sorted order
+func (s *Subscriber) Channels() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return sortedKeys(s.channels)
+}
+
+// Patterns returns subscribed patterns in sorted order
+func (s *Subscriber) Patterns() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return sortedKeys(s.patterns)
+}
+
+// Count returns number of subscriptions
+func (s *Subscriber) Count() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.channels) + len(s.patterns)
+}
+
+// Dropped returns number of messages dropped because buffer was full
+func (s *Subscriber) Dropped() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.dropped
+}
+
+// Disconnected returns true if subscriber was closed because its buffer
+// was full, it must be called after messages channel is closed
+func (s *Subscriber) Disconnected() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.disconnected
+}
+
+// Close removes all subscriptions and closes messages channel
+func (s *Subscriber) Close() {
+	s.broker.mutex.Lock()
+	defer s.broker.mutex.Unlock()
+
+	s.close()
+}
+
+func (s *Subscriber) disconnect() {
+	s.broker.mutex.Lock()
+	defer s.broker.mutex.Unlock()
+
+	s.mutex.Lock()
+	// subscriber may be slow for several subscriptions of one message
+	if !s.closed {
+		s.disconnected = true
+		subscribersDisconnected.WithLabelValues().Inc()
+	}
+	s.mutex.Unlock()
+
+	s.close()
+}
+
+// close must be called with broker mutex
+func (s *Subscriber) close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	for channel := range s.channels {
+		removeSubscriber(s.broker.channels, channel, s)
+	}
+	for pattern := range s.patterns {
+		removeSubscriber(s.broker.patterns, pattern, s)
+	}
+
+	clear(s.channels)
+	clear(s.patterns)
+	s.closed = true
+	close(s.messages)
+	subscribersActive.WithLabelValues().Dec()
+}
+
+// send sends message without blocking, returns false if buffer is full,
+// it must be called with broker read mutex
+func (s *Subscriber) send(message Message) bool {
+	select {
+	case s.messages <- message:
+		return true
+	default:
+	}
+
+	s.mutex.Lock()
+	s.dropped++
+	s.mutex.Unlock()
+
+	return false
+}
+
+func (s *Subscriber) update(index map[string]map[*Subscriber]struct{}, own map[string]struct{},
+	name string, subscribe bool,
+) int {
+	s.broker.mutex.Lock()
+	defer s.broker.mutex.Unlock()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return 0
+	}
+
+	if subscribe {
+		own[name] = struct{}{}
+		if index[name] == nil {
+			index[name] = make(map[*Subscriber]struct{})
+		}
+		index[name][s] = struct{}{}
+	} else if _, ok := own[name]; ok {
+		delete(own, name)
+		removeSubscriber(index, name, s)
+	}
+
+	return len(s.channels) + len(s.patterns)
+}
+
+func removeSubscriber(index map[string]map[*Subscriber]struct{}, name string, sub *Subscriber) {
+	delete(index[name], sub)
+	if len(index[name]) == 0 {
+		delete(index, name)
+	}
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
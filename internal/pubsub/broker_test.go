@@ -0,0 +1,242 @@
+package pubsub
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"concurrency_go_course/internal/auth"
+	"concurrency_go_course/internal/storage"
+)
+
+func TestBrokerPublish(t *testing.T) {
+	t.Parallel()
+
+	broker := NewBroker()
+	sub := broker.NewSubscriber()
+	defer sub.Close()
+
+	assert.Equal(t, 1, sub.Subscribe("news"))
+	assert.Equal(t, 2, sub.PSubscribe("user:*"))
+	assert.Equal(t, 2, sub.Subscribe("news"))
+
+	assert.Equal(t, 1, broker.Publish("news", "hello"))
+	assert.Equal(t, 1, broker.Publish("user:1", "alice"))
+	assert.Equal(t, 0, broker.Publish("order:1", "book"))
+
+	assert.Equal(t, Message{Channel: "news", Payload: "hello"}, <-sub.Messages())
+	assert.Equal(t, Message{Pattern: "user:*", Channel: "user:1", Payload: "alice"}, <-sub.Messages())
+	assert.Empty(t, sub.Messages())
+
+	assert.Equal(t, []string{"news"}, sub.Channels())
+	assert.Equal(t, []string{"user:*"}, sub.Patterns())
+
+	assert.Equal(t, 1, sub.Unsubscribe("news"))
+	assert.Equal(t, 1, sub.Unsubscribe("missing"))
+	assert.Equal(t, 0, sub.PUnsubscribe("user:*"))
+	assert.Equal(t, 0, broker.Publish("news", "hello"))
+}
+
+func TestBrokerSlowSubscriber(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                 string
+		policy               Policy
+		expectedReceived     int
+		expectedDisconnected bool
+	}{
+		{
+			name:                 "drop",
+			policy:               PolicyDrop,
+			expectedReceived:     2,
+			expectedDisconnected: false,
+		},
+		{
+			name:                 "disconnect",
+			policy:               PolicyDisconnect,
+			expectedReceived:     2,
+			expectedDisconnected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			broker := NewBroker(WithBufferSize(2), WithPolicy(tt.policy))
+			sub := broker.NewSubscriber()
+			sub.Subscribe("channel")
+
+			for range 3 {
+				broker.Publish("channel", "message")
+			}
+			assert.Equal(t, uint64(1), sub.Dropped())
+
+			if !tt.expectedDisconnected {
+				sub.Close()
+			}
+
+			received := 0
+			for range sub.Messages() {
+				received++
+			}
+			assert.Equal(t, tt.expectedReceived, received)
+			assert.Equal(t, tt.expectedDisconnected, sub.Disconnected())
+			assert.Zero(t, sub.Count())
+
+			// closing disconnected subscriber is safe
+			sub.Close()
+			assert.Equal(t, 0, sub.Subscribe("channel"))
+		})
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	t.Parallel()
+
+	policy, err := ParsePolicy("")
+	require.NoError(t, err)
+	assert.Equal(t, PolicyDisconnect, policy)
+
+	policy, err = ParsePolicy("drop")
+	require.NoError(t, err)
+	assert.Equal(t, PolicyDrop, policy)
+
+	_, err = ParsePolicy("block")
+	assert.Error(t, err)
+}
+
+func TestKeyspaceEngine(t *testing.T) {
+	t.Parallel()
+
+	broker := NewBroker()
+	sub := broker.NewSubscriber()
+	defer sub.Close()
+
+	sub.PSubscribe(KeyspacePrefix + "user:*")
+	sub.Subscribe(KeyeventChannel(EventDel))
+
+	e := NewKeyspaceEngine(storage.NewEngine(4), broker)
+	e.Set("user:1", "alice")
+	e.Set("order:1", "book")
+	e.Delete("order:1")
+
+	assert.Equal(t, Message{Pattern: "__keyspace__:user:*", Channel: "__keyspace__:user:1", Payload: EventSet},
+		<-sub.Messages())
+	assert.Equal(t, Message{Channel: "__keyevent__:del", Payload: "order:1"}, <-sub.Messages())
+	assert.Empty(t, sub.Messages())
+
+	value, ok, _ := e.Get("user:1")
+	assert.True(t, ok)
+	assert.Equal(t, "alice", value)
+
+	_, err := e.HSet("user:2", []string{"name", "bob"})
+	assert.NoError(t, err)
+	_, err = e.HSet("user:1", []string{"name", "alice"})
+	assert.ErrorIs(t, err, storage.ErrWrongType)
+	_, err = e.HDel("user:2", []string{"email"})
+	assert.NoError(t, err)
+	_, err = e.HDel("user:2", []string{"name"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, Message{Pattern: "__keyspace__:user:*", Channel: "__keyspace__:user:2", Payload: EventHSet},
+		<-sub.Messages())
+	assert.Equal(t, Message{Pattern: "__keyspace__:user:*", Channel: "__keyspace__:user:2", Payload: EventHDel},
+		<-sub.Messages())
+	assert.Empty(t, sub.Messages())
+
+	_, err = e.RPush("user:3", []string{"a"})
+	assert.NoError(t, err)
+	_, _, err = e.LPop("user:3")
+	assert.NoError(t, err)
+	_, _, err = e.RPop("user:3")
+	assert.NoError(t, err)
+
+	assert.Equal(t, Message{Pattern: "__keyspace__:user:*", Channel: "__keyspace__:user:3", Payload: EventRPush},
+		<-sub.Messages())
+	assert.Equal(t, Message{Pattern: "__keyspace__:user:*", Channel: "__keyspace__:user:3", Payload: EventLPop},
+		<-sub.Messages())
+	assert.Empty(t, sub.Messages())
+
+	_, err = e.ZAdd("user:4", []storage.ZMember{{Member: "a", Score: 1}})
+	assert.NoError(t, err)
+	_, err = e.ZRem("user:4", []string{"b"})
+	assert.NoError(t, err)
+	_, err = e.ZRemRangeByScore("user:4", storage.ScoreRange{Min: 0, Max: 1})
+	assert.NoError(t, err)
+
+	assert.Equal(t, Message{Pattern: "__keyspace__:user:*", Channel: "__keyspace__:user:4", Payload: EventZAdd},
+		<-sub.Messages())
+	assert.Equal(t, Message{Pattern: "__keyspace__:user:*", Channel: "__keyspace__:user:4", Payload: EventZRemRangeByScore},
+		<-sub.Messages())
+	assert.Empty(t, sub.Messages())
+
+	assert.False(t, e.Expire("user:5", 10))
+	assert.True(t, e.Expire("user:1", 10))
+	assert.False(t, e.DeleteExpired("user:1", 5))
+	assert.True(t, e.DeleteExpired("user:1", 10))
+
+	assert.Equal(t, Message{Pattern: "__keyspace__:user:*", Channel: "__keyspace__:user:1", Payload: EventExpire},
+		<-sub.Messages())
+	assert.Equal(t, Message{Pattern: "__keyspace__:user:*", Channel: "__keyspace__:user:1", Payload: EventExpired},
+		<-sub.Messages())
+	assert.Empty(t, sub.Messages())
+}
+
+func TestAuthorizeSubscription(t *testing.T) {
+	t.Parallel()
+
+	hash, err := auth.HashPassword("secret")
+	require.NoError(t, err)
+
+	users, err := auth.NewUsers(auth.Config{
+		Users: []auth.User{{Name: "root", PasswordHash: hash, Roles: []string{"admin"}}},
+		Roles: []auth.Role{
+			{
+				Name:  "billing",
+				Allow: []auth.Rule{{Categories: []auth.Category{auth.CategoryRead}, Keys: []string{"billing:*"}}},
+			},
+			{
+				Name:  "admin",
+				Allow: []auth.Rule{{Categories: []auth.Category{auth.CategoryRead, auth.CategoryAdmin}}},
+			},
+		},
+		AnonymousRoles: []string{"billing"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		user    string
+		channel string
+		pattern bool
+		allowed bool
+	}{
+		{name: "keyspace of own key", channel: "__keyspace__:billing:1", allowed: true},
+		{name: "keyspace of other key", channel: "__keyspace__:shop:1"},
+		{name: "keyspace of own prefix", channel: "__keyspace__:billing:*", pattern: true, allowed: true},
+		{name: "keyspace of all keys", channel: "__keyspace__:*", pattern: true},
+		{name: "keyevent channel", channel: "__keyevent__:set"},
+		{name: "keyevent pattern", channel: "__keyevent__:*", pattern: true},
+		{name: "catch-all pattern", channel: "*", pattern: true},
+		{name: "pattern matching keyspace", channel: "__key*", pattern: true},
+		{name: "channel of own prefix", channel: "billing:news", allowed: true},
+		{name: "pattern of own prefix", channel: "billing:?", pattern: true, allowed: true},
+		{name: "channel of other prefix", channel: "news"},
+		{name: "admin catch-all pattern", user: "root", channel: "*", pattern: true, allowed: true},
+		{name: "admin keyevent channel", user: "root", channel: "__keyevent__:del", allowed: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := AuthorizeSubscription(users, test.user, test.channel, test.pattern)
+			if test.allowed {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, auth.ErrPermissionDenied)
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package pubsub
+
+import (
+	"concurrency_go_course/internal/storage"
+)
+
+// Prefixes of keyspace notification channels, message of keyspace channel
+// is an event name and message of keyevent channel is a key
+const (
+	KeyspacePrefix = "__keyspace__:"
+	KeyeventPrefix = "__keyevent__:"
+)
+
+// Keyspace events
+const (
+	EventSet   = "set"
+	EventDel   = "del"
+	EventHSet  = "hset"
+	EventHDel  = "hdel"
+	EventLPush = "lpush"
+	EventRPush = "rpush"
+	EventLPop  = "lpop"
+	EventRPop  = "rpop"
+	EventZAdd  = "zadd"
+	EventZRem  = "zrem"
+	// EventZRemRangeByScore is an event of ZREMRANGEBYSCORE
+	EventZRemRangeByScore = "zrembyscore"
+	// EventExpire is an event of EXPIRE, EventExpired is notified when
+	// expired key is deleted
+	EventExpire  = "expire"
+	EventExpired = "expired"
+)
+
+// KeyspaceChannel returns channel of events of key
+func KeyspaceChannel(key string) string {
+	return KeyspacePrefix + key
+}
+
+// KeyeventChannel returns channel of keys changed by event
+func KeyeventChannel(event string) string {
+	return KeyeventPrefix + event
+}
+
+// keyspaceEngine publishes keyspace notifications for changes applied to
+// wrapped engine, so writes of clients, WAL recovery and replication
+// stream are all notified
+type keyspaceEngine struct {
+	storage.Engine
+	broker *Broker
+}
+
+// NewKeyspaceEngine returns engine which publishes keyspace notifications
+// to broker, snapshot restore replaces data without notifications
+func NewKeyspaceEngine(e storage.Engine, broker *Broker) storage.Engine {
+	return &keyspaceEngine{
+		Engine: e,
+		broker: broker,
+	}
+}
+
+// Set sets value and notifies set event
+func (e *keyspaceEngine) Set(key string, value string) {
+	e.Engine.Set(key, value)
+	e.notify(EventSet, key)
+}
+
+// Delete deletes key and notifies del event
+func (e *keyspaceEngine) Delete(key string) {
+	e.Engine.Delete(key)
+	e.notify(EventDel, key)
+}
+
+// HSet sets fields of hash and notifies hset event
+func (e *keyspaceEngine) HSet(key string, fields []string) (int, error) {
+	added, err := e.Engine.HSet(key, fields)
+	if err == nil {
+		e.notify(EventHSet, key)
+	}
+	return added, err
+}
+
+// HDel deletes fields of hash and notifies hdel event if any field was
+// deleted
+func (e *keyspaceEngine) HDel(key string, fields []string) (int, error) {
+	deleted, err := e.Engine.HDel(key, fields)
+	if deleted != 0 {
+		e.notify(EventHDel, key)
+	}
+	return deleted, err
+}
+
+// LPush inserts values at the head of list and notifies lpush event
+func (e *keyspaceEngine) LPush(key string, values []string) (int, error) {
+	length, err := e.Engine.LPush(key, values)
+	if err == nil {
+		e.notify(EventLPush, key)
+	}
+	return length, err
+}
+
+// RPush appends values to the tail of list and notifies rpush event
+func (e *keyspaceEngine) RPush(key string, values []string) (int, error) {
+	length, err := e.Engine.RPush(key, values)
+	if err == nil {
+		e.notify(EventRPush, key)
+	}
+	return length, err
+}
+
+// LPop removes the first value of list and notifies lpop event if value
+// was removed
+func (e *keyspaceEngine) LPop(key string) (string, bool, error) {
+	value, found, err := e.Engine.LPop(key)
+	if found {
+		e.notify(EventLPop, key)
+	}
+	return value, found, err
+}
+
+// RPop removes the last value of list and notifies rpop event if value
+// was removed
+func (e *keyspaceEngine) RPop(key string) (string, bool, error) {
+	value, found, err := e.Engine.RPop(key)
+	if found {
+		e.notify(EventRPop, key)
+	}
+	return value, found, err
+}
+
+// ZAdd sets scores of sorted set members and notifies zadd event
+func (e *keyspaceEngine) ZAdd(key string, members []storage.ZMember) (int, error) {
+	added, err := e.Engine.ZAdd(key, members)
+	if err == nil {
+		e.notify(EventZAdd, key)
+	}
+	return added, err
+}
+
+// ZRem removes members of sorted set and notifies zrem event if any member
+// was removed
+func (e *keyspaceEngine) ZRem(key string, members []string) (int, error) {
+	removed, err := e.Engine.ZRem(key, members)
+	if removed != 0 {
+		e.notify(EventZRem, key)
+	}
+	return removed, err
+}
+
+// ZRemRangeByScore removes members of sorted set with score in range and
+// notifies zrembyscore event if any member was removed
+func (e *keyspaceEngine) ZRemRangeByScore(key string, r storage.ScoreRange) (int, error) {
+	removed, err := e.Engine.ZRemRangeByScore(key, r)
+	if removed != 0 {
+		e.notify(EventZRemRangeByScore, key)
+	}
+	return removed, err
+}
+
+// Expire sets expiration deadline of key and notifies expire event if key
+// exists
+func (e *keyspaceEngine) Expire(key string, deadline int64) bool {
+	ok := e.Engine.Expire(key, deadline)
+	if ok {
+		e.notify(EventExpire, key)
+	}
+	return ok
+}
+
+// DeleteExpired deletes expired key and notifies expired event if key was
+// deleted
+func (e *keyspaceEngine) DeleteExpired(key string, deadline int64) bool {
+	deleted := e.Engine.DeleteExpired(key, deadline)
+	if deleted {
+		e.notify(EventExpired, key)
+	}
+	return deleted
+}
+
+func (e *keyspaceEngine) notify(event, key string) {
+	e.broker.Publish(KeyspaceChannel(key), event)
+	e.broker.Publish(KeyeventChannel(event), key)
+}
@@ -0,0 +1,71 @@
+package replication
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"concurrency_go_course/internal/config"
+)
+
+const (
+	roleMaster  = "master"
+	roleReplica = "replica"
+)
+
+// errAuthFailed is returned if HMAC proof does not match
+var errAuthFailed = errors.New("shared secret authentication failed")
+
+// Challenge is sent by master if shared secret is configured, proof
+// authenticates master to replica
+type Challenge struct {
+	Nonce []byte
+	Proof []byte
+}
+
+// sign returns HMAC of role and parts with shared secret
+func sign(secret []byte, role string, parts ...[]byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(role))
+	for _, part := range parts {
+		// parts are separated by length to avoid ambiguous concatenation
+		mac.Write([]byte{byte(len(part) >> 8), byte(len(part))})
+		mac.Write(part)
+	}
+
+	return mac.Sum(nil)
+}
+
+// masterProof returns master proof for replica nonce
+func masterProof(secret []byte, replicaNonce []byte) []byte {
+	return sign(secret, roleMaster, replicaNonce)
+}
+
+// replicaProof returns replica proof for master challenge
+func replicaProof(secret []byte, challenge []byte, handshake Handshake) []byte {
+	return sign(secret, roleReplica, challenge, []byte(handshake.ReplicaID), []byte(handshake.ClusterID))
+}
+
+func newNonce() ([]byte, error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return nonce, nil
+}
+
+// validateTLS checks that TLS config is suitable for mutual authentication
+func validateTLS(cfg *config.TLSConfig) error {
+	if cfg == nil {
+		return nil
+	}
+
+	if cfg.CertFile == "" || cfg.KeyFile == "" || cfg.CAFile == "" {
+		return fmt.Errorf("replication TLS requires certificate, key and CA files")
+	}
+
+	return nil
+}
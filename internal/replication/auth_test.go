@@ -0,0 +1,167 @@
+package replication
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"concurrency_go_course/internal/config"
+	"concurrency_go_course/internal/network/tlstest"
+	"concurrency_go_course/internal/storage/wal"
+	"concurrency_go_course/pkg/logger"
+)
+
+func TestReplicationAuth(t *testing.T) {
+	logger.MockLogger()
+
+	masterAddr := "127.0.0.1:9994"
+	certs := tlstest.Generate(t)
+
+	newConfig := func(replicaType string, tls *config.TLSConfig, secret string) *config.Config {
+		return &config.Config{
+			Network: &config.NetworkConfig{
+				Address:        "127.0.0.1:9993",
+				MaxConnections: 100,
+				MaxMessageSize: "4KB",
+				IdleTimeout:    "5m",
+			},
+			Replication: &config.ReplicationConfig{
+				ReplicaType:   replicaType,
+				MasterAddress: masterAddr,
+				ClusterID:     "cluster",
+				TLS:           tls,
+				SharedSecret:  secret,
+			},
+		}
+	}
+
+	walCfg := &config.WALCfg{
+		WalConfig: &config.WALSettings{
+			DataDirectory: "test_data",
+		},
+	}
+
+	master, err := NewReplicationServer(newConfig("master", certs.Server(), "secret"), walCfg, &wal.Position{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		master.Start(ctx)
+	}()
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	tests := []struct {
+		name   string
+		tls    *config.TLSConfig
+		secret string
+
+		expectedErr string
+	}{
+		{
+			name:   "valid certificate and secret",
+			tls:    certs.Client(),
+			secret: "secret",
+		},
+		{
+			name:        "wrong secret",
+			tls:         certs.Client(),
+			secret:      "wrong",
+			expectedErr: "replication handshake rejected: master shared secret authentication failed",
+		},
+		{
+			name:        "no secret",
+			tls:         certs.Client(),
+			expectedErr: "replication handshake rejected: master requires shared secret",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slave, err := NewReplicationClient(newConfig("slave", tt.tls, tt.secret), walCfg)
+			require.NoError(t, err)
+			defer slave.disconnect()
+
+			err = slave.handshake()
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				assert.ErrorIs(t, err, errHandshakeRejected)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.NotEmpty(t, slave.session.id)
+		})
+	}
+
+	t.Run("untrusted certificate", func(t *testing.T) {
+		other := tlstest.Generate(t)
+
+		slave, err := NewReplicationClient(newConfig("slave", other.Client(), "secret"), walCfg)
+		require.NoError(t, err)
+
+		err = slave.handshake()
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, errHandshakeRejected)
+	})
+}
+
+func TestMasterChallenge(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	secret := []byte("secret")
+	master := &Master{
+		clusterID:    "cluster",
+		capabilities: SupportedCapabilities,
+		sessions:     make(map[string]*session),
+		replicas:     make(map[string]ReplicaStats),
+		secret:       secret,
+		challenges:   make(map[string]time.Time),
+	}
+
+	handshake := NewHandshake("replica", "cluster", nil)
+	handshake.Nonce = []byte("nonce")
+
+	response := master.handshake(handshake)
+	require.NotNil(t, response.Challenge)
+	assert.False(t, response.Succeed)
+	assert.Equal(t, masterProof(secret, handshake.Nonce), response.Challenge.Proof)
+
+	handshake.Challenge = response.Challenge.Nonce
+	handshake.Proof = replicaProof(secret, handshake.Challenge, handshake)
+
+	response = master.handshake(handshake)
+	assert.True(t, response.Succeed)
+
+	// challenge can not be replayed
+	response = master.handshake(handshake)
+	assert.Equal(t, errAuthFailed.Error(), response.Error)
+
+	// proof is bound to replica ID
+	response = master.handshake(NewHandshake("replica", "cluster", nil))
+	handshake.Challenge = response.Challenge.Nonce
+	handshake.Proof = replicaProof(secret, handshake.Challenge, handshake)
+	handshake.ReplicaID = "other"
+
+	response = master.handshake(handshake)
+	assert.Equal(t, errAuthFailed.Error(), response.Error)
+}
+
+func TestValidateTLS(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, validateTLS(nil))
+	assert.Error(t, validateTLS(&config.TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem"}))
+	assert.NoError(t, validateTLS(&config.TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem", CAFile: "ca.pem"}))
+}
@@ -0,0 +1,54 @@
+package replication
+
+import (
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// backoff is a jittered exponential backoff, delay is doubled after each
+// attempt and randomized in [delay/2, delay]
+type backoff struct {
+	min     time.Duration
+	max     time.Duration
+	attempt int
+	random  *rand.Rand
+}
+
+func newBackoff(minDelay, maxDelay time.Duration) *backoff {
+	if minDelay <= 0 {
+		minDelay = defaultMinBackoff
+	}
+
+	if maxDelay < minDelay {
+		maxDelay = max(defaultMaxBackoff, minDelay)
+	}
+
+	return &backoff{
+		min:    minDelay,
+		max:    maxDelay,
+		random: rand.New(rand.NewSource(time.Now().UnixNano())), //nolint:gosec
+	}
+}
+
+// Next returns delay before the next attempt
+func (b *backoff) Next() time.Duration {
+	delay := b.min
+	for i := 0; i < b.attempt && delay < b.max; i++ {
+		delay *= 2
+	}
+	delay = min(delay, b.max)
+	b.attempt++
+
+	half := delay / 2
+	return half + time.Duration(b.random.Int63n(int64(delay-half)+1))
+}
+
+// Reset resets delay after successful attempt
+func (b *backoff) Reset() {
+	b.attempt = 0
+}
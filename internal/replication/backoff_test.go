@@ -0,0 +1,40 @@
+package replication
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+
+	b := newBackoff(100*time.Millisecond, time.Second)
+
+	expected := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+
+	for _, delay := range expected {
+		next := b.Next()
+		assert.GreaterOrEqual(t, next, delay/2)
+		assert.LessOrEqual(t, next, delay)
+	}
+
+	b.Reset()
+	assert.LessOrEqual(t, b.Next(), 100*time.Millisecond)
+}
+
+func TestBackoffDefaults(t *testing.T) {
+	t.Parallel()
+
+	b := newBackoff(0, 0)
+	assert.Equal(t, defaultMinBackoff, b.min)
+	assert.Equal(t, defaultMaxBackoff, b.max)
+}
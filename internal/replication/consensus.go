@@ -0,0 +1,111 @@
+package replication
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"concurrency_go_course/internal/config"
+	"concurrency_go_course/internal/replication/raft"
+	"concurrency_go_course/internal/storage/wal"
+)
+
+const (
+	defaultTickInterval   = 100 * time.Millisecond
+	defaultProposeTimeout = 5 * time.Second
+	defaultRaftDirectory  = "raft"
+)
+
+// Raft is a struct for raft consensus replication
+type Raft struct {
+	node           *raft.Node
+	transport      *raft.NetworkTransport
+	tickInterval   time.Duration
+	proposeTimeout time.Duration
+}
+
+// NewRaft creates raft node with TCP transport, state is persisted
+// in raft data directory
+func NewRaft(cfg *config.Config, stateMachine raft.StateMachine) (*Raft, error) {
+	if cfg == nil || cfg.Replication == nil || cfg.Replication.Raft == nil {
+		return nil, fmt.Errorf("raft config is empty")
+	}
+
+	raftCfg := cfg.Replication.Raft
+
+	transport, err := raft.NewNetworkTransport(cfg, raftCfg.NodeID, raftCfg.Peers)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create raft transport: %w", err)
+	}
+
+	directory := raftCfg.DataDirectory
+	if directory == "" {
+		directory = filepath.Join(defaultRaftDirectory, raftCfg.NodeID)
+	}
+
+	persister, err := raft.NewFilePersister(directory)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create raft persister: %w", err)
+	}
+
+	peers := make([]string, 0, len(raftCfg.Peers))
+	for id := range raftCfg.Peers {
+		peers = append(peers, id)
+	}
+
+	node, err := raft.NewNode(raft.Settings{
+		ID:                raftCfg.NodeID,
+		Peers:             peers,
+		ElectionTicks:     raftCfg.ElectionTicks,
+		HeartbeatTicks:    raftCfg.HeartbeatTicks,
+		SnapshotThreshold: raftCfg.SnapshotThreshold,
+	}, stateMachine, persister, transport)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Raft{
+		node:           node,
+		transport:      transport,
+		tickInterval:   raftCfg.TickInterval,
+		proposeTimeout: raftCfg.ProposeTimeout,
+	}
+
+	if r.tickInterval == 0 {
+		r.tickInterval = defaultTickInterval
+	}
+
+	if r.proposeTimeout == 0 {
+		r.proposeTimeout = defaultProposeTimeout
+	}
+
+	return r, nil
+}
+
+// Start starts raft node and transport
+func (r *Raft) Start(ctx context.Context) {
+	go r.node.Run(ctx, r.tickInterval)
+	r.transport.Run(ctx, r.node)
+}
+
+// Propose replicates request through raft log, it returns result of
+// state machine
+func (r *Raft) Propose(ctx context.Context, request wal.Request) (any, error) {
+	return r.node.Propose(ctx, request)
+}
+
+// ProposeTimeout returns timeout for proposals
+func (r *Raft) ProposeTimeout() time.Duration {
+	return r.proposeTimeout
+}
+
+// Status returns raft node status
+func (r *Raft) Status() raft.Status {
+	return r.node.Status()
+}
+
+// IsMaster returns flag
+func (r *Raft) IsMaster() bool {
+	return r.node.Status().State == raft.StateLeader
+}
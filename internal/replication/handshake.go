@@ -0,0 +1,124 @@
+package replication
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+const (
+	// ProtocolVersion is current replication protocol version
+	ProtocolVersion = 1
+	// MinProtocolVersion is the oldest supported replication protocol version
+	MinProtocolVersion = 1
+
+	// CapabilityCompression allows gzip compression of segment data
+	CapabilityCompression = "compression"
+	// CapabilityStreaming allows sending all pending segments in one response
+	CapabilityStreaming = "streaming"
+)
+
+// SupportedCapabilities is a list of capabilities known by this node
+var SupportedCapabilities = []string{CapabilityCompression, CapabilityStreaming}
+
+// ErrHandshakeRequired is returned by master for requests without session
+var ErrHandshakeRequired = errors.New("replication handshake required")
+
+// errHandshakeRejected is returned on slave if master rejected replica
+var errHandshakeRejected = errors.New("replication handshake rejected")
+
+// Handshake is a first message from slave
+type Handshake struct {
+	ProtocolVersion int
+	ReplicaID       string
+	ClusterID       string
+	Capabilities    []string
+
+	// Nonce is a replica challenge for master if shared secret is used
+	Nonce []byte
+	// Challenge and Proof are a response for master challenge
+	Challenge []byte
+	Proof     []byte
+}
+
+// HandshakeResponse is a master response for handshake
+type HandshakeResponse struct {
+	ProtocolVersion int
+	MasterID        string
+	ClusterID       string
+	SessionID       string
+	Capabilities    []string
+}
+
+// session is a negotiated replication session
+type session struct {
+	id           string
+	replicaID    string
+	capabilities []string
+}
+
+func (s *session) has(capability string) bool {
+	return s != nil && slices.Contains(s.capabilities, capability)
+}
+
+// NewHandshake returns new handshake message
+func NewHandshake(replicaID, clusterID string, capabilities []string) Handshake {
+	return Handshake{
+		ProtocolVersion: ProtocolVersion,
+		ReplicaID:       replicaID,
+		ClusterID:       clusterID,
+		Capabilities:    capabilities,
+	}
+}
+
+// Negotiate checks handshake and returns capabilities enabled on both sides
+func Negotiate(handshake Handshake, clusterID string, capabilities []string) ([]string, error) {
+	if handshake.ProtocolVersion < MinProtocolVersion || handshake.ProtocolVersion > ProtocolVersion {
+		return nil, fmt.Errorf("incompatible protocol version %d, supported versions %d-%d",
+			handshake.ProtocolVersion, MinProtocolVersion, ProtocolVersion)
+	}
+
+	if handshake.ReplicaID == "" {
+		return nil, errors.New("replica id is empty")
+	}
+
+	if handshake.ClusterID != clusterID {
+		return nil, fmt.Errorf("cluster id mismatch: expected %q, got %q",
+			clusterID, handshake.ClusterID)
+	}
+
+	negotiated := make([]string, 0, len(handshake.Capabilities))
+	for _, capability := range handshake.Capabilities {
+		if slices.Contains(capabilities, capability) && !slices.Contains(negotiated, capability) {
+			negotiated = append(negotiated, capability)
+		}
+	}
+	slices.Sort(negotiated)
+
+	return negotiated, nil
+}
+
+func newSessionID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buf), nil
+}
+
+func capabilitiesFromConfig(capabilities []string) ([]string, error) {
+	if capabilities == nil {
+		return SupportedCapabilities, nil
+	}
+
+	for _, capability := range capabilities {
+		if !slices.Contains(SupportedCapabilities, capability) {
+			return nil, fmt.Errorf("unknown replication capability %s", capability)
+		}
+	}
+
+	return capabilities, nil
+}
@@ -0,0 +1,163 @@
+package replication
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"concurrency_go_course/internal/filesystem"
+	"concurrency_go_course/pkg/logger"
+)
+
+func TestNegotiate(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	tests := []struct {
+		name      string
+		handshake Handshake
+		caps      []string
+
+		expected    []string
+		expectedErr string
+	}{
+		{
+			name:      "all capabilities",
+			handshake: NewHandshake("replica", "cluster", []string{CapabilityStreaming, CapabilityCompression}),
+			caps:      SupportedCapabilities,
+			expected:  []string{CapabilityCompression, CapabilityStreaming},
+		},
+		{
+			name:      "capabilities intersection",
+			handshake: NewHandshake("replica", "cluster", []string{CapabilityStreaming, "unknown"}),
+			caps:      []string{CapabilityCompression, CapabilityStreaming},
+			expected:  []string{CapabilityStreaming},
+		},
+		{
+			name:      "no capabilities",
+			handshake: NewHandshake("replica", "cluster", nil),
+			caps:      SupportedCapabilities,
+			expected:  []string{},
+		},
+		{
+			name:        "cluster mismatch",
+			handshake:   NewHandshake("replica", "other", nil),
+			caps:        SupportedCapabilities,
+			expectedErr: `cluster id mismatch: expected "cluster", got "other"`,
+		},
+		{
+			name:        "empty replica id",
+			handshake:   NewHandshake("", "cluster", nil),
+			caps:        SupportedCapabilities,
+			expectedErr: "replica id is empty",
+		},
+		{
+			name: "incompatible version",
+			handshake: Handshake{
+				ProtocolVersion: ProtocolVersion + 1,
+				ReplicaID:       "replica",
+				ClusterID:       "cluster",
+			},
+			caps:        SupportedCapabilities,
+			expectedErr: "incompatible protocol version 2, supported versions 1-1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			negotiated, err := Negotiate(tt.handshake, "cluster", tt.caps)
+			if tt.expectedErr != "" {
+				assert.EqualError(t, err, tt.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, negotiated)
+		})
+	}
+}
+
+func TestMasterHandshake(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	master := &Master{
+		walDirectory: "test_data",
+		fileLib:      filesystem.NewFileLib(),
+		position:     walPosition(t, "test_data"),
+		id:           "master",
+		clusterID:    "cluster",
+		capabilities: SupportedCapabilities,
+		sessions:     make(map[string]*session),
+		replicas:     make(map[string]ReplicaStats),
+	}
+
+	handle := func(req SlaveRequest) *MasterResponse {
+		data, err := EncodeSlaveRequest(&req)
+		require.NoError(t, err)
+
+		response := &MasterResponse{}
+		require.NoError(t, DecodeResponse(response, master.handle(context.Background(), data)))
+		return response
+	}
+
+	rejected := NewHandshake("replica", "other", SupportedCapabilities)
+	response := handle(SlaveRequest{Handshake: &rejected})
+	assert.False(t, response.Succeed)
+	assert.Contains(t, response.Error, "cluster id mismatch")
+
+	handshake := NewHandshake("replica", "cluster", SupportedCapabilities)
+	response = handle(SlaveRequest{Handshake: &handshake})
+	require.True(t, response.Succeed)
+	require.NotNil(t, response.Handshake)
+	assert.Equal(t, "master", response.Handshake.MasterID)
+	assert.Equal(t, []string{CapabilityCompression, CapabilityStreaming}, response.Handshake.Capabilities)
+
+	oldSession := response.Handshake.SessionID
+
+	// the second handshake of the same replica replaces session
+	response = handle(SlaveRequest{Handshake: &handshake})
+	require.True(t, response.Succeed)
+
+	stale := handle(SlaveRequest{SessionID: oldSession})
+	assert.Equal(t, ErrHandshakeRequired.Error(), stale.Error)
+
+	response = handle(SlaveRequest{LastSegmentName: "wal_0.log", SessionID: response.Handshake.SessionID})
+	require.True(t, response.Succeed)
+	assert.True(t, response.Compressed)
+	assert.Empty(t, response.SegmentName)
+
+	segments, err := responseSegments(response)
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	assert.Equal(t, "wal_1.log", segments[0].Name)
+
+	expected, err := master.fileLib.DataFromFiles("test_data", []string{"wal_1.log"})
+	require.NoError(t, err)
+	assert.Equal(t, expected[0], segments[0].Data)
+
+	replicas := master.Replicas()
+	require.Len(t, replicas, 1)
+	assert.Equal(t, "replica", replicas[0].ID)
+	assert.Equal(t, int64(len(expected[0])), replicas[0].LagBytes)
+}
+
+func TestCompressData(t *testing.T) {
+	t.Parallel()
+
+	data := []byte("SET key value SET key value SET key value")
+
+	compressed, err := CompressData(data)
+	require.NoError(t, err)
+
+	decompressed, err := DecompressData(compressed)
+	require.NoError(t, err)
+	assert.Equal(t, data, decompressed)
+
+	_, err = DecompressData(data)
+	assert.Error(t, err)
+}
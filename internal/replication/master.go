@@ -2,23 +2,69 @@ package replication
 
 import (
 	"context"
+	"crypto/hmac"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"concurrency_go_course/internal/config"
 	"concurrency_go_course/internal/filesystem"
 	"concurrency_go_course/internal/network"
 	"concurrency_go_course/pkg/logger"
+	"concurrency_go_course/pkg/metrics"
+	"concurrency_go_course/pkg/trace"
 
 	"go.uber.org/zap"
 )
 
+const (
+	maxStreamSegments = 16
+	maxStreamSize     = 8 << 20
+	challengeTTL      = 30 * time.Second
+)
+
+var (
+	replicaLag = metrics.Register(metrics.NewGaugeVec("replication_replica_lag_bytes",
+		"Size of WAL which replica has not received at its last sync.", "replica"))
+	replicaLastSync = metrics.Register(metrics.NewGaugeVec("replication_replica_last_sync_timestamp_seconds",
+		"Unix time of the last sync request of replica.", "replica"))
+)
+
 // Master is a struct for master node
 type Master struct {
 	server       *network.TCPServer
 	walDirectory string
 	fileLib      filesystem.FileLib
+	// position is a position of WAL kept in memory, so syncs of replicas
+	// do not read all segments
+	position WALPosition
+
+	id           string
+	clusterID    string
+	capabilities []string
+
+	mutexSessions sync.Mutex
+	sessions      map[string]*session
+	// replicas are states of replicas by id at their last sync
+	replicas map[string]ReplicaStats
+
+	// secret is a shared secret for HMAC challenge, issued challenges
+	// are stored until they are answered or expired
+	secret          []byte
+	mutexChallenges sync.Mutex
+	challenges      map[string]time.Time
+}
+
+// WALPosition is a position of requests written to WAL segments
+type WALPosition interface {
+	LastLSN() uint64
+	SizeAfter(segment string, offset int64) int64
 }
 
 // TCPServer is interface for TCP server
@@ -31,8 +77,9 @@ func (m *Master) IsMaster() bool {
 	return true
 }
 
-// NewReplicationServer creates new master replication server
-func NewReplicationServer(cfg *config.Config, walCfg *config.WALCfg) (*Master, error) {
+// NewReplicationServer creates new master replication server, position
+// is a position of WAL written to segments of WAL config
+func NewReplicationServer(cfg *config.Config, walCfg *config.WALCfg, position WALPosition) (*Master, error) {
 	if cfg == nil || cfg.Replication == nil {
 		return nil, fmt.Errorf("config is empty")
 	}
@@ -41,15 +88,41 @@ func NewReplicationServer(cfg *config.Config, walCfg *config.WALCfg) (*Master, e
 		return nil, fmt.Errorf("WAL config is empty")
 	}
 
-	server, err := network.NewServer(cfg, cfg.Replication.MasterAddress)
+	if position == nil {
+		return nil, fmt.Errorf("WAL position is empty")
+	}
+
+	capabilities, err := capabilitiesFromConfig(cfg.Replication.Capabilities)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = validateTLS(cfg.Replication.TLS); err != nil {
+		return nil, err
+	}
+
+	server, err := network.NewServerWithTLS(cfg, cfg.Replication.MasterAddress, cfg.Replication.TLS)
 	if err != nil {
 		return nil, err
 	}
 
+	id := cfg.Replication.ReplicaID
+	if id == "" {
+		id = cfg.Replication.MasterAddress
+	}
+
 	return &Master{
 		server:       server,
 		walDirectory: walCfg.WalConfig.DataDirectory,
 		fileLib:      filesystem.NewFileLib(),
+		position:     position,
+		id:           id,
+		clusterID:    cfg.Replication.ClusterID,
+		capabilities: capabilities,
+		sessions:     make(map[string]*session),
+		replicas:     make(map[string]ReplicaStats),
+		secret:       []byte(cfg.Replication.SharedSecret),
+		challenges:   make(map[string]time.Time),
 	}, nil
 }
 
@@ -61,32 +134,275 @@ func (m *Master) Start(ctx context.Context) {
 			return nil
 		}
 
-		var request SlaveRequest
-		if err := DecodeSlaveRequest(&request, requestData); err != nil {
-			logger.Error("unable to decode replication request", zap.Error(err))
-			return nil
+		return m.handle(ctx, requestData)
+	})
+}
+
+func (m *Master) handle(ctx context.Context, requestData []byte) []byte {
+	var request SlaveRequest
+	if err := DecodeSlaveRequest(&request, requestData); err != nil {
+		logger.Error("unable to decode replication request", zap.Error(err))
+		return nil
+	}
+
+	// spans of request continue trace of slave
+	if request.Traceparent != "" {
+		if parent, err := trace.ParseTraceparent(request.Traceparent); err == nil {
+			ctx = trace.ContextWithRemote(ctx, parent)
 		}
+	}
+	_, span := trace.Start(ctx, "Master.handle", trace.WithKind(trace.KindServer))
+	defer span.End()
 
-		response := m.lastSegment(request)
-		responseData, err := EncodeResponse(&response)
-		if err != nil {
-			logger.Error("unable to encode replication response", zap.Error(err))
+	var response MasterResponse
+	if request.Handshake != nil {
+		response = m.handshake(*request.Handshake)
+	} else if sess := m.session(request.SessionID); sess == nil {
+		response = MasterResponse{Error: ErrHandshakeRequired.Error()}
+	} else {
+		span.SetAttributes(trace.String("replication.replica", sess.replicaID))
+		response = m.sync(request, sess)
+	}
+
+	if response.Error != "" {
+		span.RecordError(errors.New(response.Error))
+	}
+
+	responseData, err := EncodeResponse(&response)
+	if err != nil {
+		logger.Error("unable to encode replication response", zap.Error(err))
+	}
+
+	return responseData
+}
+
+func (m *Master) handshake(handshake Handshake) MasterResponse {
+	if len(m.secret) != 0 {
+		if handshake.Proof == nil {
+			return m.challenge(handshake)
+		}
+
+		if !m.verify(handshake) {
+			logger.Error("replica was rejected", zap.String("replica_id", handshake.ReplicaID),
+				zap.Error(errAuthFailed))
+			return MasterResponse{Error: errAuthFailed.Error()}
+		}
+	}
+
+	capabilities, err := Negotiate(handshake, m.clusterID, m.capabilities)
+	if err != nil {
+		logger.Error("replica was rejected", zap.String("replica_id", handshake.ReplicaID),
+			zap.Int("protocol_version", handshake.ProtocolVersion), zap.Error(err))
+		return MasterResponse{Error: err.Error()}
+	}
+
+	id, err := newSessionID()
+	if err != nil {
+		logger.Error("unable to create replication session", zap.Error(err))
+		return MasterResponse{Error: "unable to create session"}
+	}
+
+	m.mutexSessions.Lock()
+	for sessionID, sess := range m.sessions {
+		if sess.replicaID == handshake.ReplicaID {
+			delete(m.sessions, sessionID)
+		}
+	}
+	m.sessions[id] = &session{id: id, replicaID: handshake.ReplicaID, capabilities: capabilities}
+	m.mutexSessions.Unlock()
+
+	logger.Info("replica was connected", zap.String("replica_id", handshake.ReplicaID),
+		zap.Strings("capabilities", capabilities))
+
+	return MasterResponse{
+		Succeed: true,
+		Handshake: &HandshakeResponse{
+			ProtocolVersion: ProtocolVersion,
+			MasterID:        m.id,
+			ClusterID:       m.clusterID,
+			SessionID:       id,
+			Capabilities:    capabilities,
+		},
+	}
+}
+
+// challenge returns new nonce for replica and master proof for replica nonce
+func (m *Master) challenge(handshake Handshake) MasterResponse {
+	nonce, err := newNonce()
+	if err != nil {
+		logger.Error("unable to create replication challenge", zap.Error(err))
+		return MasterResponse{Error: "unable to create challenge"}
+	}
+
+	now := time.Now()
+
+	m.mutexChallenges.Lock()
+	for key, issuedAt := range m.challenges {
+		if now.Sub(issuedAt) > challengeTTL {
+			delete(m.challenges, key)
+		}
+	}
+	m.challenges[hex.EncodeToString(nonce)] = now
+	m.mutexChallenges.Unlock()
+
+	return MasterResponse{
+		Challenge: &Challenge{
+			Nonce: nonce,
+			Proof: masterProof(m.secret, handshake.Nonce),
+		},
+	}
+}
+
+// verify checks replica proof, every challenge can be answered once
+func (m *Master) verify(handshake Handshake) bool {
+	key := hex.EncodeToString(handshake.Challenge)
+
+	m.mutexChallenges.Lock()
+	issuedAt, ok := m.challenges[key]
+	delete(m.challenges, key)
+	m.mutexChallenges.Unlock()
+
+	if !ok || time.Since(issuedAt) > challengeTTL {
+		return false
+	}
+
+	return hmac.Equal(handshake.Proof, replicaProof(m.secret, handshake.Challenge, handshake))
+}
+
+func (m *Master) session(id string) *session {
+	m.mutexSessions.Lock()
+	defer m.mutexSessions.Unlock()
+
+	return m.sessions[id]
+}
+
+func (m *Master) sync(request SlaveRequest, sess *session) MasterResponse {
+	// name is joined with WAL directory, so it must not point outside of it
+	if request.LastSegmentName != "" && !filesystem.IsSegmentName(request.LastSegmentName) {
+		return MasterResponse{Error: fmt.Sprintf("%s: %q", errInvalidSegmentName, request.LastSegmentName)}
+	}
+
+	m.observeReplica(request, sess)
+
+	response := m.lastSegment(request)
+	if !response.Succeed || response.SegmentName == "" {
+		return response
+	}
+
+	if sess.has(CapabilityStreaming) {
+		response.Segments = m.pendingSegments(response.SegmentName, response.SegmentData)
+		response.SegmentName, response.SegmentData = "", nil
+	}
+
+	if sess.has(CapabilityCompression) {
+		if err := compressResponse(&response); err != nil {
+			logger.Error("unable to compress replication response", zap.Error(err))
+			return MasterResponse{Error: err.Error()}
 		}
+	}
+
+	return response
+}
+
+// ReplicaStats is a state of replica at its last sync
+type ReplicaStats struct {
+	ID       string
+	LastSync time.Time
+	// LagBytes is a size of WAL which replica has not received
+	LagBytes int64
+}
+
+// Address returns address of replication server
+func (m *Master) Address() string {
+	return m.server.Address()
+}
+
+// Replicas returns states of replicas which synced with master, sorted
+// by replica id
+func (m *Master) Replicas() []ReplicaStats {
+	m.mutexSessions.Lock()
+	replicas := make([]ReplicaStats, 0, len(m.replicas))
+	for _, replica := range m.replicas {
+		replicas = append(replicas, replica)
+	}
+	m.mutexSessions.Unlock()
 
-		return responseData
+	slices.SortFunc(replicas, func(a, b ReplicaStats) int {
+		return strings.Compare(a.ID, b.ID)
 	})
+
+	return replicas
+}
+
+// observeReplica updates lag of replica by position of its request
+func (m *Master) observeReplica(request SlaveRequest, sess *session) {
+	now := time.Now()
+	replicaLastSync.WithLabelValues(sess.replicaID).Set(float64(now.UnixNano()) / float64(time.Second))
+
+	lag := m.position.SizeAfter(request.LastSegmentName, request.LastSegmentSize)
+	replicaLag.WithLabelValues(sess.replicaID).Set(float64(lag))
+
+	m.mutexSessions.Lock()
+	m.replicas[sess.replicaID] = ReplicaStats{ID: sess.replicaID, LastSync: now, LagBytes: lag}
+	m.mutexSessions.Unlock()
+}
+
+// pendingSegments returns first segment and segments after it, total
+// size is limited to fit into one response
+func (m *Master) pendingSegments(name string, data []byte) []Segment {
+	segments := []Segment{{Name: name, Data: data}}
+	size := len(data)
+
+	for len(segments) < maxStreamSegments {
+		next, err := m.fileLib.SegmentNext(m.walDirectory, name)
+		if err != nil {
+			break
+		}
+
+		data, err = os.ReadFile(filepath.Clean(fmt.Sprintf("%s/%s", m.walDirectory, next)))
+		if err != nil {
+			logger.Error("failed to read WAL segment", zap.Error(err))
+			break
+		}
+
+		size += len(data)
+		if size > maxStreamSize {
+			break
+		}
+
+		segments = append(segments, Segment{Name: next, Data: data})
+		name = next
+	}
+
+	return segments
+}
+
+func compressResponse(response *MasterResponse) error {
+	var err error
+	if response.SegmentData != nil {
+		if response.SegmentData, err = CompressData(response.SegmentData); err != nil {
+			return err
+		}
+	}
+
+	for i := range response.Segments {
+		if response.Segments[i].Data, err = CompressData(response.Segments[i].Data); err != nil {
+			return err
+		}
+	}
+
+	response.Compressed = true
+	return nil
 }
 
 func (m *Master) lastSegment(request SlaveRequest) MasterResponse {
 	var response MasterResponse
 
-	segmentName, err := m.fileLib.SegmentNext(m.walDirectory, request.LastSegmentName)
-	if err != nil {
-		logger.Error("failed to find WAL segment", zap.Error(err))
-		return response
-	}
+	response.LastLSN = m.position.LastLSN()
 
-	if segmentName == "" {
+	segmentName, err := m.segmentToSend(request)
+	if err != nil {
+		logger.Debug("no WAL segments to send", zap.Error(err))
 		response.Succeed = true
 		return response
 	}
@@ -107,3 +423,17 @@ func (m *Master) lastSegment(request SlaveRequest) MasterResponse {
 
 	return response
 }
+
+// segmentToSend returns the last slave segment if it was appended after
+// previous sync, otherwise the next segment
+func (m *Master) segmentToSend(request SlaveRequest) (string, error) {
+	if request.LastSegmentName != "" {
+		filename := fmt.Sprintf("%s/%s", m.walDirectory, request.LastSegmentName)
+		if info, err := os.Stat(filepath.Clean(filename)); err == nil &&
+			info.Size() > request.LastSegmentSize {
+			return request.LastSegmentName, nil
+		}
+	}
+
+	return m.fileLib.SegmentNext(m.walDirectory, request.LastSegmentName)
+}
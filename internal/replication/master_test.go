@@ -4,13 +4,20 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"concurrency_go_course/internal/config"
+	"concurrency_go_course/internal/filesystem"
+	"concurrency_go_course/internal/network"
+	"concurrency_go_course/internal/storage/wal"
 	"concurrency_go_course/pkg/logger"
+	"concurrency_go_course/pkg/trace"
 )
 
 func TestNewServerErr(t *testing.T) {
@@ -42,9 +49,10 @@ func TestNewServerErr(t *testing.T) {
 	}
 
 	tests := []struct {
-		name   string
-		cfg    *config.Config
-		walCfg *config.WALCfg
+		name     string
+		cfg      *config.Config
+		walCfg   *config.WALCfg
+		position WALPosition
 
 		expectedError error
 	}{
@@ -60,17 +68,24 @@ func TestNewServerErr(t *testing.T) {
 			walCfg:        nil,
 			expectedError: fmt.Errorf("WAL config is empty"),
 		},
+		{
+			name:          "New server without WAL position",
+			cfg:           cfgWithReplicaAddr,
+			walCfg:        walCfg,
+			expectedError: fmt.Errorf("WAL position is empty"),
+		},
 		{
 			name:          "New server without address",
 			cfg:           cfgWithoutReplicaAddr,
 			walCfg:        walCfg,
+			position:      &wal.Position{},
 			expectedError: fmt.Errorf("address is empty"),
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			server, err := NewReplicationServer(tt.cfg, tt.walCfg)
+			server, err := NewReplicationServer(tt.cfg, tt.walCfg, tt.position)
 			assert.Nil(t, server)
 			assert.Equal(t, tt.expectedError, err)
 		})
@@ -129,9 +144,11 @@ func TestNewServerOk(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			server, err := NewReplicationServer(tt.cfg, tt.walCfg)
-			assert.Nil(t, err)
-			assert.NotNil(t, server)
+			server, err := NewReplicationServer(tt.cfg, tt.walCfg, &wal.Position{})
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				_ = server.server.Close()
+			})
 		})
 	}
 }
@@ -139,9 +156,6 @@ func TestNewServerOk(t *testing.T) {
 func TestNewServerStart(t *testing.T) {
 	logger.MockLogger()
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
 	replMasterAddr := "127.0.0.1:9998"
 
 	cfg := &config.Config{
@@ -174,13 +188,13 @@ func TestNewServerStart(t *testing.T) {
 		},
 	}
 
-	server, err := NewReplicationServer(cfg, walCfg)
+	server, err := NewReplicationServer(cfg, walCfg, &wal.Position{})
 	if err != nil {
 		t.Errorf("want nil error; got %+v", err)
 	}
 	assert.NotNil(t, server)
 
-	go server.Start(ctx)
+	runMaster(t, server)
 
 	wg := sync.WaitGroup{}
 
@@ -198,29 +212,19 @@ func TestNewServerStart(t *testing.T) {
 			t.Errorf("want nil error; got %+v", err)
 		}
 
-		req := SlaveRequest{LastSegmentName: "wal_0.log"}
+		response := roundTrip(t, conn, SlaveRequest{LastSegmentName: "wal_0.log"})
+		assert.False(t, response.Succeed)
+		assert.Equal(t, ErrHandshakeRequired.Error(), response.Error)
 
-		data, err := EncodeSlaveRequest(&req)
-		if err != nil {
-			t.Errorf("want nil error; got %+v", err)
-		}
+		handshake := NewHandshake("replica", "", nil)
+		response = roundTrip(t, conn, SlaveRequest{Handshake: &handshake})
+		assert.True(t, response.Succeed)
+		assert.NotNil(t, response.Handshake)
 
-		_, err = conn.Write(data)
-		if err != nil {
-			t.Errorf("want nil error; got %+v", err)
-		}
-
-		buffer := make([]byte, 1024)
-		_, err = conn.Read(buffer)
-		if err != nil {
-			t.Errorf("want nil error; got %+v", err)
-		}
-
-		response := &MasterResponse{}
-		err = DecodeResponse(response, buffer)
-		if err != nil {
-			t.Errorf("want nil error; got %+v", err)
-		}
+		response = roundTrip(t, conn, SlaveRequest{
+			LastSegmentName: "wal_0.log",
+			SessionID:       response.Handshake.SessionID,
+		})
 
 		assert.Equal(t, expectedResponse.Succeed, response.Succeed)
 		assert.Equal(t, expectedResponse.SegmentName, response.SegmentName)
@@ -228,3 +232,188 @@ func TestNewServerStart(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestMasterLagBytes(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "wal_1.log"), make([]byte, 100), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "wal_2.log"), make([]byte, 50), 0o600))
+
+	// segments are read by WAL once, lag is computed from its position
+	master := &Master{
+		position: walPosition(t, dir),
+		replicas: make(map[string]ReplicaStats),
+	}
+	sess := &session{id: "session", replicaID: "replica"}
+
+	tests := map[string]struct {
+		request SlaveRequest
+		lag     int64
+	}{
+		"new replica":           {request: SlaveRequest{}, lag: 150},
+		"inside first segment":  {request: SlaveRequest{LastSegmentName: "wal_1.log", LastSegmentSize: 40}, lag: 110},
+		"first segment is read": {request: SlaveRequest{LastSegmentName: "wal_1.log", LastSegmentSize: 100}, lag: 50},
+		"caught up":             {request: SlaveRequest{LastSegmentName: "wal_2.log", LastSegmentSize: 50}, lag: 0},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			master.observeReplica(test.request, sess)
+
+			replicas := master.Replicas()
+			require.Len(t, replicas, 1)
+			assert.Equal(t, test.lag, replicas[0].LagBytes)
+		})
+	}
+}
+
+func TestMasterSyncSegmentName(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	master := &Master{
+		walDirectory: "test_data",
+		fileLib:      filesystem.NewFileLib(),
+		position:     &wal.Position{},
+		sessions:     make(map[string]*session),
+		replicas:     make(map[string]ReplicaStats),
+	}
+	sess := &session{id: "session", replicaID: "replica"}
+
+	tests := map[string]struct {
+		name    string
+		invalid bool
+	}{
+		"new replica":       {name: ""},
+		"segment":           {name: "wal_1.log"},
+		"parent directory":  {name: "../../etc/passwd", invalid: true},
+		"segment in parent": {name: "../wal_1.log", invalid: true},
+		"absolute path":     {name: "/etc/passwd", invalid: true},
+		"not a segment":     {name: "master.go", invalid: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			response := master.sync(SlaveRequest{LastSegmentName: test.name}, sess)
+			if test.invalid {
+				assert.False(t, response.Succeed)
+				assert.Contains(t, response.Error, errInvalidSegmentName.Error())
+				assert.Empty(t, response.SegmentData)
+				return
+			}
+
+			assert.True(t, response.Succeed)
+			assert.Empty(t, response.Error)
+		})
+	}
+}
+
+type recordingExporter struct {
+	mutex sync.Mutex
+	spans []trace.SpanData
+}
+
+func (e *recordingExporter) Export(_ context.Context, spans []trace.SpanData) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *recordingExporter) Shutdown(_ context.Context) error {
+	return nil
+}
+
+// TestMasterHandleTraceparent is not parallel, it sets default tracer
+func TestMasterHandleTraceparent(t *testing.T) {
+	logger.MockLogger()
+
+	exporter := &recordingExporter{}
+	tracer := trace.NewTracer(exporter, 1)
+	trace.SetDefault(tracer)
+	defer trace.SetDefault(nil)
+
+	master := &Master{
+		walDirectory: "test_data",
+		fileLib:      filesystem.NewFileLib(),
+		sessions:     make(map[string]*session),
+		replicas:     make(map[string]ReplicaStats),
+	}
+
+	parent := trace.SpanContext{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{2}, Sampled: true}
+	data, err := EncodeSlaveRequest(&SlaveRequest{SessionID: "unknown", Traceparent: parent.Traceparent()})
+	require.NoError(t, err)
+
+	response := &MasterResponse{}
+	require.NoError(t, DecodeResponse(response, master.handle(context.Background(), data)))
+	assert.Equal(t, ErrHandshakeRequired.Error(), response.Error)
+
+	require.NoError(t, tracer.Shutdown(context.Background()))
+	require.Len(t, exporter.spans, 1)
+
+	span := exporter.spans[0]
+	assert.Equal(t, "Master.handle", span.Name)
+	assert.Equal(t, trace.KindServer, span.Kind)
+	assert.Equal(t, parent.TraceID, span.Context.TraceID)
+	assert.Equal(t, parent.SpanID, span.ParentSpanID)
+	assert.Equal(t, ErrHandshakeRequired.Error(), span.Error)
+}
+
+func roundTrip(t *testing.T, conn net.Conn, req SlaveRequest) *MasterResponse {
+	t.Helper()
+
+	data, err := EncodeSlaveRequest(&req)
+	if err != nil {
+		t.Errorf("want nil error; got %+v", err)
+	}
+
+	err = network.WriteFrame(conn, data)
+	if err != nil {
+		t.Errorf("want nil error; got %+v", err)
+	}
+
+	buffer, err := network.ReadFrame(conn, 0)
+	if err != nil {
+		t.Errorf("want nil error; got %+v", err)
+	}
+
+	response := &MasterResponse{}
+	err = DecodeResponse(response, buffer)
+	if err != nil {
+		t.Errorf("want nil error; got %+v", err)
+	}
+
+	return response
+}
+
+// walPosition returns position of WAL with segments of directory
+func walPosition(t *testing.T, dir string) *wal.Position {
+	t.Helper()
+
+	log, err := wal.New(&config.WALCfg{WalConfig: &config.WALSettings{DataDirectory: dir}})
+	require.NoError(t, err)
+
+	return log.Position()
+}
+
+// runMaster runs master until the end of test
+func runMaster(t *testing.T, master *Master) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		master.Start(ctx)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
@@ -0,0 +1,106 @@
+package replication
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrReplicaTooStale is returned if replica can not reach requested position in time
+var ErrReplicaTooStale = errors.New("replica too stale")
+
+// Progress is a struct for replica position tracking
+type Progress struct {
+	mutex      sync.Mutex
+	changed    chan struct{}
+	appliedLSN uint64
+	masterLSN  uint64
+	caughtUpAt time.Time
+}
+
+// NewProgress returns new replica progress
+func NewProgress(appliedLSN uint64) *Progress {
+	return &Progress{
+		changed:    make(chan struct{}),
+		appliedLSN: appliedLSN,
+	}
+}
+
+// Update sets applied and master positions, syncedAt is a time when master
+// position was observed
+func (p *Progress) Update(appliedLSN, masterLSN uint64, syncedAt time.Time) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.appliedLSN = max(p.appliedLSN, appliedLSN)
+	p.masterLSN = max(p.masterLSN, masterLSN)
+	if p.appliedLSN >= p.masterLSN && syncedAt.After(p.caughtUpAt) {
+		p.caughtUpAt = syncedAt
+	}
+
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// AppliedLSN returns the last applied log sequence number
+func (p *Progress) AppliedLSN() uint64 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.appliedLSN
+}
+
+// Lag returns time since replica was known to be caught up with master,
+// false if replica was never synced
+func (p *Progress) Lag() (time.Duration, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.lag()
+}
+
+func (p *Progress) lag() (time.Duration, bool) {
+	if p.caughtUpAt.IsZero() {
+		return 0, false
+	}
+
+	return time.Since(p.caughtUpAt), true
+}
+
+// WaitLSN waits until position is applied
+func (p *Progress) WaitLSN(ctx context.Context, lsn uint64) error {
+	return p.wait(ctx, func() bool {
+		return p.appliedLSN >= lsn
+	})
+}
+
+// WaitLag waits until replica lag is less than maxLag
+func (p *Progress) WaitLag(ctx context.Context, maxLag time.Duration) error {
+	return p.wait(ctx, func() bool {
+		lag, ok := p.lag()
+		return ok && lag <= maxLag
+	})
+}
+
+func (p *Progress) wait(ctx context.Context, ready func() bool) error {
+	for {
+		p.mutex.Lock()
+		if ready() {
+			p.mutex.Unlock()
+			return nil
+		}
+		changed := p.changed
+		p.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			// canceled request is not an error of replica
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return ctx.Err()
+			}
+			return ErrReplicaTooStale
+		case <-changed:
+		}
+	}
+}
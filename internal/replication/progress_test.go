@@ -0,0 +1,73 @@
+package replication
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressWaitLSN(t *testing.T) {
+	t.Parallel()
+
+	progress := NewProgress(5)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	assert.Nil(t, progress.WaitLSN(ctx, 5))
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		progress.Update(10, 10, time.Now())
+	}()
+
+	assert.Nil(t, progress.WaitLSN(ctx, 10))
+	assert.Equal(t, uint64(10), progress.AppliedLSN())
+
+	shortCtx, shortCancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer shortCancel()
+
+	assert.Equal(t, ErrReplicaTooStale, progress.WaitLSN(shortCtx, 11))
+
+	// wait of canceled request returns without waiting for timeout
+	canceledCtx, canceledCancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		canceledCancel()
+	}()
+
+	assert.ErrorIs(t, progress.WaitLSN(canceledCtx, 11), context.Canceled)
+}
+
+func TestProgressLag(t *testing.T) {
+	t.Parallel()
+
+	progress := NewProgress(0)
+
+	_, ok := progress.Lag()
+	assert.False(t, ok)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, ErrReplicaTooStale, progress.WaitLag(ctx, time.Second))
+
+	// slave is behind master, lag is counted from the last catch up
+	progress.Update(3, 5, time.Now())
+	_, ok = progress.Lag()
+	assert.False(t, ok)
+
+	syncedAt := time.Now().Add(-time.Second)
+	progress.Update(5, 5, syncedAt)
+
+	lag, ok := progress.Lag()
+	assert.True(t, ok)
+	assert.GreaterOrEqual(t, lag, time.Second)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	assert.Nil(t, progress.WaitLag(ctx, 2*time.Second))
+}
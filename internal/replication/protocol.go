@@ -2,13 +2,29 @@ package replication
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/gob"
+	"errors"
 	"fmt"
+	"io"
 )
 
-// SlaveRequest is a struct for request from slave node
+// errInvalidSegmentName is returned for segment names which are not names
+// of WAL segment files, such names could point outside of WAL directory
+var errInvalidSegmentName = errors.New("invalid WAL segment name")
+
+// SlaveRequest is a struct for request from slave node, the first request
+// of session must contain handshake
 type SlaveRequest struct {
 	LastSegmentName string
+	LastSegmentSize int64
+
+	SessionID string
+	Handshake *Handshake
+
+	// Traceparent is a W3C trace context of slave span, master spans
+	// of request are its children
+	Traceparent string
 }
 
 // NewRequest returns new slave request
@@ -23,6 +39,22 @@ type MasterResponse struct {
 	Succeed     bool
 	SegmentName string
 	SegmentData []byte
+	LastLSN     uint64
+
+	Error     string
+	Handshake *HandshakeResponse
+	Challenge *Challenge
+
+	// Compressed is set if segments data is compressed with gzip
+	Compressed bool
+	// Segments contains pending segments if streaming is negotiated
+	Segments []Segment
+}
+
+// Segment is a WAL segment file
+type Segment struct {
+	Name string
+	Data []byte
 }
 
 // NewMasterResponse returns new master response
@@ -85,3 +117,36 @@ func DecodeSlaveRequest(request *SlaveRequest, data []byte) error {
 	}
 	return nil
 }
+
+// CompressData compresses data with gzip
+func CompressData(data []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress data: %w", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress data: %w", err)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// DecompressData decompresses gzip data
+func DecompressData(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress data: %w", err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	res, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress data: %w", err)
+	}
+
+	return res, nil
+}
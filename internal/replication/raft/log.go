@@ -0,0 +1,119 @@
+package raft
+
+// raftLog is an in-memory raft log with compacted prefix stored in snapshot
+type raftLog struct {
+	snapshot Snapshot
+	entries  []Entry
+}
+
+func newRaftLog(snapshot Snapshot, entries []Entry) *raftLog {
+	return &raftLog{
+		snapshot: snapshot,
+		entries:  entries,
+	}
+}
+
+func (l *raftLog) firstIndex() uint64 {
+	return l.snapshot.Index + 1
+}
+
+func (l *raftLog) lastIndex() uint64 {
+	if len(l.entries) == 0 {
+		return l.snapshot.Index
+	}
+
+	return l.entries[len(l.entries)-1].Index
+}
+
+func (l *raftLog) lastTerm() uint64 {
+	term, _ := l.term(l.lastIndex())
+	return term
+}
+
+// term returns term of entry with index, false if entry was compacted
+// or does not exist yet
+func (l *raftLog) term(index uint64) (uint64, bool) {
+	if index == l.snapshot.Index {
+		return l.snapshot.Term, true
+	}
+
+	if index < l.snapshot.Index || index > l.lastIndex() {
+		return 0, false
+	}
+
+	return l.entries[index-l.firstIndex()].Term, true
+}
+
+func (l *raftLog) matchTerm(index, term uint64) bool {
+	t, ok := l.term(index)
+	return ok && t == term
+}
+
+func (l *raftLog) isUpToDate(lastIndex, lastTerm uint64) bool {
+	return lastTerm > l.lastTerm() ||
+		(lastTerm == l.lastTerm() && lastIndex >= l.lastIndex())
+}
+
+// append appends entries, conflicting suffix of the log is truncated
+func (l *raftLog) append(entries []Entry) {
+	for i, entry := range entries {
+		if entry.Index <= l.snapshot.Index {
+			continue
+		}
+
+		if entry.Index > l.lastIndex() {
+			l.entries = append(l.entries, entries[i:]...)
+			return
+		}
+
+		if !l.matchTerm(entry.Index, entry.Term) {
+			l.entries = append(l.entries[:entry.Index-l.firstIndex()], entries[i:]...)
+			return
+		}
+	}
+}
+
+// slice returns entries in range [lo, hi)
+func (l *raftLog) slice(lo, hi uint64) []Entry {
+	if lo < l.firstIndex() {
+		lo = l.firstIndex()
+	}
+
+	if hi > l.lastIndex()+1 {
+		hi = l.lastIndex() + 1
+	}
+
+	if lo >= hi {
+		return nil
+	}
+
+	res := make([]Entry, hi-lo)
+	copy(res, l.entries[lo-l.firstIndex():hi-l.firstIndex()])
+	return res
+}
+
+// compact drops entries up to snapshot index
+func (l *raftLog) compact(snapshot Snapshot) {
+	if snapshot.Index <= l.snapshot.Index {
+		return
+	}
+
+	if snapshot.Index >= l.lastIndex() {
+		l.entries = nil
+	} else {
+		l.entries = append([]Entry(nil), l.entries[snapshot.Index+1-l.firstIndex():]...)
+	}
+
+	l.snapshot = snapshot
+}
+
+// restore replaces log with snapshot
+func (l *raftLog) restore(snapshot Snapshot) {
+	if l.matchTerm(snapshot.Index, snapshot.Term) && snapshot.Index <= l.lastIndex() {
+		l.compact(snapshot)
+		return
+	}
+
+	l.entries = nil
+	l.snapshot = snapshot
+}
@@ -0,0 +1,759 @@
+package raft
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"concurrency_go_course/internal/storage/wal"
+	"concurrency_go_course/pkg/logger"
+)
+
+const (
+	defaultElectionTicks  = 10
+	defaultHeartbeatTicks = 1
+
+	maxEntriesPerMessage = 64
+	snapshotChunkSize    = 1024
+)
+
+// Settings is a struct for raft node settings
+type Settings struct {
+	ID                string
+	Peers             []string
+	ElectionTicks     int
+	HeartbeatTicks    int
+	SnapshotThreshold int
+	Seed              int64
+}
+
+// Status is a struct with node state info
+type Status struct {
+	ID        string
+	State     State
+	Term      uint64
+	Leader    string
+	Commit    uint64
+	Applied   uint64
+	LastIndex uint64
+}
+
+type progress struct {
+	match uint64
+	next  uint64
+
+	sendingSnapshot bool
+	snapshot        Snapshot
+	snapshotOffset  uint64
+}
+
+type waiter struct {
+	term uint64
+	ch   chan Result
+}
+
+type incomingSnapshot struct {
+	index uint64
+	term  uint64
+	data  []byte
+}
+
+// Node is a struct for raft consensus node
+type Node struct {
+	mutex sync.Mutex
+
+	id       string
+	peers    []string
+	settings Settings
+
+	state    State
+	term     uint64
+	votedFor string
+	leader   string
+
+	log     *raftLog
+	commit  uint64
+	applied uint64
+
+	progress map[string]*progress
+	votes    map[string]bool
+
+	electionElapsed   int
+	heartbeatElapsed  int
+	randomizedTimeout int
+	rand              *rand.Rand
+
+	stateMachine StateMachine
+	persister    Persister
+	transport    Transport
+
+	waiters  map[uint64]waiter
+	incoming incomingSnapshot
+	outbox   []Message
+	dirty    bool
+	stopped  bool
+}
+
+// NewNode creates new raft node and restores its state from persister
+func NewNode(settings Settings, stateMachine StateMachine,
+	persister Persister, transport Transport,
+) (*Node, error) {
+	if settings.ID == "" {
+		return nil, fmt.Errorf("node id is empty")
+	}
+
+	if !slices.Contains(settings.Peers, settings.ID) {
+		settings.Peers = append(settings.Peers, settings.ID)
+	}
+	slices.Sort(settings.Peers)
+
+	if stateMachine == nil || persister == nil || transport == nil {
+		return nil, fmt.Errorf("state machine, persister and transport are required")
+	}
+
+	if settings.ElectionTicks <= 0 {
+		settings.ElectionTicks = defaultElectionTicks
+	}
+
+	if settings.HeartbeatTicks <= 0 {
+		settings.HeartbeatTicks = defaultHeartbeatTicks
+	}
+
+	seed := settings.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	hardState, snapshot, entries, err := persister.Load()
+	if err != nil {
+		return nil, fmt.Errorf("unable to load raft state: %w", err)
+	}
+
+	if len(snapshot.Data) != 0 {
+		if err = stateMachine.Restore(snapshot.Data); err != nil {
+			return nil, fmt.Errorf("unable to restore snapshot: %w", err)
+		}
+	}
+
+	n := &Node{
+		id:           settings.ID,
+		peers:        settings.Peers,
+		settings:     settings,
+		term:         hardState.Term,
+		votedFor:     hardState.VotedFor,
+		log:          newRaftLog(snapshot, entries),
+		commit:       max(hardState.Commit, snapshot.Index),
+		applied:      snapshot.Index,
+		rand:         rand.New(rand.NewSource(seed)), //nolint:gosec
+		stateMachine: stateMachine,
+		persister:    persister,
+		transport:    transport,
+		waiters:      make(map[uint64]waiter),
+	}
+
+	n.commit = min(n.commit, n.log.lastIndex())
+	n.applyCommitted()
+	n.becomeFollower(n.term, "")
+
+	return n, nil
+}
+
+// ID returns node id
+func (n *Node) ID() string {
+	return n.id
+}
+
+// Status returns current node status
+func (n *Node) Status() Status {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return Status{
+		ID:        n.id,
+		State:     n.state,
+		Term:      n.term,
+		Leader:    n.leader,
+		Commit:    n.commit,
+		Applied:   n.applied,
+		LastIndex: n.log.lastIndex(),
+	}
+}
+
+// Run ticks node with interval until context is done
+func (n *Node) Run(ctx context.Context, tickInterval time.Duration) {
+	logger.Info("Starting raft node", zap.String("id", n.id),
+		zap.Strings("peers", n.peers),
+		zap.String("tick_interval", tickInterval.String()))
+
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			n.Stop()
+			logger.Debug("raft node stopped", zap.String("id", n.id))
+			return
+		case <-ticker.C:
+			n.Tick()
+		}
+	}
+}
+
+// Stop stops node, pending proposals are failed
+func (n *Node) Stop() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.stopped = true
+	for index, w := range n.waiters {
+		w.ch <- Result{Err: ErrStopped}
+		delete(n.waiters, index)
+	}
+}
+
+// Tick advances logical clock of node
+func (n *Node) Tick() {
+	n.mutex.Lock()
+	if n.stopped {
+		n.mutex.Unlock()
+		return
+	}
+
+	n.tick()
+	messages := n.ready()
+	n.mutex.Unlock()
+
+	n.transport.Send(messages)
+}
+
+// Step handles message from peer
+func (n *Node) Step(m Message) {
+	n.mutex.Lock()
+	if n.stopped {
+		n.mutex.Unlock()
+		return
+	}
+
+	n.step(m)
+	messages := n.ready()
+	n.mutex.Unlock()
+
+	n.transport.Send(messages)
+}
+
+// Propose replicates request and waits until it is applied, it returns
+// result of state machine
+func (n *Node) Propose(ctx context.Context, request wal.Request) (any, error) {
+	done, err := n.ProposeAsync(request)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case result := <-done:
+		return result.Value, result.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// ProposeAsync appends request to leader log, returned channel receives
+// result when entry is applied
+func (n *Node) ProposeAsync(request wal.Request) (<-chan Result, error) {
+	n.mutex.Lock()
+	if n.stopped {
+		n.mutex.Unlock()
+		return nil, ErrStopped
+	}
+
+	if n.state != StateLeader {
+		leader := n.leader
+		n.mutex.Unlock()
+		return nil, &NotLeaderError{Leader: leader}
+	}
+
+	entry := n.appendEntry(request)
+
+	done := make(chan Result, 1)
+	n.waiters[entry.Index] = waiter{term: entry.Term, ch: done}
+
+	n.broadcastAppend()
+	n.maybeCommit()
+	messages := n.ready()
+	n.mutex.Unlock()
+
+	n.transport.Send(messages)
+
+	return done, nil
+}
+
+func (n *Node) ready() []Message {
+	if n.dirty {
+		err := n.persister.Save(HardState{Term: n.term, VotedFor: n.votedFor, Commit: n.commit},
+			n.log.snapshot, n.log.entries)
+		if err != nil {
+			// votes and acknowledgements must not be sent for state which
+			// is not saved, messages are dropped like lost ones and state
+			// is saved again on next tick
+			logger.ErrorWithMsg("unable to persist raft state:", err)
+			n.outbox = nil
+			return nil
+		}
+		n.dirty = false
+	}
+
+	messages := n.outbox
+	n.outbox = nil
+	return messages
+}
+
+func (n *Node) send(m Message) {
+	m.From = n.id
+	m.Term = n.term
+	n.outbox = append(n.outbox, m)
+}
+
+func (n *Node) quorum() int {
+	return len(n.peers)/2 + 1
+}
+
+func (n *Node) tick() {
+	if n.state == StateLeader {
+		n.heartbeatElapsed++
+		if n.heartbeatElapsed >= n.settings.HeartbeatTicks {
+			n.heartbeatElapsed = 0
+			n.broadcastAppend()
+		}
+		return
+	}
+
+	n.electionElapsed++
+	if n.electionElapsed >= n.randomizedTimeout {
+		n.campaign()
+	}
+}
+
+func (n *Node) resetElectionTimeout() {
+	n.electionElapsed = 0
+	n.heartbeatElapsed = 0
+	n.randomizedTimeout = n.settings.ElectionTicks + n.rand.Intn(n.settings.ElectionTicks)
+}
+
+func (n *Node) becomeFollower(term uint64, leader string) {
+	if term != n.term {
+		n.term = term
+		n.votedFor = ""
+		n.dirty = true
+	}
+
+	n.state = StateFollower
+	n.leader = leader
+	n.resetElectionTimeout()
+}
+
+func (n *Node) campaign() {
+	n.term++
+	n.votedFor = n.id
+	n.dirty = true
+	n.state = StateCandidate
+	n.leader = ""
+	n.votes = map[string]bool{n.id: true}
+	n.resetElectionTimeout()
+
+	logger.Debug("raft node starts election", zap.String("id", n.id),
+		zap.Uint64("term", n.term))
+
+	if n.quorum() == 1 {
+		n.becomeLeader()
+		return
+	}
+
+	for _, peer := range n.peers {
+		if peer == n.id {
+			continue
+		}
+
+		n.send(Message{
+			Type:     MsgVote,
+			To:       peer,
+			LogIndex: n.log.lastIndex(),
+			LogTerm:  n.log.lastTerm(),
+		})
+	}
+}
+
+func (n *Node) becomeLeader() {
+	n.state = StateLeader
+	n.leader = n.id
+	n.resetElectionTimeout()
+
+	logger.Info("raft node became leader", zap.String("id", n.id),
+		zap.Uint64("term", n.term))
+
+	n.progress = make(map[string]*progress, len(n.peers))
+	for _, peer := range n.peers {
+		n.progress[peer] = &progress{next: n.log.lastIndex() + 1}
+	}
+
+	// empty entry commits entries from previous terms
+	n.appendEntry(wal.Request{})
+	n.broadcastAppend()
+	n.maybeCommit()
+}
+
+func (n *Node) appendEntry(request wal.Request) Entry {
+	entry := Entry{
+		Term:    n.term,
+		Index:   n.log.lastIndex() + 1,
+		Request: request,
+	}
+
+	n.log.append([]Entry{entry})
+	n.dirty = true
+
+	pr := n.progress[n.id]
+	pr.match = entry.Index
+	pr.next = entry.Index + 1
+
+	return entry
+}
+
+func (n *Node) step(m Message) {
+	switch {
+	case m.Term > n.term:
+		leader := ""
+		if m.Type == MsgApp || m.Type == MsgSnap {
+			leader = m.From
+		}
+		n.becomeFollower(m.Term, leader)
+	case m.Term < n.term:
+		// notify stale leader or candidate about new term
+		switch m.Type {
+		case MsgApp:
+			n.send(Message{Type: MsgAppResp, To: m.From, Reject: true})
+		case MsgSnap:
+			n.send(Message{Type: MsgSnapResp, To: m.From, Reject: true})
+		case MsgVote:
+			n.send(Message{Type: MsgVoteResp, To: m.From, Reject: true})
+		}
+		return
+	}
+
+	switch m.Type {
+	case MsgVote:
+		n.handleVote(m)
+	case MsgVoteResp:
+		n.handleVoteResponse(m)
+	case MsgApp:
+		n.followLeader(m.From)
+		n.handleAppend(m)
+	case MsgAppResp:
+		n.handleAppendResponse(m)
+	case MsgSnap:
+		n.followLeader(m.From)
+		n.handleSnapshot(m)
+	case MsgSnapResp:
+		n.handleSnapshotResponse(m)
+	}
+}
+
+func (n *Node) followLeader(leader string) {
+	if n.state != StateFollower {
+		n.becomeFollower(n.term, leader)
+	}
+
+	n.leader = leader
+	n.electionElapsed = 0
+}
+
+func (n *Node) handleVote(m Message) {
+	canVote := n.votedFor == "" || n.votedFor == m.From
+	grant := canVote && n.state == StateFollower && n.log.isUpToDate(m.LogIndex, m.LogTerm)
+	if grant {
+		n.votedFor = m.From
+		n.dirty = true
+		n.electionElapsed = 0
+	}
+
+	n.send(Message{Type: MsgVoteResp, To: m.From, Reject: !grant})
+}
+
+func (n *Node) handleVoteResponse(m Message) {
+	if n.state != StateCandidate {
+		return
+	}
+
+	n.votes[m.From] = !m.Reject
+
+	granted, rejected := 0, 0
+	for _, vote := range n.votes {
+		if vote {
+			granted++
+		} else {
+			rejected++
+		}
+	}
+
+	switch {
+	case granted >= n.quorum():
+		n.becomeLeader()
+	case rejected >= n.quorum():
+		n.becomeFollower(n.term, "")
+	}
+}
+
+func (n *Node) handleAppend(m Message) {
+	if m.LogIndex < n.commit {
+		n.send(Message{Type: MsgAppResp, To: m.From, Index: n.commit})
+		return
+	}
+
+	if !n.log.matchTerm(m.LogIndex, m.LogTerm) {
+		hint := min(m.LogIndex-1, n.log.lastIndex())
+		n.send(Message{Type: MsgAppResp, To: m.From, Reject: true, Index: hint})
+		return
+	}
+
+	if len(m.Entries) != 0 {
+		n.log.append(m.Entries)
+		n.dirty = true
+	}
+
+	lastNewIndex := m.LogIndex + uint64(len(m.Entries))
+	n.commitTo(min(m.Commit, lastNewIndex))
+
+	n.send(Message{Type: MsgAppResp, To: m.From, Index: lastNewIndex})
+}
+
+func (n *Node) handleAppendResponse(m Message) {
+	pr, ok := n.progress[m.From]
+	if n.state != StateLeader || !ok {
+		return
+	}
+
+	if m.Reject {
+		pr.next = max(1, min(pr.next-1, m.Index+1))
+		n.sendAppend(m.From)
+		return
+	}
+
+	if m.Index > pr.match {
+		pr.match = m.Index
+	}
+	pr.next = max(pr.next, pr.match+1)
+
+	n.maybeCommit()
+
+	if pr.next <= n.log.lastIndex() {
+		n.sendAppend(m.From)
+	}
+}
+
+func (n *Node) handleSnapshot(m Message) {
+	if m.SnapIndex <= n.commit {
+		n.send(Message{Type: MsgSnapResp, To: m.From, SnapIndex: m.SnapIndex,
+			SnapDone: true, Index: n.commit})
+		return
+	}
+
+	if m.SnapOffset == 0 {
+		n.incoming = incomingSnapshot{index: m.SnapIndex, term: m.SnapTerm}
+	}
+
+	if n.incoming.index != m.SnapIndex || n.incoming.term != m.SnapTerm ||
+		uint64(len(n.incoming.data)) != m.SnapOffset {
+		expected := uint64(0)
+		if n.incoming.index == m.SnapIndex && n.incoming.term == m.SnapTerm {
+			expected = uint64(len(n.incoming.data))
+		}
+
+		n.send(Message{Type: MsgSnapResp, To: m.From, Reject: true,
+			SnapIndex: m.SnapIndex, SnapOffset: expected})
+		return
+	}
+
+	n.incoming.data = append(n.incoming.data, m.SnapData...)
+	if !m.SnapDone {
+		n.send(Message{Type: MsgSnapResp, To: m.From, SnapIndex: m.SnapIndex,
+			SnapOffset: uint64(len(n.incoming.data))})
+		return
+	}
+
+	snapshot := Snapshot{Index: n.incoming.index, Term: n.incoming.term, Data: n.incoming.data}
+	n.incoming = incomingSnapshot{}
+
+	if err := n.stateMachine.Restore(snapshot.Data); err != nil {
+		logger.ErrorWithMsg("unable to restore raft snapshot:", err)
+		n.send(Message{Type: MsgSnapResp, To: m.From, Reject: true, SnapIndex: m.SnapIndex})
+		return
+	}
+
+	logger.Info("raft snapshot was installed", zap.String("id", n.id),
+		zap.Uint64("index", snapshot.Index))
+
+	n.log.restore(snapshot)
+	n.commit = snapshot.Index
+	n.applied = snapshot.Index
+	n.dirty = true
+
+	for index, w := range n.waiters {
+		if index <= snapshot.Index {
+			w.ch <- Result{Err: ErrProposalDropped}
+			delete(n.waiters, index)
+		}
+	}
+
+	n.send(Message{Type: MsgSnapResp, To: m.From, SnapIndex: snapshot.Index,
+		SnapDone: true, Index: snapshot.Index})
+}
+
+func (n *Node) handleSnapshotResponse(m Message) {
+	pr, ok := n.progress[m.From]
+	if n.state != StateLeader || !ok || !pr.sendingSnapshot {
+		return
+	}
+
+	if m.SnapIndex != pr.snapshot.Index {
+		return
+	}
+
+	if m.SnapDone {
+		pr.sendingSnapshot = false
+		pr.match = max(pr.match, m.Index)
+		pr.next = pr.match + 1
+		n.sendAppend(m.From)
+		return
+	}
+
+	pr.snapshotOffset = m.SnapOffset
+	n.sendSnapshot(m.From)
+}
+
+func (n *Node) broadcastAppend() {
+	for _, peer := range n.peers {
+		if peer != n.id {
+			n.sendAppend(peer)
+		}
+	}
+}
+
+func (n *Node) sendAppend(to string) {
+	pr := n.progress[to]
+	if pr.sendingSnapshot || pr.next <= n.log.snapshot.Index {
+		n.sendSnapshot(to)
+		return
+	}
+
+	prevIndex := pr.next - 1
+	prevTerm, _ := n.log.term(prevIndex)
+	entries := n.log.slice(pr.next, pr.next+maxEntriesPerMessage)
+
+	n.send(Message{
+		Type:     MsgApp,
+		To:       to,
+		LogIndex: prevIndex,
+		LogTerm:  prevTerm,
+		Entries:  entries,
+		Commit:   n.commit,
+	})
+
+	if len(entries) != 0 {
+		pr.next = entries[len(entries)-1].Index + 1
+	}
+}
+
+func (n *Node) sendSnapshot(to string) {
+	pr := n.progress[to]
+	if !pr.sendingSnapshot || pr.snapshot.Index != n.log.snapshot.Index {
+		pr.sendingSnapshot = true
+		pr.snapshot = n.log.snapshot
+		pr.snapshotOffset = 0
+	}
+
+	offset := min(pr.snapshotOffset, uint64(len(pr.snapshot.Data)))
+	end := min(offset+snapshotChunkSize, uint64(len(pr.snapshot.Data)))
+
+	n.send(Message{
+		Type:       MsgSnap,
+		To:         to,
+		SnapIndex:  pr.snapshot.Index,
+		SnapTerm:   pr.snapshot.Term,
+		SnapOffset: offset,
+		SnapData:   pr.snapshot.Data[offset:end],
+		SnapDone:   end == uint64(len(pr.snapshot.Data)),
+	})
+}
+
+func (n *Node) maybeCommit() {
+	matches := make([]uint64, 0, len(n.peers))
+	for _, peer := range n.peers {
+		matches = append(matches, n.progress[peer].match)
+	}
+	slices.Sort(matches)
+
+	index := matches[len(matches)-n.quorum()]
+	if term, ok := n.log.term(index); ok && term == n.term {
+		n.commitTo(index)
+	}
+}
+
+func (n *Node) commitTo(index uint64) {
+	if index <= n.commit {
+		return
+	}
+
+	n.commit = index
+	n.dirty = true
+	n.applyCommitted()
+}
+
+func (n *Node) applyCommitted() {
+	if n.applied >= n.commit {
+		return
+	}
+
+	for _, entry := range n.log.slice(n.applied+1, n.commit+1) {
+		var value any
+		if entry.Request.Command != "" {
+			value = n.stateMachine.Apply(entry.Request)
+		}
+		n.applied = entry.Index
+
+		if w, ok := n.waiters[entry.Index]; ok {
+			if w.term == entry.Term {
+				w.ch <- Result{Value: value}
+			} else {
+				w.ch <- Result{Err: ErrProposalDropped}
+			}
+			delete(n.waiters, entry.Index)
+		}
+	}
+
+	n.maybeSnapshot()
+}
+
+func (n *Node) maybeSnapshot() {
+	threshold := uint64(n.settings.SnapshotThreshold) //nolint:gosec
+	if threshold == 0 || n.applied-n.log.snapshot.Index < threshold {
+		return
+	}
+
+	data, err := n.stateMachine.Snapshot()
+	if err != nil {
+		logger.ErrorWithMsg("unable to create raft snapshot:", err)
+		return
+	}
+
+	term, _ := n.log.term(n.applied)
+	n.log.compact(Snapshot{Index: n.applied, Term: term, Data: data})
+	n.dirty = true
+
+	logger.Debug("raft log was compacted", zap.String("id", n.id),
+		zap.Uint64("index", n.applied))
+}
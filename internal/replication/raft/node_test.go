@@ -0,0 +1,452 @@
+package raft
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"concurrency_go_course/internal/storage/wal"
+	"concurrency_go_course/pkg/logger"
+)
+
+type mapStateMachine struct {
+	mutex sync.Mutex
+	data  map[string]string
+}
+
+func newMapStateMachine() *mapStateMachine {
+	return &mapStateMachine{data: make(map[string]string)}
+}
+
+func (m *mapStateMachine) Apply(request wal.Request) any {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	switch request.Command {
+	case "SET":
+		m.data[request.Args[0]] = request.Args[1]
+	case "DEL":
+		_, ok := m.data[request.Args[0]]
+		delete(m.data, request.Args[0])
+		return ok
+	}
+
+	return nil
+}
+
+func (m *mapStateMachine) Snapshot() ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var buffer bytes.Buffer
+	err := gob.NewEncoder(&buffer).Encode(m.data)
+	return buffer.Bytes(), err
+}
+
+func (m *mapStateMachine) Restore(data []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.data = make(map[string]string)
+	return gob.NewDecoder(bytes.NewBuffer(data)).Decode(&m.data)
+}
+
+func (m *mapStateMachine) get(key string) (string, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	value, ok := m.data[key]
+	return value, ok
+}
+
+type testCluster struct {
+	network    *SimNetwork
+	ids        []string
+	machines   map[string]*mapStateMachine
+	persisters map[string]*MemoryPersister
+	threshold  int
+}
+
+func newTestCluster(t *testing.T, seed int64, threshold int, ids ...string) *testCluster {
+	t.Helper()
+
+	c := &testCluster{
+		network:    NewSimNetwork(seed),
+		ids:        ids,
+		machines:   make(map[string]*mapStateMachine),
+		persisters: make(map[string]*MemoryPersister),
+		threshold:  threshold,
+	}
+
+	for i, id := range ids {
+		c.persisters[id] = NewMemoryPersister()
+		c.start(t, id, seed+int64(i))
+	}
+
+	return c
+}
+
+func (c *testCluster) start(t *testing.T, id string, seed int64) {
+	t.Helper()
+
+	c.machines[id] = newMapStateMachine()
+	node, err := NewNode(Settings{
+		ID:                id,
+		Peers:             c.ids,
+		ElectionTicks:     10,
+		HeartbeatTicks:    1,
+		SnapshotThreshold: c.threshold,
+		Seed:              seed,
+	}, c.machines[id], c.persisters[id], c.network.Transport())
+	require.NoError(t, err)
+
+	c.network.AddNode(node)
+}
+
+func (c *testCluster) waitLeader(t *testing.T) string {
+	t.Helper()
+
+	for range 100 {
+		c.network.Tick()
+		if leader := c.network.Leader(); leader != "" {
+			return leader
+		}
+	}
+
+	t.Fatal("leader was not elected")
+	return ""
+}
+
+func (c *testCluster) propose(t *testing.T, id string, command string, args ...string) <-chan Result {
+	t.Helper()
+
+	done, err := c.network.Node(id).ProposeAsync(wal.Request{Command: command, Args: args})
+	require.NoError(t, err)
+
+	return done
+}
+
+func TestElection(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	c := newTestCluster(t, 1, 0, "n1", "n2", "n3")
+	leader := c.waitLeader(t)
+
+	c.network.Run(20)
+
+	leaders := 0
+	for _, id := range c.ids {
+		status := c.network.Node(id).Status()
+		if status.State == StateLeader {
+			leaders++
+		}
+		assert.Equal(t, leader, status.Leader)
+	}
+	assert.Equal(t, 1, leaders)
+}
+
+func TestProposeNotLeader(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	c := newTestCluster(t, 2, 0, "n1", "n2", "n3")
+	leader := c.waitLeader(t)
+	c.network.Run(5)
+
+	for _, id := range c.ids {
+		if id == leader {
+			continue
+		}
+
+		_, err := c.network.Node(id).ProposeAsync(wal.Request{Command: "SET", Args: []string{"k", "v"}})
+		var notLeader *NotLeaderError
+		require.ErrorAs(t, err, &notLeader)
+		assert.Equal(t, leader, notLeader.Leader)
+	}
+}
+
+func TestReplication(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	c := newTestCluster(t, 3, 0, "n1", "n2", "n3")
+	leader := c.waitLeader(t)
+
+	set := c.propose(t, leader, "SET", "key", "value")
+	del := c.propose(t, leader, "SET", "key2", "value2")
+	c.network.Run(5)
+
+	assert.NoError(t, (<-set).Err)
+	assert.NoError(t, (<-del).Err)
+
+	for _, id := range c.ids {
+		value, ok := c.machines[id].get("key")
+		assert.True(t, ok, id)
+		assert.Equal(t, "value", value, id)
+
+		value, ok = c.machines[id].get("key2")
+		assert.True(t, ok, id)
+		assert.Equal(t, "value2", value, id)
+	}
+}
+
+func TestProposeResult(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	c := newTestCluster(t, 8, 0, "n1", "n2", "n3")
+	leader := c.waitLeader(t)
+
+	missing := c.propose(t, leader, "DEL", "key")
+	set := c.propose(t, leader, "SET", "key", "value")
+	deleted := c.propose(t, leader, "DEL", "key")
+	c.network.Run(5)
+
+	assert.Equal(t, Result{Value: false}, <-missing)
+	assert.Equal(t, Result{}, <-set)
+	assert.Equal(t, Result{Value: true}, <-deleted)
+}
+
+func TestPartitionedLeader(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	c := newTestCluster(t, 4, 0, "n1", "n2", "n3", "n4", "n5")
+	oldLeader := c.waitLeader(t)
+
+	var majority []string
+	for _, id := range c.ids {
+		if id != oldLeader && len(majority) < 3 {
+			majority = append(majority, id)
+		}
+	}
+	c.network.Partition(majority)
+
+	// proposal in minority can not be committed
+	lost := c.propose(t, oldLeader, "SET", "key", "lost")
+
+	var newLeader string
+	for range 100 {
+		c.network.Tick()
+		newLeader = c.network.Leader()
+		if newLeader != "" && newLeader != oldLeader {
+			break
+		}
+	}
+	require.NotEqual(t, oldLeader, newLeader)
+	require.Contains(t, majority, newLeader)
+
+	committed := c.propose(t, newLeader, "SET", "key", "committed")
+	c.network.Run(5)
+	assert.NoError(t, (<-committed).Err)
+
+	c.network.Heal()
+	c.network.Run(30)
+
+	assert.ErrorIs(t, (<-lost).Err, ErrProposalDropped)
+	assert.Equal(t, newLeader, c.network.Leader())
+
+	for _, id := range c.ids {
+		value, _ := c.machines[id].get("key")
+		assert.Equal(t, "committed", value, id)
+	}
+}
+
+func TestSnapshotInstall(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	c := newTestCluster(t, 5, 5, "n1", "n2", "n3")
+	leader := c.waitLeader(t)
+
+	var lagging string
+	for _, id := range c.ids {
+		if id != leader {
+			lagging = id
+			break
+		}
+	}
+	c.network.Crash(lagging)
+
+	for i := range 30 {
+		c.propose(t, leader, "SET", fmt.Sprintf("key%d", i), fmt.Sprintf("value%d", i))
+		c.network.Run(1)
+	}
+
+	status := c.network.Node(leader).Status()
+	require.Greater(t, c.network.Node(leader).log.snapshot.Index, uint64(0))
+
+	c.start(t, lagging, 100)
+	c.network.Run(30)
+
+	lagStatus := c.network.Node(lagging).Status()
+	assert.GreaterOrEqual(t, lagStatus.Applied, status.Applied)
+
+	for i := range 30 {
+		value, ok := c.machines[lagging].get(fmt.Sprintf("key%d", i))
+		assert.True(t, ok)
+		assert.Equal(t, fmt.Sprintf("value%d", i), value)
+	}
+}
+
+func TestLossyNetwork(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	c := newTestCluster(t, 6, 10, "n1", "n2", "n3")
+	c.network.SetDropRate(0.2)
+
+	var results []<-chan Result
+	for i := 0; len(results) < 20 && i < 1000; i++ {
+		c.network.Tick()
+
+		leader := c.network.Leader()
+		if leader == "" {
+			continue
+		}
+
+		done, err := c.network.Node(leader).ProposeAsync(wal.Request{
+			Command: "SET", Args: []string{fmt.Sprintf("key%d", len(results)), "value"},
+		})
+		if err == nil {
+			results = append(results, done)
+		}
+	}
+
+	c.network.SetDropRate(0)
+	c.network.Run(50)
+
+	leader := c.network.Leader()
+	require.NotEmpty(t, leader)
+
+	// committed entries must be applied on all nodes
+	for i, done := range results {
+		if result := <-done; result.Err != nil {
+			continue
+		}
+
+		key := fmt.Sprintf("key%d", i)
+		for _, id := range c.ids {
+			_, ok := c.machines[id].get(key)
+			assert.True(t, ok, "%s: %s", id, key)
+		}
+	}
+}
+
+func TestRestartFromPersister(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	c := newTestCluster(t, 7, 0, "n1", "n2", "n3")
+	leader := c.waitLeader(t)
+
+	done := c.propose(t, leader, "SET", "key", "value")
+	c.network.Run(5)
+	require.NoError(t, (<-done).Err)
+
+	for i, id := range c.ids {
+		c.network.Crash(id)
+		c.start(t, id, int64(200+i))
+
+		value, ok := c.machines[id].get("key")
+		assert.True(t, ok, id)
+		assert.Equal(t, "value", value, id)
+	}
+
+	newLeader := c.waitLeader(t)
+	done = c.propose(t, newLeader, "DEL", "key")
+	c.network.Run(5)
+
+	result := <-done
+	require.NoError(t, result.Err)
+	assert.Equal(t, true, result.Value)
+
+	for _, id := range c.ids {
+		_, ok := c.machines[id].get("key")
+		assert.False(t, ok, id)
+	}
+}
+
+type failingPersister struct {
+	*MemoryPersister
+
+	mutex sync.Mutex
+	fail  bool
+}
+
+func (p *failingPersister) setFail(fail bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.fail = fail
+}
+
+func (p *failingPersister) Save(state HardState, snapshot Snapshot, entries []Entry) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.fail {
+		return fmt.Errorf("disk is full")
+	}
+
+	return p.MemoryPersister.Save(state, snapshot, entries)
+}
+
+type recordingTransport struct {
+	messages []Message
+}
+
+func (t *recordingTransport) Send(messages []Message) {
+	t.messages = append(t.messages, messages...)
+}
+
+func TestPersistFailure(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	persister := &failingPersister{MemoryPersister: NewMemoryPersister()}
+	transport := &recordingTransport{}
+
+	node, err := NewNode(Settings{ID: "n1", Peers: []string{"n1", "n2", "n3"}, Seed: 1},
+		newMapStateMachine(), persister, transport)
+	require.NoError(t, err)
+
+	persister.setFail(true)
+	node.Step(Message{Type: MsgVote, From: "n2", To: "n1", Term: 1})
+
+	// vote is not granted until it is saved
+	assert.Empty(t, transport.messages)
+
+	state, _, _, err := persister.Load()
+	require.NoError(t, err)
+	assert.Equal(t, HardState{}, state)
+
+	persister.setFail(false)
+	node.Tick()
+
+	state, _, _, err = persister.Load()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), state.Term)
+	assert.Equal(t, "n2", state.VotedFor)
+
+	// node which voted in term does not vote for another candidate
+	node.Step(Message{Type: MsgVote, From: "n3", To: "n1", Term: 1})
+	require.Len(t, transport.messages, 1)
+	assert.Equal(t, MsgVoteResp, transport.messages[0].Type)
+	assert.True(t, transport.messages[0].Reject)
+}
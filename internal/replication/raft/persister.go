@@ -0,0 +1,329 @@
+package raft
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Files of FilePersister
+const (
+	hardStateFilename = "raft_state.bin"
+	snapshotFilename  = "raft_snapshot.bin"
+	logFilename       = "raft_log.bin"
+)
+
+// Persister is interface for raft state storage
+type Persister interface {
+	Save(state HardState, snapshot Snapshot, entries []Entry) error
+	Load() (HardState, Snapshot, []Entry, error)
+}
+
+type persistentState struct {
+	State    HardState
+	Snapshot Snapshot
+	Entries  []Entry
+}
+
+// MemoryPersister is a persister which keeps state in memory
+type MemoryPersister struct {
+	mutex sync.Mutex
+	state persistentState
+}
+
+// NewMemoryPersister returns new memory persister
+func NewMemoryPersister() *MemoryPersister {
+	return &MemoryPersister{}
+}
+
+// Save saves state
+func (p *MemoryPersister) Save(state HardState, snapshot Snapshot, entries []Entry) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.state = persistentState{
+		State:    state,
+		Snapshot: snapshot,
+		Entries:  append([]Entry(nil), entries...),
+	}
+	return nil
+}
+
+// Load loads state
+func (p *MemoryPersister) Load() (HardState, Snapshot, []Entry, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.state.State, p.state.Snapshot, append([]Entry(nil), p.state.Entries...), nil
+}
+
+// FilePersister is a persister which keeps state in files. Log entries
+// are appended to log file, it is rewritten only when its prefix is
+// compacted or its suffix is truncated. Hard state and snapshot are
+// replaced atomically when they are changed
+type FilePersister struct {
+	directory string
+
+	// state and snapshot are the last saved ones
+	state    HardState
+	snapshot Snapshot
+	// first and last are indexes of entries in log file and lastTerm is
+	// term of the last one, entries with the same index and term are
+	// preceded by the same entries, so log file is a prefix of saved
+	// entries if its last entry matches
+	first    uint64
+	last     uint64
+	lastTerm uint64
+	// loaded is false until files are read or written, so unknown files
+	// are replaced by the first save
+	loaded bool
+}
+
+// NewFilePersister returns new file persister
+func NewFilePersister(directory string) (*FilePersister, error) {
+	if err := os.MkdirAll(directory, os.ModePerm); err != nil { //nolint:gosec
+		return nil, fmt.Errorf("mkdir error: %w", err)
+	}
+
+	return &FilePersister{directory: directory}, nil
+}
+
+// Save writes changed parts of state. Snapshot is written before log is
+// compacted and hard state is written after log, so entries are not lost
+// if save is interrupted
+func (p *FilePersister) Save(state HardState, snapshot Snapshot, entries []Entry) error {
+	if !p.loaded || snapshot.Index != p.snapshot.Index || snapshot.Term != p.snapshot.Term {
+		if err := p.writeGob(snapshotFilename, snapshot); err != nil {
+			return fmt.Errorf("failed to write raft snapshot: %w", err)
+		}
+		p.snapshot = snapshot
+	}
+
+	if err := p.saveEntries(snapshot, entries); err != nil {
+		return fmt.Errorf("failed to write raft log: %w", err)
+	}
+
+	if !p.loaded || state != p.state {
+		if err := p.writeGob(hardStateFilename, state); err != nil {
+			return fmt.Errorf("failed to write raft state: %w", err)
+		}
+		p.state = state
+	}
+
+	p.loaded = true
+	return nil
+}
+
+// Load reads state from files
+func (p *FilePersister) Load() (HardState, Snapshot, []Entry, error) {
+	var state HardState
+	if err := p.readGob(hardStateFilename, &state); err != nil {
+		return HardState{}, Snapshot{}, nil, fmt.Errorf("failed to read raft state: %w", err)
+	}
+
+	var snapshot Snapshot
+	if err := p.readGob(snapshotFilename, &snapshot); err != nil {
+		return HardState{}, Snapshot{}, nil, fmt.Errorf("failed to read raft snapshot: %w", err)
+	}
+
+	entries, err := p.readEntries()
+	if err != nil {
+		return HardState{}, Snapshot{}, nil, fmt.Errorf("failed to read raft log: %w", err)
+	}
+
+	// log could be not compacted yet after snapshot was written
+	for len(entries) != 0 && entries[0].Index <= snapshot.Index {
+		entries = entries[1:]
+	}
+
+	p.state = state
+	p.snapshot = snapshot
+	p.setEntries(snapshot, entries)
+	p.loaded = true
+
+	return state, snapshot, entries, nil
+}
+
+// saveEntries appends entries which are not in log file, log file is
+// rewritten if its entries are compacted or truncated
+func (p *FilePersister) saveEntries(snapshot Snapshot, entries []Entry) error {
+	first := snapshot.Index + 1
+	if len(entries) != 0 {
+		first = entries[0].Index
+	}
+
+	last := first - 1
+	if len(entries) != 0 {
+		last = entries[len(entries)-1].Index
+	}
+
+	switch {
+	case !p.loaded || first != p.first || last < p.last:
+		return p.rewriteEntries(snapshot, entries)
+	case p.last >= first && entries[p.last-first].Term != p.lastTerm:
+		return p.rewriteEntries(snapshot, entries)
+	case last == p.last:
+		return nil
+	}
+
+	data, err := encodeEntries(entries[p.last+1-first:])
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(filepath.Join(p.directory, logFilename), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if err = writeAndSync(file, data); err != nil {
+		return err
+	}
+
+	p.setEntries(snapshot, entries)
+	return nil
+}
+
+// rewriteEntries atomically replaces log file with entries
+func (p *FilePersister) rewriteEntries(snapshot Snapshot, entries []Entry) error {
+	data, err := encodeEntries(entries)
+	if err != nil {
+		return err
+	}
+
+	if err = p.writeFile(logFilename, data); err != nil {
+		return err
+	}
+
+	p.setEntries(snapshot, entries)
+	return nil
+}
+
+// setEntries remembers position of entries saved in log file
+func (p *FilePersister) setEntries(snapshot Snapshot, entries []Entry) {
+	p.first = snapshot.Index + 1
+	p.last = snapshot.Index
+	p.lastTerm = snapshot.Term
+
+	if len(entries) != 0 {
+		p.first = entries[0].Index
+		p.last = entries[len(entries)-1].Index
+		p.lastTerm = entries[len(entries)-1].Term
+	}
+}
+
+// encodeEntries returns log records of entries, every record is encoded
+// size of entry and its gob encoding
+func encodeEntries(entries []Entry) ([]byte, error) {
+	var buffer bytes.Buffer
+	for _, entry := range entries {
+		var record bytes.Buffer
+		if err := gob.NewEncoder(&record).Encode(entry); err != nil {
+			return nil, err
+		}
+
+		_ = binary.Write(&buffer, binary.LittleEndian, uint32(record.Len())) //nolint:gosec
+		buffer.Write(record.Bytes())
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// readEntries reads log file, incomplete record written by interrupted
+// save is removed
+func (p *FilePersister) readEntries() ([]Entry, error) {
+	filename := filepath.Join(p.directory, logFilename)
+
+	data, err := os.ReadFile(filepath.Clean(filename))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []Entry
+	offset := 0
+	for offset+4 <= len(data) {
+		size := int(binary.LittleEndian.Uint32(data[offset:]))
+		if offset+4+size > len(data) {
+			break
+		}
+
+		var entry Entry
+		if err := gob.NewDecoder(bytes.NewReader(data[offset+4 : offset+4+size])).Decode(&entry); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+		offset += 4 + size
+	}
+
+	if offset != len(data) {
+		if err := os.Truncate(filename, int64(offset)); err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+// writeGob atomically replaces file with encoded value
+func (p *FilePersister) writeGob(name string, value any) error {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(value); err != nil {
+		return err
+	}
+
+	return p.writeFile(name, buffer.Bytes())
+}
+
+// writeFile writes data to temporary file and atomically renames it
+func (p *FilePersister) writeFile(name string, data []byte) error {
+	filename := filepath.Join(p.directory, name)
+	tmpFilename := filename + ".tmp"
+
+	file, err := os.OpenFile(filepath.Clean(tmpFilename), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if err = writeAndSync(file, data); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFilename, filename)
+}
+
+// writeAndSync writes data to file, syncs and closes it
+func writeAndSync(file *os.File, data []byte) error {
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// readGob decodes file to value, missing file leaves value empty
+func (p *FilePersister) readGob(name string, value any) error {
+	data, err := os.ReadFile(filepath.Join(p.directory, name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(value)
+}
@@ -0,0 +1,142 @@
+package raft
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"concurrency_go_course/internal/storage/wal"
+)
+
+func testEntries(term uint64, from, to uint64) []Entry {
+	var entries []Entry
+	for index := from; index <= to; index++ {
+		request := wal.Request{Command: "SET", Args: []string{"key", "value"}}
+		entries = append(entries, Entry{Term: term, Index: index, Request: request})
+	}
+	return entries
+}
+
+func loadFile(t *testing.T, directory string) (HardState, Snapshot, []Entry) {
+	t.Helper()
+
+	persister, err := NewFilePersister(directory)
+	require.NoError(t, err)
+
+	state, snapshot, entries, err := persister.Load()
+	require.NoError(t, err)
+
+	return state, snapshot, entries
+}
+
+func statFile(t *testing.T, directory, name string) os.FileInfo {
+	t.Helper()
+
+	info, err := os.Stat(filepath.Join(directory, name))
+	require.NoError(t, err)
+
+	return info
+}
+
+func TestFilePersister(t *testing.T) {
+	t.Parallel()
+
+	directory := t.TempDir()
+
+	persister, err := NewFilePersister(directory)
+	require.NoError(t, err)
+
+	state, snapshot, entries, err := persister.Load()
+	require.NoError(t, err)
+	assert.Equal(t, HardState{}, state)
+	assert.Equal(t, Snapshot{}, snapshot)
+	assert.Empty(t, entries)
+
+	state = HardState{Term: 1, VotedFor: "n1", Commit: 2}
+	require.NoError(t, persister.Save(state, Snapshot{}, testEntries(1, 1, 3)))
+	logInfo := statFile(t, directory, logFilename)
+	stateInfo := statFile(t, directory, hardStateFilename)
+
+	t.Run("new entries are appended", func(t *testing.T) {
+		require.NoError(t, persister.Save(state, Snapshot{}, testEntries(1, 1, 5)))
+
+		// log and hard state files are not replaced
+		assert.True(t, os.SameFile(logInfo, statFile(t, directory, logFilename)))
+		assert.True(t, os.SameFile(stateInfo, statFile(t, directory, hardStateFilename)))
+
+		loadedState, _, loadedEntries := loadFile(t, directory)
+		assert.Equal(t, state, loadedState)
+		assert.Equal(t, testEntries(1, 1, 5), loadedEntries)
+	})
+
+	t.Run("conflicting suffix is truncated", func(t *testing.T) {
+		entries := append(testEntries(1, 1, 3), testEntries(2, 4, 4)...)
+		state = HardState{Term: 2, Commit: 3}
+		require.NoError(t, persister.Save(state, Snapshot{}, entries))
+
+		loadedState, _, loadedEntries := loadFile(t, directory)
+		assert.Equal(t, state, loadedState)
+		assert.Equal(t, entries, loadedEntries)
+
+		entries = testEntries(1, 1, 2)
+		require.NoError(t, persister.Save(state, Snapshot{}, entries))
+
+		_, _, loadedEntries = loadFile(t, directory)
+		assert.Equal(t, entries, loadedEntries)
+	})
+
+	t.Run("compacted prefix is removed", func(t *testing.T) {
+		snapshot := Snapshot{Index: 2, Term: 1, Data: []byte("data")}
+		entries := testEntries(2, 3, 4)
+		require.NoError(t, persister.Save(state, snapshot, entries))
+
+		_, loadedSnapshot, loadedEntries := loadFile(t, directory)
+		assert.Equal(t, snapshot, loadedSnapshot)
+		assert.Equal(t, entries, loadedEntries)
+	})
+}
+
+func TestFilePersisterRecovery(t *testing.T) {
+	t.Parallel()
+
+	directory := t.TempDir()
+
+	persister, err := NewFilePersister(directory)
+	require.NoError(t, err)
+
+	_, _, _, err = persister.Load()
+	require.NoError(t, err)
+	require.NoError(t, persister.Save(HardState{Term: 1}, Snapshot{}, testEntries(1, 1, 2)))
+
+	// record of interrupted append is incomplete
+	file, err := os.OpenFile(filepath.Join(directory, logFilename), os.O_WRONLY|os.O_APPEND, 0o600)
+	require.NoError(t, err)
+	_, err = file.Write([]byte{100, 0, 0, 0, 1})
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	restarted, err := NewFilePersister(directory)
+	require.NoError(t, err)
+
+	_, _, entries, err := restarted.Load()
+	require.NoError(t, err)
+	assert.Equal(t, testEntries(1, 1, 2), entries)
+
+	require.NoError(t, restarted.Save(HardState{Term: 1}, Snapshot{}, testEntries(1, 1, 3)))
+
+	_, _, entries = loadFile(t, directory)
+	assert.Equal(t, testEntries(1, 1, 3), entries)
+
+	t.Run("log is not compacted after snapshot", func(t *testing.T) {
+		// snapshot is written before log is compacted
+		snapshot := Snapshot{Index: 2, Term: 1}
+		require.NoError(t, restarted.writeGob(snapshotFilename, snapshot))
+
+		_, loadedSnapshot, entries := loadFile(t, directory)
+		assert.Equal(t, snapshot, loadedSnapshot)
+		assert.Equal(t, testEntries(1, 3, 3), entries)
+	})
+}
@@ -0,0 +1,141 @@
+package raft
+
+import (
+	"errors"
+	"fmt"
+
+	"concurrency_go_course/internal/storage/wal"
+)
+
+// State is a raft node role
+type State int
+
+const (
+	// StateFollower is a follower role
+	StateFollower State = iota
+	// StateCandidate is a candidate role
+	StateCandidate
+	// StateLeader is a leader role
+	StateLeader
+)
+
+// String returns state name
+func (s State) String() string {
+	switch s {
+	case StateFollower:
+		return "follower"
+	case StateCandidate:
+		return "candidate"
+	case StateLeader:
+		return "leader"
+	}
+
+	return "unknown"
+}
+
+// MessageType is a type of raft message
+type MessageType int
+
+const (
+	// MsgVote is a vote request from candidate
+	MsgVote MessageType = iota
+	// MsgVoteResp is a response for vote request
+	MsgVoteResp
+	// MsgApp is an append entries request (also used as heartbeat)
+	MsgApp
+	// MsgAppResp is a response for append entries request
+	MsgAppResp
+	// MsgSnap is a chunk of snapshot sent by leader
+	MsgSnap
+	// MsgSnapResp is a response for snapshot chunk
+	MsgSnapResp
+)
+
+// Entry is a raft log entry
+type Entry struct {
+	Term    uint64
+	Index   uint64
+	Request wal.Request
+}
+
+// Snapshot is a state machine snapshot with last included entry position
+type Snapshot struct {
+	Index uint64
+	Term  uint64
+	Data  []byte
+}
+
+// HardState is a state which must be persisted before responding to messages
+type HardState struct {
+	Term     uint64
+	VotedFor string
+	Commit   uint64
+}
+
+// Message is a raft RPC message
+type Message struct {
+	Type MessageType
+	From string
+	To   string
+	Term uint64
+
+	// LogIndex and LogTerm are the previous entry position for MsgApp
+	// and the last entry position for MsgVote
+	LogIndex uint64
+	LogTerm  uint64
+	Entries  []Entry
+	Commit   uint64
+
+	// Reject marks negative response, Index is a match index
+	// (or a hint for the leader if the request was rejected)
+	Reject bool
+	Index  uint64
+
+	// Snapshot chunk fields
+	SnapIndex  uint64
+	SnapTerm   uint64
+	SnapOffset uint64
+	SnapData   []byte
+	SnapDone   bool
+}
+
+// StateMachine is interface for replicated state machine, result of Apply
+// is returned to proposer of request
+type StateMachine interface {
+	Apply(request wal.Request) any
+	Snapshot() ([]byte, error)
+	Restore(data []byte) error
+}
+
+// Result is a result of proposal, Value is returned by state machine when
+// request is applied
+type Result struct {
+	Value any
+	Err   error
+}
+
+// Transport is interface for sending messages to peers
+type Transport interface {
+	Send(messages []Message)
+}
+
+var (
+	// ErrProposalDropped is returned if proposal was not committed
+	ErrProposalDropped = errors.New("raft proposal dropped")
+	// ErrStopped is returned if node was stopped
+	ErrStopped = errors.New("raft node stopped")
+)
+
+// NotLeaderError is returned if proposal was sent to non-leader node
+type NotLeaderError struct {
+	Leader string
+}
+
+// Error returns error message
+func (e *NotLeaderError) Error() string {
+	if e.Leader == "" {
+		return "raft: node is not a leader, leader is unknown"
+	}
+
+	return fmt.Sprintf("raft: node is not a leader, leader is %s", e.Leader)
+}
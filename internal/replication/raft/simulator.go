@@ -0,0 +1,189 @@
+package raft
+
+import (
+	"math/rand"
+	"slices"
+	"sync"
+)
+
+const maxDeliveryRounds = 1000
+
+// SimNetwork is a deterministic in-process network for raft nodes.
+// Messages are delivered only by Tick/Deliver calls, so the run
+// depends only on the seed and the sequence of calls.
+type SimNetwork struct {
+	mutex sync.Mutex
+
+	rand     *rand.Rand
+	nodes    map[string]*Node
+	ids      []string
+	queue    []Message
+	groups   map[string]int
+	down     map[string]bool
+	dropRate float64
+}
+
+type simTransport struct {
+	network *SimNetwork
+}
+
+// NewSimNetwork returns new simulated network
+func NewSimNetwork(seed int64) *SimNetwork {
+	return &SimNetwork{
+		rand:   rand.New(rand.NewSource(seed)), //nolint:gosec
+		nodes:  make(map[string]*Node),
+		groups: make(map[string]int),
+		down:   make(map[string]bool),
+	}
+}
+
+// Transport returns transport bound to simulated network
+func (s *SimNetwork) Transport() Transport {
+	return &simTransport{network: s}
+}
+
+// Send enqueues messages for delivery
+func (t *simTransport) Send(messages []Message) {
+	t.network.mutex.Lock()
+	defer t.network.mutex.Unlock()
+
+	t.network.queue = append(t.network.queue, messages...)
+}
+
+// AddNode adds node to network, node with the same id is replaced
+func (s *SimNetwork) AddNode(node *Node) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.nodes[node.ID()]; !ok {
+		s.ids = append(s.ids, node.ID())
+		slices.Sort(s.ids)
+	}
+	s.nodes[node.ID()] = node
+	delete(s.down, node.ID())
+}
+
+// Node returns node by id
+func (s *SimNetwork) Node(id string) *Node {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.nodes[id]
+}
+
+// Crash stops delivering messages and ticks to node
+func (s *SimNetwork) Crash(id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.down[id] = true
+}
+
+// Partition splits network into groups, nodes which are not listed
+// form one more group
+func (s *SimNetwork) Partition(groups ...[]string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.groups = make(map[string]int)
+	for i, group := range groups {
+		for _, id := range group {
+			s.groups[id] = i + 1
+		}
+	}
+}
+
+// Heal removes all partitions
+func (s *SimNetwork) Heal() {
+	s.Partition()
+}
+
+// SetDropRate sets probability of message loss
+func (s *SimNetwork) SetDropRate(rate float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.dropRate = rate
+}
+
+// Tick ticks all nodes and delivers messages
+func (s *SimNetwork) Tick() {
+	for _, node := range s.aliveNodes() {
+		node.Tick()
+	}
+
+	s.Deliver()
+}
+
+// Run makes ticks
+func (s *SimNetwork) Run(ticks int) {
+	for range ticks {
+		s.Tick()
+	}
+}
+
+// Deliver delivers queued messages until queue is empty
+func (s *SimNetwork) Deliver() {
+	for range maxDeliveryRounds {
+		s.mutex.Lock()
+		queue := s.queue
+		s.queue = nil
+		s.mutex.Unlock()
+
+		if len(queue) == 0 {
+			return
+		}
+
+		for _, m := range queue {
+			if node := s.receiver(m); node != nil {
+				node.Step(m)
+			}
+		}
+	}
+}
+
+// Leader returns id of leader with the highest term among alive nodes
+func (s *SimNetwork) Leader() string {
+	var (
+		leader string
+		term   uint64
+	)
+
+	for _, node := range s.aliveNodes() {
+		status := node.Status()
+		if status.State == StateLeader && status.Term >= term {
+			leader, term = status.ID, status.Term
+		}
+	}
+
+	return leader
+}
+
+func (s *SimNetwork) aliveNodes() []*Node {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	nodes := make([]*Node, 0, len(s.ids))
+	for _, id := range s.ids {
+		if !s.down[id] {
+			nodes = append(nodes, s.nodes[id])
+		}
+	}
+
+	return nodes
+}
+
+func (s *SimNetwork) receiver(m Message) *Node {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.down[m.From] || s.down[m.To] || s.groups[m.From] != s.groups[m.To] {
+		return nil
+	}
+
+	if s.dropRate > 0 && s.rand.Float64() < s.dropRate {
+		return nil
+	}
+
+	return s.nodes[m.To]
+}
@@ -0,0 +1,200 @@
+package raft
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"concurrency_go_course/internal/config"
+	"concurrency_go_course/internal/network"
+	"concurrency_go_course/pkg/logger"
+)
+
+const (
+	peerQueueSize = 256
+	// maxMessageSize limits raft messages, it does not depend on client
+	// message size because append messages carry batches of entries
+	maxMessageSize = "16MB"
+)
+
+var ackResponse = []byte("OK")
+
+// NetworkTransport is a transport which sends messages over TCP
+type NetworkTransport struct {
+	id     string
+	peers  map[string]string
+	server *network.TCPServer
+	queues map[string]chan Message
+	tls    *config.TLSConfig
+}
+
+// NewNetworkTransport creates TCP transport listening on node address
+func NewNetworkTransport(cfg *config.Config, id string, peers map[string]string) (*NetworkTransport, error) {
+	address, ok := peers[id]
+	if !ok {
+		return nil, fmt.Errorf("address for node %s is not found in peers", id)
+	}
+
+	if cfg == nil || cfg.Network == nil {
+		return nil, fmt.Errorf("config is empty")
+	}
+
+	var tlsCfg *config.TLSConfig
+	if cfg.Replication != nil {
+		tlsCfg = cfg.Replication.TLS
+	}
+
+	networkCfg := *cfg.Network
+	networkCfg.MaxMessageSize = maxMessageSize
+	serverCfg := *cfg
+	serverCfg.Network = &networkCfg
+
+	server, err := network.NewServerWithTLS(&serverCfg, address, tlsCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	queues := make(map[string]chan Message, len(peers))
+	for peer := range peers {
+		if peer != id {
+			queues[peer] = make(chan Message, peerQueueSize)
+		}
+	}
+
+	return &NetworkTransport{
+		id:     id,
+		peers:  peers,
+		server: server,
+		queues: queues,
+		tls:    tlsCfg,
+	}, nil
+}
+
+// Send enqueues messages, messages are dropped if peer queue is full
+func (t *NetworkTransport) Send(messages []Message) {
+	for _, m := range messages {
+		queue, ok := t.queues[m.To]
+		if !ok {
+			continue
+		}
+
+		select {
+		case queue <- m:
+		default:
+			logger.Debug("raft peer queue is full, message dropped", zap.String("peer", m.To))
+		}
+	}
+}
+
+// Run starts receiving messages for node and sending queued messages to peers
+func (t *NetworkTransport) Run(ctx context.Context, node *Node) {
+	var wg sync.WaitGroup
+
+	for peer, queue := range t.queues {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.sendLoop(ctx, t.peers[peer], queue)
+		}()
+	}
+
+	t.server.Run(ctx, func(ctx context.Context, data []byte) []byte {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		m, err := DecodeMessage(data)
+		if err != nil {
+			logger.Error("unable to decode raft message", zap.Error(err))
+			return ackResponse
+		}
+
+		node.Step(m)
+		return ackResponse
+	})
+
+	wg.Wait()
+}
+
+func (t *NetworkTransport) sendLoop(ctx context.Context, address string, queue chan Message) {
+	var (
+		mutex  sync.Mutex
+		client *network.TCPClient
+	)
+
+	// peer does not respond after its server is stopped, so pending send
+	// is interrupted by closing connection
+	stop := context.AfterFunc(ctx, func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if client != nil {
+			client.Close()
+		}
+	})
+	defer func() {
+		if stop() && client != nil {
+			client.Close()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m := <-queue:
+			if client == nil {
+				conn, err := network.NewClientWithTLS(address, t.tls)
+				if err != nil {
+					logger.Debug("unable to connect to raft peer", zap.String("address", address),
+						zap.Error(err))
+					continue
+				}
+
+				mutex.Lock()
+				client = conn
+				mutex.Unlock()
+				if ctx.Err() != nil {
+					return
+				}
+			}
+
+			data, err := EncodeMessage(m)
+			if err != nil {
+				logger.Error("unable to encode raft message", zap.Error(err))
+				continue
+			}
+
+			if _, err = client.Send(data); err != nil {
+				logger.Debug("unable to send raft message", zap.String("address", address),
+					zap.Error(err))
+				mutex.Lock()
+				client.Close()
+				client = nil
+				mutex.Unlock()
+			}
+		}
+	}
+}
+
+// EncodeMessage encodes raft message
+func EncodeMessage(m Message) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(m); err != nil {
+		return nil, fmt.Errorf("failed to encode object: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+// DecodeMessage decodes raft message
+func DecodeMessage(data []byte) (Message, error) {
+	var m Message
+	if err := gob.NewDecoder(bytes.NewBuffer(data)).Decode(&m); err != nil {
+		return Message{}, fmt.Errorf("failed to decode object: %w", err)
+	}
+	return m, nil
+}
@@ -0,0 +1,92 @@
+package raft
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"concurrency_go_course/internal/config"
+	"concurrency_go_course/internal/storage/wal"
+	"concurrency_go_course/pkg/logger"
+)
+
+func TestNetworkTransportCluster(t *testing.T) {
+	logger.MockLogger()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	peers := map[string]string{
+		"n1": "127.0.0.1:4501",
+		"n2": "127.0.0.1:4502",
+		"n3": "127.0.0.1:4503",
+	}
+
+	cfg := &config.Config{
+		Network: &config.NetworkConfig{
+			MaxConnections: 100,
+			MaxMessageSize: "4KB",
+			IdleTimeout:    "5m",
+		},
+	}
+
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	nodes := make(map[string]*Node)
+	machines := make(map[string]*mapStateMachine)
+	for id := range peers {
+		transport, err := NewNetworkTransport(cfg, id, peers)
+		require.NoError(t, err)
+
+		machines[id] = newMapStateMachine()
+		node, err := NewNode(Settings{
+			ID:    id,
+			Peers: []string{"n1", "n2", "n3"},
+		}, machines[id], NewMemoryPersister(), transport)
+		require.NoError(t, err)
+		nodes[id] = node
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			transport.Run(ctx, node)
+		}()
+		go func() {
+			defer wg.Done()
+			node.Run(ctx, 10*time.Millisecond)
+		}()
+	}
+
+	var leader *Node
+	require.Eventually(t, func() bool {
+		for _, node := range nodes {
+			if node.Status().State == StateLeader {
+				leader = node
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+
+	proposeCtx, proposeCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer proposeCancel()
+
+	_, err := leader.Propose(proposeCtx, wal.Request{Command: "SET", Args: []string{"key", "value"}})
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		for _, machine := range machines {
+			if value, ok := machine.get("key"); !ok || value != "value" {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -5,10 +5,13 @@ const (
 	ReplicaTypeMaster = "master"
 	// ReplicaTypeSlave is replication type slave
 	ReplicaTypeSlave = "slave"
+	// ReplicaTypeRaft is replication type with raft consensus
+	ReplicaTypeRaft = "raft"
 )
 
 // Replication is struct for replication
 type Replication struct {
 	Slave  *Slave
 	Master *Master
+	Raft   *Raft
 }
@@ -1,10 +1,14 @@
 package replication
 
 import (
-	"bytes"
 	"context"
+	"crypto/hmac"
+	"errors"
 	"fmt"
+	"os"
 	"path"
+	"slices"
+	"sync/atomic"
 	"time"
 
 	"concurrency_go_course/internal/config"
@@ -12,18 +16,51 @@ import (
 	"concurrency_go_course/internal/network"
 	"concurrency_go_course/internal/storage/wal"
 	"concurrency_go_course/pkg/logger"
+	"concurrency_go_course/pkg/trace"
 
 	"go.uber.org/zap"
 )
 
+// Batch is a batch of requests from replication stream, receiver must
+// call Ack after requests are applied
+type Batch struct {
+	Requests []wal.Request
+	applied  chan struct{}
+}
+
+// Ack marks batch as applied
+func (b Batch) Ack() {
+	close(b.applied)
+}
+
+const defaultResponseTimeout = 10 * time.Second
+
 // Slave is struct for slave replication
 type Slave struct {
-	masterAddress string
-	connection    *network.TCPClient
-	syncInterval  time.Duration
-	walDirectory  string
-	stream        chan []wal.Request
-	fileLib       filesystem.FileLib
+	// addresses are candidate masters, current is the index of the last
+	// used address, connection is nil until master is reached
+	addresses       []string
+	current         int
+	tls             *config.TLSConfig
+	connection      *network.TCPClient
+	backoff         *backoff
+	syncInterval    time.Duration
+	readWaitTimeout time.Duration
+	walDirectory    string
+	stream          chan Batch
+	fileLib         filesystem.FileLib
+	progress        *Progress
+
+	replicaID    string
+	clusterID    string
+	capabilities []string
+	session      *session
+	secret       []byte
+
+	// master is an address of the last reached master, it is read by INFO
+	// while slave syncs
+	master atomic.Pointer[string]
+	linkUp atomic.Bool
 }
 
 // NewReplicationClient returns new replication client
@@ -38,105 +75,401 @@ func NewReplicationClient(
 		return nil, fmt.Errorf("WAL config is empty")
 	}
 
-	connection, err := network.NewClient(cfg.Replication.MasterAddress)
+	if err := validateTLS(cfg.Replication.TLS); err != nil {
+		return nil, err
+	}
+
+	if cfg.Replication.TLS != nil {
+		if _, err := network.ClientTLSConfig(cfg.Replication.TLS); err != nil {
+			return nil, err
+		}
+	}
+
+	addresses := masterAddresses(cfg.Replication)
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("master address is empty")
+	}
+
+	capabilities, err := capabilitiesFromConfig(cfg.Replication.Capabilities)
 	if err != nil {
-		return nil, fmt.Errorf("connection create error: %w", err)
+		return nil, err
+	}
+
+	replicaID := cfg.Replication.ReplicaID
+	if replicaID == "" && cfg.Network != nil {
+		replicaID = cfg.Network.Address
+	}
+	if replicaID == "" {
+		replicaID, _ = os.Hostname()
+	}
+
+	fileLib := filesystem.NewFileLib()
+
+	readWaitTimeout := cfg.Replication.ReadWaitTimeout
+	if readWaitTimeout == 0 {
+		readWaitTimeout = 2 * cfg.Replication.SyncInterval
 	}
 
 	return &Slave{
-		connection:    connection,
-		masterAddress: cfg.Replication.MasterAddress,
-		syncInterval:  cfg.Replication.SyncInterval,
-		walDirectory:  walCfg.WalConfig.DataDirectory,
-		stream:        make(chan []wal.Request),
-		fileLib:       filesystem.NewFileLib(),
+		addresses:       addresses,
+		tls:             cfg.Replication.TLS,
+		backoff:         newBackoff(cfg.Replication.ReconnectMinBackoff, cfg.Replication.ReconnectMaxBackoff),
+		syncInterval:    cfg.Replication.SyncInterval,
+		readWaitTimeout: readWaitTimeout,
+		walDirectory:    walCfg.WalConfig.DataDirectory,
+		stream:          make(chan Batch),
+		fileLib:         fileLib,
+		progress:        NewProgress(localLSN(fileLib, walCfg.WalConfig.DataDirectory)),
+		replicaID:       replicaID,
+		clusterID:       cfg.Replication.ClusterID,
+		capabilities:    capabilities,
+		secret:          []byte(cfg.Replication.SharedSecret),
 	}, nil
 }
 
-// Start starts slave
+// Start starts slave, after failed sync master is retried with backoff
 func (s *Slave) Start(ctx context.Context) {
 	logger.Debug("replication client was started",
-		zap.String("sync_interval", s.syncInterval.String()))
-	ticker := time.NewTicker(s.syncInterval)
+		zap.String("sync_interval", s.syncInterval.String()),
+		zap.Strings("master_addresses", s.addresses))
+	timer := time.NewTimer(s.syncInterval)
 	defer func() {
-		ticker.Stop()
-		s.connection.Close()
+		timer.Stop()
+		s.disconnect()
 	}()
 
 	for {
 		select {
 		case <-ctx.Done():
 			logger.Debug("replication client stopping")
-			s.connection.Close()
 			return
-		default:
+		case <-timer.C:
 		}
 
-		select {
-		case <-ticker.C:
-			s.syncWithMaster()
+		delay := s.syncInterval
+		if err := s.syncWithMaster(); err != nil {
+			if errors.Is(err, errHandshakeRejected) {
+				logger.ErrorWithMsg("replication client stopped:", err)
+				return
+			}
 
-		case <-ctx.Done():
-			logger.Debug("replication client stopping")
-			s.connection.Close()
-			return
+			delay = s.backoff.Next()
+			logger.Info("master is unavailable, retrying", zap.Duration("delay", delay))
+		} else {
+			s.backoff.Reset()
 		}
+
+		timer.Reset(delay)
 	}
 }
 
 // ReplicationStream returns replication stream channel
-func (s *Slave) ReplicationStream() chan []wal.Request {
+func (s *Slave) ReplicationStream() chan Batch {
 	return s.stream
 }
 
+// AppliedLSN returns the last applied position
+func (s *Slave) AppliedLSN() uint64 {
+	return s.progress.AppliedLSN()
+}
+
+// Lag returns replication lag, false if slave was never synced with master
+func (s *Slave) Lag() (time.Duration, bool) {
+	return s.progress.Lag()
+}
+
+// WaitLSN waits until position is applied, read wait timeout is expired or
+// ctx is canceled
+func (s *Slave) WaitLSN(ctx context.Context, lsn uint64) error {
+	ctx, cancel := context.WithTimeout(ctx, s.readWaitTimeout)
+	defer cancel()
+
+	return s.progress.WaitLSN(ctx, lsn)
+}
+
+// WaitLag waits until lag is less than maxLag, read wait timeout is expired
+// or ctx is canceled
+func (s *Slave) WaitLag(ctx context.Context, maxLag time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, s.readWaitTimeout)
+	defer cancel()
+
+	return s.progress.WaitLag(ctx, maxLag)
+}
+
 // IsMaster returns flag
 func (s *Slave) IsMaster() bool {
 	return false
 }
 
-func (s *Slave) syncWithMaster() {
-	lastSegmentName, err := s.fileLib.SegmentLast(s.walDirectory)
+func (s *Slave) syncWithMaster() error {
+	if s.session == nil {
+		if err := s.handshake(); err != nil {
+			return err
+		}
+	}
+
+	// segments are requested until slave is caught up
+	for {
+		synced, err := s.syncSegments()
+		if errors.Is(err, ErrHandshakeRequired) {
+			// master was restarted or session was replaced
+			s.session = nil
+			return nil
+		}
+
+		if err != nil {
+			logger.ErrorWithMsg("unable to sync on slave:", err)
+			return err
+		}
+
+		if !synced {
+			return nil
+		}
+	}
+}
+
+func (s *Slave) handshake() error {
+	handshake := NewHandshake(s.replicaID, s.clusterID, s.capabilities)
+	if len(s.secret) != 0 {
+		nonce, err := newNonce()
+		if err != nil {
+			return err
+		}
+		handshake.Nonce = nonce
+	}
+
+	response, err := s.send(SlaveRequest{Handshake: &handshake})
 	if err != nil {
-		logger.ErrorWithMsg("unable to sync on slave:", err)
+		logger.ErrorWithMsg("unable to handshake with master:", err)
+		return err
+	}
+
+	if response.Challenge != nil {
+		if err = s.answer(&handshake, response.Challenge); err != nil {
+			return fmt.Errorf("%w: %w", errHandshakeRejected, err)
+		}
+
+		response, err = s.send(SlaveRequest{Handshake: &handshake})
+		if err != nil {
+			logger.ErrorWithMsg("unable to handshake with master:", err)
+			return err
+		}
+	} else if len(s.secret) != 0 && response.Succeed {
+		return fmt.Errorf("%w: master did not authenticate with shared secret", errHandshakeRejected)
 	}
-	req := SlaveRequest{LastSegmentName: lastSegmentName}
 
+	if response.Error != "" || response.Handshake == nil {
+		return fmt.Errorf("%w: %s", errHandshakeRejected, response.Error)
+	}
+
+	s.session = &session{
+		id:           response.Handshake.SessionID,
+		replicaID:    s.replicaID,
+		capabilities: response.Handshake.Capabilities,
+	}
+
+	logger.Info("replication session was established",
+		zap.String("master_id", response.Handshake.MasterID),
+		zap.Int("protocol_version", response.Handshake.ProtocolVersion),
+		zap.Strings("capabilities", response.Handshake.Capabilities))
+
+	return nil
+}
+
+// answer verifies master proof and signs master challenge
+func (s *Slave) answer(handshake *Handshake, challenge *Challenge) error {
+	if len(s.secret) == 0 {
+		return errors.New("master requires shared secret")
+	}
+
+	if !hmac.Equal(challenge.Proof, masterProof(s.secret, handshake.Nonce)) {
+		return fmt.Errorf("master %w", errAuthFailed)
+	}
+
+	handshake.Challenge = challenge.Nonce
+	handshake.Proof = replicaProof(s.secret, challenge.Nonce, *handshake)
+	return nil
+}
+
+func (s *Slave) send(req SlaveRequest) (*MasterResponse, error) {
 	data, err := EncodeSlaveRequest(&req)
 	if err != nil {
-		logger.ErrorWithMsg("unable to encode request", err)
-		return
+		return nil, fmt.Errorf("unable to encode request: %w", err)
+	}
+
+	if s.connection == nil {
+		if err = s.connect(); err != nil {
+			return nil, err
+		}
 	}
 
 	resp, err := s.connection.Send(data)
 	if err != nil {
-		logger.ErrorWithMsg("unable to connect with master", err)
-		return
+		// the next attempt starts from another candidate to follow failover
+		s.disconnect()
+		s.current = (s.current + 1) % len(s.addresses)
+		return nil, fmt.Errorf("unable to connect with master: %w", err)
 	}
 
 	response := &MasterResponse{}
 	err = DecodeResponse(response, resp)
 	if err != nil {
-		logger.ErrorWithMsg("unable to decode response", err)
-		return
+		return nil, fmt.Errorf("unable to decode response: %w", err)
 	}
 
-	err = s.saveSegment(response.SegmentName, response.SegmentData)
+	return response, nil
+}
+
+// connect dials candidate masters starting from the current one
+func (s *Slave) connect() error {
+	var errs []error
+	for range s.addresses {
+		address := s.addresses[s.current]
+
+		connection, err := network.NewClientWithTLS(address, s.tls)
+		if err == nil {
+			connection.SetTimeout(defaultResponseTimeout)
+			s.connection = connection
+			s.master.Store(&address)
+			s.linkUp.Store(true)
+			logger.Info("connected to master", zap.String("address", address))
+			return nil
+		}
+
+		errs = append(errs, err)
+		s.current = (s.current + 1) % len(s.addresses)
+	}
+
+	return fmt.Errorf("unable to connect with master: %w", errors.Join(errs...))
+}
+
+// disconnect closes connection, session must be negotiated again
+// after reconnect
+func (s *Slave) disconnect() {
+	if s.connection != nil {
+		s.connection.Close()
+		s.connection = nil
+	}
+	s.session = nil
+	s.linkUp.Store(false)
+}
+
+// MasterStatus returns address of the last reached master, the first
+// candidate if master was not reached, and whether slave is connected
+func (s *Slave) MasterStatus() (string, bool) {
+	if address := s.master.Load(); address != nil {
+		return *address, s.linkUp.Load()
+	}
+
+	return s.addresses[0], false
+}
+
+// syncSegments requests segments from master, returns true if new data was received
+func (s *Slave) syncSegments() (_ bool, err error) {
+	_, span := trace.Start(context.Background(), "Slave.syncSegments", trace.WithKind(trace.KindClient))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	lastSegmentName, err := s.fileLib.SegmentLast(s.walDirectory)
 	if err != nil {
-		logger.ErrorWithMsg("unable to save segment", err)
-		return
+		logger.Debug("no local segments on slave", zap.Error(err))
+	}
+	span.SetAttributes(trace.String("replication.last_segment", lastSegmentName))
+
+	syncedAt := time.Now()
+	response, err := s.send(SlaveRequest{
+		LastSegmentName: lastSegmentName,
+		LastSegmentSize: s.segmentSize(lastSegmentName),
+		SessionID:       s.session.id,
+		Traceparent:     span.Context().Traceparent(),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if response.Error == ErrHandshakeRequired.Error() {
+		return false, ErrHandshakeRequired
+	}
+
+	if !response.Succeed {
+		return false, fmt.Errorf("master was unable to handle request: %s", response.Error)
+	}
+
+	segments, err := responseSegments(response)
+	if err != nil {
+		return false, err
+	}
+
+	prevLSN := s.progress.AppliedLSN()
+	appliedLSN := prevLSN
+	for _, segment := range segments {
+		err = s.saveSegment(segment.Name, segment.Data)
+		if err != nil {
+			return false, fmt.Errorf("unable to save segment: %w", err)
+		}
+
+		appliedLSN, err = s.applyDataToEngine(segment.Data)
+		if err != nil {
+			return false, fmt.Errorf("unable to apply data to engine: %w", err)
+		}
+	}
+
+	s.progress.Update(appliedLSN, response.LastLSN, syncedAt)
+	span.SetAttributes(trace.Int("replication.segments", len(segments)),
+		trace.Int64("replication.applied_lsn", int64(appliedLSN)))
+
+	if len(segments) == 0 {
+		return false, nil
 	}
 
-	err = s.applyDataToEngine(response.SegmentData)
+	advanced := appliedLSN > prevLSN || segments[len(segments)-1].Name != lastSegmentName
+	return advanced && appliedLSN < response.LastLSN, nil
+}
+
+// responseSegments returns decompressed segments from response
+func responseSegments(response *MasterResponse) ([]Segment, error) {
+	segments := response.Segments
+	if response.SegmentName != "" {
+		segments = append([]Segment{{Name: response.SegmentName, Data: response.SegmentData}}, segments...)
+	}
+
+	if !response.Compressed {
+		return segments, nil
+	}
+
+	for i := range segments {
+		data, err := DecompressData(segments[i].Data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decompress segment %s: %w", segments[i].Name, err)
+		}
+		segments[i].Data = data
+	}
+
+	return segments, nil
+}
+
+func (s *Slave) segmentSize(name string) int64 {
+	if name == "" {
+		return 0
+	}
+
+	info, err := os.Stat(path.Join(s.walDirectory, name))
 	if err != nil {
-		logger.ErrorWithMsg("unable to apply data to engine", err)
-		return
+		return 0
 	}
+
+	return info.Size()
 }
 
 func (s *Slave) saveSegment(name string, data []byte) error {
 	if name == "" {
 		return nil
 	}
+	if !filesystem.IsSegmentName(name) {
+		return fmt.Errorf("%w: %q", errInvalidSegmentName, name)
+	}
 	filename := path.Join(s.walDirectory, name)
 	segmentFile, err := s.fileLib.CreateFile(filename)
 	if err != nil {
@@ -150,22 +483,66 @@ func (s *Slave) saveSegment(name string, data []byte) error {
 	return nil
 }
 
-func (s *Slave) applyDataToEngine(segmentData []byte) error {
+// applyDataToEngine sends requests which were not applied yet to
+// replication stream, returns the last applied position
+func (s *Slave) applyDataToEngine(segmentData []byte) (uint64, error) {
+	appliedLSN := s.progress.AppliedLSN()
 	if len(segmentData) == 0 {
-		return nil
+		return appliedLSN, nil
+	}
+
+	requests, _, err := wal.DecodeRequests(segmentData)
+	if err != nil {
+		logger.ErrorWithMsg("segment was decoded partially", err)
 	}
 
-	var queries []wal.Request
-	buffer := bytes.NewBuffer(segmentData)
-	for buffer.Len() > 0 {
-		var request wal.Request
-		if err := request.Decode(buffer); err != nil {
-			return fmt.Errorf("unable to parse request data: %w", err)
+	queries := make([]wal.Request, 0, len(requests))
+	for _, request := range requests {
+		// requests without position were written before LSN was introduced
+		if request.LSN == 0 || request.LSN > appliedLSN {
+			queries = append(queries, request)
 		}
+	}
 
-		queries = append(queries, request)
+	if len(queries) == 0 {
+		return appliedLSN, nil
 	}
 
-	s.stream <- queries
-	return nil
+	batch := Batch{Requests: queries, applied: make(chan struct{})}
+	s.stream <- batch
+	<-batch.applied
+
+	return max(appliedLSN, wal.LastLSN(queries)), nil
+}
+
+// masterAddresses returns master address followed by candidates without duplicates
+func masterAddresses(cfg *config.ReplicationConfig) []string {
+	addresses := make([]string, 0, len(cfg.MasterAddresses)+1)
+	for _, address := range append([]string{cfg.MasterAddress}, cfg.MasterAddresses...) {
+		if address != "" && !slices.Contains(addresses, address) {
+			addresses = append(addresses, address)
+		}
+	}
+
+	return addresses
+}
+
+func localLSN(fileLib filesystem.FileLib, directory string) uint64 {
+	filenames, err := fileLib.FilenamesFromDir(directory)
+	if err != nil {
+		return 0
+	}
+
+	segments, err := fileLib.DataFromFiles(directory, filenames)
+	if err != nil {
+		return 0
+	}
+
+	var lsn uint64
+	for _, data := range segments {
+		requests, _, _ := wal.DecodeRequests(data)
+		lsn = max(lsn, wal.LastLSN(requests))
+	}
+
+	return lsn
 }
@@ -3,11 +3,17 @@ package replication
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"concurrency_go_course/internal/config"
+	"concurrency_go_course/internal/filesystem"
+	"concurrency_go_course/internal/storage/wal"
 	"concurrency_go_course/pkg/logger"
 )
 
@@ -60,7 +66,7 @@ func TestNewSlaveErr(t *testing.T) {
 			name:          "New replica client without address",
 			cfg:           cfgWithoutReplicaAddr,
 			walCfg:        walCfg,
-			expectedError: fmt.Errorf("connection create error: dial tcp: missing address"),
+			expectedError: fmt.Errorf("master address is empty"),
 		},
 	}
 
@@ -76,9 +82,6 @@ func TestNewSlaveErr(t *testing.T) {
 func TestNewSlaveOk(t *testing.T) {
 	logger.MockLogger()
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
 	masterAddr := "127.0.0.1:9995"
 
 	cfgMaster := &config.Config{
@@ -111,13 +114,13 @@ func TestNewSlaveOk(t *testing.T) {
 		},
 	}
 
-	server, err := NewReplicationServer(cfgMaster, walCfg)
+	server, err := NewReplicationServer(cfgMaster, walCfg, &wal.Position{})
 	if err != nil {
 		t.Errorf("expected nil error; got %+v", err)
 	}
 	assert.NotNil(t, server)
 
-	go server.Start(ctx)
+	runMaster(t, server)
 
 	cfgSlave := &config.Config{
 		Engine: &config.EngineConfig{
@@ -160,3 +163,109 @@ func TestNewSlaveOk(t *testing.T) {
 		})
 	}
 }
+
+func TestSlaveReconnect(t *testing.T) {
+	logger.MockLogger()
+
+	firstAddr, secondAddr := "127.0.0.1:9992", "127.0.0.1:9991"
+
+	walCfg := &config.WALCfg{
+		WalConfig: &config.WALSettings{
+			DataDirectory: "test_data",
+		},
+	}
+
+	newConfig := func(replicaType, masterAddr string, candidates ...string) *config.Config {
+		return &config.Config{
+			Network: &config.NetworkConfig{
+				Address:        "127.0.0.1:9990",
+				MaxConnections: 100,
+				MaxMessageSize: "4KB",
+				IdleTimeout:    "5m",
+			},
+			Replication: &config.ReplicationConfig{
+				ReplicaType:     replicaType,
+				MasterAddress:   masterAddr,
+				MasterAddresses: candidates,
+			},
+		}
+	}
+
+	startMaster := func(address string) func() {
+		master, err := NewReplicationServer(newConfig("master", address), walCfg, &wal.Position{})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			master.Start(ctx)
+		}()
+
+		return func() {
+			cancel()
+			<-done
+		}
+	}
+
+	slave, err := NewReplicationClient(newConfig("slave", secondAddr, firstAddr, secondAddr), walCfg)
+	require.NoError(t, err)
+	assert.Equal(t, []string{secondAddr, firstAddr}, slave.addresses)
+	defer slave.disconnect()
+
+	go func() {
+		for batch := range slave.ReplicationStream() {
+			batch.Ack()
+		}
+	}()
+
+	// second address is not available yet
+	stopFirst := startMaster(firstAddr)
+	require.NoError(t, slave.syncWithMaster())
+	assert.Equal(t, firstAddr, slave.addresses[slave.current])
+
+	// master fails over to second address
+	stopFirst()
+	assert.Error(t, slave.syncWithMaster())
+	assert.Nil(t, slave.connection)
+
+	stopSecond := startMaster(secondAddr)
+	defer stopSecond()
+
+	require.NoError(t, slave.syncWithMaster())
+	assert.Equal(t, secondAddr, slave.addresses[slave.current])
+	assert.NotNil(t, slave.session)
+}
+
+func TestSlaveSaveSegmentName(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	slave := &Slave{
+		walDirectory: filepath.Join(dir, "wal"),
+		fileLib:      filesystem.NewFileLib(),
+	}
+	require.NoError(t, os.Mkdir(slave.walDirectory, 0o700))
+
+	err := slave.saveSegment("../wal_1.log", []byte("data"))
+	require.ErrorIs(t, err, errInvalidSegmentName)
+	assert.NoFileExists(t, filepath.Join(dir, "wal_1.log"))
+
+	require.NoError(t, slave.saveSegment("wal_1.log", []byte("data")))
+	assert.FileExists(t, filepath.Join(slave.walDirectory, "wal_1.log"))
+}
+
+func TestSlaveWaitCanceled(t *testing.T) {
+	t.Parallel()
+
+	slave := &Slave{
+		progress:        NewProgress(0),
+		readWaitTimeout: time.Minute,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, slave.WaitLSN(ctx, 1), context.Canceled)
+	assert.ErrorIs(t, slave.WaitLag(ctx, time.Second), context.Canceled)
+}
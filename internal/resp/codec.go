@@ -0,0 +1,355 @@
+// Package resp implements Redis serialization protocol (RESP2 and RESP3)
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// RESP value types
+const (
+	TypeSimpleString = '+'
+	TypeError        = '-'
+	TypeInteger      = ':'
+	TypeBulkString   = '$'
+	TypeArray        = '*'
+	TypeNull         = '_'
+	TypeBoolean      = '#'
+	TypeDouble       = ','
+	TypeMap          = '%'
+	TypeSet          = '~'
+	TypePush         = '>'
+)
+
+const (
+	// maxLineSize limits simple strings, integers and inline commands
+	maxLineSize = 64 * 1024
+	// maxArrayLength limits number of array elements
+	maxArrayLength = 1024 * 1024
+	// maxDepth limits nesting of aggregates
+	maxDepth = 32
+)
+
+// ErrProtocol is returned for malformed input
+var ErrProtocol = errors.New("protocol error")
+
+// Value is a decoded RESP value
+type Value struct {
+	Type  byte
+	Str   string
+	Int   int64
+	Array []Value
+	// Null is set for RESP2 null bulk string and array and RESP3 null
+	Null bool
+}
+
+// Reader decodes RESP values
+type Reader struct {
+	reader  *bufio.Reader
+	maxSize int
+}
+
+// NewReader returns new reader, bulk strings larger than maxSize are
+// rejected, zero maxSize means no limit
+func NewReader(r io.Reader, maxSize int) *Reader {
+	return &Reader{
+		reader:  bufio.NewReader(r),
+		maxSize: maxSize,
+	}
+}
+
+// Buffered returns number of bytes which can be read without blocking
+func (r *Reader) Buffered() int {
+	return r.reader.Buffered()
+}
+
+// Peek waits until data of the next command is available without reading it
+func (r *Reader) Peek() error {
+	_, err := r.reader.Peek(1)
+	return err
+}
+
+// ReadCommand reads command as array of bulk strings or inline command
+func (r *Reader) ReadCommand() ([]string, error) {
+	first, err := r.reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] != TypeArray {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		return strings.Fields(line), nil
+	}
+
+	value, err := r.ReadValue()
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, 0, len(value.Array))
+	for _, item := range value.Array {
+		if item.Type != TypeBulkString || item.Null {
+			return nil, fmt.Errorf("%w: expected bulk string in command", ErrProtocol)
+		}
+		args = append(args, item.Str)
+	}
+
+	return args, nil
+}
+
+// ReadValue reads one value
+func (r *Reader) ReadValue() (Value, error) {
+	return r.readValue(0)
+}
+
+func (r *Reader) readValue(depth int) (Value, error) {
+	if depth > maxDepth {
+		return Value{}, fmt.Errorf("%w: too deep nesting", ErrProtocol)
+	}
+
+	line, err := r.readLine()
+	if err != nil {
+		return Value{}, err
+	}
+
+	if line == "" {
+		return Value{}, fmt.Errorf("%w: empty line", ErrProtocol)
+	}
+
+	value := Value{Type: line[0]}
+	payload := line[1:]
+
+	switch value.Type {
+	case TypeSimpleString, TypeError, TypeDouble:
+		value.Str = payload
+	case TypeInteger:
+		value.Int, err = strconv.ParseInt(payload, 10, 64)
+		if err != nil {
+			return Value{}, fmt.Errorf("%w: invalid integer", ErrProtocol)
+		}
+	case TypeBoolean:
+		if payload != "t" && payload != "f" {
+			return Value{}, fmt.Errorf("%w: invalid boolean", ErrProtocol)
+		}
+		value.Str = payload
+	case TypeNull:
+		value.Null = true
+	case TypeBulkString:
+		return r.readBulk(value, payload)
+	case TypeArray, TypeSet, TypePush, TypeMap:
+		return r.readAggregate(value, payload, depth)
+	default:
+		return Value{}, fmt.Errorf("%w: unknown type %q", ErrProtocol, value.Type)
+	}
+
+	return value, nil
+}
+
+func (r *Reader) readBulk(value Value, payload string) (Value, error) {
+	size, err := strconv.Atoi(payload)
+	if err != nil || size < -1 {
+		return Value{}, fmt.Errorf("%w: invalid bulk length", ErrProtocol)
+	}
+
+	if size == -1 {
+		value.Null = true
+		return value, nil
+	}
+
+	if r.maxSize > 0 && size > r.maxSize {
+		return Value{}, fmt.Errorf("%w: bulk length %d exceeds max message size %d",
+			ErrProtocol, size, r.maxSize)
+	}
+
+	data := make([]byte, size+2)
+	if _, err = io.ReadFull(r.reader, data); err != nil {
+		return Value{}, unexpectedEOF(err)
+	}
+
+	if data[size] != '\r' || data[size+1] != '\n' {
+		return Value{}, fmt.Errorf("%w: bulk string is not terminated", ErrProtocol)
+	}
+
+	value.Str = string(data[:size])
+	return value, nil
+}
+
+func (r *Reader) readAggregate(value Value, payload string, depth int) (Value, error) {
+	length, err := strconv.Atoi(payload)
+	if err != nil || length < -1 {
+		return Value{}, fmt.Errorf("%w: invalid aggregate length", ErrProtocol)
+	}
+
+	if length == -1 {
+		value.Null = true
+		return value, nil
+	}
+
+	if value.Type == TypeMap {
+		length *= 2
+	}
+
+	if length > maxArrayLength {
+		return Value{}, fmt.Errorf("%w: aggregate length %d is too large", ErrProtocol, length)
+	}
+
+	value.Array = make([]Value, 0, min(length, 1024))
+	for range length {
+		item, err := r.readValue(depth + 1)
+		if err != nil {
+			return Value{}, unexpectedEOF(err)
+		}
+		value.Array = append(value.Array, item)
+	}
+
+	return value, nil
+}
+
+func (r *Reader) readLine() (string, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := r.reader.ReadLine()
+		if err != nil {
+			if len(line) != 0 {
+				return "", unexpectedEOF(err)
+			}
+			return "", err
+		}
+
+		line = append(line, chunk...)
+		if len(line) > maxLineSize {
+			return "", fmt.Errorf("%w: line is too long", ErrProtocol)
+		}
+
+		if !isPrefix {
+			return strings.TrimSuffix(string(line), "\r"), nil
+		}
+	}
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Writer encodes RESP values, values are buffered until Flush
+type Writer struct {
+	writer  *bufio.Writer
+	version int
+}
+
+// NewWriter returns new RESP2 writer
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		writer:  bufio.NewWriter(w),
+		version: 2,
+	}
+}
+
+// SetVersion sets protocol version negotiated by HELLO
+func (w *Writer) SetVersion(version int) {
+	w.version = version
+}
+
+// Version returns protocol version
+func (w *Writer) Version() int {
+	return w.version
+}
+
+// WriteSimpleString writes simple string, it must not contain CR or LF
+func (w *Writer) WriteSimpleString(s string) {
+	w.writeLine(TypeSimpleString, sanitize(s))
+}
+
+// WriteError writes error, message must start with error code
+func (w *Writer) WriteError(message string) {
+	w.writeLine(TypeError, sanitize(message))
+}
+
+// WriteInteger writes integer
+func (w *Writer) WriteInteger(n int64) {
+	w.writeLine(TypeInteger, strconv.FormatInt(n, 10))
+}
+
+// WriteBulkString writes binary safe string
+func (w *Writer) WriteBulkString(s string) {
+	w.writeLine(TypeBulkString, strconv.Itoa(len(s)))
+	_, _ = w.writer.WriteString(s)
+	_, _ = w.writer.WriteString("\r\n")
+}
+
+// WriteNull writes null bulk string in RESP2 and null in RESP3
+func (w *Writer) WriteNull() {
+	if w.version >= 3 {
+		w.writeLine(TypeNull, "")
+		return
+	}
+	w.writeLine(TypeBulkString, "-1")
+}
+
+// WriteNullArray writes null array in RESP2 and null in RESP3
+func (w *Writer) WriteNullArray() {
+	if w.version >= 3 {
+		w.writeLine(TypeNull, "")
+		return
+	}
+	w.writeLine(TypeArray, "-1")
+}
+
+// WriteArrayHeader writes array header, n values must follow
+func (w *Writer) WriteArrayHeader(n int) {
+	w.writeLine(TypeArray, strconv.Itoa(n))
+}
+
+// WritePushHeader writes header of out-of-band push, n values must follow,
+// push is written as array in RESP2
+func (w *Writer) WritePushHeader(n int) {
+	if w.version >= 3 {
+		w.writeLine(TypePush, strconv.Itoa(n))
+		return
+	}
+	w.WriteArrayHeader(n)
+}
+
+// WriteMapHeader writes map header, n key-value pairs must follow,
+// map is written as flat array in RESP2
+func (w *Writer) WriteMapHeader(n int) {
+	if w.version >= 3 {
+		w.writeLine(TypeMap, strconv.Itoa(n))
+		return
+	}
+	w.WriteArrayHeader(2 * n)
+}
+
+// WriteCommand writes command as array of bulk strings
+func (w *Writer) WriteCommand(args ...string) {
+	w.WriteArrayHeader(len(args))
+	for _, arg := range args {
+		w.WriteBulkString(arg)
+	}
+}
+
+// Flush writes buffered data
+func (w *Writer) Flush() error {
+	return w.writer.Flush()
+}
+
+func (w *Writer) writeLine(valueType byte, payload string) {
+	_ = w.writer.WriteByte(valueType)
+	_, _ = w.writer.WriteString(payload)
+	_, _ = w.writer.WriteString("\r\n")
+}
+
+func sanitize(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
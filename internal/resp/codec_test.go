@@ -0,0 +1,182 @@
+package resp
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		version  int
+		write    func(w *Writer)
+		expected string
+	}{
+		{
+			name:     "simple string",
+			write:    func(w *Writer) { w.WriteSimpleString("OK") },
+			expected: "+OK\r\n",
+		},
+		{
+			name:     "error without line breaks",
+			write:    func(w *Writer) { w.WriteError("ERR bad\r\nvalue") },
+			expected: "-ERR bad  value\r\n",
+		},
+		{
+			name:     "bulk string",
+			write:    func(w *Writer) { w.WriteBulkString("a b\r\nc") },
+			expected: "$6\r\na b\r\nc\r\n",
+		},
+		{
+			name:     "RESP2 null",
+			write:    func(w *Writer) { w.WriteNull() },
+			expected: "$-1\r\n",
+		},
+		{
+			name:     "RESP3 null",
+			version:  3,
+			write:    func(w *Writer) { w.WriteNull() },
+			expected: "_\r\n",
+		},
+		{
+			name:     "RESP2 null array",
+			write:    func(w *Writer) { w.WriteNullArray() },
+			expected: "*-1\r\n",
+		},
+		{
+			name:     "RESP3 null array",
+			version:  3,
+			write:    func(w *Writer) { w.WriteNullArray() },
+			expected: "_\r\n",
+		},
+		{
+			name:     "RESP2 map",
+			write:    func(w *Writer) { w.WriteMapHeader(1); w.WriteBulkString("k"); w.WriteInteger(1) },
+			expected: "*2\r\n$1\r\nk\r\n:1\r\n",
+		},
+		{
+			name:     "RESP3 map",
+			version:  3,
+			write:    func(w *Writer) { w.WriteMapHeader(1); w.WriteBulkString("k"); w.WriteInteger(1) },
+			expected: "%1\r\n$1\r\nk\r\n:1\r\n",
+		},
+		{
+			name:     "command",
+			write:    func(w *Writer) { w.WriteCommand("SET", "key", "") },
+			expected: "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$0\r\n\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			w := NewWriter(&buffer)
+			if tt.version != 0 {
+				w.SetVersion(tt.version)
+			}
+
+			tt.write(w)
+			require.NoError(t, w.Flush())
+			assert.Equal(t, tt.expected, buffer.String())
+		})
+	}
+}
+
+func TestReadValue(t *testing.T) {
+	t.Parallel()
+
+	input := "+OK\r\n-ERR fail\r\n:42\r\n$5\r\nhello\r\n$-1\r\n_\r\n#t\r\n" +
+		"*2\r\n$1\r\na\r\n:1\r\n%1\r\n+k\r\n+v\r\n"
+	r := NewReader(strings.NewReader(input), 0)
+
+	expected := []Value{
+		{Type: TypeSimpleString, Str: "OK"},
+		{Type: TypeError, Str: "ERR fail"},
+		{Type: TypeInteger, Int: 42},
+		{Type: TypeBulkString, Str: "hello"},
+		{Type: TypeBulkString, Null: true},
+		{Type: TypeNull, Null: true},
+		{Type: TypeBoolean, Str: "t"},
+		{Type: TypeArray, Array: []Value{
+			{Type: TypeBulkString, Str: "a"},
+			{Type: TypeInteger, Int: 1},
+		}},
+		{Type: TypeMap, Array: []Value{
+			{Type: TypeSimpleString, Str: "k"},
+			{Type: TypeSimpleString, Str: "v"},
+		}},
+	}
+
+	for _, value := range expected {
+		result, err := r.ReadValue()
+		require.NoError(t, err)
+		assert.Equal(t, value, result)
+	}
+
+	_, err := r.ReadValue()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestReadCommand(t *testing.T) {
+	t.Parallel()
+
+	var buffer bytes.Buffer
+	w := NewWriter(&buffer)
+	w.WriteCommand("SET", "key", "value with spaces")
+	require.NoError(t, w.Flush())
+	buffer.WriteString("GET  key\r\nPING\n")
+
+	r := NewReader(&buffer, 1024)
+
+	args, err := r.ReadCommand()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SET", "key", "value with spaces"}, args)
+
+	args, err = r.ReadCommand()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GET", "key"}, args)
+
+	args, err = r.ReadCommand()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"PING"}, args)
+
+	_, err = r.ReadCommand()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestReadErr(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		input    string
+		maxSize  int
+		expected error
+	}{
+		{name: "unknown type", input: "?1\r\n", expected: ErrProtocol},
+		{name: "invalid integer", input: ":a\r\n", expected: ErrProtocol},
+		{name: "invalid bulk length", input: "$-2\r\n", expected: ErrProtocol},
+		{name: "too large bulk", input: "$5\r\nhello\r\n", maxSize: 4, expected: ErrProtocol},
+		{name: "not terminated bulk", input: "$2\r\nhello\r\n", expected: ErrProtocol},
+		{name: "truncated bulk", input: "$5\r\nhel", expected: io.ErrUnexpectedEOF},
+		{name: "truncated array", input: "*2\r\n$1\r\na\r\n", expected: io.ErrUnexpectedEOF},
+		{name: "too deep nesting", input: strings.Repeat("*1\r\n", maxDepth+2), expected: ErrProtocol},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewReader(strings.NewReader(tt.input), tt.maxSize).ReadValue()
+			assert.ErrorIs(t, err, tt.expected)
+		})
+	}
+
+	_, err := NewReader(strings.NewReader("*1\r\n:1\r\n"), 0).ReadCommand()
+	assert.ErrorIs(t, err, ErrProtocol)
+}
@@ -0,0 +1,504 @@
+package resp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"concurrency_go_course/internal/auth"
+	"concurrency_go_course/internal/compute"
+	"concurrency_go_course/internal/config"
+	"concurrency_go_course/internal/database"
+	"concurrency_go_course/internal/network"
+	"concurrency_go_course/internal/pubsub"
+	"concurrency_go_course/internal/slowlog"
+	"concurrency_go_course/pkg/logger"
+	"concurrency_go_course/pkg/parser"
+)
+
+// Protocol is a value of network protocol option for RESP listener
+const Protocol = "resp"
+
+const (
+	serverName    = "concurrency_go_course"
+	serverVersion = "1.0.0"
+
+	// defaultUser is a user of AUTH with password only
+	defaultUser = "default"
+)
+
+// Handler serves RESP connections
+type Handler struct {
+	db             database.Database
+	broker         *pubsub.Broker
+	users          *auth.Users
+	maxMessageSize int
+	idleTimeout    time.Duration
+	clients        atomic.Int64
+}
+
+// Option is an option of RESP handler
+type Option func(*Handler)
+
+// WithUsers enables AUTH, unauthenticated connections may only run AUTH,
+// HELLO with AUTH option, PING and QUIT unless users allow anonymous
+// clients. Commands and subscriptions are checked with roles of user
+func WithUsers(users *auth.Users) Option {
+	return func(h *Handler) {
+		h.users = users
+	}
+}
+
+// NewHandler returns new RESP handler, network config limits message
+// size and idle time of connections, pub/sub commands are enabled if
+// broker is set
+func NewHandler(cfg *config.NetworkConfig, db database.Database, broker *pubsub.Broker,
+	options ...Option,
+) (*Handler, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("network config is empty")
+	}
+
+	if db == nil {
+		return nil, fmt.Errorf("database is empty")
+	}
+
+	maxMessageSize, err := parser.ParseSize(cfg.MaxMessageSize)
+	if err != nil {
+		return nil, fmt.Errorf("unable to set max message size: %w", err)
+	}
+
+	idleTimeout, err := time.ParseDuration(cfg.IdleTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("unable to set idle timeout: %w", err)
+	}
+
+	h := &Handler{
+		db:             db,
+		broker:         broker,
+		maxMessageSize: maxMessageSize,
+		idleTimeout:    idleTimeout,
+	}
+	for _, option := range options {
+		option(h)
+	}
+
+	return h, nil
+}
+
+// session is a state of one client connection
+type session struct {
+	id     int64
+	reader *Reader
+	writer *Writer
+	name   string
+	// user is a name of authenticated user, it is empty until AUTH
+	user string
+}
+
+// ServeConn handles commands until client disconnects, replies to pipelined
+// commands are flushed together
+func (h *Handler) ServeConn(ctx context.Context, conn net.Conn) {
+	ctx = slowlog.ContextWithClient(ctx, conn.RemoteAddr().String())
+
+	s := &session{
+		id:     h.clients.Add(1),
+		reader: NewReader(conn, h.maxMessageSize),
+		writer: NewWriter(conn),
+	}
+
+	for ctx.Err() == nil {
+		if h.idleTimeout != 0 {
+			if err := conn.SetDeadline(time.Now().Add(h.idleTimeout)); err != nil {
+				logger.ErrorWithMsg("unable to set deadline:", err)
+				return
+			}
+		}
+
+		args, err := s.reader.ReadCommand()
+		if err != nil {
+			if errors.Is(err, ErrProtocol) {
+				s.writer.WriteError("ERR " + err.Error())
+				_ = s.writer.Flush()
+			}
+
+			if !errors.Is(err, io.EOF) {
+				logger.ErrorWithMsg("unable to read RESP command:", err)
+			}
+			return
+		}
+
+		if len(args) == 0 {
+			continue
+		}
+
+		if isSubscribe(args[0]) && h.broker != nil && h.authenticated(s) {
+			h.serveSubscribed(ctx, conn, s, args)
+			return
+		}
+
+		blocking := strings.EqualFold(args[0], compute.CommandBLPop)
+		if blocking {
+			// replies to pipelined commands are not delayed by blocking command
+			if err = s.writer.Flush(); err != nil {
+				logger.ErrorWithMsg("unable to write RESP reply:", err)
+				return
+			}
+		}
+
+		// blocked command is released if client disconnects
+		commandCtx, stopWatch := network.WatchClose(ctx, conn, s.reader.Peek)
+		quit := h.execute(commandCtx, s, args)
+		stopWatch()
+
+		if blocking && h.idleTimeout != 0 {
+			// deadline could expire while command was blocked
+			if err = conn.SetWriteDeadline(time.Now().Add(h.idleTimeout)); err != nil {
+				logger.ErrorWithMsg("unable to set deadline:", err)
+				return
+			}
+		}
+
+		if s.reader.Buffered() == 0 || quit {
+			if err = s.writer.Flush(); err != nil {
+				logger.ErrorWithMsg("unable to write RESP reply:", err)
+				return
+			}
+		}
+
+		if quit {
+			return
+		}
+	}
+}
+
+// execute writes reply for command, returns true if connection must be closed
+func (h *Handler) execute(ctx context.Context, s *session, args []string) bool {
+	command := strings.ToUpper(args[0])
+
+	if !h.authenticated(s) {
+		switch command {
+		case compute.CommandAuth, "HELLO", "PING", "QUIT":
+		default:
+			s.writer.WriteError("NOAUTH Authentication required.")
+			return false
+		}
+	}
+
+	switch command {
+	case "PING":
+		if len(args) > 1 {
+			s.writer.WriteBulkString(args[1])
+		} else {
+			s.writer.WriteSimpleString("PONG")
+		}
+	case "ECHO":
+		if len(args) != 2 {
+			writeArgsError(s.writer, command)
+			break
+		}
+		s.writer.WriteBulkString(args[1])
+	case compute.CommandAuth:
+		switch len(args) {
+		case 2:
+			h.auth(s, defaultUser, args[1])
+		case 3:
+			h.auth(s, args[1], args[2])
+		default:
+			writeArgsError(s.writer, command)
+		}
+	case "HELLO":
+		h.hello(s, args[1:])
+	case "QUIT":
+		s.writer.WriteSimpleString("OK")
+		return true
+	case "SELECT":
+		if len(args) != 2 {
+			writeArgsError(s.writer, command)
+		} else if args[1] != "0" {
+			s.writer.WriteError("ERR DB index is out of range")
+		} else {
+			s.writer.WriteSimpleString("OK")
+		}
+	case "COMMAND":
+		// command docs are not provided, clients fall back to defaults
+		s.writer.WriteArrayHeader(0)
+	case "CLIENT":
+		h.client(s, args[1:])
+	case compute.CommandSubscribe, compute.CommandPSubscribe, compute.CommandUnsubscribe, compute.CommandPUnsubscribe:
+		if h.broker == nil {
+			s.writer.WriteError("ERR pub/sub is not enabled")
+			break
+		}
+
+		// connection without subscriptions has nothing to unsubscribe
+		s.writer.WritePushHeader(3)
+		s.writer.WriteBulkString(strings.ToLower(command))
+		s.writer.WriteNull()
+		s.writer.WriteInteger(0)
+	default:
+		h.query(ctx, s, command, args[1:])
+	}
+
+	return false
+}
+
+func (h *Handler) query(ctx context.Context, s *session, command string, args []string) {
+	query, err := compute.ParseFields(append([]string{command}, args...))
+	if err != nil {
+		s.writer.WriteError("ERR " + err.Error())
+		return
+	}
+
+	if s.user != "" {
+		ctx = auth.ContextWithUser(ctx, s.user)
+	}
+
+	result, err := h.db.HandleQuery(ctx, query)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			if query.Command == compute.CommandBLPop {
+				s.writer.WriteNullArray()
+				return
+			}
+
+			s.writer.WriteNull()
+			return
+		}
+
+		if errors.Is(err, database.ErrPermissionDenied) {
+			s.writer.WriteError("NOPERM " + err.Error())
+			return
+		}
+
+		// message of wrong type error starts with error code
+		if errors.Is(err, database.ErrWrongType) {
+			s.writer.WriteError(err.Error())
+			return
+		}
+
+		logger.Error("unable to handle RESP query", zap.Error(err))
+		s.writer.WriteError("ERR " + err.Error())
+		return
+	}
+
+	switch query.Command {
+	case compute.CommandGet, compute.CommandInfo, compute.CommandHGet, compute.CommandLPop, compute.CommandRPop,
+		compute.CommandZScore:
+		s.writer.WriteBulkString(result)
+		return
+	case compute.CommandPublish, compute.CommandHSet, compute.CommandHDel,
+		compute.CommandLPush, compute.CommandRPush, compute.CommandLLen,
+		compute.CommandZAdd, compute.CommandZRem, compute.CommandZRemRangeByScore, compute.CommandZCard,
+		compute.CommandExpire, compute.CommandTTL:
+		n, _ := strconv.ParseInt(result, 10, 64)
+		s.writer.WriteInteger(n)
+		return
+	case compute.CommandHGetAll:
+		writeHash(s.writer, result)
+		return
+	case compute.CommandLRange, compute.CommandBLPop, compute.CommandZRange, compute.CommandZRangeByScore:
+		writeList(s.writer, result)
+		return
+	}
+
+	if query.Command == compute.CommandSlowLog {
+		writeSlowLog(s.writer, query.Args[0], result)
+		return
+	}
+
+	s.writer.WriteSimpleString(result)
+}
+
+// writeSlowLog writes entries of SLOWLOG GET as array of bulk strings and
+// length of SLOWLOG LEN as integer
+func writeSlowLog(writer *Writer, subcommand, result string) {
+	switch subcommand {
+	case compute.SlowLogGet:
+		var entries []string
+		if result != "" {
+			entries = strings.Split(result, "\n")
+		}
+
+		writer.WriteArrayHeader(len(entries))
+		for _, entry := range entries {
+			writer.WriteBulkString(entry)
+		}
+	case compute.SlowLogLen:
+		n, _ := strconv.ParseInt(result, 10, 64)
+		writer.WriteInteger(n)
+	default:
+		writer.WriteSimpleString(result)
+	}
+}
+
+// writeHash writes fields and values of HGETALL as map, result contains
+// field and value on separate lines
+func writeHash(writer *Writer, result string) {
+	var pairs []string
+	if result != "" {
+		pairs = strings.Split(result, "\n")
+	}
+
+	writer.WriteMapHeader(len(pairs) / 2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		writer.WriteBulkString(pairs[i])
+		writer.WriteBulkString(pairs[i+1])
+	}
+}
+
+// writeList writes values of LRANGE, members of ZRANGE and ZRANGEBYSCORE
+// and key and value of BLPOP as array, result contains values on separate
+// lines
+func writeList(writer *Writer, result string) {
+	var values []string
+	if result != "" {
+		values = strings.Split(result, "\n")
+	}
+
+	writer.WriteArrayHeader(len(values))
+	for _, value := range values {
+		writer.WriteBulkString(value)
+	}
+}
+
+// authenticated returns true if connection may run commands, anonymous
+// connections may run them if users allow it
+func (h *Handler) authenticated(s *session) bool {
+	return h.users == nil || s.user != "" || h.users.AllowsAnonymous()
+}
+
+// auth authenticates connection as user and writes reply
+func (h *Handler) auth(s *session, name, password string) {
+	if h.authenticate(s, name, password) {
+		s.writer.WriteSimpleString("OK")
+	}
+}
+
+// authenticate checks password of user, error is written if it fails
+func (h *Handler) authenticate(s *session, name, password string) bool {
+	if h.users == nil {
+		s.writer.WriteError("ERR authentication is disabled")
+		return false
+	}
+
+	if err := h.users.Authenticate(name, password); err != nil {
+		logger.Warn("authentication failed", zap.String("user", name), zap.Int64("client", s.id))
+		s.writer.WriteError("WRONGPASS invalid username-password pair or user is disabled.")
+		return false
+	}
+
+	s.user = name
+	logger.Info("authenticated", zap.String("user", name), zap.Int64("client", s.id))
+
+	return true
+}
+
+// hello switches protocol version, HELLO [protover [AUTH username password]
+// [SETNAME name]]
+func (h *Handler) hello(s *session, args []string) {
+	version := s.writer.Version()
+	if len(args) > 0 {
+		requested, err := strconv.Atoi(args[0])
+		if err != nil {
+			s.writer.WriteError("ERR Protocol version is not an integer or out of range")
+			return
+		}
+
+		if requested != 2 && requested != 3 {
+			s.writer.WriteError("NOPROTO unsupported protocol version")
+			return
+		}
+		version = requested
+		args = args[1:]
+	}
+
+	for len(args) > 0 {
+		switch strings.ToUpper(args[0]) {
+		case compute.CommandAuth:
+			if len(args) < 3 {
+				writeArgsError(s.writer, "HELLO")
+				return
+			}
+			if !h.authenticate(s, args[1], args[2]) {
+				return
+			}
+			args = args[3:]
+		case "SETNAME":
+			if len(args) < 2 {
+				writeArgsError(s.writer, "HELLO")
+				return
+			}
+			s.name = args[1]
+			args = args[2:]
+		default:
+			s.writer.WriteError("ERR syntax error in HELLO option " + args[0])
+			return
+		}
+	}
+
+	if !h.authenticated(s) {
+		s.writer.WriteError("NOAUTH HELLO must be called with the client already authenticated, " +
+			"otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client " +
+			"and select the RESP protocol version at the same time")
+		return
+	}
+
+	s.writer.SetVersion(version)
+
+	s.writer.WriteMapHeader(6)
+	s.writer.WriteBulkString("server")
+	s.writer.WriteBulkString(serverName)
+	s.writer.WriteBulkString("version")
+	s.writer.WriteBulkString(serverVersion)
+	s.writer.WriteBulkString("proto")
+	s.writer.WriteInteger(int64(version))
+	s.writer.WriteBulkString("id")
+	s.writer.WriteInteger(s.id)
+	s.writer.WriteBulkString("mode")
+	s.writer.WriteBulkString("standalone")
+	s.writer.WriteBulkString("modules")
+	s.writer.WriteArrayHeader(0)
+}
+
+// client handles CLIENT subcommands used by client libraries on connect
+func (h *Handler) client(s *session, args []string) {
+	if len(args) == 0 {
+		writeArgsError(s.writer, "CLIENT")
+		return
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "SETNAME":
+		if len(args) != 2 {
+			writeArgsError(s.writer, "CLIENT")
+			return
+		}
+		s.name = args[1]
+		s.writer.WriteSimpleString("OK")
+	case "GETNAME":
+		if s.name == "" {
+			s.writer.WriteNull()
+			return
+		}
+		s.writer.WriteBulkString(s.name)
+	case "ID":
+		s.writer.WriteInteger(s.id)
+	case "SETINFO":
+		s.writer.WriteSimpleString("OK")
+	default:
+		s.writer.WriteError("ERR unknown subcommand " + args[0])
+	}
+}
+
+func writeArgsError(w *Writer, command string) {
+	w.WriteError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(command)))
+}
@@ -0,0 +1,568 @@
+package resp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"concurrency_go_course/internal/auth"
+	"concurrency_go_course/internal/compute"
+	"concurrency_go_course/internal/config"
+	"concurrency_go_course/internal/database"
+	"concurrency_go_course/internal/info"
+	"concurrency_go_course/internal/pubsub"
+	"concurrency_go_course/internal/slowlog"
+	"concurrency_go_course/internal/storage"
+	"concurrency_go_course/pkg/logger"
+)
+
+type testClient struct {
+	conn   net.Conn
+	reader *Reader
+	writer *Writer
+}
+
+func newTestClient(t *testing.T, options ...database.Option) *testClient {
+	t.Helper()
+
+	return newTestHandler(t, nil, options...).connect(t)
+}
+
+type testHandler struct {
+	handler *Handler
+}
+
+func newTestHandler(t *testing.T, broker *pubsub.Broker, options ...database.Option) *testHandler {
+	t.Helper()
+
+	logger.MockLogger()
+
+	var engine storage.Engine = storage.NewEngine(4)
+	if broker != nil {
+		engine = pubsub.NewKeyspaceEngine(engine, broker)
+	}
+	store, err := storage.New(engine, nil, "master", nil)
+	require.NoError(t, err)
+
+	nodeInfo := info.New()
+	nodeInfo.Add(info.SectionServer, func() []info.Field {
+		return []info.Field{info.String("version", "test")}
+	})
+
+	options = append(options, database.WithInfo(nodeInfo))
+	if broker != nil {
+		options = append(options, database.WithPublisher(broker))
+	}
+	db := database.NewDatabase(store, compute.NewCompute(compute.NewRequestParser()), options...)
+	handler, err := NewHandler(&config.NetworkConfig{
+		MaxMessageSize: "1KB",
+		IdleTimeout:    "5m",
+	}, db, broker)
+	require.NoError(t, err)
+
+	return &testHandler{handler: handler}
+}
+
+func (h *testHandler) connect(t *testing.T) *testClient {
+	t.Helper()
+
+	server, client := net.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer server.Close()
+		h.handler.ServeConn(ctx, server)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		client.Close()
+		<-done
+	})
+
+	return &testClient{
+		conn:   client,
+		reader: NewReader(client, 0),
+		writer: NewWriter(client),
+	}
+}
+
+func (c *testClient) do(t *testing.T, args ...string) Value {
+	t.Helper()
+
+	c.writer.WriteCommand(args...)
+	require.NoError(t, c.writer.Flush())
+
+	value, err := c.reader.ReadValue()
+	require.NoError(t, err)
+	return value
+}
+
+func TestHandlerCommands(t *testing.T) {
+	t.Parallel()
+
+	c := newTestClient(t)
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected Value
+	}{
+		{
+			name:     "ping",
+			args:     []string{"PING"},
+			expected: Value{Type: TypeSimpleString, Str: "PONG"},
+		},
+		{
+			name:     "ping with message",
+			args:     []string{"ping", "hello"},
+			expected: Value{Type: TypeBulkString, Str: "hello"},
+		},
+		{
+			name:     "get missing key",
+			args:     []string{"GET", "key"},
+			expected: Value{Type: TypeBulkString, Null: true},
+		},
+		{
+			name:     "set",
+			args:     []string{"set", "key", "value"},
+			expected: Value{Type: TypeSimpleString, Str: "OK"},
+		},
+		{
+			name:     "get",
+			args:     []string{"get", "key"},
+			expected: Value{Type: TypeBulkString, Str: "value"},
+		},
+		{
+			name:     "del",
+			args:     []string{"DEL", "key"},
+			expected: Value{Type: TypeSimpleString, Str: "OK"},
+		},
+		{
+			name:     "wrong arguments",
+			args:     []string{"SET", "key"},
+			expected: Value{Type: TypeError, Str: "ERR for command SET expected 2 arguments, got 1"},
+		},
+		{
+			name:     "unknown command",
+			args:     []string{"INCR", "key"},
+			expected: Value{Type: TypeError, Str: "ERR invalid command INCR"},
+		},
+		{
+			name:     "info",
+			args:     []string{"info", "server"},
+			expected: Value{Type: TypeBulkString, Str: "# server\nversion:test"},
+		},
+		{
+			name:     "select",
+			args:     []string{"SELECT", "1"},
+			expected: Value{Type: TypeError, Str: "ERR DB index is out of range"},
+		},
+		{
+			name:     "client name",
+			args:     []string{"CLIENT", "SETNAME", "test"},
+			expected: Value{Type: TypeSimpleString, Str: "OK"},
+		},
+		{
+			name:     "command docs",
+			args:     []string{"COMMAND", "DOCS"},
+			expected: Value{Type: TypeArray, Array: []Value{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, c.do(t, tt.args...))
+		})
+	}
+}
+
+func TestHandlerHash(t *testing.T) {
+	t.Parallel()
+
+	c := newTestClient(t)
+
+	assert.Equal(t, Value{Type: TypeInteger, Int: 2}, c.do(t, "HSET", "user", "name", "alice smith", "age", "30"))
+	assert.Equal(t, Value{Type: TypeBulkString, Str: "alice smith"}, c.do(t, "HGET", "user", "name"))
+	assert.Equal(t, Value{Type: TypeBulkString, Null: true}, c.do(t, "HGET", "user", "email"))
+	assert.Equal(t, Value{Type: TypeArray, Array: []Value{
+		{Type: TypeBulkString, Str: "age"},
+		{Type: TypeBulkString, Str: "30"},
+		{Type: TypeBulkString, Str: "name"},
+		{Type: TypeBulkString, Str: "alice smith"},
+	}}, c.do(t, "HGETALL", "user"))
+	assert.Equal(t, Value{Type: TypeError, Str: "WRONGTYPE Operation against a key holding the wrong kind of value"},
+		c.do(t, "GET", "user"))
+	assert.Equal(t, Value{Type: TypeInteger, Int: 1}, c.do(t, "HDEL", "user", "age", "email"))
+
+	// hash is written as map in RESP3
+	reply := c.do(t, "HELLO", "3")
+	require.Equal(t, byte(TypeMap), reply.Type)
+	assert.Equal(t, Value{Type: TypeMap, Array: []Value{
+		{Type: TypeBulkString, Str: "name"},
+		{Type: TypeBulkString, Str: "alice smith"},
+	}}, c.do(t, "HGETALL", "user"))
+	assert.Equal(t, Value{Type: TypeMap, Array: []Value{}}, c.do(t, "HGETALL", "missing"))
+}
+
+func TestHandlerList(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t, nil)
+	c := h.connect(t)
+
+	bulk := func(s string) Value { return Value{Type: TypeBulkString, Str: s} }
+
+	assert.Equal(t, Value{Type: TypeInteger, Int: 2}, c.do(t, "LPUSH", "jobs", "b", "a"))
+	assert.Equal(t, Value{Type: TypeInteger, Int: 3}, c.do(t, "RPUSH", "jobs", "c d"))
+	assert.Equal(t, Value{Type: TypeArray, Array: []Value{bulk("a"), bulk("b"), bulk("c d")}},
+		c.do(t, "LRANGE", "jobs", "0", "-1"))
+	assert.Equal(t, Value{Type: TypeArray, Array: []Value{}}, c.do(t, "LRANGE", "missing", "0", "-1"))
+	assert.Equal(t, Value{Type: TypeInteger, Int: 3}, c.do(t, "LLEN", "jobs"))
+	assert.Equal(t, bulk("c d"), c.do(t, "RPOP", "jobs"))
+	assert.Equal(t, bulk("a"), c.do(t, "LPOP", "jobs"))
+	assert.Equal(t, Value{Type: TypeArray, Array: []Value{bulk("jobs"), bulk("b")}}, c.do(t, "BLPOP", "jobs", "0"))
+	assert.Equal(t, Value{Type: TypeBulkString, Null: true}, c.do(t, "LPOP", "jobs"))
+	assert.Equal(t, Value{Type: TypeArray, Null: true}, c.do(t, "BLPOP", "jobs", "0.01"))
+
+	t.Run("blocked client is served by push of another client", func(t *testing.T) {
+		blocked := h.connect(t)
+		blocked.writer.WriteCommand("BLPOP", "queue", "0")
+		require.NoError(t, blocked.writer.Flush())
+
+		// push is retried until client is blocked, value pushed before is popped by LPOP
+		require.Eventually(t, func() bool {
+			c.do(t, "RPUSH", "queue", "job")
+			return c.do(t, "LPOP", "queue").Null
+		}, time.Second, 10*time.Millisecond)
+
+		reply, err := blocked.reader.ReadValue()
+		require.NoError(t, err)
+		assert.Equal(t, Value{Type: TypeArray, Array: []Value{bulk("queue"), bulk("job")}}, reply)
+	})
+
+	t.Run("disconnected client is released", func(t *testing.T) {
+		blocked := h.connect(t)
+		blocked.writer.WriteCommand("BLPOP", "tasks", "0")
+		require.NoError(t, blocked.writer.Flush())
+		require.NoError(t, blocked.conn.Close())
+
+		// value is not given to client which has disconnected
+		time.Sleep(200 * time.Millisecond)
+		assert.Equal(t, Value{Type: TypeInteger, Int: 1}, c.do(t, "RPUSH", "tasks", "task"))
+		assert.Equal(t, Value{Type: TypeInteger, Int: 1}, c.do(t, "LLEN", "tasks"))
+	})
+}
+
+func TestHandlerZSet(t *testing.T) {
+	t.Parallel()
+
+	c := newTestClient(t)
+
+	bulk := func(s string) Value { return Value{Type: TypeBulkString, Str: s} }
+
+	assert.Equal(t, Value{Type: TypeInteger, Int: 3}, c.do(t, "ZADD", "board", "30", "alice", "10", "bob", "20", "carol"))
+	assert.Equal(t, bulk("30"), c.do(t, "ZSCORE", "board", "alice"))
+	assert.Equal(t, Value{Type: TypeBulkString, Null: true}, c.do(t, "ZSCORE", "board", "dave"))
+	assert.Equal(t, Value{Type: TypeArray, Array: []Value{bulk("carol"), bulk("20"), bulk("alice"), bulk("30")}},
+		c.do(t, "ZRANGE", "board", "-2", "-1", "WITHSCORES"))
+	assert.Equal(t, Value{Type: TypeArray, Array: []Value{bulk("bob"), bulk("carol")}},
+		c.do(t, "ZRANGEBYSCORE", "board", "-inf", "(30"))
+	assert.Equal(t, Value{Type: TypeInteger, Int: 2}, c.do(t, "ZREMRANGEBYSCORE", "board", "10", "20"))
+	assert.Equal(t, Value{Type: TypeInteger, Int: 1}, c.do(t, "ZREM", "board", "alice"))
+	assert.Equal(t, Value{Type: TypeInteger, Int: 0}, c.do(t, "ZCARD", "board"))
+	assert.Equal(t, Value{Type: TypeError, Str: "ERR invalid ZADD score high"}, c.do(t, "ZADD", "board", "high", "dave"))
+}
+
+func TestHandlerSlowLog(t *testing.T) {
+	t.Parallel()
+
+	c := newTestClient(t, database.WithSlowLog(slowlog.New(&config.SlowLogConfig{Threshold: time.Nanosecond})))
+
+	assert.Equal(t, Value{Type: TypeSimpleString, Str: "OK"}, c.do(t, "SET", "key", "value"))
+
+	entries := c.do(t, "SLOWLOG", "get", "1")
+	require.EqualValues(t, TypeArray, entries.Type)
+	require.Len(t, entries.Array, 1)
+	assert.Contains(t, entries.Array[0].Str, "client=pipe command=SET key=key")
+
+	assert.Equal(t, Value{Type: TypeInteger, Int: 2}, c.do(t, "SLOWLOG", "LEN"))
+	assert.Equal(t, Value{Type: TypeSimpleString, Str: "OK"}, c.do(t, "SLOWLOG", "RESET"))
+	// RESET is recorded after it is executed
+	assert.Equal(t, Value{Type: TypeInteger, Int: 1}, c.do(t, "SLOWLOG", "LEN"))
+}
+
+func TestHandlerSubscribe(t *testing.T) {
+	t.Parallel()
+
+	h := newTestHandler(t, pubsub.NewBroker())
+	subscriber := h.connect(t)
+	publisher := h.connect(t)
+
+	bulk := func(s string) Value { return Value{Type: TypeBulkString, Str: s} }
+	integer := func(n int64) Value { return Value{Type: TypeInteger, Int: n} }
+	push := func(values ...Value) Value { return Value{Type: TypeArray, Array: values} }
+
+	assert.Equal(t, push(bulk("subscribe"), bulk("__keyevent__:del"), integer(1)),
+		subscriber.do(t, "SUBSCRIBE", "__keyevent__:del"))
+	assert.Equal(t, push(bulk("psubscribe"), bulk("__keyspace__:user:*"), integer(2)),
+		subscriber.do(t, "PSUBSCRIBE", "__keyspace__:user:*"))
+
+	assert.Equal(t, "OK", publisher.do(t, "SET", "user:1", "value").Str)
+	assert.Equal(t, "OK", publisher.do(t, "SET", "other", "value").Str)
+	assert.Equal(t, "OK", publisher.do(t, "DEL", "user:1").Str)
+	// keyspace notifications can not be faked by clients
+	assert.Equal(t, byte(TypeError), publisher.do(t, "PUBLISH", "__keyevent__:del", "manual").Type)
+	assert.Equal(t, integer(0), publisher.do(t, "PUBLISH", "news", "hello"))
+	assert.Equal(t, integer(1), publisher.do(t, "EXPIRE", "other", "60"))
+	assert.Equal(t, integer(60), publisher.do(t, "TTL", "other"))
+	assert.Equal(t, integer(-2), publisher.do(t, "TTL", "user:1"))
+
+	expected := []Value{
+		push(bulk("pmessage"), bulk("__keyspace__:user:*"), bulk("__keyspace__:user:1"), bulk("set")),
+		push(bulk("pmessage"), bulk("__keyspace__:user:*"), bulk("__keyspace__:user:1"), bulk("del")),
+		push(bulk("message"), bulk("__keyevent__:del"), bulk("user:1")),
+	}
+	for _, message := range expected {
+		value, err := subscriber.reader.ReadValue()
+		require.NoError(t, err)
+		assert.Equal(t, message, value)
+	}
+
+	assert.Equal(t, push(bulk("pong"), bulk("")), subscriber.do(t, "PING"))
+	assert.Equal(t, byte(TypeError), subscriber.do(t, "GET", "other").Type)
+
+	assert.Equal(t, push(bulk("unsubscribe"), bulk("__keyevent__:del"), integer(1)),
+		subscriber.do(t, "UNSUBSCRIBE"))
+	assert.Equal(t, push(bulk("punsubscribe"), bulk("__keyspace__:user:*"), integer(0)),
+		subscriber.do(t, "PUNSUBSCRIBE"))
+
+	// commands are allowed after all subscriptions are removed
+	assert.Equal(t, bulk("value"), subscriber.do(t, "GET", "other"))
+}
+
+func TestHandlerSubscribeDenied(t *testing.T) {
+	t.Parallel()
+
+	hash, err := auth.HashPassword("secret")
+	require.NoError(t, err)
+
+	users, err := auth.NewUsers(auth.Config{
+		Users: []auth.User{{Name: "alice", PasswordHash: hash, Roles: []string{"billing"}}},
+		Roles: []auth.Role{{
+			Name:  "billing",
+			Allow: []auth.Rule{{Categories: []auth.Category{auth.CategoryRead}, Keys: []string{"billing:*"}}},
+		}},
+	})
+	require.NoError(t, err)
+
+	broker := pubsub.NewBroker()
+	h := newTestHandler(t, broker)
+	WithUsers(users)(h.handler)
+	c := h.connect(t)
+
+	// subscriptions require authentication
+	assert.Equal(t, Value{Type: TypeError, Str: "NOAUTH Authentication required."},
+		c.do(t, "SUBSCRIBE", "__keyevent__:set"))
+	require.Equal(t, "OK", c.do(t, "AUTH", "alice", "secret").Str)
+
+	denied := c.do(t, "PSUBSCRIBE", "*")
+	assert.Equal(t, byte(TypeError), denied.Type)
+	assert.Contains(t, denied.Str, "NOPERM ")
+
+	denied = c.do(t, "SUBSCRIBE", "__keyspace__:billing:1", "__keyevent__:set")
+	assert.Equal(t, byte(TypeError), denied.Type)
+	assert.Equal(t, 0, broker.Publish("__keyspace__:billing:1", "set"))
+
+	assert.Equal(t, Value{Type: TypeArray, Array: []Value{
+		{Type: TypeBulkString, Str: "psubscribe"},
+		{Type: TypeBulkString, Str: "__keyspace__:billing:*"},
+		{Type: TypeInteger, Int: 1},
+	}}, c.do(t, "PSUBSCRIBE", "__keyspace__:billing:*"))
+}
+
+func TestHandlerAuth(t *testing.T) {
+	t.Parallel()
+
+	hash, err := auth.HashPassword("secret")
+	require.NoError(t, err)
+
+	users, err := auth.NewUsers(auth.Config{
+		Users: []auth.User{
+			{Name: "alice", PasswordHash: hash, Roles: []string{"billing"}},
+			{Name: "default", PasswordHash: hash, Roles: []string{"billing"}},
+		},
+		Roles: []auth.Role{{
+			Name: "billing",
+			Allow: []auth.Rule{{
+				Categories: []auth.Category{auth.CategoryRead, auth.CategoryWrite},
+				Keys:       []string{"billing:*"},
+			}},
+		}},
+	})
+	require.NoError(t, err)
+
+	h := newTestHandler(t, nil, database.WithAuthorizer(users))
+	WithUsers(users)(h.handler)
+
+	t.Run("AUTH", func(t *testing.T) {
+		c := h.connect(t)
+
+		noAuth := Value{Type: TypeError, Str: "NOAUTH Authentication required."}
+		assert.Equal(t, noAuth, c.do(t, "GET", "billing:1"))
+		assert.Equal(t, noAuth, c.do(t, "CLIENT", "ID"))
+		assert.Equal(t, "PONG", c.do(t, "PING").Str)
+
+		hello := c.do(t, "HELLO", "3")
+		assert.Equal(t, byte(TypeError), hello.Type)
+		assert.Contains(t, hello.Str, "NOAUTH ")
+
+		assert.Equal(t, Value{Type: TypeError, Str: "WRONGPASS invalid username-password pair or user is disabled."},
+			c.do(t, "AUTH", "alice", "wrong"))
+		assert.Equal(t, noAuth, c.do(t, "GET", "billing:1"))
+
+		assert.Equal(t, "OK", c.do(t, "AUTH", "alice", "secret").Str)
+		assert.Equal(t, "OK", c.do(t, "SET", "billing:1", "10").Str)
+
+		// commands are checked with roles of user
+		denied := c.do(t, "SET", "other", "10")
+		assert.Equal(t, byte(TypeError), denied.Type)
+		assert.Contains(t, denied.Str, "NOPERM ")
+	})
+
+	t.Run("AUTH of default user", func(t *testing.T) {
+		c := h.connect(t)
+
+		assert.Equal(t, "OK", c.do(t, "AUTH", "secret").Str)
+		assert.Equal(t, byte(TypeBulkString), c.do(t, "GET", "billing:1").Type)
+	})
+
+	t.Run("HELLO with AUTH", func(t *testing.T) {
+		c := h.connect(t)
+
+		hello := c.do(t, "HELLO", "3", "AUTH", "alice", "secret", "SETNAME", "app")
+		assert.Equal(t, byte(TypeMap), hello.Type)
+		assert.Equal(t, "app", c.do(t, "CLIENT", "GETNAME").Str)
+		assert.Equal(t, "10", c.do(t, "GET", "billing:1").Str)
+	})
+
+	t.Run("anonymous roles", func(t *testing.T) {
+		users, err := auth.NewUsers(auth.Config{
+			Roles: []auth.Role{{
+				Name:  "reader",
+				Allow: []auth.Rule{{Categories: []auth.Category{auth.CategoryRead}, Keys: []string{"billing:*"}}},
+			}},
+			AnonymousRoles: []string{"reader"},
+		})
+		require.NoError(t, err)
+
+		h := newTestHandler(t, nil, database.WithAuthorizer(users))
+		WithUsers(users)(h.handler)
+		c := h.connect(t)
+
+		assert.Equal(t, Value{Type: TypeBulkString, Null: true}, c.do(t, "GET", "billing:1"))
+
+		denied := c.do(t, "SET", "billing:1", "10")
+		assert.Equal(t, byte(TypeError), denied.Type)
+		assert.Contains(t, denied.Str, "NOPERM ")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		c := newTestClient(t)
+
+		assert.Equal(t, Value{Type: TypeError, Str: "ERR authentication is disabled"},
+			c.do(t, "AUTH", "alice", "secret"))
+	})
+}
+
+func TestHandlerSubscribeDisabled(t *testing.T) {
+	t.Parallel()
+
+	c := newTestClient(t)
+
+	assert.Equal(t, Value{Type: TypeError, Str: "ERR pub/sub is not enabled"}, c.do(t, "SUBSCRIBE", "channel"))
+}
+
+func TestHandlerBinarySafeValue(t *testing.T) {
+	t.Parallel()
+
+	c := newTestClient(t)
+
+	value := "value with spaces\r\nand lines"
+	assert.Equal(t, "OK", c.do(t, "SET", "key", value).Str)
+	assert.Equal(t, value, c.do(t, "GET", "key").Str)
+}
+
+func TestHandlerHello(t *testing.T) {
+	t.Parallel()
+
+	c := newTestClient(t)
+
+	reply := c.do(t, "HELLO", "3", "SETNAME", "test")
+	require.Equal(t, byte(TypeMap), reply.Type)
+	require.Len(t, reply.Array, 12)
+	assert.Equal(t, "proto", reply.Array[4].Str)
+	assert.Equal(t, int64(3), reply.Array[5].Int)
+
+	// RESP3 null after protocol switch
+	assert.Equal(t, Value{Type: TypeNull, Null: true}, c.do(t, "GET", "missing"))
+	assert.Equal(t, "test", c.do(t, "CLIENT", "GETNAME").Str)
+
+	reply = c.do(t, "HELLO", "4")
+	assert.Equal(t, Value{Type: TypeError, Str: "NOPROTO unsupported protocol version"}, reply)
+
+	reply = c.do(t, "HELLO", "2")
+	assert.Equal(t, byte(TypeArray), reply.Type)
+	assert.Equal(t, Value{Type: TypeBulkString, Null: true}, c.do(t, "GET", "missing"))
+}
+
+func TestHandlerPipeline(t *testing.T) {
+	t.Parallel()
+
+	c := newTestClient(t)
+
+	c.writer.WriteCommand("SET", "a", "1")
+	c.writer.WriteCommand("SET", "b", "2")
+	c.writer.WriteCommand("GET", "a")
+	c.writer.WriteCommand("GET", "b")
+	require.NoError(t, c.writer.Flush())
+
+	for _, expected := range []string{"OK", "OK", "1", "2"} {
+		value, err := c.reader.ReadValue()
+		require.NoError(t, err)
+		assert.Equal(t, expected, value.Str)
+	}
+}
+
+func TestHandlerProtocolError(t *testing.T) {
+	t.Parallel()
+
+	c := newTestClient(t)
+
+	_, err := c.conn.Write([]byte("*1\r\n$2000\r\n"))
+	require.NoError(t, err)
+
+	value, err := c.reader.ReadValue()
+	require.NoError(t, err)
+	assert.Equal(t, byte(TypeError), value.Type)
+	assert.Contains(t, value.Str, "exceeds max message size")
+
+	_, err = c.reader.ReadValue()
+	assert.Error(t, err)
+}
+
+func TestHandlerQuit(t *testing.T) {
+	t.Parallel()
+
+	c := newTestClient(t)
+
+	assert.Equal(t, "OK", c.do(t, "QUIT").Str)
+
+	_, err := c.reader.ReadValue()
+	assert.Error(t, err)
+}
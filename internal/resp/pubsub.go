@@ -0,0 +1,225 @@
+package resp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"concurrency_go_course/internal/compute"
+	"concurrency_go_course/internal/pubsub"
+	"concurrency_go_course/pkg/logger"
+)
+
+func isSubscribe(command string) bool {
+	command = strings.ToUpper(command)
+	return command == compute.CommandSubscribe || command == compute.CommandPSubscribe
+}
+
+// serveSubscribed handles connection in subscribe mode until client
+// disconnects, commands are read by separate goroutine, so messages are
+// pushed while client is idle, connection stays in this mode after all
+// subscriptions are removed
+func (h *Handler) serveSubscribed(ctx context.Context, conn net.Conn, s *session, args []string) {
+	sub := h.broker.NewSubscriber()
+	defer sub.Close()
+
+	// subscribers wait for messages, so they are not disconnected when idle
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		logger.ErrorWithMsg("unable to reset deadline:", err)
+		return
+	}
+
+	commands := make(chan []string)
+	readErr := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for {
+			args, err := s.reader.ReadCommand()
+			if err != nil {
+				readErr <- err
+				return
+			}
+
+			select {
+			case commands <- args:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	quit := h.executeSubscribed(ctx, s, sub, args)
+	for !quit {
+		if err := h.flush(conn, s); err != nil {
+			logger.ErrorWithMsg("unable to write RESP reply:", err)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-readErr:
+			if errors.Is(err, ErrProtocol) {
+				s.writer.WriteError("ERR " + err.Error())
+				_ = h.flush(conn, s)
+			}
+
+			if !errors.Is(err, io.EOF) {
+				logger.ErrorWithMsg("unable to read RESP command:", err)
+			}
+			return
+		case args := <-commands:
+			if len(args) != 0 {
+				quit = h.executeSubscribed(ctx, s, sub, args)
+			}
+		case message, ok := <-sub.Messages():
+			if !ok {
+				logger.Info("subscriber is too slow, connection is closed")
+				return
+			}
+
+			writeMessage(s.writer, message)
+		}
+	}
+
+	if err := h.flush(conn, s); err != nil {
+		logger.ErrorWithMsg("unable to write RESP reply:", err)
+	}
+}
+
+// flush writes buffered replies, write deadline keeps stuck client from
+// blocking connection forever
+func (h *Handler) flush(conn net.Conn, s *session) error {
+	if h.idleTimeout != 0 {
+		if err := conn.SetWriteDeadline(time.Now().Add(h.idleTimeout)); err != nil {
+			return err
+		}
+	}
+
+	return s.writer.Flush()
+}
+
+// executeSubscribed writes reply for command in subscribe mode, returns
+// true if connection must be closed, other commands are allowed in RESP3
+// and in RESP2 after all subscriptions are removed
+func (h *Handler) executeSubscribed(ctx context.Context, s *session, sub *pubsub.Subscriber, args []string) bool {
+	command := strings.ToUpper(args[0])
+
+	switch command {
+	case compute.CommandSubscribe, compute.CommandPSubscribe:
+		if len(args) < 2 {
+			writeArgsError(s.writer, command)
+			return false
+		}
+		if err := h.authorizeSubscriptions(s, command, args[1:]); err != nil {
+			s.writer.WriteError("NOPERM " + err.Error())
+			return false
+		}
+		subscribe(s.writer, sub, command, args[1:])
+	case compute.CommandUnsubscribe, compute.CommandPUnsubscribe:
+		subscribe(s.writer, sub, command, args[1:])
+	case "PING":
+		if s.writer.Version() >= 3 || sub.Count() == 0 {
+			return h.execute(ctx, s, args)
+		}
+
+		// RESP2 subscriber receives PING reply in message format
+		var message string
+		if len(args) > 1 {
+			message = args[1]
+		}
+		s.writer.WriteArrayHeader(2)
+		s.writer.WriteBulkString("pong")
+		s.writer.WriteBulkString(message)
+	default:
+		if s.writer.Version() >= 3 || sub.Count() == 0 {
+			return h.execute(ctx, s, args)
+		}
+
+		s.writer.WriteError("ERR Can't execute '" + strings.ToLower(command) +
+			"': only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT are allowed in this context")
+	}
+
+	return false
+}
+
+// subscribe changes subscriptions of subscriber and writes confirmation for
+// every channel or pattern, unsubscribe without names removes all of them
+// authorizeSubscriptions checks access to all channels or patterns of
+// subscribe command, so denied command does not change subscriptions
+func (h *Handler) authorizeSubscriptions(s *session, command string, names []string) error {
+	if h.users == nil {
+		return nil
+	}
+
+	for _, name := range names {
+		if err := pubsub.AuthorizeSubscription(h.users, s.user, name, command == compute.CommandPSubscribe); err != nil {
+			logger.Warn("permission denied", zap.String("user", s.user),
+				zap.Bool("authenticated", s.user != ""), zap.String("command", command),
+				zap.String("channel", name))
+			return err
+		}
+	}
+
+	return nil
+}
+
+func subscribe(writer *Writer, sub *pubsub.Subscriber, command string, names []string) {
+	var change func(string) int
+	switch command {
+	case compute.CommandSubscribe:
+		change = sub.Subscribe
+	case compute.CommandPSubscribe:
+		change = sub.PSubscribe
+	case compute.CommandUnsubscribe:
+		change = sub.Unsubscribe
+		if len(names) == 0 {
+			names = sub.Channels()
+		}
+	case compute.CommandPUnsubscribe:
+		change = sub.PUnsubscribe
+		if len(names) == 0 {
+			names = sub.Patterns()
+		}
+	}
+
+	kind := strings.ToLower(command)
+	if len(names) == 0 {
+		writer.WritePushHeader(3)
+		writer.WriteBulkString(kind)
+		writer.WriteNull()
+		writer.WriteInteger(int64(sub.Count()))
+		return
+	}
+
+	for _, name := range names {
+		count := change(name)
+
+		writer.WritePushHeader(3)
+		writer.WriteBulkString(kind)
+		writer.WriteBulkString(name)
+		writer.WriteInteger(int64(count))
+	}
+}
+
+// writeMessage writes message as push, pattern messages contain pattern
+func writeMessage(writer *Writer, message pubsub.Message) {
+	if message.Pattern != "" {
+		writer.WritePushHeader(4)
+		writer.WriteBulkString("pmessage")
+		writer.WriteBulkString(message.Pattern)
+	} else {
+		writer.WritePushHeader(3)
+		writer.WriteBulkString("message")
+	}
+
+	writer.WriteBulkString(message.Channel)
+	writer.WriteBulkString(message.Payload)
+}
@@ -0,0 +1,187 @@
+// Package slowlog records commands which latency exceeds threshold in
+// bounded in-memory ring buffer
+package slowlog
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"concurrency_go_course/internal/config"
+	"concurrency_go_course/pkg/logger"
+)
+
+const (
+	defaultThreshold = 10 * time.Millisecond
+	defaultMaxLen    = 128
+)
+
+// Entry is a recorded command, latency of command is a sum of parse and
+// execute durations
+type Entry struct {
+	ID      uint64
+	Time    time.Time
+	Command string
+	// Key is empty for commands without key, it is a hash of key if keys
+	// are redacted
+	Key     string
+	Client  string
+	Parse   time.Duration
+	Execute time.Duration
+}
+
+// Total returns latency of command
+func (e Entry) Total() time.Duration {
+	return e.Parse + e.Execute
+}
+
+// String returns entry as one line of space separated key=value pairs,
+// durations are in microseconds
+func (e Entry) String() string {
+	return strings.Join([]string{
+		"id=" + strconv.FormatUint(e.ID, 10),
+		"time_unix=" + strconv.FormatInt(e.Time.Unix(), 10),
+		"total_us=" + strconv.FormatInt(e.Total().Microseconds(), 10),
+		"parse_us=" + strconv.FormatInt(e.Parse.Microseconds(), 10),
+		"execute_us=" + strconv.FormatInt(e.Execute.Microseconds(), 10),
+		"client=" + e.Client,
+		"command=" + e.Command,
+		"key=" + e.Key,
+	}, " ")
+}
+
+// Log is a ring buffer of the latest slow commands
+type Log struct {
+	threshold  time.Duration
+	redactKeys bool
+	logEntries bool
+
+	mutex   sync.Mutex
+	entries []Entry
+	// next is a position of the next entry in ring buffer
+	next   int
+	lastID uint64
+}
+
+// New returns slow log of config, default threshold and length are used
+// for zero values
+func New(cfg *config.SlowLogConfig) *Log {
+	threshold := cfg.Threshold
+	if threshold <= 0 {
+		threshold = defaultThreshold
+	}
+
+	maxLen := cfg.MaxLen
+	if maxLen <= 0 {
+		maxLen = defaultMaxLen
+	}
+
+	return &Log{
+		threshold:  threshold,
+		redactKeys: cfg.RedactKeys,
+		logEntries: cfg.LogEntries,
+		entries:    make([]Entry, 0, maxLen),
+	}
+}
+
+// Add records command if its latency exceeds threshold, client address is
+// taken from context
+func (l *Log) Add(ctx context.Context, command, key string, parse, execute time.Duration) {
+	if parse+execute < l.threshold {
+		return
+	}
+
+	if key != "" && l.redactKeys {
+		key = redact(key)
+	}
+
+	entry := Entry{
+		Time:    time.Now(),
+		Command: command,
+		Key:     key,
+		Client:  ClientFromContext(ctx),
+		Parse:   parse,
+		Execute: execute,
+	}
+
+	l.mutex.Lock()
+	l.lastID++
+	entry.ID = l.lastID
+	if len(l.entries) < cap(l.entries) {
+		l.entries = append(l.entries, entry)
+	} else {
+		l.entries[l.next] = entry
+	}
+	l.next = (l.next + 1) % cap(l.entries)
+	l.mutex.Unlock()
+
+	if l.logEntries {
+		logger.Warn("slow command",
+			zap.Uint64("id", entry.ID),
+			zap.String("command", entry.Command),
+			zap.String("key", entry.Key),
+			zap.String("client", entry.Client),
+			zap.Duration("total", entry.Total()),
+			zap.Duration("parse", entry.Parse),
+			zap.Duration("execute", entry.Execute))
+	}
+}
+
+// Get returns up to n the latest entries, the newest entry is the first
+func (l *Log) Get(n int) []Entry {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	n = min(max(n, 0), len(l.entries))
+	entries := make([]Entry, 0, n)
+	for i := 1; i <= n; i++ {
+		position := (l.next - i + cap(l.entries)) % cap(l.entries)
+		entries = append(entries, l.entries[position])
+	}
+
+	return entries
+}
+
+// Len returns number of entries
+func (l *Log) Len() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return len(l.entries)
+}
+
+// Reset removes all entries, ids of new entries continue previous ones
+func (l *Log) Reset() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.entries = l.entries[:0]
+	l.next = 0
+}
+
+// redact returns short hash of key, so entries of the same key are still
+// correlated
+func redact(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:6])
+}
+
+type clientKey struct{}
+
+// ContextWithClient returns context with network address of client
+func ContextWithClient(ctx context.Context, address string) context.Context {
+	return context.WithValue(ctx, clientKey{}, address)
+}
+
+// ClientFromContext returns network address of client, it is empty if
+// request was not received from network client
+func ClientFromContext(ctx context.Context) string {
+	address, _ := ctx.Value(clientKey{}).(string)
+	return address
+}
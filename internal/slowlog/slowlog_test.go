@@ -0,0 +1,83 @@
+package slowlog
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"concurrency_go_course/internal/config"
+	"concurrency_go_course/pkg/logger"
+)
+
+func TestLogAdd(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	log := New(&config.SlowLogConfig{Threshold: time.Millisecond, MaxLen: 3, LogEntries: true})
+	ctx := ContextWithClient(context.Background(), "127.0.0.1:5000")
+
+	log.Add(ctx, "GET", "fast", 0, time.Microsecond)
+	for i := range 5 {
+		log.Add(ctx, "SET", "key"+strconv.Itoa(i), time.Microsecond, time.Millisecond)
+	}
+
+	assert.Equal(t, 3, log.Len())
+
+	entries := log.Get(10)
+	require.Len(t, entries, 3)
+	assert.Equal(t, uint64(5), entries[0].ID)
+	assert.Equal(t, "key4", entries[0].Key)
+	assert.Equal(t, "key2", entries[2].Key)
+	assert.Equal(t, "127.0.0.1:5000", entries[0].Client)
+	assert.Equal(t, time.Millisecond+time.Microsecond, entries[0].Total())
+
+	assert.Equal(t, entries[:2], log.Get(2))
+	assert.Empty(t, log.Get(0))
+
+	log.Reset()
+	assert.Equal(t, 0, log.Len())
+
+	log.Add(context.Background(), "DEL", "key", 0, time.Second)
+	entries = log.Get(10)
+	require.Len(t, entries, 1)
+	assert.Equal(t, uint64(6), entries[0].ID)
+	assert.Empty(t, entries[0].Client)
+}
+
+func TestLogRedactKeys(t *testing.T) {
+	t.Parallel()
+
+	log := New(&config.SlowLogConfig{Threshold: time.Nanosecond, RedactKeys: true})
+	log.Add(context.Background(), "SET", "secret", 0, time.Millisecond)
+	log.Add(context.Background(), "GET", "secret", 0, time.Millisecond)
+	log.Add(context.Background(), "INFO", "", 0, time.Millisecond)
+
+	entries := log.Get(3)
+	require.Len(t, entries, 3)
+	assert.Empty(t, entries[0].Key)
+	assert.Regexp(t, `^sha256:[0-9a-f]{12}$`, entries[1].Key)
+	assert.Equal(t, entries[1].Key, entries[2].Key)
+	assert.NotContains(t, entries[1].String(), "secret")
+}
+
+func TestEntryString(t *testing.T) {
+	t.Parallel()
+
+	entry := Entry{
+		ID:      7,
+		Time:    time.Unix(1700000000, 0),
+		Command: "SET",
+		Key:     "key",
+		Client:  "127.0.0.1:5000",
+		Parse:   15 * time.Microsecond,
+		Execute: 2 * time.Millisecond,
+	}
+
+	assert.Equal(t, "id=7 time_unix=1700000000 total_us=2015 parse_us=15 execute_us=2000 "+
+		"client=127.0.0.1:5000 command=SET key=key", entry.String())
+}
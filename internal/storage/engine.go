@@ -1,18 +1,59 @@
 package storage
 
 import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
 	"hash/fnv"
+	"strconv"
 	"sync"
+
+	"concurrency_go_course/pkg/metrics"
 )
 
 // Engine is interface for engine
 type Engine interface {
-	Get(key string) (string, bool)
+	Get(key string) (string, bool, error)
 	Set(key string, value string)
 	Delete(key string)
+	Type(key string) string
+	HSet(key string, fields []string) (int, error)
+	HGet(key, field string) (string, bool, error)
+	HDel(key string, fields []string) (int, error)
+	HGetAll(key string) ([]string, error)
+	LPush(key string, values []string) (int, error)
+	RPush(key string, values []string) (int, error)
+	LPop(key string) (string, bool, error)
+	RPop(key string) (string, bool, error)
+	LRange(key string, start, stop int) ([]string, error)
+	LLen(key string) (int, error)
+	ZAdd(key string, members []ZMember) (int, error)
+	ZRem(key string, members []string) (int, error)
+	ZScore(key, member string) (float64, bool, error)
+	ZRange(key string, start, stop int) ([]ZMember, error)
+	ZRangeByScore(key string, r ScoreRange) ([]ZMember, error)
+	ZRemRangeByScore(key string, r ScoreRange) (int, error)
+	ZCard(key string) (int, error)
+	Expire(key string, deadline int64) bool
+	Deadline(key string) int64
+	DeleteExpired(key string, deadline int64) bool
+	ExpiredKeys(now int64, limit int) []string
+	Snapshot() ([]byte, error)
+	RestoreSnapshot(data []byte) error
+	Partitions() []PartitionStats
+}
+
+// PartitionStats is a size of engine partition
+type PartitionStats struct {
+	Keys int
+	// Bytes is an approximate memory size of keys and values
+	Bytes int
 }
 
 type engine struct {
+	// mutex is locked for reading by operations over all partitions, so
+	// partitions replaced by snapshot are seen by them at once
+	mutex sync.RWMutex
 	parts []*HashTable
 }
 
@@ -27,40 +68,294 @@ func NewEngine(partsNumber int) Engine {
 	for i := 0; i < partsNumber; i++ {
 		engine.parts[i] = &HashTable{
 			mutex: sync.RWMutex{},
-			data:  make(map[string]string, defaultKeyCount),
+			data:  make(map[string]value, defaultKeyCount),
 		}
 	}
+
+	metrics.Register(metrics.NewGaugeFunc("storage_partition_keys",
+		"Number of keys in engine partition.", []string{"partition"}, engine.collectKeys))
+
 	return engine
 }
 
-// Get returns value
-func (e *engine) Get(key string) (string, bool) {
-	hash := getHash(key, len(e.parts))
+// Partitions returns sizes of partitions in order of partition number
+func (e *engine) Partitions() []PartitionStats {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
 
-	part := e.parts[hash]
+	stats := make([]PartitionStats, len(e.parts))
+	for i, part := range e.parts {
+		stats[i] = PartitionStats{Keys: part.Len(), Bytes: part.Bytes()}
+	}
 
-	value, ok := part.Get(key)
-	if !ok {
-		return "", false
+	return stats
+}
+
+// collectKeys emits number of keys of every partition
+func (e *engine) collectKeys(emit func(float64, ...string)) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	for i, part := range e.parts {
+		emit(float64(part.Len()), strconv.Itoa(i))
 	}
+}
 
-	return value, ok
+// Get returns string value
+func (e *engine) Get(key string) (string, bool, error) {
+	return e.part(key).Get(key)
 }
 
 // Set sets new value for key
 func (e *engine) Set(key string, value string) {
-	hash := getHash(key, len(e.parts))
-	part := e.parts[hash]
-
-	part.Set(key, value)
+	e.part(key).Set(key, value)
 }
 
 // Delete deletes key-value pair
 func (e *engine) Delete(key string) {
-	hash := getHash(key, len(e.parts))
-	part := e.parts[hash]
+	e.part(key).Del(key)
+}
+
+// Type returns type of value for key
+func (e *engine) Type(key string) string {
+	return e.part(key).Type(key)
+}
+
+// HSet sets fields of hash, it returns number of added fields
+func (e *engine) HSet(key string, fields []string) (int, error) {
+	return e.part(key).HSet(key, fields)
+}
+
+// HGet returns value of hash field
+func (e *engine) HGet(key, field string) (string, bool, error) {
+	return e.part(key).HGet(key, field)
+}
+
+// HDel deletes fields of hash, it returns number of deleted fields
+func (e *engine) HDel(key string, fields []string) (int, error) {
+	return e.part(key).HDel(key, fields)
+}
+
+// HGetAll returns pairs of field and value of hash
+func (e *engine) HGetAll(key string) ([]string, error) {
+	return e.part(key).HGetAll(key)
+}
+
+// LPush inserts values at the head of list, it returns length of list
+func (e *engine) LPush(key string, values []string) (int, error) {
+	return e.part(key).LPush(key, values)
+}
+
+// RPush appends values to the tail of list, it returns length of list
+func (e *engine) RPush(key string, values []string) (int, error) {
+	return e.part(key).RPush(key, values)
+}
+
+// LPop removes and returns the first value of list
+func (e *engine) LPop(key string) (string, bool, error) {
+	return e.part(key).LPop(key)
+}
+
+// RPop removes and returns the last value of list
+func (e *engine) RPop(key string) (string, bool, error) {
+	return e.part(key).RPop(key)
+}
+
+// LRange returns values of list from start to stop inclusive
+func (e *engine) LRange(key string, start, stop int) ([]string, error) {
+	return e.part(key).LRange(key, start, stop)
+}
+
+// LLen returns length of list
+func (e *engine) LLen(key string) (int, error) {
+	return e.part(key).LLen(key)
+}
+
+// ZAdd sets scores of sorted set members, it returns number of added
+// members
+func (e *engine) ZAdd(key string, members []ZMember) (int, error) {
+	return e.part(key).ZAdd(key, members)
+}
+
+// ZRem removes members of sorted set, it returns number of removed members
+func (e *engine) ZRem(key string, members []string) (int, error) {
+	return e.part(key).ZRem(key, members)
+}
+
+// ZScore returns score of sorted set member
+func (e *engine) ZScore(key, member string) (float64, bool, error) {
+	return e.part(key).ZScore(key, member)
+}
+
+// ZRange returns members of sorted set from rank start to stop inclusive
+func (e *engine) ZRange(key string, start, stop int) ([]ZMember, error) {
+	return e.part(key).ZRange(key, start, stop)
+}
+
+// ZRangeByScore returns members of sorted set with score in range
+func (e *engine) ZRangeByScore(key string, r ScoreRange) ([]ZMember, error) {
+	return e.part(key).ZRangeByScore(key, r)
+}
+
+// ZRemRangeByScore removes members of sorted set with score in range, it
+// returns number of removed members
+func (e *engine) ZRemRangeByScore(key string, r ScoreRange) (int, error) {
+	return e.part(key).ZRemRangeByScore(key, r)
+}
+
+// ZCard returns number of sorted set members
+func (e *engine) ZCard(key string) (int, error) {
+	return e.part(key).ZCard(key)
+}
+
+// Expire sets expiration deadline of key in unix milliseconds, it returns
+// false if key does not exist
+func (e *engine) Expire(key string, deadline int64) bool {
+	return e.part(key).Expire(key, deadline)
+}
+
+// Deadline returns expiration deadline of key in unix milliseconds, it is
+// zero if key does not expire
+func (e *engine) Deadline(key string) int64 {
+	return e.part(key).Deadline(key)
+}
+
+// DeleteExpired deletes key if it still expires at deadline
+func (e *engine) DeleteExpired(key string, deadline int64) bool {
+	return e.part(key).DeleteExpired(key, deadline)
+}
+
+// ExpiredKeys returns at most limit keys with deadline not after now
+func (e *engine) ExpiredKeys(now int64, limit int) []string {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	var keys []string
+	for _, part := range e.parts {
+		if len(keys) == limit {
+			break
+		}
+		keys = append(keys, part.ExpiredKeys(now, limit-len(keys))...)
+	}
+
+	return keys
+}
+
+func (e *engine) part(key string) *HashTable {
+	return e.parts[getHash(key, len(e.parts))]
+}
+
+// snapshotData is an encoded form of engine data
+type snapshotData struct {
+	Strings map[string]string
+	Hashes  map[string]map[string]string
+	Lists   map[string][]string
+	ZSets   map[string]map[string]float64
+	// Expires are expiration deadlines of keys in unix milliseconds
+	Expires map[string]int64
+}
+
+// Snapshot returns encoded copy of all partitions
+func (e *engine) Snapshot() ([]byte, error) {
+	data := snapshotData{
+		Strings: make(map[string]string),
+		Hashes:  make(map[string]map[string]string),
+		Lists:   make(map[string][]string),
+		ZSets:   make(map[string]map[string]float64),
+		Expires: make(map[string]int64),
+	}
+
+	e.mutex.RLock()
+	for _, part := range e.parts {
+		part.copyTo(&data)
+	}
+	e.mutex.RUnlock()
+
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(data); err != nil {
+		return nil, fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// RestoreSnapshot replaces engine data with snapshot data, snapshot of
+// string values written by older versions is restored too. Snapshot is
+// restored to new partitions first, so engine data is kept if snapshot is
+// invalid. All partitions are replaced at once
+func (e *engine) RestoreSnapshot(snapshot []byte) error {
+	var data snapshotData
+	if err := gob.NewDecoder(bytes.NewBuffer(snapshot)).Decode(&data); err != nil {
+		if err := gob.NewDecoder(bytes.NewBuffer(snapshot)).Decode(&data.Strings); err != nil {
+			return fmt.Errorf("failed to decode snapshot: %w", err)
+		}
+	}
+
+	restored := &engine{parts: make([]*HashTable, len(e.parts))}
+	for i := range restored.parts {
+		restored.parts[i] = NewHashTable()
+	}
+
+	if err := restored.restore(data); err != nil {
+		return fmt.Errorf("failed to restore snapshot: %w", err)
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for _, part := range e.parts {
+		part.mutex.Lock()
+		defer part.mutex.Unlock()
+	}
+
+	for i, part := range e.parts {
+		part.replace(restored.parts[i])
+	}
+
+	return nil
+}
+
+// restore adds snapshot data to engine
+func (e *engine) restore(data snapshotData) error {
+	for key, value := range data.Strings {
+		e.Set(key, value)
+	}
+
+	for key, hash := range data.Hashes {
+		fields := make([]string, 0, 2*len(hash))
+		for field, value := range hash {
+			fields = append(fields, field, value)
+		}
+
+		if _, err := e.HSet(key, fields); err != nil {
+			return fmt.Errorf("hash %s: %w", key, err)
+		}
+	}
+
+	for key, values := range data.Lists {
+		if _, err := e.RPush(key, values); err != nil {
+			return fmt.Errorf("list %s: %w", key, err)
+		}
+	}
+
+	for key, scores := range data.ZSets {
+		members := make([]ZMember, 0, len(scores))
+		for member, score := range scores {
+			members = append(members, ZMember{Member: member, Score: score})
+		}
+
+		if _, err := e.ZAdd(key, members); err != nil {
+			return fmt.Errorf("sorted set %s: %w", key, err)
+		}
+	}
+
+	for key, deadline := range data.Expires {
+		if !e.Expire(key, deadline) {
+			return fmt.Errorf("deadline of missing key %s", key)
+		}
+	}
 
-	part.Del(key)
+	return nil
 }
 
 func getHash(key string, partsCount int) int {
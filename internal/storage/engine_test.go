@@ -1,9 +1,15 @@
 package storage
 
 import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"math"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetEngine(t *testing.T) {
@@ -28,7 +34,7 @@ func TestGetEngine(t *testing.T) {
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			value, _ := engine.Get(test.key)
+			value, _, _ := engine.Get(test.key)
 			assert.Equal(t, value, test.expectedValue)
 		})
 	}
@@ -56,7 +62,7 @@ func TestSetEngine(t *testing.T) {
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
 			engine.Set(test.key, test.value)
-			value, _ := engine.Get(test.key)
+			value, _, _ := engine.Get(test.key)
 			assert.Equal(t, value, test.value)
 		})
 	}
@@ -84,8 +90,252 @@ func TestDeleteEngine(t *testing.T) {
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
 			engine.Delete(test.key)
-			value, _ := engine.Get(test.key)
+			value, _, _ := engine.Get(test.key)
 			assert.Equal(t, value, "")
 		})
 	}
 }
+
+func TestSnapshotEngine(t *testing.T) {
+	t.Parallel()
+
+	engine := NewEngine(4)
+	engine.Set("key1", "a")
+	engine.Set("key2", "b")
+
+	snapshot, err := engine.Snapshot()
+	assert.Nil(t, err)
+
+	restored := NewEngine(8)
+	restored.Set("key3", "c")
+	assert.Nil(t, restored.RestoreSnapshot(snapshot))
+
+	value, ok, _ := restored.Get("key1")
+	assert.True(t, ok)
+	assert.Equal(t, "a", value)
+
+	value, ok, _ = restored.Get("key2")
+	assert.True(t, ok)
+	assert.Equal(t, "b", value)
+
+	_, ok, _ = restored.Get("key3")
+	assert.False(t, ok)
+}
+
+func TestCollectKeysEngine(t *testing.T) {
+	t.Parallel()
+
+	e := NewEngine(4)
+	for _, key := range []string{"key1", "key2", "key3"} {
+		e.Set(key, "a")
+	}
+	e.Delete("key2")
+
+	keys := make(map[string]float64)
+	e.(*engine).collectKeys(func(value float64, labelValues ...string) {
+		keys[labelValues[0]] = value
+	})
+
+	assert.Len(t, keys, 4)
+
+	var total float64
+	for _, count := range keys {
+		total += count
+	}
+	assert.Equal(t, float64(2), total)
+}
+
+func TestSnapshotEngineHash(t *testing.T) {
+	t.Parallel()
+
+	engine := NewEngine(4)
+	engine.Set("key", "value")
+	_, err := engine.HSet("user", []string{"name", "alice", "age", "30"})
+	require.NoError(t, err)
+
+	snapshot, err := engine.Snapshot()
+	require.NoError(t, err)
+
+	restored := NewEngine(8)
+	require.NoError(t, restored.RestoreSnapshot(snapshot))
+
+	pairs, err := restored.HGetAll("user")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"age", "30", "name", "alice"}, pairs)
+
+	value, ok, err := restored.Get("key")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "value", value)
+	assert.Equal(t, totalBytes(engine), totalBytes(restored))
+
+	t.Run("snapshot of string values", func(t *testing.T) {
+		var buffer bytes.Buffer
+		require.NoError(t, gob.NewEncoder(&buffer).Encode(map[string]string{"key": "legacy"}))
+
+		restored := NewEngine(2)
+		require.NoError(t, restored.RestoreSnapshot(buffer.Bytes()))
+
+		value, ok, err := restored.Get("key")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, "legacy", value)
+	})
+}
+
+func TestSnapshotEngineList(t *testing.T) {
+	t.Parallel()
+
+	engine := NewEngine(4)
+	_, err := engine.LPush("jobs", []string{"b", "a"})
+	require.NoError(t, err)
+	_, err = engine.RPush("jobs", []string{"c"})
+	require.NoError(t, err)
+
+	snapshot, err := engine.Snapshot()
+	require.NoError(t, err)
+
+	restored := NewEngine(8)
+	require.NoError(t, restored.RestoreSnapshot(snapshot))
+
+	values, err := restored.LRange("jobs", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, values)
+	assert.Equal(t, totalBytes(engine), totalBytes(restored))
+}
+
+func TestSnapshotEngineZSet(t *testing.T) {
+	t.Parallel()
+
+	engine := NewEngine(4)
+	_, err := engine.ZAdd("board", []ZMember{{"alice", 30}, {"bob", math.Inf(1)}, {"carol", -1.5}})
+	require.NoError(t, err)
+
+	snapshot, err := engine.Snapshot()
+	require.NoError(t, err)
+
+	restored := NewEngine(8)
+	require.NoError(t, restored.RestoreSnapshot(snapshot))
+
+	members, err := restored.ZRange("board", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []ZMember{{"carol", -1.5}, {"alice", 30}, {"bob", math.Inf(1)}}, members)
+	assert.Equal(t, totalBytes(engine), totalBytes(restored))
+}
+
+func TestSnapshotEngineExpire(t *testing.T) {
+	t.Parallel()
+
+	engine := NewEngine(4)
+	engine.Set("key", "value")
+	engine.Set("session", "token")
+	require.True(t, engine.Expire("session", 100))
+
+	snapshot, err := engine.Snapshot()
+	require.NoError(t, err)
+
+	restored := NewEngine(8)
+	require.NoError(t, restored.RestoreSnapshot(snapshot))
+
+	assert.Equal(t, int64(100), restored.Deadline("session"))
+	assert.Zero(t, restored.Deadline("key"))
+	assert.Equal(t, []string{"session"}, restored.ExpiredKeys(100, 10))
+}
+
+func TestRestoreInvalidSnapshotEngine(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]snapshotData{
+		"key of two types": {
+			Strings: map[string]string{"a": "value", "user": "alice"},
+			Hashes:  map[string]map[string]string{"user": {"name": "alice"}},
+		},
+		"deadline of missing key": {
+			Strings: map[string]string{"a": "value"},
+			Expires: map[string]int64{"session": 100},
+		},
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var buffer bytes.Buffer
+			require.NoError(t, gob.NewEncoder(&buffer).Encode(data))
+
+			engine := NewEngine(4)
+			engine.Set("key", "value")
+			_, err := engine.RPush("jobs", []string{"a"})
+			require.NoError(t, err)
+			bytesBefore := totalBytes(engine)
+
+			assert.Error(t, engine.RestoreSnapshot(buffer.Bytes()))
+
+			// data is not changed by invalid snapshot
+			value, ok, _ := engine.Get("key")
+			assert.True(t, ok)
+			assert.Equal(t, "value", value)
+			assert.Equal(t, TypeList, engine.Type("jobs"))
+			assert.Equal(t, TypeNone, engine.Type("a"))
+			assert.Equal(t, bytesBefore, totalBytes(engine))
+		})
+	}
+}
+
+func totalBytes(e Engine) int {
+	total := 0
+	for _, stats := range e.Partitions() {
+		total += stats.Bytes
+	}
+	return total
+}
+
+func TestRestoreSnapshotAtOnce(t *testing.T) {
+	t.Parallel()
+
+	source := NewEngine(4)
+	for key := range 16 {
+		source.Set(fmt.Sprintf("key%d", key), "new")
+	}
+
+	snapshot, err := source.Snapshot()
+	require.NoError(t, err)
+
+	restored := NewEngine(4).(*engine)
+	for key := range 16 {
+		restored.Set(fmt.Sprintf("key%d", key), "old")
+	}
+
+	// restore waits for the last partition used by another client
+	last := restored.parts[len(restored.parts)-1]
+	last.mutex.Lock()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- restored.RestoreSnapshot(snapshot)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	values := make(chan string, 16)
+	go func() {
+		for key := range 16 {
+			value, _, _ := restored.Get(fmt.Sprintf("key%d", key))
+			values <- value
+		}
+	}()
+
+	// other partitions are not replaced before the last one
+	select {
+	case value := <-values:
+		assert.Equal(t, "old", value)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	last.mutex.Unlock()
+	require.NoError(t, <-done)
+
+	for key := range 16 {
+		value, _, _ := restored.Get(fmt.Sprintf("key%d", key))
+		assert.Equal(t, "new", value)
+	}
+}
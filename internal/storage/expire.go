@@ -0,0 +1,63 @@
+package storage
+
+// Expire sets expiration deadline of key in unix milliseconds, it returns
+// false if key does not exist, deadline is removed when value of key is
+// replaced or deleted
+func (s *HashTable) Expire(key string, deadline int64) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, found := s.data[key]; !found {
+		return false
+	}
+
+	if s.expires == nil {
+		s.expires = make(map[string]int64)
+	}
+	s.expires[key] = deadline
+
+	return true
+}
+
+// Deadline returns expiration deadline of key in unix milliseconds, it is
+// zero if key does not exist or does not expire
+func (s *HashTable) Deadline(key string) int64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.expires[key]
+}
+
+// DeleteExpired deletes key if it still expires at deadline, so key
+// changed after it was found expired is kept, it returns true if key was
+// deleted
+func (s *HashTable) DeleteExpired(key string, deadline int64) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if current, found := s.expires[key]; !found || current != deadline {
+		return false
+	}
+
+	s.remove(key)
+	return true
+}
+
+// ExpiredKeys returns at most limit keys with deadline not after now in
+// unix milliseconds
+func (s *HashTable) ExpiredKeys(now int64, limit int) []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var keys []string
+	for key, deadline := range s.expires {
+		if len(keys) == limit {
+			break
+		}
+		if deadline <= now {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
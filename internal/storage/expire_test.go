@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashTable_Expire(t *testing.T) {
+	t.Parallel()
+
+	t.Run("deadline is set only for existing key", func(t *testing.T) {
+		table := NewHashTable()
+		assert.False(t, table.Expire("key", 10))
+		assert.Zero(t, table.Deadline("key"))
+
+		table.Set("key", "value")
+		assert.True(t, table.Expire("key", 10))
+		assert.Equal(t, int64(10), table.Deadline("key"))
+	})
+
+	t.Run("deadline is removed with value", func(t *testing.T) {
+		table := NewHashTable()
+		table.Set("key", "value")
+		table.Expire("key", 10)
+		table.Set("key", "other")
+		assert.Zero(t, table.Deadline("key"))
+
+		_, err := table.HSet("user", []string{"name", "alice"})
+		require.NoError(t, err)
+		table.Expire("user", 10)
+		_, err = table.HSet("user", []string{"age", "30"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(10), table.Deadline("user"))
+
+		table.Del("user")
+		assert.Zero(t, table.Deadline("user"))
+	})
+
+	t.Run("expired key is deleted only at its deadline", func(t *testing.T) {
+		table := NewHashTable()
+		table.Set("key", "value")
+		table.Expire("key", 10)
+
+		assert.False(t, table.DeleteExpired("key", 5))
+		assert.True(t, table.DeleteExpired("key", 10))
+		assert.False(t, table.DeleteExpired("key", 10))
+
+		_, found, _ := table.Get("key")
+		assert.False(t, found)
+		assert.Zero(t, table.Len())
+	})
+
+	t.Run("expired keys are limited", func(t *testing.T) {
+		table := NewHashTable()
+		for i, key := range []string{"a", "b", "c", "d"} {
+			table.Set(key, "value")
+			table.Expire(key, int64(10*(i+1)))
+		}
+
+		assert.ElementsMatch(t, []string{"a", "b"}, table.ExpiredKeys(20, 10))
+		assert.Len(t, table.ExpiredKeys(40, 3), 3)
+		assert.Empty(t, table.ExpiredKeys(5, 10))
+	})
+}
@@ -1,37 +1,98 @@
 package storage
 
 import (
+	"errors"
+	"slices"
 	"sync"
 )
 
+// entryOverhead is an approximate size of map entry without key and value
+// data, it is two string headers and share of bucket metadata
+const entryOverhead = 48
+
+// Types of values
+const (
+	TypeNone   = "none"
+	TypeString = "string"
+	TypeHash   = "hash"
+	TypeList   = "list"
+	TypeZSet   = "zset"
+)
+
+// ErrWrongType is returned by command of one type applied to key holding
+// value of another type
+var ErrWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// value is a value of key, only field of its type is set
+type value struct {
+	kind string
+	str  string
+	hash map[string]string
+	list *deque
+	zset *zset
+}
+
+// size returns approximate memory size of value data
+func (v value) size() int {
+	size := 0
+	switch v.kind {
+	case TypeHash:
+		for field, fieldValue := range v.hash {
+			size += entrySize(field, fieldValue)
+		}
+	case TypeList:
+		for i := 0; i < v.list.len(); i++ {
+			size += len(v.list.at(i)) + elementOverhead
+		}
+	case TypeZSet:
+		for member := range v.zset.scores {
+			size += len(member) + zsetEntryOverhead
+		}
+	default:
+		size = len(v.str)
+	}
+	return size
+}
+
 // HashTable is a struct for hash table
 type HashTable struct {
 	mutex sync.RWMutex
-	data  map[string]string
+	data  map[string]value
+	// expires are expiration deadlines of keys in unix milliseconds
+	expires map[string]int64
+	// bytes is an approximate size of keys and values
+	bytes int
 }
 
 // NewHashTable returns new hash table
 func NewHashTable() *HashTable {
 	return &HashTable{
-		data: make(map[string]string),
+		data: make(map[string]value),
 	}
 }
 
-// Set sets new key-value
-func (s *HashTable) Set(key, value string) {
+// Set sets new key-value, value of any type is replaced
+func (s *HashTable) Set(key, str string) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	s.data[key] = value
+	s.put(key, value{kind: TypeString, str: str})
 }
 
-// Get returns value for key
-func (s *HashTable) Get(key string) (string, bool) {
+// Get returns string value for key
+func (s *HashTable) Get(key string) (string, bool, error) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
-	value, found := s.data[key]
-	return value, found
+	v, found := s.data[key]
+	if !found {
+		return "", false, nil
+	}
+	if v.kind != TypeString {
+		return "", false, ErrWrongType
+	}
+
+	return v.str, true, nil
 }
 
 // Del deletes key
@@ -39,5 +100,196 @@ func (s *HashTable) Del(key string) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	delete(s.data, key)
+	s.remove(key)
+}
+
+// Type returns type of value for key, it is TypeNone if key does not exist
+func (s *HashTable) Type(key string) string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	v, found := s.data[key]
+	if !found {
+		return TypeNone
+	}
+	return v.kind
+}
+
+// HSet sets fields of hash, fields are pairs of field and value, it
+// returns number of added fields
+func (s *HashTable) HSet(key string, fields []string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	v, found := s.data[key]
+	if found && v.kind != TypeHash {
+		return 0, ErrWrongType
+	}
+	if !found {
+		v = value{kind: TypeHash, hash: make(map[string]string, len(fields)/2)}
+		s.bytes += entrySize(key, "")
+	}
+
+	added := 0
+	for i := 0; i+1 < len(fields); i += 2 {
+		if old, ok := v.hash[fields[i]]; ok {
+			s.bytes -= entrySize(fields[i], old)
+		} else {
+			added++
+		}
+
+		v.hash[fields[i]] = fields[i+1]
+		s.bytes += entrySize(fields[i], fields[i+1])
+	}
+
+	s.data[key] = v
+	return added, nil
+}
+
+// HGet returns value of hash field
+func (s *HashTable) HGet(key, field string) (string, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	v, found := s.data[key]
+	if !found {
+		return "", false, nil
+	}
+	if v.kind != TypeHash {
+		return "", false, ErrWrongType
+	}
+
+	fieldValue, found := v.hash[field]
+	return fieldValue, found, nil
+}
+
+// HDel deletes fields of hash, hash without fields is deleted, it returns
+// number of deleted fields
+func (s *HashTable) HDel(key string, fields []string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	v, found := s.data[key]
+	if !found {
+		return 0, nil
+	}
+	if v.kind != TypeHash {
+		return 0, ErrWrongType
+	}
+
+	deleted := 0
+	for _, field := range fields {
+		if old, ok := v.hash[field]; ok {
+			s.bytes -= entrySize(field, old)
+			delete(v.hash, field)
+			deleted++
+		}
+	}
+
+	if len(v.hash) == 0 {
+		s.remove(key)
+	}
+
+	return deleted, nil
+}
+
+// HGetAll returns pairs of field and value of hash sorted by field
+func (s *HashTable) HGetAll(key string) ([]string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	v, found := s.data[key]
+	if !found {
+		return nil, nil
+	}
+	if v.kind != TypeHash {
+		return nil, ErrWrongType
+	}
+
+	fields := make([]string, 0, len(v.hash))
+	for field := range v.hash {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+
+	pairs := make([]string, 0, 2*len(fields))
+	for _, field := range fields {
+		pairs = append(pairs, field, v.hash[field])
+	}
+	return pairs, nil
+}
+
+// Len returns number of keys
+func (s *HashTable) Len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return len(s.data)
+}
+
+// Bytes returns approximate memory size of keys and values
+func (s *HashTable) Bytes() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.bytes
+}
+
+// copyTo adds copy of all key-values to snapshot data
+func (s *HashTable) copyTo(data *snapshotData) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for key, v := range s.data {
+		switch v.kind {
+		case TypeHash:
+			hash := make(map[string]string, len(v.hash))
+			for field, fieldValue := range v.hash {
+				hash[field] = fieldValue
+			}
+			data.Hashes[key] = hash
+		case TypeList:
+			data.Lists[key] = v.list.values(0, v.list.len()-1)
+		case TypeZSet:
+			scores := make(map[string]float64, v.zset.len())
+			for member, score := range v.zset.scores {
+				scores[member] = score
+			}
+			data.ZSets[key] = scores
+		default:
+			data.Strings[key] = v.str
+		}
+	}
+
+	for key, deadline := range s.expires {
+		data.Expires[key] = deadline
+	}
+}
+
+// replace replaces all keys with keys of other table, other table must
+// not be used after it, mutex must be locked
+func (s *HashTable) replace(other *HashTable) {
+	s.data = other.data
+	s.expires = other.expires
+	s.bytes = other.bytes
+}
+
+// put replaces value of key, mutex must be locked
+func (s *HashTable) put(key string, v value) {
+	s.remove(key)
+	s.data[key] = v
+	s.bytes += entrySize(key, "") + v.size()
+}
+
+// remove deletes key with its deadline, mutex must be locked
+func (s *HashTable) remove(key string) {
+	if old, ok := s.data[key]; ok {
+		s.bytes -= entrySize(key, "") + old.size()
+		delete(s.data, key)
+		delete(s.expires, key)
+	}
+}
+
+func entrySize(key, value string) int {
+	return len(key) + len(value) + entryOverhead
 }
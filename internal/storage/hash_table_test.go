@@ -11,14 +11,14 @@ func TestHashTable_Get(t *testing.T) {
 	t.Run("return existing value for key", func(t *testing.T) {
 		table := NewHashTable()
 		table.Set("key1", "value1")
-		value, found := table.Get("key1")
+		value, found, _ := table.Get("key1")
 		require.True(t, found)
 		require.Equal(t, "value1", value)
 	})
 
 	t.Run("return empty string and false if key does not exist", func(t *testing.T) {
 		table := NewHashTable()
-		value, found := table.Get("key1")
+		value, found, _ := table.Get("key1")
 		require.False(t, found)
 		require.Empty(t, value)
 	})
@@ -31,7 +31,7 @@ func TestHashTable_Del(t *testing.T) {
 		table := NewHashTable()
 		table.Set("key1", "value1")
 		table.Del("key1")
-		value, found := table.Get("key1")
+		value, found, _ := table.Get("key1")
 		require.False(t, found)
 		require.Empty(t, value)
 	})
@@ -39,7 +39,7 @@ func TestHashTable_Del(t *testing.T) {
 	t.Run("Deletion of not existing key (no error)", func(t *testing.T) {
 		table := NewHashTable()
 		table.Del("key1")
-		value, found := table.Get("key1")
+		value, found, _ := table.Get("key1")
 		require.False(t, found)
 		require.Empty(t, value)
 	})
@@ -50,7 +50,7 @@ func TestMemoryTable_Set(t *testing.T) {
 	t.Run("correct setting key and value", func(t *testing.T) {
 		table := NewHashTable()
 		table.Set("key1", "value1")
-		value, found := table.Get("key1")
+		value, found, _ := table.Get("key1")
 		require.True(t, found)
 		require.Equal(t, "value1", value)
 	})
@@ -59,8 +59,79 @@ func TestMemoryTable_Set(t *testing.T) {
 		table := NewHashTable()
 		table.Set("key1", "value1")
 		table.Set("key1", "value2")
-		value, found := table.Get("key1")
+		value, found, _ := table.Get("key1")
 		require.True(t, found)
 		require.Equal(t, "value2", value)
 	})
 }
+
+func TestHashTable_Hash(t *testing.T) {
+	t.Parallel()
+
+	t.Run("set, get and delete fields", func(t *testing.T) {
+		table := NewHashTable()
+
+		added, err := table.HSet("user", []string{"name", "alice", "age", "30"})
+		require.NoError(t, err)
+		require.Equal(t, 2, added)
+
+		added, err = table.HSet("user", []string{"age", "31"})
+		require.NoError(t, err)
+		require.Equal(t, 0, added)
+		require.Equal(t, TypeHash, table.Type("user"))
+
+		value, found, err := table.HGet("user", "age")
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, "31", value)
+
+		pairs, err := table.HGetAll("user")
+		require.NoError(t, err)
+		require.Equal(t, []string{"age", "31", "name", "alice"}, pairs)
+
+		deleted, err := table.HDel("user", []string{"age", "email"})
+		require.NoError(t, err)
+		require.Equal(t, 1, deleted)
+	})
+
+	t.Run("hash without fields is deleted", func(t *testing.T) {
+		table := NewHashTable()
+
+		_, err := table.HSet("user", []string{"name", "alice"})
+		require.NoError(t, err)
+
+		deleted, err := table.HDel("user", []string{"name"})
+		require.NoError(t, err)
+		require.Equal(t, 1, deleted)
+		require.Equal(t, TypeNone, table.Type("user"))
+		require.Equal(t, 0, table.Len())
+		require.Equal(t, 0, table.Bytes())
+	})
+
+	t.Run("wrong type", func(t *testing.T) {
+		table := NewHashTable()
+		table.Set("key", "value")
+
+		_, err := table.HSet("key", []string{"field", "value"})
+		require.ErrorIs(t, err, ErrWrongType)
+		_, _, err = table.HGet("key", "field")
+		require.ErrorIs(t, err, ErrWrongType)
+		_, err = table.HDel("key", []string{"field"})
+		require.ErrorIs(t, err, ErrWrongType)
+		_, err = table.HGetAll("key")
+		require.ErrorIs(t, err, ErrWrongType)
+
+		_, err = table.HSet("hash", []string{"field", "value"})
+		require.NoError(t, err)
+		_, _, err = table.Get("hash")
+		require.ErrorIs(t, err, ErrWrongType)
+
+		// set replaces value of any type
+		table.Set("hash", "value")
+		value, found, err := table.Get("hash")
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, "value", value)
+		require.Equal(t, entrySize("key", "value")+entrySize("hash", "value"), table.Bytes())
+	})
+}
@@ -0,0 +1,188 @@
+package storage
+
+// elementOverhead is an approximate size of list element without value
+// data, it is a string header
+const elementOverhead = 16
+
+// deque is a list of values with push and pop at both ends in constant
+// time, values are kept in ring buffer
+type deque struct {
+	items []string
+	head  int
+	size  int
+}
+
+func (d *deque) len() int {
+	return d.size
+}
+
+// at returns value at index from the front
+func (d *deque) at(i int) string {
+	return d.items[(d.head+i)%len(d.items)]
+}
+
+func (d *deque) pushFront(v string) {
+	d.grow()
+	d.head = (d.head - 1 + len(d.items)) % len(d.items)
+	d.items[d.head] = v
+	d.size++
+}
+
+func (d *deque) pushBack(v string) {
+	d.grow()
+	d.items[(d.head+d.size)%len(d.items)] = v
+	d.size++
+}
+
+func (d *deque) popFront() string {
+	v := d.items[d.head]
+	d.items[d.head] = ""
+	d.head = (d.head + 1) % len(d.items)
+	d.size--
+	return v
+}
+
+func (d *deque) popBack() string {
+	i := (d.head + d.size - 1) % len(d.items)
+	v := d.items[i]
+	d.items[i] = ""
+	d.size--
+	return v
+}
+
+// grow doubles buffer if it is full
+func (d *deque) grow() {
+	if d.size < len(d.items) {
+		return
+	}
+
+	items := make([]string, max(2*len(d.items), defaultKeyCount))
+	for i := 0; i < d.size; i++ {
+		items[i] = d.at(i)
+	}
+	d.items = items
+	d.head = 0
+}
+
+// values returns copy of values from start to stop inclusive
+func (d *deque) values(start, stop int) []string {
+	values := make([]string, 0, stop-start+1)
+	for i := start; i <= stop; i++ {
+		values = append(values, d.at(i))
+	}
+	return values
+}
+
+// LPush inserts values at the head of list one by one, so the last value
+// becomes the first, it returns length of list
+func (s *HashTable) LPush(key string, values []string) (int, error) {
+	return s.push(key, values, (*deque).pushFront)
+}
+
+// RPush appends values to the tail of list, it returns length of list
+func (s *HashTable) RPush(key string, values []string) (int, error) {
+	return s.push(key, values, (*deque).pushBack)
+}
+
+func (s *HashTable) push(key string, values []string, push func(*deque, string)) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	v, found := s.data[key]
+	if found && v.kind != TypeList {
+		return 0, ErrWrongType
+	}
+	if !found {
+		v = value{kind: TypeList, list: &deque{}}
+		s.data[key] = v
+		s.bytes += entrySize(key, "")
+	}
+
+	for _, element := range values {
+		push(v.list, element)
+		s.bytes += len(element) + elementOverhead
+	}
+
+	return v.list.len(), nil
+}
+
+// LPop removes and returns the first value of list, list without values
+// is deleted
+func (s *HashTable) LPop(key string) (string, bool, error) {
+	return s.pop(key, (*deque).popFront)
+}
+
+// RPop removes and returns the last value of list, list without values
+// is deleted
+func (s *HashTable) RPop(key string) (string, bool, error) {
+	return s.pop(key, (*deque).popBack)
+}
+
+func (s *HashTable) pop(key string, pop func(*deque) string) (string, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	v, found := s.data[key]
+	if !found {
+		return "", false, nil
+	}
+	if v.kind != TypeList {
+		return "", false, ErrWrongType
+	}
+
+	element := pop(v.list)
+	s.bytes -= len(element) + elementOverhead
+
+	if v.list.len() == 0 {
+		s.remove(key)
+	}
+
+	return element, true, nil
+}
+
+// LRange returns values of list from start to stop inclusive, negative
+// index is an offset from the end of list, indexes out of list are
+// limited by its bounds
+func (s *HashTable) LRange(key string, start, stop int) ([]string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	v, found := s.data[key]
+	if !found {
+		return nil, nil
+	}
+	if v.kind != TypeList {
+		return nil, ErrWrongType
+	}
+
+	length := v.list.len()
+	if start < 0 {
+		start = max(length+start, 0)
+	}
+	if stop < 0 {
+		stop = length + stop
+	}
+	stop = min(stop, length-1)
+
+	if start > stop {
+		return nil, nil
+	}
+
+	return v.list.values(start, stop), nil
+}
+
+// LLen returns length of list, it is zero if key does not exist
+func (s *HashTable) LLen(key string) (int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	v, found := s.data[key]
+	if !found {
+		return 0, nil
+	}
+	if v.kind != TypeList {
+		return 0, ErrWrongType
+	}
+
+	return v.list.len(), nil
+}
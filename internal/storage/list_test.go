@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashTable_List(t *testing.T) {
+	t.Parallel()
+
+	t.Run("push and pop at both ends", func(t *testing.T) {
+		table := NewHashTable()
+
+		length, err := table.LPush("list", []string{"b", "a"})
+		require.NoError(t, err)
+		require.Equal(t, 2, length)
+
+		length, err = table.RPush("list", []string{"c", "d"})
+		require.NoError(t, err)
+		require.Equal(t, 4, length)
+		require.Equal(t, TypeList, table.Type("list"))
+
+		values, err := table.LRange("list", 0, -1)
+		require.NoError(t, err)
+		require.Equal(t, []string{"a", "b", "c", "d"}, values)
+
+		value, found, err := table.LPop("list")
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, "a", value)
+
+		value, found, err = table.RPop("list")
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, "d", value)
+
+		length, err = table.LLen("list")
+		require.NoError(t, err)
+		require.Equal(t, 2, length)
+	})
+
+	t.Run("list without values is deleted", func(t *testing.T) {
+		table := NewHashTable()
+
+		_, err := table.RPush("list", []string{"a"})
+		require.NoError(t, err)
+
+		_, found, err := table.RPop("list")
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, TypeNone, table.Type("list"))
+		require.Equal(t, 0, table.Len())
+		require.Equal(t, 0, table.Bytes())
+
+		_, found, err = table.LPop("list")
+		require.NoError(t, err)
+		require.False(t, found)
+	})
+
+	t.Run("wrong type", func(t *testing.T) {
+		table := NewHashTable()
+		table.Set("key", "value")
+
+		_, err := table.LPush("key", []string{"a"})
+		require.ErrorIs(t, err, ErrWrongType)
+
+		_, _, err = table.LPop("key")
+		require.ErrorIs(t, err, ErrWrongType)
+
+		_, err = table.LRange("key", 0, -1)
+		require.ErrorIs(t, err, ErrWrongType)
+
+		_, err = table.LLen("key")
+		require.ErrorIs(t, err, ErrWrongType)
+	})
+
+	t.Run("bytes", func(t *testing.T) {
+		table := NewHashTable()
+
+		_, err := table.RPush("list", []string{"a", "bc"})
+		require.NoError(t, err)
+		require.Equal(t, entrySize("list", "")+3+2*elementOverhead, table.Bytes())
+
+		// replacing list by string releases its values
+		table.Set("list", "value")
+		require.Equal(t, entrySize("list", "value"), table.Bytes())
+	})
+}
+
+func TestHashTable_LRange(t *testing.T) {
+	t.Parallel()
+
+	table := NewHashTable()
+	_, err := table.RPush("list", []string{"a", "b", "c", "d", "e"})
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		start, stop int
+		expected    []string
+	}{
+		"all":                  {start: 0, stop: -1, expected: []string{"a", "b", "c", "d", "e"}},
+		"middle":               {start: 1, stop: 3, expected: []string{"b", "c", "d"}},
+		"negative":             {start: -2, stop: -1, expected: []string{"d", "e"}},
+		"stop after the end":   {start: 3, stop: 100, expected: []string{"d", "e"}},
+		"start before the end": {start: -100, stop: 0, expected: []string{"a"}},
+		"start after stop":     {start: 3, stop: 1},
+		"start after the end":  {start: 5, stop: 10},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			values, err := table.LRange("list", test.start, test.stop)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, values)
+		})
+	}
+}
+
+func TestDeque(t *testing.T) {
+	t.Parallel()
+
+	var d deque
+	var expected []string
+
+	// buffer grows while head is moved around it
+	for i := 0; i < 20; i++ {
+		value := strconv.Itoa(i)
+		if i%2 == 0 {
+			d.pushFront(value)
+			expected = append([]string{value}, expected...)
+		} else {
+			d.pushBack(value)
+			expected = append(expected, value)
+		}
+
+		if i%3 == 0 {
+			require.Equal(t, expected[0], d.popFront())
+			expected = expected[1:]
+		}
+	}
+
+	require.Equal(t, len(expected), d.len())
+	assert.Equal(t, expected, d.values(0, d.len()-1))
+
+	require.Equal(t, expected[len(expected)-1], d.popBack())
+}
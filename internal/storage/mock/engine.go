@@ -7,6 +7,7 @@ package mock
 import (
 	reflect "reflect"
 
+	storage "concurrency_go_course/internal/storage"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -33,6 +34,20 @@ func (m *MockEngine) EXPECT() *MockEngineMockRecorder {
 	return m.recorder
 }
 
+// Deadline mocks base method.
+func (m *MockEngine) Deadline(key string) int64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Deadline", key)
+	ret0, _ := ret[0].(int64)
+	return ret0
+}
+
+// Deadline indicates an expected call of Deadline.
+func (mr *MockEngineMockRecorder) Deadline(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deadline", reflect.TypeOf((*MockEngine)(nil).Deadline), key)
+}
+
 // Delete mocks base method.
 func (m *MockEngine) Delete(key string) {
 	m.ctrl.T.Helper()
@@ -45,13 +60,56 @@ func (mr *MockEngineMockRecorder) Delete(key interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockEngine)(nil).Delete), key)
 }
 
+// DeleteExpired mocks base method.
+func (m *MockEngine) DeleteExpired(key string, deadline int64) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpired", key, deadline)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// DeleteExpired indicates an expected call of DeleteExpired.
+func (mr *MockEngineMockRecorder) DeleteExpired(key, deadline interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpired", reflect.TypeOf((*MockEngine)(nil).DeleteExpired), key, deadline)
+}
+
+// Expire mocks base method.
+func (m *MockEngine) Expire(key string, deadline int64) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Expire", key, deadline)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Expire indicates an expected call of Expire.
+func (mr *MockEngineMockRecorder) Expire(key, deadline interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Expire", reflect.TypeOf((*MockEngine)(nil).Expire), key, deadline)
+}
+
+// ExpiredKeys mocks base method.
+func (m *MockEngine) ExpiredKeys(now int64, limit int) []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpiredKeys", now, limit)
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// ExpiredKeys indicates an expected call of ExpiredKeys.
+func (mr *MockEngineMockRecorder) ExpiredKeys(now, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpiredKeys", reflect.TypeOf((*MockEngine)(nil).ExpiredKeys), now, limit)
+}
+
 // Get mocks base method.
-func (m *MockEngine) Get(key string) (string, bool) {
+func (m *MockEngine) Get(key string) (string, bool, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Get", key)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(bool)
-	return ret0, ret1
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // Get indicates an expected call of Get.
@@ -60,6 +118,187 @@ func (mr *MockEngineMockRecorder) Get(key interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockEngine)(nil).Get), key)
 }
 
+// HDel mocks base method.
+func (m *MockEngine) HDel(key string, fields []string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HDel", key, fields)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HDel indicates an expected call of HDel.
+func (mr *MockEngineMockRecorder) HDel(key, fields interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HDel", reflect.TypeOf((*MockEngine)(nil).HDel), key, fields)
+}
+
+// HGet mocks base method.
+func (m *MockEngine) HGet(key, field string) (string, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HGet", key, field)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// HGet indicates an expected call of HGet.
+func (mr *MockEngineMockRecorder) HGet(key, field interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HGet", reflect.TypeOf((*MockEngine)(nil).HGet), key, field)
+}
+
+// HGetAll mocks base method.
+func (m *MockEngine) HGetAll(key string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HGetAll", key)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HGetAll indicates an expected call of HGetAll.
+func (mr *MockEngineMockRecorder) HGetAll(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HGetAll", reflect.TypeOf((*MockEngine)(nil).HGetAll), key)
+}
+
+// HSet mocks base method.
+func (m *MockEngine) HSet(key string, fields []string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HSet", key, fields)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HSet indicates an expected call of HSet.
+func (mr *MockEngineMockRecorder) HSet(key, fields interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HSet", reflect.TypeOf((*MockEngine)(nil).HSet), key, fields)
+}
+
+// LLen mocks base method.
+func (m *MockEngine) LLen(key string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LLen", key)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LLen indicates an expected call of LLen.
+func (mr *MockEngineMockRecorder) LLen(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LLen", reflect.TypeOf((*MockEngine)(nil).LLen), key)
+}
+
+// LPop mocks base method.
+func (m *MockEngine) LPop(key string) (string, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LPop", key)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// LPop indicates an expected call of LPop.
+func (mr *MockEngineMockRecorder) LPop(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LPop", reflect.TypeOf((*MockEngine)(nil).LPop), key)
+}
+
+// LPush mocks base method.
+func (m *MockEngine) LPush(key string, values []string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LPush", key, values)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LPush indicates an expected call of LPush.
+func (mr *MockEngineMockRecorder) LPush(key, values interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LPush", reflect.TypeOf((*MockEngine)(nil).LPush), key, values)
+}
+
+// LRange mocks base method.
+func (m *MockEngine) LRange(key string, start, stop int) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LRange", key, start, stop)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LRange indicates an expected call of LRange.
+func (mr *MockEngineMockRecorder) LRange(key, start, stop interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LRange", reflect.TypeOf((*MockEngine)(nil).LRange), key, start, stop)
+}
+
+// Partitions mocks base method.
+func (m *MockEngine) Partitions() []storage.PartitionStats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Partitions")
+	ret0, _ := ret[0].([]storage.PartitionStats)
+	return ret0
+}
+
+// Partitions indicates an expected call of Partitions.
+func (mr *MockEngineMockRecorder) Partitions() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Partitions", reflect.TypeOf((*MockEngine)(nil).Partitions))
+}
+
+// RPop mocks base method.
+func (m *MockEngine) RPop(key string) (string, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RPop", key)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// RPop indicates an expected call of RPop.
+func (mr *MockEngineMockRecorder) RPop(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RPop", reflect.TypeOf((*MockEngine)(nil).RPop), key)
+}
+
+// RPush mocks base method.
+func (m *MockEngine) RPush(key string, values []string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RPush", key, values)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RPush indicates an expected call of RPush.
+func (mr *MockEngineMockRecorder) RPush(key, values interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RPush", reflect.TypeOf((*MockEngine)(nil).RPush), key, values)
+}
+
+// RestoreSnapshot mocks base method.
+func (m *MockEngine) RestoreSnapshot(data []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreSnapshot", data)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestoreSnapshot indicates an expected call of RestoreSnapshot.
+func (mr *MockEngineMockRecorder) RestoreSnapshot(data interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreSnapshot", reflect.TypeOf((*MockEngine)(nil).RestoreSnapshot), data)
+}
+
 // Set mocks base method.
 func (m *MockEngine) Set(key, value string) {
 	m.ctrl.T.Helper()
@@ -71,3 +310,138 @@ func (mr *MockEngineMockRecorder) Set(key, value interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockEngine)(nil).Set), key, value)
 }
+
+// Snapshot mocks base method.
+func (m *MockEngine) Snapshot() ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Snapshot")
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Snapshot indicates an expected call of Snapshot.
+func (mr *MockEngineMockRecorder) Snapshot() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Snapshot", reflect.TypeOf((*MockEngine)(nil).Snapshot))
+}
+
+// Type mocks base method.
+func (m *MockEngine) Type(key string) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Type", key)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// Type indicates an expected call of Type.
+func (mr *MockEngineMockRecorder) Type(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Type", reflect.TypeOf((*MockEngine)(nil).Type), key)
+}
+
+// ZAdd mocks base method.
+func (m *MockEngine) ZAdd(key string, members []storage.ZMember) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ZAdd", key, members)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ZAdd indicates an expected call of ZAdd.
+func (mr *MockEngineMockRecorder) ZAdd(key, members interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ZAdd", reflect.TypeOf((*MockEngine)(nil).ZAdd), key, members)
+}
+
+// ZCard mocks base method.
+func (m *MockEngine) ZCard(key string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ZCard", key)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ZCard indicates an expected call of ZCard.
+func (mr *MockEngineMockRecorder) ZCard(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ZCard", reflect.TypeOf((*MockEngine)(nil).ZCard), key)
+}
+
+// ZRange mocks base method.
+func (m *MockEngine) ZRange(key string, start, stop int) ([]storage.ZMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ZRange", key, start, stop)
+	ret0, _ := ret[0].([]storage.ZMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ZRange indicates an expected call of ZRange.
+func (mr *MockEngineMockRecorder) ZRange(key, start, stop interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ZRange", reflect.TypeOf((*MockEngine)(nil).ZRange), key, start, stop)
+}
+
+// ZRangeByScore mocks base method.
+func (m *MockEngine) ZRangeByScore(key string, r storage.ScoreRange) ([]storage.ZMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ZRangeByScore", key, r)
+	ret0, _ := ret[0].([]storage.ZMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ZRangeByScore indicates an expected call of ZRangeByScore.
+func (mr *MockEngineMockRecorder) ZRangeByScore(key, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ZRangeByScore", reflect.TypeOf((*MockEngine)(nil).ZRangeByScore), key, r)
+}
+
+// ZRem mocks base method.
+func (m *MockEngine) ZRem(key string, members []string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ZRem", key, members)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ZRem indicates an expected call of ZRem.
+func (mr *MockEngineMockRecorder) ZRem(key, members interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ZRem", reflect.TypeOf((*MockEngine)(nil).ZRem), key, members)
+}
+
+// ZRemRangeByScore mocks base method.
+func (m *MockEngine) ZRemRangeByScore(key string, r storage.ScoreRange) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ZRemRangeByScore", key, r)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ZRemRangeByScore indicates an expected call of ZRemRangeByScore.
+func (mr *MockEngineMockRecorder) ZRemRangeByScore(key, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ZRemRangeByScore", reflect.TypeOf((*MockEngine)(nil).ZRemRangeByScore), key, r)
+}
+
+// ZScore mocks base method.
+func (m *MockEngine) ZScore(key, member string) (float64, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ZScore", key, member)
+	ret0, _ := ret[0].(float64)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ZScore indicates an expected call of ZScore.
+func (mr *MockEngineMockRecorder) ZScore(key, member interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ZScore", reflect.TypeOf((*MockEngine)(nil).ZScore), key, member)
+}
@@ -5,8 +5,11 @@
 package mock
 
 import (
+	storage "concurrency_go_course/internal/storage"
 	wal "concurrency_go_course/internal/storage/wal"
+	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -34,27 +37,74 @@ func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
 	return m.recorder
 }
 
+// BLPop mocks base method.
+func (m *MockStorage) BLPop(ctx context.Context, key string, timeout time.Duration) (string, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BLPop", ctx, key, timeout)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// BLPop indicates an expected call of BLPop.
+func (mr *MockStorageMockRecorder) BLPop(ctx, key, timeout interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BLPop", reflect.TypeOf((*MockStorage)(nil).BLPop), ctx, key, timeout)
+}
+
 // Del mocks base method.
-func (m *MockStorage) Del(key string) error {
+func (m *MockStorage) Del(ctx context.Context, key string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Del", key)
+	ret := m.ctrl.Call(m, "Del", ctx, key)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Del indicates an expected call of Del.
-func (mr *MockStorageMockRecorder) Del(key interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) Del(ctx, key interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Del", reflect.TypeOf((*MockStorage)(nil).Del), key)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Del", reflect.TypeOf((*MockStorage)(nil).Del), ctx, key)
+}
+
+// Expire mocks base method.
+func (m *MockStorage) Expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Expire", ctx, key, ttl)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Expire indicates an expected call of Expire.
+func (mr *MockStorageMockRecorder) Expire(ctx, key, ttl interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Expire", reflect.TypeOf((*MockStorage)(nil).Expire), ctx, key, ttl)
+}
+
+// ExpireTime mocks base method.
+func (m *MockStorage) ExpireTime(key string) (time.Time, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireTime", key)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// ExpireTime indicates an expected call of ExpireTime.
+func (mr *MockStorageMockRecorder) ExpireTime(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireTime", reflect.TypeOf((*MockStorage)(nil).ExpireTime), key)
 }
 
 // Get mocks base method.
-func (m *MockStorage) Get(key string) (string, bool) {
+func (m *MockStorage) Get(key string) (string, bool, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Get", key)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(bool)
-	return ret0, ret1
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
 }
 
 // Get indicates an expected call of Get.
@@ -63,6 +113,159 @@ func (mr *MockStorageMockRecorder) Get(key interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockStorage)(nil).Get), key)
 }
 
+// HDel mocks base method.
+func (m *MockStorage) HDel(ctx context.Context, key string, fields []string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HDel", ctx, key, fields)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HDel indicates an expected call of HDel.
+func (mr *MockStorageMockRecorder) HDel(ctx, key, fields interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HDel", reflect.TypeOf((*MockStorage)(nil).HDel), ctx, key, fields)
+}
+
+// HGet mocks base method.
+func (m *MockStorage) HGet(key, field string) (string, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HGet", key, field)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// HGet indicates an expected call of HGet.
+func (mr *MockStorageMockRecorder) HGet(key, field interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HGet", reflect.TypeOf((*MockStorage)(nil).HGet), key, field)
+}
+
+// HGetAll mocks base method.
+func (m *MockStorage) HGetAll(key string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HGetAll", key)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HGetAll indicates an expected call of HGetAll.
+func (mr *MockStorageMockRecorder) HGetAll(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HGetAll", reflect.TypeOf((*MockStorage)(nil).HGetAll), key)
+}
+
+// HSet mocks base method.
+func (m *MockStorage) HSet(ctx context.Context, key string, fields []string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HSet", ctx, key, fields)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HSet indicates an expected call of HSet.
+func (mr *MockStorageMockRecorder) HSet(ctx, key, fields interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HSet", reflect.TypeOf((*MockStorage)(nil).HSet), ctx, key, fields)
+}
+
+// LLen mocks base method.
+func (m *MockStorage) LLen(key string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LLen", key)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LLen indicates an expected call of LLen.
+func (mr *MockStorageMockRecorder) LLen(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LLen", reflect.TypeOf((*MockStorage)(nil).LLen), key)
+}
+
+// LPop mocks base method.
+func (m *MockStorage) LPop(ctx context.Context, key string) (string, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LPop", ctx, key)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// LPop indicates an expected call of LPop.
+func (mr *MockStorageMockRecorder) LPop(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LPop", reflect.TypeOf((*MockStorage)(nil).LPop), ctx, key)
+}
+
+// LPush mocks base method.
+func (m *MockStorage) LPush(ctx context.Context, key string, values []string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LPush", ctx, key, values)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LPush indicates an expected call of LPush.
+func (mr *MockStorageMockRecorder) LPush(ctx, key, values interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LPush", reflect.TypeOf((*MockStorage)(nil).LPush), ctx, key, values)
+}
+
+// LRange mocks base method.
+func (m *MockStorage) LRange(key string, start, stop int) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LRange", key, start, stop)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LRange indicates an expected call of LRange.
+func (mr *MockStorageMockRecorder) LRange(key, start, stop interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LRange", reflect.TypeOf((*MockStorage)(nil).LRange), key, start, stop)
+}
+
+// RPop mocks base method.
+func (m *MockStorage) RPop(ctx context.Context, key string) (string, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RPop", ctx, key)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// RPop indicates an expected call of RPop.
+func (mr *MockStorageMockRecorder) RPop(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RPop", reflect.TypeOf((*MockStorage)(nil).RPop), ctx, key)
+}
+
+// RPush mocks base method.
+func (m *MockStorage) RPush(ctx context.Context, key string, values []string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RPush", ctx, key, values)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RPush indicates an expected call of RPush.
+func (mr *MockStorageMockRecorder) RPush(ctx, key, values interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RPush", reflect.TypeOf((*MockStorage)(nil).RPush), ctx, key, values)
+}
+
 // Restore mocks base method.
 func (m *MockStorage) Restore(requests []wal.Request) {
 	m.ctrl.T.Helper()
@@ -75,18 +278,136 @@ func (mr *MockStorageMockRecorder) Restore(requests interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockStorage)(nil).Restore), requests)
 }
 
+// RunExpiration mocks base method.
+func (m *MockStorage) RunExpiration(ctx context.Context) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "RunExpiration", ctx)
+}
+
+// RunExpiration indicates an expected call of RunExpiration.
+func (mr *MockStorageMockRecorder) RunExpiration(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RunExpiration", reflect.TypeOf((*MockStorage)(nil).RunExpiration), ctx)
+}
+
 // Set mocks base method.
-func (m *MockStorage) Set(key, value string) error {
+func (m *MockStorage) Set(ctx context.Context, key, value string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Set", key, value)
+	ret := m.ctrl.Call(m, "Set", ctx, key, value)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Set indicates an expected call of Set.
-func (mr *MockStorageMockRecorder) Set(key, value interface{}) *gomock.Call {
+func (mr *MockStorageMockRecorder) Set(ctx, key, value interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockStorage)(nil).Set), key, value)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockStorage)(nil).Set), ctx, key, value)
+}
+
+// ZAdd mocks base method.
+func (m *MockStorage) ZAdd(ctx context.Context, key string, members []storage.ZMember) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ZAdd", ctx, key, members)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ZAdd indicates an expected call of ZAdd.
+func (mr *MockStorageMockRecorder) ZAdd(ctx, key, members interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ZAdd", reflect.TypeOf((*MockStorage)(nil).ZAdd), ctx, key, members)
+}
+
+// ZCard mocks base method.
+func (m *MockStorage) ZCard(key string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ZCard", key)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ZCard indicates an expected call of ZCard.
+func (mr *MockStorageMockRecorder) ZCard(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ZCard", reflect.TypeOf((*MockStorage)(nil).ZCard), key)
+}
+
+// ZRange mocks base method.
+func (m *MockStorage) ZRange(key string, start, stop int) ([]storage.ZMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ZRange", key, start, stop)
+	ret0, _ := ret[0].([]storage.ZMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ZRange indicates an expected call of ZRange.
+func (mr *MockStorageMockRecorder) ZRange(key, start, stop interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ZRange", reflect.TypeOf((*MockStorage)(nil).ZRange), key, start, stop)
+}
+
+// ZRangeByScore mocks base method.
+func (m *MockStorage) ZRangeByScore(key string, r storage.ScoreRange) ([]storage.ZMember, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ZRangeByScore", key, r)
+	ret0, _ := ret[0].([]storage.ZMember)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ZRangeByScore indicates an expected call of ZRangeByScore.
+func (mr *MockStorageMockRecorder) ZRangeByScore(key, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ZRangeByScore", reflect.TypeOf((*MockStorage)(nil).ZRangeByScore), key, r)
+}
+
+// ZRem mocks base method.
+func (m *MockStorage) ZRem(ctx context.Context, key string, members []string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ZRem", ctx, key, members)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ZRem indicates an expected call of ZRem.
+func (mr *MockStorageMockRecorder) ZRem(ctx, key, members interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ZRem", reflect.TypeOf((*MockStorage)(nil).ZRem), ctx, key, members)
+}
+
+// ZRemRangeByScore mocks base method.
+func (m *MockStorage) ZRemRangeByScore(ctx context.Context, key string, r storage.ScoreRange) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ZRemRangeByScore", ctx, key, r)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ZRemRangeByScore indicates an expected call of ZRemRangeByScore.
+func (mr *MockStorageMockRecorder) ZRemRangeByScore(ctx, key, r interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ZRemRangeByScore", reflect.TypeOf((*MockStorage)(nil).ZRemRangeByScore), ctx, key, r)
+}
+
+// ZScore mocks base method.
+func (m *MockStorage) ZScore(key, member string) (float64, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ZScore", key, member)
+	ret0, _ := ret[0].(float64)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ZScore indicates an expected call of ZScore.
+func (mr *MockStorageMockRecorder) ZScore(key, member interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ZScore", reflect.TypeOf((*MockStorage)(nil).ZScore), key, member)
 }
 
 // MockWAL is a mock of WAL interface.
@@ -112,18 +433,88 @@ func (m *MockWAL) EXPECT() *MockWALMockRecorder {
 	return m.recorder
 }
 
+// Append mocks base method.
+func (m *MockWAL) Append(arg0 context.Context, arg1 string, arg2 []string) <-chan error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Append", arg0, arg1, arg2)
+	ret0, _ := ret[0].(<-chan error)
+	return ret0
+}
+
+// Append indicates an expected call of Append.
+func (mr *MockWALMockRecorder) Append(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Append", reflect.TypeOf((*MockWAL)(nil).Append), arg0, arg1, arg2)
+}
+
 // Del mocks base method.
-func (m *MockWAL) Del(arg0 string) error {
+func (m *MockWAL) Del(arg0 context.Context, arg1 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Del", arg0)
+	ret := m.ctrl.Call(m, "Del", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Del indicates an expected call of Del.
-func (mr *MockWALMockRecorder) Del(arg0 interface{}) *gomock.Call {
+func (mr *MockWALMockRecorder) Del(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Del", reflect.TypeOf((*MockWAL)(nil).Del), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Del", reflect.TypeOf((*MockWAL)(nil).Del), arg0, arg1)
+}
+
+// Expired mocks base method.
+func (m *MockWAL) Expired(arg0 context.Context, arg1 string, arg2 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Expired", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Expired indicates an expected call of Expired.
+func (mr *MockWALMockRecorder) Expired(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Expired", reflect.TypeOf((*MockWAL)(nil).Expired), arg0, arg1, arg2)
+}
+
+// HDel mocks base method.
+func (m *MockWAL) HDel(arg0 context.Context, arg1 string, arg2 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HDel", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// HDel indicates an expected call of HDel.
+func (mr *MockWALMockRecorder) HDel(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HDel", reflect.TypeOf((*MockWAL)(nil).HDel), arg0, arg1, arg2)
+}
+
+// HSet mocks base method.
+func (m *MockWAL) HSet(arg0 context.Context, arg1 string, arg2 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HSet", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// HSet indicates an expected call of HSet.
+func (mr *MockWALMockRecorder) HSet(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HSet", reflect.TypeOf((*MockWAL)(nil).HSet), arg0, arg1, arg2)
+}
+
+// PExpireAt mocks base method.
+func (m *MockWAL) PExpireAt(arg0 context.Context, arg1 string, arg2 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PExpireAt", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PExpireAt indicates an expected call of PExpireAt.
+func (mr *MockWALMockRecorder) PExpireAt(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PExpireAt", reflect.TypeOf((*MockWAL)(nil).PExpireAt), arg0, arg1, arg2)
 }
 
 // Recover mocks base method.
@@ -142,15 +533,57 @@ func (mr *MockWALMockRecorder) Recover() *gomock.Call {
 }
 
 // Set mocks base method.
-func (m *MockWAL) Set(arg0, arg1 string) error {
+func (m *MockWAL) Set(arg0 context.Context, arg1, arg2 string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Set", arg0, arg1)
+	ret := m.ctrl.Call(m, "Set", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Set indicates an expected call of Set.
-func (mr *MockWALMockRecorder) Set(arg0, arg1 interface{}) *gomock.Call {
+func (mr *MockWALMockRecorder) Set(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockWAL)(nil).Set), arg0, arg1, arg2)
+}
+
+// ZAdd mocks base method.
+func (m *MockWAL) ZAdd(arg0 context.Context, arg1 string, arg2 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ZAdd", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ZAdd indicates an expected call of ZAdd.
+func (mr *MockWALMockRecorder) ZAdd(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ZAdd", reflect.TypeOf((*MockWAL)(nil).ZAdd), arg0, arg1, arg2)
+}
+
+// ZRem mocks base method.
+func (m *MockWAL) ZRem(arg0 context.Context, arg1 string, arg2 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ZRem", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ZRem indicates an expected call of ZRem.
+func (mr *MockWALMockRecorder) ZRem(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ZRem", reflect.TypeOf((*MockWAL)(nil).ZRem), arg0, arg1, arg2)
+}
+
+// ZRemRangeByScore mocks base method.
+func (m *MockWAL) ZRemRangeByScore(arg0 context.Context, arg1, arg2, arg3 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ZRemRangeByScore", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ZRemRangeByScore indicates an expected call of ZRemRangeByScore.
+func (mr *MockWALMockRecorder) ZRemRangeByScore(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockWAL)(nil).Set), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ZRemRangeByScore", reflect.TypeOf((*MockWAL)(nil).ZRemRangeByScore), arg0, arg1, arg2, arg3)
 }
@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"math/rand/v2"
+)
+
+const (
+	skiplistMaxLevel = 32
+	// skiplistP is a probability of node to have the next level
+	skiplistP = 0.25
+)
+
+// skiplist keeps members of sorted set ordered by score and then by
+// member, spans of links give rank of node in logarithmic time
+type skiplist struct {
+	head   *skiplistNode
+	level  int
+	length int
+}
+
+type skiplistNode struct {
+	member string
+	score  float64
+	levels []skiplistLevel
+}
+
+type skiplistLevel struct {
+	forward *skiplistNode
+	// span is a number of nodes between node and forward one, forward
+	// node is counted
+	span int
+}
+
+func newSkiplist() *skiplist {
+	return &skiplist{
+		head:  &skiplistNode{levels: make([]skiplistLevel, skiplistMaxLevel)},
+		level: 1,
+	}
+}
+
+// before reports whether node is ordered before score and member
+func (n *skiplistNode) before(score float64, member string) bool {
+	return n.score < score || (n.score == score && n.member < member)
+}
+
+func randomLevel() int {
+	level := 1
+	for level < skiplistMaxLevel && rand.Float64() < skiplistP {
+		level++
+	}
+	return level
+}
+
+// insert adds member, member must not be in the list
+func (l *skiplist) insert(score float64, member string) {
+	var update [skiplistMaxLevel]*skiplistNode
+	var rank [skiplistMaxLevel]int
+
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		if i != l.level-1 {
+			rank[i] = rank[i+1]
+		}
+
+		for x.levels[i].forward != nil && x.levels[i].forward.before(score, member) {
+			rank[i] += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	level := randomLevel()
+	if level > l.level {
+		for i := l.level; i < level; i++ {
+			update[i] = l.head
+			update[i].levels[i].span = l.length
+		}
+		l.level = level
+	}
+
+	x = &skiplistNode{member: member, score: score, levels: make([]skiplistLevel, level)}
+	for i := 0; i < level; i++ {
+		x.levels[i].forward = update[i].levels[i].forward
+		update[i].levels[i].forward = x
+
+		x.levels[i].span = update[i].levels[i].span - (rank[0] - rank[i])
+		update[i].levels[i].span = rank[0] - rank[i] + 1
+	}
+
+	// links above new node skip it
+	for i := level; i < l.level; i++ {
+		update[i].levels[i].span++
+	}
+
+	l.length++
+}
+
+// delete removes member with score, it returns false if there is no such
+// member
+func (l *skiplist) delete(score float64, member string) bool {
+	var update [skiplistMaxLevel]*skiplistNode
+
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && x.levels[i].forward.before(score, member) {
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	x = x.levels[0].forward
+	if x == nil || x.score != score || x.member != member {
+		return false
+	}
+
+	for i := 0; i < l.level; i++ {
+		if update[i].levels[i].forward == x {
+			update[i].levels[i].span += x.levels[i].span - 1
+			update[i].levels[i].forward = x.levels[i].forward
+		} else {
+			update[i].levels[i].span--
+		}
+	}
+
+	for l.level > 1 && l.head.levels[l.level-1].forward == nil {
+		l.level--
+	}
+	l.length--
+
+	return true
+}
+
+// byRank returns node at zero-based rank, it is nil if rank is out of list
+func (l *skiplist) byRank(rank int) *skiplistNode {
+	if rank < 0 || rank >= l.length {
+		return nil
+	}
+
+	traversed := 0
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && traversed+x.levels[i].span <= rank+1 {
+			traversed += x.levels[i].span
+			x = x.levels[i].forward
+		}
+
+		if traversed == rank+1 {
+			return x
+		}
+	}
+
+	return nil
+}
+
+// firstInRange returns the first node with score in range, it is nil if
+// there is no such node
+func (l *skiplist) firstInRange(r ScoreRange) *skiplistNode {
+	if r.empty() {
+		return nil
+	}
+
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && !r.aboveMin(x.levels[i].forward.score) {
+			x = x.levels[i].forward
+		}
+	}
+
+	x = x.levels[0].forward
+	if x == nil || !r.belowMax(x.score) {
+		return nil
+	}
+
+	return x
+}
+
+// next returns node after node in order
+func (n *skiplistNode) next() *skiplistNode {
+	return n.levels[0].forward
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"cmp"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// skiplistMembers returns members of list in order
+func skiplistMembers(l *skiplist) []ZMember {
+	var members []ZMember
+	for x := l.head.next(); x != nil; x = x.next() {
+		members = append(members, ZMember{Member: x.member, Score: x.score})
+	}
+	return members
+}
+
+func TestSkiplist(t *testing.T) {
+	t.Parallel()
+
+	l := newSkiplist()
+	expected := make(map[string]float64)
+
+	// scores repeat, so members with equal scores are ordered by member
+	for i := 0; i < 500; i++ {
+		member := strconv.Itoa(rand.IntN(200))
+		if score, ok := expected[member]; ok {
+			require.True(t, l.delete(score, member))
+			delete(expected, member)
+			continue
+		}
+
+		score := float64(rand.IntN(20))
+		l.insert(score, member)
+		expected[member] = score
+	}
+
+	sorted := make([]ZMember, 0, len(expected))
+	for member, score := range expected {
+		sorted = append(sorted, ZMember{Member: member, Score: score})
+	}
+	slices.SortFunc(sorted, func(a, b ZMember) int {
+		return cmp.Or(cmp.Compare(a.Score, b.Score), cmp.Compare(a.Member, b.Member))
+	})
+
+	require.Equal(t, len(sorted), l.length)
+	assert.Equal(t, sorted, skiplistMembers(l))
+
+	for rank, member := range sorted {
+		x := l.byRank(rank)
+		require.NotNil(t, x)
+		assert.Equal(t, member, ZMember{Member: x.member, Score: x.score}, rank)
+	}
+	assert.Nil(t, l.byRank(len(sorted)))
+	assert.Nil(t, l.byRank(-1))
+
+	assert.False(t, l.delete(100, "missing"))
+}
+
+func TestSkiplistFirstInRange(t *testing.T) {
+	t.Parallel()
+
+	l := newSkiplist()
+	for i, member := range []string{"a", "b", "c", "d"} {
+		l.insert(float64(i+1), member)
+	}
+
+	tests := map[string]struct {
+		r        ScoreRange
+		expected string
+	}{
+		"inclusive min":      {r: ScoreRange{Min: 2, Max: 4}, expected: "b"},
+		"exclusive min":      {r: ScoreRange{Min: 2, Max: 4, MinExclusive: true}, expected: "c"},
+		"min between scores": {r: ScoreRange{Min: 2.5, Max: 4}, expected: "c"},
+		"max before min":     {r: ScoreRange{Min: 2.5, Max: 2.7}},
+		"exclusive max":      {r: ScoreRange{Min: 2, Max: 2, MaxExclusive: true}},
+		"after the last":     {r: ScoreRange{Min: 5, Max: 10}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			x := l.firstInRange(test.r)
+			if test.expected == "" {
+				assert.Nil(t, x)
+				return
+			}
+
+			require.NotNil(t, x)
+			assert.Equal(t, test.expected, x.member)
+		})
+	}
+}
@@ -1,41 +1,92 @@
 package storage
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"time"
 
 	"concurrency_go_course/internal/compute"
 	"concurrency_go_course/internal/replication"
+	"concurrency_go_course/internal/replication/raft"
 	"concurrency_go_course/internal/storage/wal"
 	"concurrency_go_course/pkg/logger"
+	"concurrency_go_course/pkg/trace"
 
 	"go.uber.org/zap"
 )
 
+// ErrReadOnly is returned for writes sent to slave replica or raft follower
+var ErrReadOnly = errors.New("replica is read-only")
+
 // Storage is interface for storage
 type Storage interface {
-	Set(key, value string) error
-	Get(key string) (string, bool)
-	Del(key string) error
+	Set(ctx context.Context, key, value string) error
+	Get(key string) (string, bool, error)
+	Del(ctx context.Context, key string) error
+	HSet(ctx context.Context, key string, fields []string) (int, error)
+	HGet(key, field string) (string, bool, error)
+	HDel(ctx context.Context, key string, fields []string) (int, error)
+	HGetAll(key string) ([]string, error)
+	LPush(ctx context.Context, key string, values []string) (int, error)
+	RPush(ctx context.Context, key string, values []string) (int, error)
+	LPop(ctx context.Context, key string) (string, bool, error)
+	RPop(ctx context.Context, key string) (string, bool, error)
+	LRange(key string, start, stop int) ([]string, error)
+	LLen(key string) (int, error)
+	BLPop(ctx context.Context, key string, timeout time.Duration) (string, bool, error)
+	ZAdd(ctx context.Context, key string, members []ZMember) (int, error)
+	ZRem(ctx context.Context, key string, members []string) (int, error)
+	ZScore(key, member string) (float64, bool, error)
+	ZRange(key string, start, stop int) ([]ZMember, error)
+	ZRangeByScore(key string, r ScoreRange) ([]ZMember, error)
+	ZRemRangeByScore(ctx context.Context, key string, r ScoreRange) (int, error)
+	ZCard(key string) (int, error)
+	Expire(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	ExpireTime(key string) (time.Time, bool)
+	RunExpiration(ctx context.Context)
 	Restore(requests []wal.Request)
 }
 
 type storage struct {
 	engine            Engine
-	replicationStream chan []wal.Request
-	wal               *wal.WAL
+	replicationStream chan replication.Batch
+	wal               WAL
 	isMasterRepl      bool
+
+	consensus      Consensus
+	proposeTimeout time.Duration
+
+	// lists order changes of lists with their WAL records and keep queues
+	// of clients blocked by BLPOP
+	lists [listShards]listShard
+}
+
+// Consensus is interface for replicated log, changes are applied to engine
+// by consensus state machine, Propose returns result of state machine
+type Consensus interface {
+	Propose(ctx context.Context, request wal.Request) (any, error)
 }
 
 // WAL is interface for write ahead log
 type WAL interface {
-	Set(string, string) error
-	Del(string) error
+	Set(context.Context, string, string) error
+	Del(context.Context, string) error
+	HSet(context.Context, string, []string) error
+	HDel(context.Context, string, []string) error
+	ZAdd(context.Context, string, []string) error
+	ZRem(context.Context, string, []string) error
+	ZRemRangeByScore(context.Context, string, string, string) error
+	PExpireAt(context.Context, string, int64) error
+	Expired(context.Context, string, int64) error
+	Append(context.Context, string, []string) <-chan error
 	Recover() ([]wal.Request, error)
 }
 
 // New creates new storage
 func New(engine Engine, wal *wal.WAL,
-	replicationType string, replStream chan []wal.Request,
+	replicationType string, replStream chan replication.Batch,
 ) (Storage, error) {
 	if engine == nil {
 		return nil, fmt.Errorf("unable to create storage: engine is empty")
@@ -43,12 +94,13 @@ func New(engine Engine, wal *wal.WAL,
 
 	stor := &storage{
 		engine:            engine,
-		wal:               wal,
 		replicationStream: replStream,
 		isMasterRepl:      replicationType == replication.ReplicaTypeMaster,
 	}
 
 	if wal != nil {
+		stor.wal = wal
+
 		requests, err := stor.wal.Recover()
 		if err != nil {
 			logger.ErrorWithMsg("unable to get requests from WAL", err)
@@ -59,9 +111,10 @@ func New(engine Engine, wal *wal.WAL,
 
 	if replStream != nil {
 		go func() {
-			for request := range replStream {
+			for batch := range replStream {
 				logger.Debug("applying request from replication stream")
-				stor.Restore(request)
+				stor.Restore(batch.Requests)
+				batch.Ack()
 			}
 		}()
 	}
@@ -69,55 +122,339 @@ func New(engine Engine, wal *wal.WAL,
 	return stor, nil
 }
 
+// NewConsensus creates new storage which writes changes through consensus log
+func NewConsensus(engine Engine, consensus Consensus, proposeTimeout time.Duration) (Storage, error) {
+	if engine == nil {
+		return nil, fmt.Errorf("unable to create storage: engine is empty")
+	}
+
+	if consensus == nil {
+		return nil, fmt.Errorf("unable to create storage: consensus is empty")
+	}
+
+	return &storage{
+		engine:         engine,
+		consensus:      consensus,
+		proposeTimeout: proposeTimeout,
+	}, nil
+}
+
 // Set sets new value
-func (s *storage) Set(key, value string) error {
+func (s *storage) Set(ctx context.Context, key, value string) (err error) {
+	ctx, span := trace.Start(ctx, "storage.Set")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if s.consensus != nil {
+		_, err := s.propose(ctx, compute.CommandSet, []string{key, value})
+		return err
+	}
+
 	if !s.isMasterRepl {
-		return fmt.Errorf("unable to execute set command on slave")
+		return fmt.Errorf("unable to execute set command on slave: %w", ErrReadOnly)
 	}
 
 	if s.wal != nil {
-		err := s.wal.Set(key, value)
+		err := s.wal.Set(ctx, key, value)
 		if err != nil {
 			return err
 		}
 	}
 
+	_, engineSpan := trace.Start(ctx, "engine.Set")
 	s.engine.Set(key, value)
+	engineSpan.End()
+
 	return nil
 }
 
 // Get returns value by key
-func (s *storage) Get(key string) (string, bool) {
+func (s *storage) Get(key string) (string, bool, error) {
+	if s.expired(key) {
+		return "", false, nil
+	}
+
 	return s.engine.Get(key)
 }
 
 // Del deletes key
-func (s *storage) Del(key string) error {
+func (s *storage) Del(ctx context.Context, key string) (err error) {
+	ctx, span := trace.Start(ctx, "storage.Del")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if s.consensus != nil {
+		_, err := s.propose(ctx, compute.CommandDelete, []string{key})
+		return err
+	}
+
 	if !s.isMasterRepl {
-		return fmt.Errorf("unable to execute delete command on slave")
+		return fmt.Errorf("unable to execute delete command on slave: %w", ErrReadOnly)
 	}
 
 	if s.wal != nil {
-		if err := s.wal.Del(key); err != nil {
+		if err := s.wal.Del(ctx, key); err != nil {
 			return err
 		}
 	}
 
+	_, engineSpan := trace.Start(ctx, "engine.Delete")
 	s.engine.Delete(key)
+	engineSpan.End()
+
+	return nil
+}
+
+// HSet sets fields of hash, fields are pairs of field and value, it
+// returns number of added fields
+func (s *storage) HSet(ctx context.Context, key string, fields []string) (added int, err error) {
+	ctx, span := trace.Start(ctx, "storage.HSet")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if err := s.expireIfNeeded(ctx, key); err != nil {
+		return 0, err
+	}
+
+	if err := s.checkType(key, TypeHash); err != nil {
+		return 0, err
+	}
+
+	if s.consensus != nil {
+		result, err := s.propose(ctx, compute.CommandHSet, append([]string{key}, fields...))
+		return result.count, err
+	}
+
+	if !s.isMasterRepl {
+		return 0, fmt.Errorf("unable to execute hset command on slave: %w", ErrReadOnly)
+	}
+
+	if s.wal != nil {
+		if err := s.wal.HSet(ctx, key, fields); err != nil {
+			return 0, err
+		}
+	}
+
+	_, engineSpan := trace.Start(ctx, "engine.HSet")
+	defer engineSpan.End()
+
+	return s.engine.HSet(key, fields)
+}
+
+// HGet returns value of hash field
+func (s *storage) HGet(key, field string) (string, bool, error) {
+	if s.expired(key) {
+		return "", false, nil
+	}
+
+	return s.engine.HGet(key, field)
+}
+
+// HDel deletes fields of hash, it returns number of deleted fields
+func (s *storage) HDel(ctx context.Context, key string, fields []string) (deleted int, err error) {
+	ctx, span := trace.Start(ctx, "storage.HDel")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if err := s.expireIfNeeded(ctx, key); err != nil {
+		return 0, err
+	}
+
+	if err := s.checkType(key, TypeHash); err != nil {
+		return 0, err
+	}
+
+	if s.consensus != nil {
+		result, err := s.propose(ctx, compute.CommandHDel, append([]string{key}, fields...))
+		return result.count, err
+	}
+
+	if !s.isMasterRepl {
+		return 0, fmt.Errorf("unable to execute hdel command on slave: %w", ErrReadOnly)
+	}
+
+	if s.wal != nil {
+		if err := s.wal.HDel(ctx, key, fields); err != nil {
+			return 0, err
+		}
+	}
+
+	_, engineSpan := trace.Start(ctx, "engine.HDel")
+	defer engineSpan.End()
+
+	return s.engine.HDel(key, fields)
+}
+
+// HGetAll returns pairs of field and value of hash sorted by field
+func (s *storage) HGetAll(key string) ([]string, error) {
+	if s.expired(key) {
+		return nil, nil
+	}
+
+	return s.engine.HGetAll(key)
+}
+
+// checkType returns ErrWrongType if key holds value of another type, so
+// command failing in engine is not written to WAL
+func (s *storage) checkType(key, kind string) error {
+	if current := s.engine.Type(key); current != TypeNone && current != kind {
+		return ErrWrongType
+	}
+
 	return nil
 }
 
 // Restore restores WAL settings
 func (s *storage) Restore(requests []wal.Request) {
 	for _, request := range requests {
-		switch request.Command {
-		case compute.CommandSet:
-			s.engine.Set(request.Args[0], request.Args[1])
-			logger.Debug("Was restored", zap.String("key", request.Args[0]),
-				zap.String("value", request.Args[1]))
-		case compute.CommandDelete:
-			s.engine.Delete(request.Args[0])
-			logger.Debug("Was deleted", zap.String("key", request.Args[0]))
+		applyRequest(s.engine, request)
+	}
+}
+
+// propose replicates change through consensus log, it returns result of
+// change applied by state machine
+func (s *storage) propose(ctx context.Context, cmd string, args []string) (applyResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.proposeTimeout)
+	defer cancel()
+
+	value, err := s.consensus.Propose(ctx, wal.Request{Command: cmd, Args: args})
+
+	var notLeader *raft.NotLeaderError
+	if errors.As(err, &notLeader) {
+		return applyResult{}, fmt.Errorf("%w: %w", ErrReadOnly, err)
+	}
+
+	if err != nil {
+		return applyResult{}, err
+	}
+
+	result, _ := value.(applyResult)
+	return result, result.err
+}
+
+// applyResult is a result of request applied to engine, count is a number
+// of changed elements or length of list, value and found are a popped list
+// value or flag of existing key
+type applyResult struct {
+	count int
+	value string
+	found bool
+	err   error
+}
+
+func applyRequest(engine Engine, request wal.Request) (result applyResult) {
+	switch request.Command {
+	case compute.CommandSet:
+		engine.Set(request.Args[0], request.Args[1])
+		logger.Debug("Was restored", zap.String("key", request.Args[0]),
+			zap.String("value", request.Args[1]))
+	case compute.CommandDelete:
+		engine.Delete(request.Args[0])
+		logger.Debug("Was deleted", zap.String("key", request.Args[0]))
+	case compute.CommandHSet:
+		if result.count, result.err = engine.HSet(request.Args[0], request.Args[1:]); result.err != nil {
+			logger.Error("unable to restore hash fields", zap.String("key", request.Args[0]), zap.Error(result.err))
+		}
+	case compute.CommandHDel:
+		if result.count, result.err = engine.HDel(request.Args[0], request.Args[1:]); result.err != nil {
+			logger.Error("unable to restore deletion of hash fields", zap.String("key", request.Args[0]),
+				zap.Error(result.err))
+		}
+	case compute.CommandLPush:
+		if result.count, result.err = engine.LPush(request.Args[0], request.Args[1:]); result.err != nil {
+			logger.Error("unable to restore list values", zap.String("key", request.Args[0]), zap.Error(result.err))
+		}
+	case compute.CommandRPush:
+		if result.count, result.err = engine.RPush(request.Args[0], request.Args[1:]); result.err != nil {
+			logger.Error("unable to restore list values", zap.String("key", request.Args[0]), zap.Error(result.err))
+		}
+	case compute.CommandLPop:
+		if result.value, result.found, result.err = engine.LPop(request.Args[0]); result.err != nil {
+			logger.Error("unable to restore pop of list value", zap.String("key", request.Args[0]),
+				zap.Error(result.err))
+		}
+	case compute.CommandRPop:
+		if result.value, result.found, result.err = engine.RPop(request.Args[0]); result.err != nil {
+			logger.Error("unable to restore pop of list value", zap.String("key", request.Args[0]),
+				zap.Error(result.err))
+		}
+	case compute.CommandZAdd:
+		var members []ZMember
+		members, result.err = ParseZMembers(request.Args[1:])
+		if result.err == nil {
+			result.count, result.err = engine.ZAdd(request.Args[0], members)
+		}
+		if result.err != nil {
+			logger.Error("unable to restore sorted set members", zap.String("key", request.Args[0]),
+				zap.Error(result.err))
+		}
+	case compute.CommandZRem:
+		if result.count, result.err = engine.ZRem(request.Args[0], request.Args[1:]); result.err != nil {
+			logger.Error("unable to restore removal of sorted set members", zap.String("key", request.Args[0]),
+				zap.Error(result.err))
+		}
+	case compute.CommandZRemRangeByScore:
+		var r ScoreRange
+		r, result.err = NewScoreRange(request.Args[1], request.Args[2])
+		if result.err == nil {
+			result.count, result.err = engine.ZRemRangeByScore(request.Args[0], r)
+		}
+		if result.err != nil {
+			logger.Error("unable to restore removal of sorted set members", zap.String("key", request.Args[0]),
+				zap.Error(result.err))
 		}
+	case compute.CommandPExpireAt:
+		var deadline int64
+		deadline, result.err = strconv.ParseInt(request.Args[1], 10, 64)
+		if result.err != nil {
+			logger.Error("unable to restore expiration of key", zap.String("key", request.Args[0]),
+				zap.Error(result.err))
+			return result
+		}
+		result.found = engine.Expire(request.Args[0], deadline)
+	case compute.CommandExpired:
+		var deadline int64
+		deadline, result.err = strconv.ParseInt(request.Args[1], 10, 64)
+		if result.err != nil {
+			logger.Error("unable to restore deletion of expired key", zap.String("key", request.Args[0]),
+				zap.Error(result.err))
+			return result
+		}
+		engine.DeleteExpired(request.Args[0], deadline)
 	}
+
+	return result
+}
+
+// StateMachine applies committed consensus log entries to engine
+type StateMachine struct {
+	engine Engine
+}
+
+// NewStateMachine returns new state machine over engine
+func NewStateMachine(engine Engine) *StateMachine {
+	return &StateMachine{engine: engine}
+}
+
+// Apply applies request to engine, it returns result of change
+func (m *StateMachine) Apply(request wal.Request) any {
+	return applyRequest(m.engine, request)
+}
+
+// Snapshot returns engine snapshot
+func (m *StateMachine) Snapshot() ([]byte, error) {
+	return m.engine.Snapshot()
+}
+
+// Restore replaces engine data with snapshot
+func (m *StateMachine) Restore(data []byte) error {
+	return m.engine.RestoreSnapshot(data)
 }
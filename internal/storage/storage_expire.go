@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"concurrency_go_course/internal/compute"
+	"concurrency_go_course/pkg/logger"
+	"concurrency_go_course/pkg/trace"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// expirationInterval is a period of deletion of expired keys which are
+	// not accessed by clients
+	expirationInterval = 100 * time.Millisecond
+	// expirationBatch is a maximum number of keys deleted in one period
+	expirationBatch = 100
+)
+
+// Expire sets time to live of key, it returns false if key does not exist.
+// Deadline is removed when value of key is replaced by SET or key is deleted
+func (s *storage) Expire(ctx context.Context, key string, ttl time.Duration) (ok bool, err error) {
+	ctx, span := trace.Start(ctx, "storage.Expire")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if err := s.expireIfNeeded(ctx, key); err != nil {
+		return false, err
+	}
+
+	if s.engine.Type(key) == TypeNone {
+		return false, nil
+	}
+
+	deadline := time.Now().Add(ttl).UnixMilli()
+
+	if s.consensus != nil {
+		_, err := s.propose(ctx, compute.CommandPExpireAt, []string{key, strconv.FormatInt(deadline, 10)})
+		return true, err
+	}
+
+	if !s.isMasterRepl {
+		return false, fmt.Errorf("unable to execute expire command on slave: %w", ErrReadOnly)
+	}
+
+	if s.wal != nil {
+		if err := s.wal.PExpireAt(ctx, key, deadline); err != nil {
+			return false, err
+		}
+	}
+
+	return s.engine.Expire(key, deadline), nil
+}
+
+// ExpireTime returns expiration time of key, it is zero if key does not
+// expire, key is not found if it does not exist or is expired
+func (s *storage) ExpireTime(key string) (time.Time, bool) {
+	if s.engine.Type(key) == TypeNone || s.expired(key) {
+		return time.Time{}, false
+	}
+
+	deadline := s.engine.Deadline(key)
+	if deadline == 0 {
+		return time.Time{}, true
+	}
+
+	return time.UnixMilli(deadline), true
+}
+
+// RunExpiration deletes expired keys which are not accessed by clients
+// until context is done. Slave replica receives deletions from master
+func (s *storage) RunExpiration(ctx context.Context) {
+	if s.consensus == nil && !s.isMasterRepl {
+		return
+	}
+
+	ticker := time.NewTicker(expirationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.expireKeys(ctx)
+		}
+	}
+}
+
+// expireKeys deletes batch of expired keys, deletions are written to WAL
+// together and applied after their batch is written
+func (s *storage) expireKeys(ctx context.Context) {
+	now := time.Now().UnixMilli()
+	keys := s.engine.ExpiredKeys(now, expirationBatch)
+
+	if s.consensus != nil {
+		for _, key := range keys {
+			if err := s.expireIfNeeded(ctx, key); err != nil {
+				logExpirationError(ctx, key, err)
+				return
+			}
+		}
+		return
+	}
+
+	type expiration struct {
+		key      string
+		deadline int64
+		done     <-chan error
+	}
+
+	expirations := make([]expiration, 0, len(keys))
+	for _, key := range keys {
+		deadline := s.engine.Deadline(key)
+		if deadline == 0 || deadline > now {
+			continue
+		}
+
+		done := s.appendWAL(ctx, compute.CommandExpired, []string{key, strconv.FormatInt(deadline, 10)})
+		expirations = append(expirations, expiration{key: key, deadline: deadline, done: done})
+	}
+
+	for _, e := range expirations {
+		if err := wait(e.done); err != nil {
+			logExpirationError(ctx, e.key, err)
+			continue
+		}
+
+		s.engine.DeleteExpired(e.key, e.deadline)
+	}
+}
+
+// expireIfNeeded deletes key if it is expired, so commands do not see
+// expired value. Slave replica keeps expired keys until deletion is
+// received from master, they are hidden from reads
+func (s *storage) expireIfNeeded(ctx context.Context, key string) error {
+	if s.consensus == nil && !s.isMasterRepl {
+		return nil
+	}
+
+	deadline := s.engine.Deadline(key)
+	if deadline == 0 || deadline > time.Now().UnixMilli() {
+		return nil
+	}
+
+	args := []string{key, strconv.FormatInt(deadline, 10)}
+	if s.consensus != nil {
+		_, err := s.propose(ctx, compute.CommandExpired, args)
+		return err
+	}
+
+	if s.wal != nil {
+		if err := s.wal.Expired(ctx, key, deadline); err != nil {
+			return err
+		}
+	}
+
+	s.engine.DeleteExpired(key, deadline)
+	return nil
+}
+
+// expired returns true if deadline of key is passed
+func (s *storage) expired(key string) bool {
+	deadline := s.engine.Deadline(key)
+	return deadline != 0 && deadline <= time.Now().UnixMilli()
+}
+
+func logExpirationError(ctx context.Context, key string, err error) {
+	if errors.Is(err, ErrReadOnly) || ctx.Err() != nil {
+		return
+	}
+
+	logger.Error("unable to delete expired key", zap.String("key", key), zap.Error(err))
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"concurrency_go_course/internal/compute"
+	"concurrency_go_course/internal/storage/wal"
+	"concurrency_go_course/pkg/logger"
+)
+
+func TestStorageExpire(t *testing.T) {
+	logger.MockLogger()
+
+	stor := newListStorage(t, "master")
+	past := time.Now().Add(-time.Second).UnixMilli()
+
+	ok, err := stor.Expire(context.Background(), "key", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, stor.Set(context.Background(), "key", "value"))
+	_, ok = stor.ExpireTime("key")
+	assert.True(t, ok)
+
+	ok, err = stor.Expire(context.Background(), "key", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	deadline, ok := stor.ExpireTime("key")
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+
+	t.Run("expired key is hidden from reads", func(t *testing.T) {
+		_, err := stor.ZAdd(context.Background(), "board", []ZMember{{"alice", 1}})
+		require.NoError(t, err)
+		require.True(t, stor.engine.Expire("board", past))
+
+		count, err := stor.ZCard("board")
+		require.NoError(t, err)
+		assert.Zero(t, count)
+
+		_, ok := stor.ExpireTime("board")
+		assert.False(t, ok)
+	})
+
+	t.Run("expired key is deleted before write", func(t *testing.T) {
+		require.NoError(t, stor.Set(context.Background(), "user", "alice"))
+		require.True(t, stor.engine.Expire("user", past))
+
+		added, err := stor.HSet(context.Background(), "user", []string{"name", "alice"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, added)
+		assert.Zero(t, stor.engine.Deadline("user"))
+
+		length, err := stor.RPush(context.Background(), "jobs", []string{"a"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, length)
+		require.True(t, stor.engine.Expire("jobs", past))
+
+		length, err = stor.RPush(context.Background(), "jobs", []string{"b"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, length)
+	})
+
+	t.Run("expired keys are deleted in background", func(t *testing.T) {
+		require.NoError(t, stor.Set(context.Background(), "session", "token"))
+		require.True(t, stor.engine.Expire("session", past))
+
+		stor.expireKeys(context.Background())
+
+		assert.Equal(t, TypeNone, stor.engine.Type("session"))
+		assert.Equal(t, TypeString, stor.engine.Type("key"))
+	})
+
+	t.Run("slave", func(t *testing.T) {
+		slave := newListStorage(t, "slave")
+		slave.Restore([]wal.Request{
+			{Command: compute.CommandSet, Args: []string{"key", "value"}},
+			{Command: compute.CommandPExpireAt, Args: []string{"key", strconv.FormatInt(past, 10)}},
+		})
+
+		_, ok, err := slave.Get("key")
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		// expired key is kept until deletion is received from master
+		slave.RunExpiration(context.Background())
+		assert.Equal(t, TypeString, slave.engine.Type("key"))
+
+		slave.Restore([]wal.Request{
+			{Command: compute.CommandExpired, Args: []string{"key", strconv.FormatInt(past, 10)}},
+		})
+		assert.Equal(t, TypeNone, slave.engine.Type("key"))
+
+		slave.Restore([]wal.Request{{Command: compute.CommandSet, Args: []string{"key", "value"}}})
+		_, err = slave.Expire(context.Background(), "key", time.Minute)
+		assert.ErrorIs(t, err, ErrReadOnly)
+	})
+}
+
+func TestStorageExpireWAL(t *testing.T) {
+	logger.MockLogger()
+
+	cfg := walConfig(t)
+	walObj := startWAL(t, cfg)
+
+	created, err := New(NewEngine(4), walObj, "master", nil)
+	require.NoError(t, err)
+	stor := created.(*storage)
+
+	require.NoError(t, stor.Set(context.Background(), "key", "value"))
+	require.NoError(t, stor.Set(context.Background(), "session", "token"))
+
+	_, err = stor.Expire(context.Background(), "key", time.Hour)
+	require.NoError(t, err)
+	_, err = stor.Expire(context.Background(), "session", time.Millisecond)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		stor.expireKeys(context.Background())
+		return stor.engine.Type("session") == TypeNone
+	}, time.Second, 10*time.Millisecond)
+
+	recovered, err := wal.New(cfg)
+	require.NoError(t, err)
+
+	requests, err := recovered.Recover()
+	require.NoError(t, err)
+
+	restored := newListStorage(t, "master")
+	restored.Restore(requests)
+
+	assert.Equal(t, TypeNone, restored.engine.Type("session"))
+	assert.Equal(t, stor.engine.Deadline("key"), restored.engine.Deadline("key"))
+}
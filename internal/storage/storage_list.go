@@ -0,0 +1,433 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"concurrency_go_course/internal/compute"
+	"concurrency_go_course/internal/storage/wal"
+	"concurrency_go_course/pkg/metrics"
+	"concurrency_go_course/pkg/trace"
+)
+
+var blockedClients = metrics.Register(metrics.NewGaugeVec("storage_blocked_clients",
+	"Number of clients blocked by BLPOP."))
+
+// listShards is a number of locks of list keys, changes of lists in
+// different shards do not wait for each other
+const listShards = 64
+
+// listShard guards state of lists with keys of the shard
+type listShard struct {
+	mutex sync.Mutex
+	keys  map[string]*listState
+}
+
+// listState is a state of list key: changes waiting for their WAL records,
+// number of pops which are not applied yet and clients blocked by BLPOP
+type listState struct {
+	pending *list.List
+	pops    int
+	waiters *list.List
+}
+
+// listChange is a change of list. Changes of WAL storage are applied to
+// engine in order of their records after the records are written, so
+// lock of key is not held while WAL is flushed
+type listChange struct {
+	request wal.Request
+	done    <-chan error
+	written bool
+	err     error
+	result  chan applyResult
+
+	// waiter is queued if pop finds no value
+	waiter *popWaiter
+}
+
+// popWaiter is a client blocked by BLPOP, it receives pop made for it
+type popWaiter struct {
+	change  chan *listChange
+	element *list.Element
+}
+
+// LPush inserts values at the head of list, it returns length of list
+func (s *storage) LPush(ctx context.Context, key string, values []string) (int, error) {
+	return s.push(ctx, compute.CommandLPush, key, values)
+}
+
+// RPush appends values to the tail of list, it returns length of list
+func (s *storage) RPush(ctx context.Context, key string, values []string) (int, error) {
+	return s.push(ctx, compute.CommandRPush, key, values)
+}
+
+// LPop removes and returns the first value of list
+func (s *storage) LPop(ctx context.Context, key string) (string, bool, error) {
+	return s.pop(ctx, compute.CommandLPop, key)
+}
+
+// RPop removes and returns the last value of list
+func (s *storage) RPop(ctx context.Context, key string) (string, bool, error) {
+	return s.pop(ctx, compute.CommandRPop, key)
+}
+
+// LRange returns values of list from start to stop inclusive
+func (s *storage) LRange(key string, start, stop int) ([]string, error) {
+	if s.expired(key) {
+		return nil, nil
+	}
+
+	return s.engine.LRange(key, start, stop)
+}
+
+// LLen returns length of list
+func (s *storage) LLen(key string) (int, error) {
+	if s.expired(key) {
+		return 0, nil
+	}
+
+	return s.engine.LLen(key)
+}
+
+// BLPop removes and returns the first value of list, if list is empty it
+// waits for value pushed by another client up to timeout, zero timeout
+// means waiting without limit. Clients waiting for the same key are served
+// in order of arrival. Value is not found if timeout is expired, waiting is
+// stopped with error if context is done
+func (s *storage) BLPop(ctx context.Context, key string, timeout time.Duration) (value string, found bool, err error) {
+	ctx, span := trace.Start(ctx, "storage.BLPop")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if err := s.checkWrite(ctx, compute.CommandBLPop, key); err != nil {
+		return "", false, err
+	}
+
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	waiter := &popWaiter{change: make(chan *listChange, 1)}
+
+	shard := s.listShard(key)
+	shard.mutex.Lock()
+	state := shard.stateLocked(key)
+
+	var change *listChange
+	if state.waiters.Len() == 0 && s.canPopLocked(key, state) {
+		change = newListChange(compute.CommandLPop, key, nil)
+		change.waiter = waiter
+		s.startLocked(ctx, state, change)
+	} else {
+		waiter.element = state.waiters.PushBack(waiter)
+	}
+	shard.mutex.Unlock()
+
+	if change != nil {
+		result := s.finish(ctx, key, change)
+		if result.err != nil || result.found {
+			return result.value, result.found, result.err
+		}
+	}
+
+	blockedClients.WithLabelValues().Inc()
+	defer blockedClients.WithLabelValues().Dec()
+
+	for {
+		change, err := s.waitServed(ctx, key, waiter, deadline)
+		if change == nil {
+			return "", false, err
+		}
+
+		// value is taken by pop of another client if list was deleted
+		// before pop of waiter, then waiter is queued again
+		result := s.finish(context.WithoutCancel(ctx), key, change)
+		if result.err != nil || result.found {
+			return result.value, result.found, result.err
+		}
+	}
+}
+
+func (s *storage) push(ctx context.Context, cmd, key string, values []string) (length int, err error) {
+	ctx, span := trace.Start(ctx, "storage."+cmd)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if err := s.checkWrite(ctx, cmd, key); err != nil {
+		return 0, err
+	}
+
+	change := newListChange(cmd, key, values)
+	if s.consensus == nil {
+		shard := s.listShard(key)
+		shard.mutex.Lock()
+		s.startLocked(ctx, shard.stateLocked(key), change)
+		shard.mutex.Unlock()
+	}
+
+	result := s.finish(ctx, key, change)
+	if result.err != nil {
+		return 0, result.err
+	}
+
+	return result.count, nil
+}
+
+func (s *storage) pop(ctx context.Context, cmd, key string) (value string, found bool, err error) {
+	ctx, span := trace.Start(ctx, "storage."+cmd)
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if err := s.checkWrite(ctx, cmd, key); err != nil {
+		return "", false, err
+	}
+
+	shard := s.listShard(key)
+	shard.mutex.Lock()
+	state := shard.stateLocked(key)
+	if !s.canPopLocked(key, state) {
+		shard.releaseLocked(key, state)
+		shard.mutex.Unlock()
+		return "", false, nil
+	}
+
+	change := newListChange(cmd, key, nil)
+	s.startLocked(ctx, state, change)
+	shard.mutex.Unlock()
+
+	result := s.finish(ctx, key, change)
+	return result.value, result.found, result.err
+}
+
+// checkWrite returns error if list command can not change key, expired
+// key is deleted before the change
+func (s *storage) checkWrite(ctx context.Context, cmd, key string) error {
+	if s.consensus == nil && !s.isMasterRepl {
+		return fmt.Errorf("unable to execute %s command on slave: %w", strings.ToLower(cmd), ErrReadOnly)
+	}
+
+	if err := s.expireIfNeeded(ctx, key); err != nil {
+		return err
+	}
+
+	return s.checkType(key, TypeList)
+}
+
+func newListChange(cmd, key string, values []string) *listChange {
+	return &listChange{
+		request: wal.Request{Command: cmd, Args: append([]string{key}, values...)},
+		result:  make(chan applyResult, 1),
+	}
+}
+
+// canPopLocked returns false if pop would find no value, so it is not
+// written to WAL. Values of pops which are not applied yet are not
+// counted, changes waiting for WAL may push new values
+func (s *storage) canPopLocked(key string, state *listState) bool {
+	length, _ := s.engine.LLen(key)
+	return length > state.pops || state.pending.Len() != 0
+}
+
+// startLocked starts change of list, shard mutex must be locked. Change of
+// WAL storage is appended to WAL and queued until its record is written
+func (s *storage) startLocked(ctx context.Context, state *listState, change *listChange) {
+	if isPop(change.request.Command) {
+		state.pops++
+	}
+
+	if s.consensus != nil {
+		return
+	}
+
+	change.done = s.appendWAL(ctx, change.request.Command, change.request.Args)
+	state.pending.PushBack(change)
+}
+
+// finish waits for WAL record of change or proposes it and returns result
+// of change applied to engine
+func (s *storage) finish(ctx context.Context, key string, change *listChange) applyResult {
+	shard := s.listShard(key)
+
+	if s.consensus != nil {
+		// change is applied by state machine, it returns popped value
+		result, err := s.propose(ctx, change.request.Command, change.request.Args)
+		if err != nil {
+			result = applyResult{err: err}
+		}
+
+		shard.mutex.Lock()
+		defer shard.mutex.Unlock()
+
+		state := shard.stateLocked(key)
+		defer shard.releaseLocked(key, state)
+
+		return s.appliedLocked(ctx, key, state, change, result)
+	}
+
+	err := wait(change.done)
+
+	shard.mutex.Lock()
+	state := shard.stateLocked(key)
+	change.written, change.err = true, err
+	s.applyWrittenLocked(ctx, key, state)
+	shard.releaseLocked(key, state)
+	shard.mutex.Unlock()
+
+	return <-change.result
+}
+
+// applyWrittenLocked applies changes from the head of queue whose WAL
+// records are written, shard mutex must be locked
+func (s *storage) applyWrittenLocked(ctx context.Context, key string, state *listState) {
+	for state.pending.Len() != 0 {
+		change := state.pending.Front().Value.(*listChange)
+		if !change.written {
+			return
+		}
+		state.pending.Remove(state.pending.Front())
+
+		result := applyResult{err: change.err}
+		if change.err == nil {
+			result = applyRequest(s.engine, change.request)
+		}
+
+		change.result <- s.appliedLocked(ctx, key, state, change, result)
+	}
+}
+
+// appliedLocked queues waiter of pop which found no value and serves
+// blocked clients after change is applied or failed, shard mutex must be
+// locked
+func (s *storage) appliedLocked(ctx context.Context, key string, state *listState,
+	change *listChange, result applyResult,
+) applyResult {
+	if isPop(change.request.Command) {
+		state.pops--
+
+		if result.err == nil && !result.found && change.waiter != nil {
+			change.waiter.element = state.waiters.PushBack(change.waiter)
+		}
+	}
+
+	s.serveWaitersLocked(ctx, key, state)
+
+	return result
+}
+
+// serveWaitersLocked starts pops for clients blocked on key in order of
+// their arrival, shard mutex must be locked
+func (s *storage) serveWaitersLocked(ctx context.Context, key string, state *listState) {
+	length, err := s.engine.LLen(key)
+	if err != nil {
+		return
+	}
+
+	for available := length - state.pops; available > 0 && state.waiters.Len() != 0; available-- {
+		waiter := state.waiters.Remove(state.waiters.Front()).(*popWaiter)
+		waiter.element = nil
+
+		change := newListChange(compute.CommandLPop, key, nil)
+		change.waiter = waiter
+		s.startLocked(ctx, state, change)
+
+		waiter.change <- change
+	}
+}
+
+// waitServed waits until pop is started for waiter, nil change is returned
+// if context is done or deadline is passed, waiter is removed from queue
+// then
+func (s *storage) waitServed(ctx context.Context, key string, waiter *popWaiter,
+	deadline time.Time,
+) (*listChange, error) {
+	var expired <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	var err error
+	select {
+	case change := <-waiter.change:
+		return change, nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-expired:
+	}
+
+	shard := s.listShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	// pop could be started for waiter while it was waking up
+	select {
+	case change := <-waiter.change:
+		return change, nil
+	default:
+	}
+
+	state := shard.stateLocked(key)
+	state.waiters.Remove(waiter.element)
+	waiter.element = nil
+	shard.releaseLocked(key, state)
+
+	return nil, err
+}
+
+func (s *storage) listShard(key string) *listShard {
+	return &s.lists[getHash(key, listShards)]
+}
+
+func (sh *listShard) stateLocked(key string) *listState {
+	if sh.keys == nil {
+		sh.keys = make(map[string]*listState)
+	}
+
+	state, ok := sh.keys[key]
+	if !ok {
+		state = &listState{pending: list.New(), waiters: list.New()}
+		sh.keys[key] = state
+	}
+
+	return state
+}
+
+// releaseLocked deletes state of key which has no changes and waiters
+func (sh *listShard) releaseLocked(key string, state *listState) {
+	if state.pending.Len() == 0 && state.pops == 0 && state.waiters.Len() == 0 {
+		delete(sh.keys, key)
+	}
+}
+
+func isPop(cmd string) bool {
+	return cmd == compute.CommandLPop || cmd == compute.CommandRPop
+}
+
+func (s *storage) appendWAL(ctx context.Context, cmd string, args []string) <-chan error {
+	if s.wal == nil {
+		return nil
+	}
+
+	return s.wal.Append(ctx, cmd, args)
+}
+
+// wait returns result of WAL write, nil channel means there is no write
+func wait(done <-chan error) error {
+	if done == nil {
+		return nil
+	}
+
+	return <-done
+}
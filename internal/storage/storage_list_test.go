@@ -0,0 +1,440 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"concurrency_go_course/internal/compute"
+	"concurrency_go_course/internal/storage/wal"
+	"concurrency_go_course/pkg/logger"
+)
+
+type popReply struct {
+	value string
+	found bool
+	err   error
+}
+
+// blpop starts BLPOP and waits until client is queued
+func blpop(t *testing.T, ctx context.Context, stor *storage, key string, timeout time.Duration) <-chan popReply {
+	t.Helper()
+
+	queued := waitersLen(stor, key)
+	replies := make(chan popReply, 1)
+	go func() {
+		value, found, err := stor.BLPop(ctx, key, timeout)
+		replies <- popReply{value: value, found: found, err: err}
+	}()
+
+	require.Eventually(t, func() bool { return waitersLen(stor, key) == queued+1 },
+		time.Second, time.Millisecond)
+
+	return replies
+}
+
+func waitersLen(stor *storage, key string) int {
+	shard := stor.listShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	if state := shard.keys[key]; state != nil {
+		return state.waiters.Len()
+	}
+	return 0
+}
+
+// failingWAL fails write of every appended request
+type failingWAL struct {
+	WAL
+	err error
+}
+
+func (w failingWAL) Append(context.Context, string, []string) <-chan error {
+	done := make(chan error, 1)
+	done <- w.err
+	return done
+}
+
+// manualWAL keeps writes of appended requests until test completes them
+type manualWAL struct {
+	WAL
+
+	mutex   sync.Mutex
+	appends []chan error
+}
+
+func (w *manualWAL) Append(context.Context, string, []string) <-chan error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	done := make(chan error, 1)
+	w.appends = append(w.appends, done)
+	return done
+}
+
+func (w *manualWAL) appended() int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return len(w.appends)
+}
+
+func (w *manualWAL) complete(i int, err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.appends[i] <- err
+}
+
+func newListStorage(t *testing.T, replicationType string) *storage {
+	t.Helper()
+
+	stor, err := New(NewEngine(4), nil, replicationType, nil)
+	require.NoError(t, err)
+
+	return stor.(*storage)
+}
+
+func TestStorageRestoreList(t *testing.T) {
+	logger.MockLogger()
+
+	stor := newListStorage(t, "master")
+
+	stor.Restore([]wal.Request{
+		{Command: compute.CommandRPush, Args: []string{"jobs", "a", "b", "c"}},
+		{Command: compute.CommandLPush, Args: []string{"jobs", "z"}},
+		{Command: compute.CommandLPop, Args: []string{"jobs"}},
+		{Command: compute.CommandRPop, Args: []string{"jobs"}},
+	})
+
+	values, err := stor.LRange("jobs", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, values)
+}
+
+func TestStorageBLPop(t *testing.T) {
+	logger.MockLogger()
+
+	t.Run("value of list", func(t *testing.T) {
+		stor := newListStorage(t, "master")
+
+		_, err := stor.RPush(context.Background(), "jobs", []string{"a"})
+		require.NoError(t, err)
+
+		value, found, err := stor.BLPop(context.Background(), "jobs", time.Millisecond)
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "a", value)
+	})
+
+	t.Run("clients are served in order of arrival", func(t *testing.T) {
+		stor := newListStorage(t, "master")
+
+		first := blpop(t, context.Background(), stor, "jobs", 0)
+		second := blpop(t, context.Background(), stor, "jobs", 0)
+
+		length, err := stor.RPush(context.Background(), "jobs", []string{"a", "b", "c"})
+		require.NoError(t, err)
+		assert.Equal(t, 3, length)
+
+		assert.Equal(t, popReply{value: "a", found: true}, <-first)
+		assert.Equal(t, popReply{value: "b", found: true}, <-second)
+
+		values, err := stor.LRange("jobs", 0, -1)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"c"}, values)
+		assert.Equal(t, 0, waitersLen(stor, "jobs"))
+	})
+
+	t.Run("queued client is served before new one", func(t *testing.T) {
+		stor := newListStorage(t, "master")
+
+		first := blpop(t, context.Background(), stor, "jobs", 0)
+
+		// value is pushed while list is empty, so it is given to queued client
+		_, err := stor.LPush(context.Background(), "jobs", []string{"a"})
+		require.NoError(t, err)
+		assert.Equal(t, popReply{value: "a", found: true}, <-first)
+
+		_, found, err := stor.BLPop(context.Background(), "jobs", time.Millisecond)
+		require.NoError(t, err)
+		assert.False(t, found)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		stor := newListStorage(t, "master")
+
+		start := time.Now()
+		_, found, err := stor.BLPop(context.Background(), "jobs", 20*time.Millisecond)
+		require.NoError(t, err)
+		assert.False(t, found)
+		assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+		assert.Equal(t, 0, waitersLen(stor, "jobs"))
+	})
+
+	t.Run("context cancellation", func(t *testing.T) {
+		stor := newListStorage(t, "master")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		canceled := blpop(t, ctx, stor, "jobs", 0)
+		waiting := blpop(t, context.Background(), stor, "jobs", 0)
+
+		cancel()
+		assert.Equal(t, popReply{err: context.Canceled}, <-canceled)
+		require.Eventually(t, func() bool { return waitersLen(stor, "jobs") == 1 }, time.Second, time.Millisecond)
+
+		_, err := stor.RPush(context.Background(), "jobs", []string{"a"})
+		require.NoError(t, err)
+		assert.Equal(t, popReply{value: "a", found: true}, <-waiting)
+	})
+
+	t.Run("wrong type", func(t *testing.T) {
+		stor := newListStorage(t, "master")
+
+		require.NoError(t, stor.Set(context.Background(), "key", "value"))
+
+		_, _, err := stor.BLPop(context.Background(), "key", 0)
+		assert.ErrorIs(t, err, ErrWrongType)
+	})
+
+	t.Run("slave", func(t *testing.T) {
+		stor := newListStorage(t, "slave")
+
+		_, _, err := stor.BLPop(context.Background(), "jobs", 0)
+		assert.ErrorIs(t, err, ErrReadOnly)
+
+		_, err = stor.RPush(context.Background(), "jobs", []string{"a"})
+		assert.ErrorIs(t, err, ErrReadOnly)
+	})
+}
+
+func TestStorageListWALFailure(t *testing.T) {
+	logger.MockLogger()
+
+	errWrite := errors.New("disk is full")
+
+	stor := newListStorage(t, "master")
+	_, err := stor.RPush(context.Background(), "jobs", []string{"a", "b"})
+	require.NoError(t, err)
+
+	stor.wal = failingWAL{err: errWrite}
+
+	// list is not changed if its change is not written to WAL
+	_, err = stor.RPush(context.Background(), "jobs", []string{"c"})
+	assert.ErrorIs(t, err, errWrite)
+
+	_, _, err = stor.LPop(context.Background(), "jobs")
+	assert.ErrorIs(t, err, errWrite)
+
+	_, _, err = stor.BLPop(context.Background(), "jobs", time.Millisecond)
+	assert.ErrorIs(t, err, errWrite)
+
+	_, err = stor.LPush(context.Background(), "queue", []string{"a"})
+	assert.ErrorIs(t, err, errWrite)
+
+	values, err := stor.LRange("jobs", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, values)
+	assert.Equal(t, TypeNone, stor.engine.Type("queue"))
+}
+
+func TestStorageBLPopWAL(t *testing.T) {
+	logger.MockLogger()
+
+	cfg := walConfig(t)
+	walObj := startWAL(t, cfg)
+
+	created, err := New(NewEngine(4), walObj, "master", nil)
+	require.NoError(t, err)
+	stor := created.(*storage)
+
+	blocked := blpop(t, context.Background(), stor, "jobs", 0)
+
+	_, err = stor.RPush(context.Background(), "jobs", []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, popReply{value: "a", found: true}, <-blocked)
+
+	// value given to blocked client is recovered as popped
+	recovered, err := wal.New(cfg)
+	require.NoError(t, err)
+
+	requests, err := recovered.Recover()
+	require.NoError(t, err)
+
+	restored := newListStorage(t, "master")
+	restored.Restore(requests)
+
+	values, err := restored.LRange("jobs", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, values)
+}
+
+type pushReply struct {
+	length int
+	err    error
+}
+
+func TestStorageListWALOrder(t *testing.T) {
+	logger.MockLogger()
+
+	walObj := &manualWAL{}
+	stor := newListStorage(t, "master")
+	stor.wal = walObj
+
+	rpush := func(key, value string) <-chan pushReply {
+		appended := walObj.appended()
+		replies := make(chan pushReply, 1)
+		go func() {
+			length, err := stor.RPush(context.Background(), key, []string{value})
+			replies <- pushReply{length: length, err: err}
+		}()
+
+		require.Eventually(t, func() bool { return walObj.appended() == appended+1 },
+			time.Second, time.Millisecond)
+		return replies
+	}
+
+	first := rpush("jobs", "a")
+	second := rpush("jobs", "b")
+	other := rpush("queue", "x")
+
+	// change of another key does not wait for WAL writes of list
+	walObj.complete(2, nil)
+	assert.Equal(t, pushReply{length: 1}, <-other)
+
+	// change is applied after changes written to WAL before it
+	walObj.complete(1, nil)
+	select {
+	case reply := <-second:
+		t.Fatalf("push is applied before previous one: %v", reply)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	values, err := stor.LRange("jobs", 0, -1)
+	require.NoError(t, err)
+	assert.Empty(t, values)
+
+	walObj.complete(0, nil)
+	assert.Equal(t, pushReply{length: 1}, <-first)
+	assert.Equal(t, pushReply{length: 2}, <-second)
+
+	values, err = stor.LRange("jobs", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, values)
+}
+
+func TestStorageListConsensus(t *testing.T) {
+	logger.MockLogger()
+
+	newConsensus := func(t *testing.T, other wal.Request) *storage {
+		engine := NewEngine(4)
+		_, err := engine.RPush("jobs", []string{"a", "b"})
+		require.NoError(t, err)
+
+		stor, err := NewConsensus(engine, concurrentConsensus{engine: engine, other: other}, time.Second)
+		require.NoError(t, err)
+
+		return stor.(*storage)
+	}
+
+	t.Run("value popped by state machine", func(t *testing.T) {
+		stor := newConsensus(t, wal.Request{Command: compute.CommandLPop, Args: []string{"jobs"}})
+
+		value, found, err := stor.LPop(context.Background(), "jobs")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, "b", value)
+	})
+
+	t.Run("length of list after push", func(t *testing.T) {
+		stor := newConsensus(t, wal.Request{Command: compute.CommandRPush, Args: []string{"jobs", "c"}})
+
+		length, err := stor.RPush(context.Background(), "jobs", []string{"d"})
+		require.NoError(t, err)
+		assert.Equal(t, 4, length)
+	})
+
+	t.Run("blocked client", func(t *testing.T) {
+		engine := NewEngine(4)
+		created, err := NewConsensus(engine, applyingConsensus{engine: engine}, time.Second)
+		require.NoError(t, err)
+		stor := created.(*storage)
+
+		blocked := blpop(t, context.Background(), stor, "jobs", 0)
+
+		_, err = stor.RPush(context.Background(), "jobs", []string{"a", "b"})
+		require.NoError(t, err)
+		assert.Equal(t, popReply{value: "a", found: true}, <-blocked)
+
+		values, err := stor.LRange("jobs", 0, -1)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b"}, values)
+	})
+}
+
+func TestStorageListConcurrentWAL(t *testing.T) {
+	logger.MockLogger()
+
+	cfg := walConfig(t)
+	walObj := startWAL(t, cfg)
+
+	created, err := New(NewEngine(4), walObj, "master", nil)
+	require.NoError(t, err)
+	stor := created.(*storage)
+
+	const pushers, values = 4, 50
+
+	var wg sync.WaitGroup
+	for i := range pushers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range values {
+				_, err := stor.RPush(context.Background(), "jobs", []string{fmt.Sprintf("%d-%d", i, j)})
+				assert.NoError(t, err)
+			}
+		}()
+	}
+
+	popped := make(chan string, pushers*values)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				value, found, err := stor.BLPop(context.Background(), "jobs", 50*time.Millisecond)
+				if !assert.NoError(t, err) || !found {
+					return
+				}
+				popped <- value
+			}
+		}()
+	}
+	wg.Wait()
+	close(popped)
+
+	// every value is given to one client
+	seen := make(map[string]bool)
+	for value := range popped {
+		assert.False(t, seen[value], value)
+		seen[value] = true
+	}
+	assert.Len(t, seen, pushers*values)
+
+	recovered, err := wal.New(cfg)
+	require.NoError(t, err)
+
+	requests, err := recovered.Recover()
+	require.NoError(t, err)
+
+	restored := newListStorage(t, "master")
+	restored.Restore(requests)
+	assert.Equal(t, TypeNone, restored.engine.Type("jobs"))
+}
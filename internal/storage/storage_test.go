@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"concurrency_go_course/internal/compute"
+	"concurrency_go_course/internal/config"
+	"concurrency_go_course/internal/storage/wal"
+	"concurrency_go_course/pkg/logger"
+)
+
+func TestStorageRestoreHash(t *testing.T) {
+	logger.MockLogger()
+
+	stor, err := New(NewEngine(4), nil, "master", nil)
+	require.NoError(t, err)
+
+	stor.Restore([]wal.Request{
+		{Command: compute.CommandHSet, Args: []string{"user", "name", "alice", "age", "30"}},
+		{Command: compute.CommandSet, Args: []string{"key", "value"}},
+		// request failed with wrong type is skipped
+		{Command: compute.CommandHSet, Args: []string{"key", "field", "value"}},
+		{Command: compute.CommandHDel, Args: []string{"user", "age"}},
+	})
+
+	pairs, err := stor.HGetAll("user")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"name", "alice"}, pairs)
+
+	value, ok, err := stor.Get("key")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "value", value)
+}
+
+// applyingConsensus applies proposed requests to engine at once
+type applyingConsensus struct {
+	engine Engine
+}
+
+func (c applyingConsensus) Propose(_ context.Context, request wal.Request) (any, error) {
+	return applyRequest(c.engine, request), nil
+}
+
+func TestStorageHashDuplicateFields(t *testing.T) {
+	logger.MockLogger()
+
+	engine := NewEngine(4)
+	consensus, err := NewConsensus(engine, applyingConsensus{engine: engine}, time.Second)
+	require.NoError(t, err)
+	master, err := New(NewEngine(4), nil, "master", nil)
+	require.NoError(t, err)
+
+	for name, stor := range map[string]Storage{"consensus": consensus, "master": master} {
+		t.Run(name, func(t *testing.T) {
+			added, err := stor.HSet(context.Background(), "user", []string{"name", "alice", "name", "bob"})
+			require.NoError(t, err)
+			assert.Equal(t, 1, added)
+
+			value, _, err := stor.HGet("user", "name")
+			require.NoError(t, err)
+			assert.Equal(t, "bob", value)
+
+			added, err = stor.HSet(context.Background(), "user", []string{"age", "30", "name", "carol", "age", "31"})
+			require.NoError(t, err)
+			assert.Equal(t, 1, added)
+
+			deleted, err := stor.HDel(context.Background(), "user", []string{"age", "age", "city"})
+			require.NoError(t, err)
+			assert.Equal(t, 1, deleted)
+		})
+	}
+}
+
+// concurrentConsensus applies request of another client before proposed one
+type concurrentConsensus struct {
+	engine Engine
+	other  wal.Request
+}
+
+func (c concurrentConsensus) Propose(_ context.Context, request wal.Request) (any, error) {
+	applyRequest(c.engine, c.other)
+	return applyRequest(c.engine, request), nil
+}
+
+func TestStorageHashConcurrentProposals(t *testing.T) {
+	logger.MockLogger()
+
+	tests := map[string]struct {
+		other  wal.Request
+		change func(Storage) (int, error)
+		count  int
+	}{
+		"field added by other client": {
+			other: wal.Request{Command: compute.CommandHSet, Args: []string{"user", "name", "bob"}},
+			change: func(stor Storage) (int, error) {
+				return stor.HSet(context.Background(), "user", []string{"name", "alice", "age", "30"})
+			},
+			count: 1,
+		},
+		"field deleted by other client": {
+			other: wal.Request{Command: compute.CommandHDel, Args: []string{"user", "city"}},
+			change: func(stor Storage) (int, error) {
+				return stor.HDel(context.Background(), "user", []string{"city", "email"})
+			},
+			count: 1,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			engine := NewEngine(4)
+			_, err := engine.HSet("user", []string{"city", "paris", "email", "alice@example.com"})
+			require.NoError(t, err)
+
+			stor, err := NewConsensus(engine, concurrentConsensus{engine: engine, other: tt.other}, time.Second)
+			require.NoError(t, err)
+
+			count, err := tt.change(stor)
+			require.NoError(t, err)
+			assert.Equal(t, tt.count, count)
+		})
+	}
+}
+
+func TestStorageHashWrongType(t *testing.T) {
+	logger.MockLogger()
+
+	stor, err := New(NewEngine(4), nil, "master", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, stor.Set(context.Background(), "key", "value"))
+
+	_, err = stor.HSet(context.Background(), "key", []string{"field", "value"})
+	assert.ErrorIs(t, err, ErrWrongType)
+
+	_, err = stor.HDel(context.Background(), "key", []string{"field"})
+	assert.ErrorIs(t, err, ErrWrongType)
+
+	t.Run("slave", func(t *testing.T) {
+		stor, err := New(NewEngine(4), nil, "slave", nil)
+		require.NoError(t, err)
+
+		_, err = stor.HSet(context.Background(), "user", []string{"name", "alice"})
+		assert.ErrorIs(t, err, ErrReadOnly)
+	})
+}
+
+// walConfig returns config of WAL in temporary directory of test
+func walConfig(t *testing.T) *config.WALCfg {
+	return &config.WALCfg{
+		WalConfig: &config.WALSettings{
+			FlushingBatchSize:    100,
+			FlushingBatchTimeout: "10ms",
+			MaxSegmentSize:       "1MB",
+			DataDirectory:        t.TempDir(),
+		},
+	}
+}
+
+// startWAL creates WAL and runs it until the end of test
+func startWAL(t *testing.T, cfg *config.WALCfg) *wal.WAL {
+	t.Helper()
+
+	walObj, err := wal.New(cfg)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		walObj.Start(ctx)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	return walObj
+}
@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"concurrency_go_course/internal/compute"
+	"concurrency_go_course/pkg/trace"
+)
+
+// ZAdd sets scores of sorted set members, it returns number of added
+// members
+func (s *storage) ZAdd(ctx context.Context, key string, members []ZMember) (added int, err error) {
+	ctx, span := trace.Start(ctx, "storage.ZAdd")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if err := s.expireIfNeeded(ctx, key); err != nil {
+		return 0, err
+	}
+
+	if err := s.checkType(key, TypeZSet); err != nil {
+		return 0, err
+	}
+
+	if s.consensus != nil {
+		result, err := s.propose(ctx, compute.CommandZAdd, append([]string{key}, zmemberArgs(members)...))
+		return result.count, err
+	}
+
+	if !s.isMasterRepl {
+		return 0, fmt.Errorf("unable to execute zadd command on slave: %w", ErrReadOnly)
+	}
+
+	if s.wal != nil {
+		if err := s.wal.ZAdd(ctx, key, zmemberArgs(members)); err != nil {
+			return 0, err
+		}
+	}
+
+	_, engineSpan := trace.Start(ctx, "engine.ZAdd")
+	defer engineSpan.End()
+
+	return s.engine.ZAdd(key, members)
+}
+
+// ZRem removes members of sorted set, it returns number of removed members
+func (s *storage) ZRem(ctx context.Context, key string, members []string) (removed int, err error) {
+	ctx, span := trace.Start(ctx, "storage.ZRem")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if err := s.expireIfNeeded(ctx, key); err != nil {
+		return 0, err
+	}
+
+	if err := s.checkType(key, TypeZSet); err != nil {
+		return 0, err
+	}
+
+	if s.consensus != nil {
+		result, err := s.propose(ctx, compute.CommandZRem, append([]string{key}, members...))
+		return result.count, err
+	}
+
+	if !s.isMasterRepl {
+		return 0, fmt.Errorf("unable to execute zrem command on slave: %w", ErrReadOnly)
+	}
+
+	if s.wal != nil {
+		if err := s.wal.ZRem(ctx, key, members); err != nil {
+			return 0, err
+		}
+	}
+
+	_, engineSpan := trace.Start(ctx, "engine.ZRem")
+	defer engineSpan.End()
+
+	return s.engine.ZRem(key, members)
+}
+
+// ZRemRangeByScore removes members of sorted set with score in range, it
+// returns number of removed members
+func (s *storage) ZRemRangeByScore(ctx context.Context, key string, r ScoreRange) (removed int, err error) {
+	ctx, span := trace.Start(ctx, "storage.ZRemRangeByScore")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if err := s.expireIfNeeded(ctx, key); err != nil {
+		return 0, err
+	}
+
+	if err := s.checkType(key, TypeZSet); err != nil {
+		return 0, err
+	}
+
+	if s.consensus != nil {
+		result, err := s.propose(ctx, compute.CommandZRemRangeByScore, append([]string{key}, r.args()...))
+		return result.count, err
+	}
+
+	if !s.isMasterRepl {
+		return 0, fmt.Errorf("unable to execute zremrangebyscore command on slave: %w", ErrReadOnly)
+	}
+
+	if s.wal != nil {
+		bounds := r.args()
+		if err := s.wal.ZRemRangeByScore(ctx, key, bounds[0], bounds[1]); err != nil {
+			return 0, err
+		}
+	}
+
+	_, engineSpan := trace.Start(ctx, "engine.ZRemRangeByScore")
+	defer engineSpan.End()
+
+	return s.engine.ZRemRangeByScore(key, r)
+}
+
+// ZScore returns score of sorted set member
+func (s *storage) ZScore(key, member string) (float64, bool, error) {
+	if s.expired(key) {
+		return 0, false, nil
+	}
+
+	return s.engine.ZScore(key, member)
+}
+
+// ZRange returns members of sorted set from rank start to stop inclusive
+func (s *storage) ZRange(key string, start, stop int) ([]ZMember, error) {
+	if s.expired(key) {
+		return nil, nil
+	}
+
+	return s.engine.ZRange(key, start, stop)
+}
+
+// ZRangeByScore returns members of sorted set with score in range
+func (s *storage) ZRangeByScore(key string, r ScoreRange) ([]ZMember, error) {
+	if s.expired(key) {
+		return nil, nil
+	}
+
+	return s.engine.ZRangeByScore(key, r)
+}
+
+// ZCard returns number of sorted set members
+func (s *storage) ZCard(key string) (int, error) {
+	if s.expired(key) {
+		return 0, nil
+	}
+
+	return s.engine.ZCard(key)
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"concurrency_go_course/internal/compute"
+	"concurrency_go_course/internal/storage/wal"
+	"concurrency_go_course/pkg/logger"
+)
+
+func TestStorageRestoreZSet(t *testing.T) {
+	logger.MockLogger()
+
+	stor, err := New(NewEngine(4), nil, "master", nil)
+	require.NoError(t, err)
+
+	stor.Restore([]wal.Request{
+		{Command: compute.CommandZAdd, Args: []string{"board", "30", "alice", "10", "bob", "-inf", "carol"}},
+		{Command: compute.CommandZRem, Args: []string{"board", "bob"}},
+		{Command: compute.CommandZAdd, Args: []string{"window", "1", "a", "2", "b", "3", "c"}},
+		{Command: compute.CommandZRemRangeByScore, Args: []string{"window", "-inf", "(3"}},
+		// request with invalid score is skipped
+		{Command: compute.CommandZAdd, Args: []string{"board", "high", "dave"}},
+	})
+
+	members, err := stor.ZRange("board", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"carol", "alice"}, []string{members[0].Member, members[1].Member})
+
+	count, err := stor.ZCard("window")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+func TestStorageZSetWAL(t *testing.T) {
+	logger.MockLogger()
+
+	cfg := walConfig(t)
+	walObj := startWAL(t, cfg)
+
+	stor, err := New(NewEngine(4), walObj, "master", nil)
+	require.NoError(t, err)
+
+	added, err := stor.ZAdd(context.Background(), "window", []ZMember{{"a", 0.1}, {"b", 0.2}, {"c", 0.3}})
+	require.NoError(t, err)
+	assert.Equal(t, 3, added)
+
+	removed, err := stor.ZRemRangeByScore(context.Background(), "window", ScoreRange{Min: 0, Max: 0.2, MaxExclusive: true})
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	removed, err = stor.ZRem(context.Background(), "window", []string{"c"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	recovered, err := wal.New(cfg)
+	require.NoError(t, err)
+
+	requests, err := recovered.Recover()
+	require.NoError(t, err)
+
+	restored, err := New(NewEngine(4), nil, "master", nil)
+	require.NoError(t, err)
+	restored.Restore(requests)
+
+	members, err := restored.ZRange("window", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []ZMember{{"b", 0.2}}, members)
+}
+
+func TestStorageZSetConcurrentProposals(t *testing.T) {
+	logger.MockLogger()
+
+	tests := map[string]struct {
+		other  wal.Request
+		change func(Storage) (int, error)
+		count  int
+	}{
+		"member added by other client": {
+			other: wal.Request{Command: compute.CommandZAdd, Args: []string{"board", "5", "carol"}},
+			change: func(stor Storage) (int, error) {
+				return stor.ZAdd(context.Background(), "board", []ZMember{{"carol", 1}, {"dave", 2}})
+			},
+			count: 1,
+		},
+		"member removed by other client": {
+			other: wal.Request{Command: compute.CommandZRem, Args: []string{"board", "alice"}},
+			change: func(stor Storage) (int, error) {
+				return stor.ZRem(context.Background(), "board", []string{"alice", "bob"})
+			},
+			count: 1,
+		},
+		"range removed by other client": {
+			other: wal.Request{Command: compute.CommandZRem, Args: []string{"board", "alice"}},
+			change: func(stor Storage) (int, error) {
+				return stor.ZRemRangeByScore(context.Background(), "board", ScoreRange{Min: 0, Max: 100})
+			},
+			count: 1,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			engine := NewEngine(4)
+			_, err := engine.ZAdd("board", []ZMember{{"alice", 10}, {"bob", 20}})
+			require.NoError(t, err)
+
+			stor, err := NewConsensus(engine, concurrentConsensus{engine: engine, other: tt.other}, time.Second)
+			require.NoError(t, err)
+
+			count, err := tt.change(stor)
+			require.NoError(t, err)
+			assert.Equal(t, tt.count, count)
+		})
+	}
+}
+
+func TestStorageZSetWrongType(t *testing.T) {
+	logger.MockLogger()
+
+	stor, err := New(NewEngine(4), nil, "master", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, stor.Set(context.Background(), "key", "value"))
+
+	_, err = stor.ZAdd(context.Background(), "key", []ZMember{{"a", 1}})
+	assert.ErrorIs(t, err, ErrWrongType)
+
+	_, err = stor.ZRemRangeByScore(context.Background(), "key", ScoreRange{Max: 1})
+	assert.ErrorIs(t, err, ErrWrongType)
+
+	t.Run("slave", func(t *testing.T) {
+		stor, err := New(NewEngine(4), nil, "slave", nil)
+		require.NoError(t, err)
+
+		_, err = stor.ZAdd(context.Background(), "board", []ZMember{{"a", 1}})
+		assert.ErrorIs(t, err, ErrReadOnly)
+	})
+}
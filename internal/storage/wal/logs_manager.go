@@ -2,11 +2,22 @@ package wal
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	fs "concurrency_go_course/internal/filesystem"
 	"concurrency_go_course/pkg/logger"
+	"concurrency_go_course/pkg/metrics"
+	"concurrency_go_course/pkg/trace"
+)
+
+var (
+	batchSize = metrics.Register(metrics.NewHistogramVec("wal_batch_size",
+		"Number of requests in flushed WAL batches.", metrics.ExponentialBuckets(1, 2, 12)))
+	flushDuration = metrics.Register(metrics.NewHistogramVec("wal_flush_duration_seconds",
+		"Duration of WAL batch flush including encoding and fsync.", metrics.DurationBuckets))
 )
 
 // LogsManager is interface for manager
@@ -18,36 +29,76 @@ type LogsManager interface {
 // LogsManager is a struct for logs manager
 type logsmanager struct {
 	segment fs.Segment
+	// position is updated before requests are acknowledged, so it covers
+	// every acknowledged request
+	position *Position
 }
 
-// NewLogsManager returns new logs manager
-func NewLogsManager(segment fs.Segment) (LogsManager, error) {
+// NewLogsManager returns new logs manager, written requests are recorded
+// in position
+func NewLogsManager(segment fs.Segment, position *Position) (LogsManager, error) {
 	if segment == nil {
 		return nil, errors.New("segment is invalid")
 	}
 
-	return &logsmanager{segment: segment}, nil
+	if position == nil {
+		return nil, errors.New("position is invalid")
+	}
+
+	return &logsmanager{segment: segment, position: position}, nil
 }
 
 // Write writes requests
 func (l *logsmanager) Write(requests []Request) {
+	start := time.Now()
+	defer flushDuration.WithLabelValues().ObserveSince(start)
+	batchSize.WithLabelValues().Observe(float64(len(requests)))
+
+	ctx, span := startFlushSpan(requests)
+	defer span.End()
+
+	timestamp := time.Now().UnixNano()
+
 	var buffer bytes.Buffer
 	for _, req := range requests {
+		req.Timestamp = timestamp
 		if err := req.Encode(&buffer); err != nil {
 			logger.ErrorWithMsg("failed to encode requests", err)
+			span.RecordError(err)
 			l.acknowledgeWrite(requests, err)
 			return
 		}
 	}
 
-	err := l.segment.Write(buffer.Bytes())
+	err := l.segment.Write(ctx, buffer.Bytes())
 	if err != nil {
 		logger.ErrorWithMsg("failed to write request data:", err)
+		span.RecordError(err)
+	} else {
+		l.position.add(l.segment.Name(), buffer.Len(), LastLSN(requests))
 	}
 
 	l.acknowledgeWrite(requests, err)
 }
 
+// startFlushSpan starts span of batch flush, it is a child of the first
+// request span and is linked to spans of other requests, so every request
+// trace reaches the flush
+func startFlushSpan(requests []Request) (context.Context, *trace.Span) {
+	var parent trace.SpanContext
+	links := make([]trace.SpanContext, 0, len(requests))
+	for _, req := range requests {
+		if !parent.IsValid() {
+			parent = req.spanContext
+			continue
+		}
+		links = append(links, req.spanContext)
+	}
+
+	return trace.Start(trace.ContextWithRemote(context.Background(), parent), "WAL.flush",
+		trace.WithLinks(links...), trace.WithAttributes(trace.Int("wal.batch_size", len(requests))))
+}
+
 // ReadAll reads all requests
 func (l *logsmanager) ReadAll() ([]Request, error) {
 	segmentsData, err := l.segment.ReadAll()
@@ -57,10 +108,12 @@ func (l *logsmanager) ReadAll() ([]Request, error) {
 
 	var requests []Request
 	for _, data := range segmentsData {
-		requests, err = l.readSegment(requests, data)
+		segmentRequests, _, err := DecodeRequests(data)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read segments: %w", err)
 		}
+
+		requests = append(requests, segmentRequests...)
 	}
 
 	logger.Debug("WAL requests was readed")
@@ -68,20 +121,6 @@ func (l *logsmanager) ReadAll() ([]Request, error) {
 	return requests, nil
 }
 
-func (l *logsmanager) readSegment(requests []Request, data []byte) ([]Request, error) {
-	buffer := bytes.NewBuffer(data)
-	for buffer.Len() > 0 {
-		var request Request
-		if err := request.Decode(buffer); err != nil {
-			return nil, fmt.Errorf("failed to parse logs data: %w", err)
-		}
-
-		requests = append(requests, request)
-	}
-
-	return requests, nil
-}
-
 func (l *logsmanager) acknowledgeWrite(requests []Request, err error) {
 	for _, req := range requests {
 		req.doneStatus <- err
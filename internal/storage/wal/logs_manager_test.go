@@ -49,7 +49,7 @@ func TestLogsManagerWrite(t *testing.T) {
 	fileLib := filesystem.NewFileLib()
 	segment := filesystem.NewSegment(testDataDir, 10, fileLib)
 
-	logsManager, err := NewLogsManager(segment)
+	logsManager, err := NewLogsManager(segment, &Position{})
 	if err != nil {
 		t.Errorf("failed: %s", err)
 	}
@@ -101,7 +101,7 @@ func TestLogsManagerReadAll(t *testing.T) {
 	fileLib := filesystem.NewMockFileLib()
 	segment := filesystem.NewSegment(testDataDirRead, 10, fileLib)
 
-	logsManager, err := NewLogsManager(segment)
+	logsManager, err := NewLogsManager(segment, &Position{})
 	if err != nil {
 		t.Errorf("failed: %s", err)
 	}
@@ -110,7 +110,7 @@ func TestLogsManagerReadAll(t *testing.T) {
 
 	segmentR := filesystem.NewSegment(testDataDirRead, 10, fileLib)
 
-	logsManager, err = NewLogsManager(segmentR)
+	logsManager, err = NewLogsManager(segmentR, &Position{})
 	if err != nil {
 		t.Errorf("failed: %s", err)
 	}
@@ -0,0 +1,95 @@
+package wal
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"concurrency_go_course/internal/filesystem"
+)
+
+// Position is a position of requests written to segments, it is kept in
+// memory and updated on write, so readers such as replication master do
+// not read segment files. Zero value is an empty position
+type Position struct {
+	mutex sync.RWMutex
+	lsn   uint64
+	// segments are sizes of segment files sorted by name, names of new
+	// segments are greater than names of previous ones
+	segments []segmentSize
+}
+
+type segmentSize struct {
+	name string
+	size int64
+}
+
+// newPosition returns position of segments in directory, LSN is set on
+// recovery
+func newPosition(directory string) (*Position, error) {
+	filenames, err := filesystem.NewFileLib().FilenamesFromDir(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	position := &Position{segments: make([]segmentSize, 0, len(filenames))}
+	for _, filename := range filenames {
+		info, err := os.Stat(filepath.Join(directory, filename))
+		if err != nil {
+			return nil, err
+		}
+
+		position.segments = append(position.segments, segmentSize{name: filename, size: info.Size()})
+	}
+
+	return position, nil
+}
+
+// LastLSN returns log sequence number of the last request written to
+// segments
+func (p *Position) LastLSN() uint64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	return p.lsn
+}
+
+// SizeAfter returns size of segments after offset of segment, all
+// segments are counted if name is empty
+func (p *Position) SizeAfter(name string, offset int64) int64 {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	var size int64
+	for i := len(p.segments) - 1; i >= 0 && p.segments[i].name >= name; i-- {
+		size += p.segments[i].size
+		if p.segments[i].name == name {
+			size -= min(p.segments[i].size, offset)
+		}
+	}
+
+	return size
+}
+
+// advance moves LSN forward, it is not moved back by older requests
+func (p *Position) advance(lsn uint64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.lsn = max(p.lsn, lsn)
+}
+
+// add records batch of requests written to segment
+func (p *Position) add(name string, size int, lsn uint64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.lsn = max(p.lsn, lsn)
+
+	if last := len(p.segments) - 1; last >= 0 && p.segments[last].name == name {
+		p.segments[last].size += int64(size)
+		return
+	}
+
+	p.segments = append(p.segments, segmentSize{name: name, size: int64(size)})
+}
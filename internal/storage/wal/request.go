@@ -3,14 +3,24 @@ package wal
 import (
 	"bytes"
 	"encoding/gob"
+	"fmt"
+
+	"concurrency_go_course/pkg/trace"
 )
 
 // Request is a struct for request
 type Request struct {
+	LSN     uint64
 	Command string
 	Args    []string
+	// Timestamp is unix time in nanoseconds when batch of request was
+	// written, it is zero for requests written by older versions
+	Timestamp int64
 
 	doneStatus chan error
+	// spanContext is a context of span waiting for request, it is not
+	// encoded
+	spanContext trace.SpanContext
 }
 
 // NewRequest returns new request
@@ -34,3 +44,32 @@ func (r *Request) Decode(buffer *bytes.Buffer) error {
 	decoder := gob.NewDecoder(buffer)
 	return decoder.Decode(r)
 }
+
+// DecodeRequests decodes requests of segment data, requests decoded before
+// error are returned with number of bytes they take, so segment which is
+// being written is read up to the last complete request
+func DecodeRequests(data []byte) ([]Request, int, error) {
+	var requests []Request
+	buffer := bytes.NewBuffer(data)
+	for buffer.Len() > 0 {
+		read := len(data) - buffer.Len()
+
+		var request Request
+		if err := request.Decode(buffer); err != nil {
+			return requests, read, fmt.Errorf("unable to parse request data: %w", err)
+		}
+
+		requests = append(requests, request)
+	}
+
+	return requests, len(data), nil
+}
+
+// LastLSN returns the greatest log sequence number of requests
+func LastLSN(requests []Request) uint64 {
+	var lsn uint64
+	for _, request := range requests {
+		lsn = max(lsn, request.LSN)
+	}
+	return lsn
+}
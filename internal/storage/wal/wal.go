@@ -4,7 +4,10 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
@@ -13,7 +16,9 @@ import (
 	"concurrency_go_course/internal/config"
 	"concurrency_go_course/internal/filesystem"
 	"concurrency_go_course/pkg/logger"
+	"concurrency_go_course/pkg/metrics"
 	"concurrency_go_course/pkg/parser"
+	"concurrency_go_course/pkg/trace"
 )
 
 const (
@@ -35,13 +40,17 @@ type WAL struct {
 	settings *Settings
 
 	logsManager LogsManager
+	fileLib     filesystem.FileLib
+	position    *Position
+
+	// lastFlush is unix time in nanoseconds of the last written batch
+	lastFlush atomic.Int64
 
 	mutexBuffer sync.Mutex
 	buffer      []Request
+	lsn         uint64
 
 	bufferCh chan []Request
-
-	writeStatus <-chan error
 }
 
 // New creates new WAL
@@ -55,11 +64,6 @@ func New(cfg *config.WALCfg) (*WAL, error) {
 		return nil, err
 	}
 
-	logsManager, err := getLogsManager(settings)
-	if err != nil {
-		return nil, err
-	}
-
 	if _, err := os.Stat(settings.DataDirectory); err != nil {
 		if os.IsNotExist(err) {
 			err := os.MkdirAll(settings.DataDirectory, os.ModePerm) //nolint:gosec
@@ -71,16 +75,32 @@ func New(cfg *config.WALCfg) (*WAL, error) {
 		}
 	}
 
+	// segments are read once, position is updated by writes after it
+	position, err := newPosition(settings.DataDirectory)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get WAL position: %w", err)
+	}
+
+	logsManager, err := getLogsManager(settings, position)
+	if err != nil {
+		return nil, err
+	}
+
+	registerSegmentMetrics(settings.DataDirectory)
+
 	return &WAL{
 		settings:    settings,
 		mutexBuffer: sync.Mutex{},
 		buffer:      make([]Request, 0),
 		bufferCh:    make(chan []Request, 1),
 		logsManager: logsManager,
+		fileLib:     filesystem.NewFileLib(),
+		position:    position,
 	}, nil
 }
 
-// Start initializes WAL
+// Start writes batches of WAL until context is done, the last batch is
+// flushed before it returns
 func (w *WAL) Start(ctx context.Context) {
 	logger.Info("Starting WAL with settings",
 		zap.String("flushing_timeout", w.settings.FlushingBatchTimeout.String()),
@@ -88,59 +108,136 @@ func (w *WAL) Start(ctx context.Context) {
 		zap.Int("max_segment_size", w.settings.MaxSegmentSize),
 	)
 
-	go func() {
-		ticker := time.NewTicker(w.settings.FlushingBatchTimeout)
-		defer ticker.Stop()
+	ticker := time.NewTicker(w.settings.FlushingBatchTimeout)
+	defer ticker.Stop()
 
-		for {
-			select {
-			case <-ctx.Done():
-				w.flushBatch()
-				logger.Debug("Batch was flushed by ctx")
-				return
-			default:
-			}
+	for {
+		select {
+		case <-ctx.Done():
+			w.flushBatch()
+			logger.Debug("Batch was flushed by ctx")
+			return
+		default:
+		}
 
-			select {
-			case <-ctx.Done():
-				w.flushBatch()
-				logger.Debug("Batch was flushed by ctx")
-				return
-			case batch := <-w.bufferCh:
-				w.logsManager.Write(batch)
-				ticker.Reset(w.settings.FlushingBatchTimeout * time.Second)
-				logger.Debug("Batch was flushed by buffer")
-			case <-ticker.C:
-				w.flushBatch()
-				logger.Debug("Batch was flushed by timeout")
-			}
+		select {
+		case <-ctx.Done():
+			w.flushBatch()
+			logger.Debug("Batch was flushed by ctx")
+			return
+		case batch := <-w.bufferCh:
+			w.write(batch)
+			ticker.Reset(w.settings.FlushingBatchTimeout * time.Second)
+			logger.Debug("Batch was flushed by buffer")
+		case <-ticker.C:
+			w.flushBatch()
+			logger.Debug("Batch was flushed by timeout")
 		}
-	}()
+	}
 }
 
 // Recover recover from files
 func (w *WAL) Recover() ([]Request, error) {
-	return w.logsManager.ReadAll()
+	requests, err := w.logsManager.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	w.mutexBuffer.Lock()
+	w.lsn = max(w.lsn, LastLSN(requests))
+	w.mutexBuffer.Unlock()
+
+	w.position.advance(LastLSN(requests))
+
+	return requests, nil
 }
 
-// Set sets new value
-func (w *WAL) Set(key, value string) error {
-	w.push(compute.CommandSet, []string{key, value})
+// Position returns position of requests written to segments, it is
+// updated by writes
+func (w *WAL) Position() *Position {
+	return w.position
+}
 
-	return <-w.writeStatus
+// LastLSN returns log sequence number of the last pushed request
+func (w *WAL) LastLSN() uint64 {
+	w.mutexBuffer.Lock()
+	defer w.mutexBuffer.Unlock()
+
+	return w.lsn
+}
+
+// Set sets new value
+func (w *WAL) Set(ctx context.Context, key, value string) error {
+	return w.pushAndWait(ctx, compute.CommandSet, []string{key, value})
 }
 
 // Del deletes key
-func (w *WAL) Del(key string) error {
-	w.push(compute.CommandDelete, []string{key})
+func (w *WAL) Del(ctx context.Context, key string) error {
+	return w.pushAndWait(ctx, compute.CommandDelete, []string{key})
+}
+
+// HSet sets fields of hash, fields are pairs of field and value
+func (w *WAL) HSet(ctx context.Context, key string, fields []string) error {
+	return w.pushAndWait(ctx, compute.CommandHSet, append([]string{key}, fields...))
+}
+
+// HDel deletes fields of hash
+func (w *WAL) HDel(ctx context.Context, key string, fields []string) error {
+	return w.pushAndWait(ctx, compute.CommandHDel, append([]string{key}, fields...))
+}
 
-	return <-w.writeStatus
+// ZAdd sets scores of sorted set members, members are pairs of score and
+// member
+func (w *WAL) ZAdd(ctx context.Context, key string, members []string) error {
+	return w.pushAndWait(ctx, compute.CommandZAdd, append([]string{key}, members...))
 }
 
-func (w *WAL) push(cmd string, args []string) {
+// ZRem removes members of sorted set
+func (w *WAL) ZRem(ctx context.Context, key string, members []string) error {
+	return w.pushAndWait(ctx, compute.CommandZRem, append([]string{key}, members...))
+}
+
+// ZRemRangeByScore removes members of sorted set with score between bounds
+func (w *WAL) ZRemRangeByScore(ctx context.Context, key, min, max string) error {
+	return w.pushAndWait(ctx, compute.CommandZRemRangeByScore, []string{key, min, max})
+}
+
+// PExpireAt sets expiration deadline of key in unix milliseconds
+func (w *WAL) PExpireAt(ctx context.Context, key string, deadline int64) error {
+	return w.pushAndWait(ctx, compute.CommandPExpireAt, []string{key, strconv.FormatInt(deadline, 10)})
+}
+
+// Expired deletes key expired at deadline in unix milliseconds
+func (w *WAL) Expired(ctx context.Context, key string, deadline int64) error {
+	return w.pushAndWait(ctx, compute.CommandExpired, []string{key, strconv.FormatInt(deadline, 10)})
+}
+
+// Append pushes request without waiting, returned channel receives result
+// of its batch write, order of requests is the order of Append calls
+func (w *WAL) Append(ctx context.Context, cmd string, args []string) <-chan error {
+	return w.push(ctx, cmd, args)
+}
+
+// pushAndWait pushes request and waits until its batch is written, span
+// covers the time from push to acknowledgement
+func (w *WAL) pushAndWait(ctx context.Context, cmd string, args []string) error {
+	ctx, span := trace.Start(ctx, "WAL.push", trace.WithAttributes(trace.String("db.command", cmd)))
+	defer span.End()
+
+	err := <-w.push(ctx, cmd, args)
+	span.RecordError(err)
+
+	return err
+}
+
+func (w *WAL) push(ctx context.Context, cmd string, args []string) <-chan error {
 	request := NewRequest(cmd, args)
+	request.spanContext = trace.SpanContextFromContext(ctx)
 
 	w.mutexBuffer.Lock()
+	w.lsn++
+	request.LSN = w.lsn
+	trace.SpanFromContext(ctx).SetAttributes(trace.Int64("wal.lsn", int64(request.LSN)))
 	w.buffer = append(w.buffer, request)
 	if len(w.buffer) == w.settings.FlushingBatchSize {
 		w.bufferCh <- w.buffer
@@ -148,7 +245,7 @@ func (w *WAL) push(cmd string, args []string) {
 	}
 	w.mutexBuffer.Unlock()
 
-	w.writeStatus = request.doneStatus
+	return request.doneStatus
 }
 
 func (w *WAL) flushBatch() {
@@ -160,8 +257,42 @@ func (w *WAL) flushBatch() {
 	w.mutexBuffer.Unlock()
 
 	if len(batch) != 0 {
-		w.logsManager.Write(batch)
+		w.write(batch)
+	}
+}
+
+func (w *WAL) write(batch []Request) {
+	w.logsManager.Write(batch)
+	w.lastFlush.Store(time.Now().UnixNano())
+}
+
+// Stats is a state of WAL persistence
+type Stats struct {
+	// LastFlush is zero if no batch was written since start
+	LastFlush     time.Time
+	ActiveSegment string
+	Segments      int
+	LastLSN       uint64
+}
+
+// Stats returns state of WAL, segments are read from data directory
+func (w *WAL) Stats() (Stats, error) {
+	stats := Stats{LastLSN: w.LastLSN()}
+	if lastFlush := w.lastFlush.Load(); lastFlush != 0 {
+		stats.LastFlush = time.Unix(0, lastFlush)
+	}
+
+	filenames, err := w.fileLib.FilenamesFromDir(w.settings.DataDirectory)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	stats.Segments = len(filenames)
+	if len(filenames) != 0 {
+		stats.ActiveSegment = filenames[len(filenames)-1]
 	}
+
+	return stats, nil
 }
 
 func walSettings(cfg *config.WALCfg) (*Settings, error) {
@@ -199,13 +330,46 @@ func walSettings(cfg *config.WALCfg) (*Settings, error) {
 	return &settings, nil
 }
 
-func getLogsManager(settings *Settings) (LogsManager, error) {
+// registerSegmentMetrics exposes number and size of segments in directory,
+// they are read from directory on scrape
+func registerSegmentMetrics(directory string) {
+	fileLib := filesystem.NewFileLib()
+
+	segments := func() ([]string, error) {
+		return fileLib.FilenamesFromDir(directory)
+	}
+
+	metrics.Register(metrics.NewGaugeFunc("wal_segments", "Number of WAL segment files.", nil,
+		func(emit func(float64, ...string)) {
+			if filenames, err := segments(); err == nil {
+				emit(float64(len(filenames)))
+			}
+		}))
+
+	metrics.Register(metrics.NewGaugeFunc("wal_segments_bytes", "Total size of WAL segment files.", nil,
+		func(emit func(float64, ...string)) {
+			filenames, err := segments()
+			if err != nil {
+				return
+			}
+
+			var size int64
+			for _, filename := range filenames {
+				if info, err := os.Stat(filepath.Join(directory, filename)); err == nil {
+					size += info.Size()
+				}
+			}
+			emit(float64(size))
+		}))
+}
+
+func getLogsManager(settings *Settings, position *Position) (LogsManager, error) {
 	fileLib := filesystem.NewFileLib()
 
 	segment := filesystem.NewSegment(settings.DataDirectory,
 		settings.MaxSegmentSize, fileLib)
 
-	logsManager, err := NewLogsManager(segment)
+	logsManager, err := NewLogsManager(segment, position)
 	if err != nil {
 		return nil, err
 	}
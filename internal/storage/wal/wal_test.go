@@ -3,6 +3,8 @@ package wal
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sync"
 	"testing"
@@ -145,11 +147,8 @@ func TestWAL_Start_WriteByTimeout(t *testing.T) {
 	}
 
 	start := time.Now()
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	wal.Start(ctx)
-	err = wal.Set("key", "value")
+	startWAL(t, wal)
+	err = wal.Set(context.Background(), "key", "value")
 	if err != nil {
 		t.Errorf("unable to set value: %s", err)
 	}
@@ -179,9 +178,7 @@ func TestWAL_Start_WriteByBatchSize(t *testing.T) {
 	}
 
 	start := time.Now()
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	wal.Start(ctx)
+	startWAL(t, wal)
 
 	var wg sync.WaitGroup
 	wg.Add(2)
@@ -189,7 +186,7 @@ func TestWAL_Start_WriteByBatchSize(t *testing.T) {
 	go func() {
 		defer wg.Done()
 
-		err = wal.Set("key1", "value1")
+		err := wal.Set(context.Background(), "key1", "value1")
 		if err != nil {
 			t.Errorf("unable to set value: %s", err)
 		}
@@ -198,7 +195,7 @@ func TestWAL_Start_WriteByBatchSize(t *testing.T) {
 	go func() {
 		defer wg.Done()
 
-		err = wal.Set("key2", "value2")
+		err := wal.Set(context.Background(), "key2", "value2")
 		if err != nil {
 			t.Errorf("unable to set value: %s", err)
 		}
@@ -230,9 +227,7 @@ func TestWAL_Recover(t *testing.T) {
 		t.Errorf("unable to create WAL: %s", err)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	wal.Start(ctx)
+	startWAL(t, wal)
 
 	requests, err := wal.Recover()
 	if err != nil {
@@ -265,3 +260,135 @@ func TestWAL_Recover(t *testing.T) {
 		t.Errorf("recover error: got args %+v, expected %+v", requests[2].Args, []string{"lemmy"})
 	}
 }
+
+func TestWAL_LSN(t *testing.T) {
+	logger.MockLogger()
+
+	cfg := &config.WALCfg{
+		WalConfig: &config.WALSettings{
+			FlushingBatchSize:    1,
+			FlushingBatchTimeout: "10ms",
+			MaxSegmentSize:       "1MB",
+			DataDirectory:        "tmp_lsn",
+		},
+	}
+
+	defer func() {
+		_ = os.RemoveAll("tmp_lsn")
+	}()
+
+	wal, err := New(cfg)
+	assert.Nil(t, err)
+
+	startWAL(t, wal)
+
+	assert.Nil(t, wal.Set(context.Background(), "key", "value"))
+	assert.Nil(t, wal.Del(context.Background(), "key"))
+	assert.Equal(t, uint64(2), wal.LastLSN())
+
+	recovered, err := New(cfg)
+	assert.Nil(t, err)
+
+	requests, err := recovered.Recover()
+	assert.Nil(t, err)
+	assert.Len(t, requests, 2)
+	assert.Equal(t, uint64(1), requests[0].LSN)
+	assert.Equal(t, uint64(2), requests[1].LSN)
+	assert.Equal(t, uint64(2), recovered.LastLSN())
+}
+
+func TestWAL_Stats(t *testing.T) {
+	t.Parallel()
+	logger.MockLogger()
+
+	cfg := &config.WALCfg{
+		WalConfig: &config.WALSettings{
+			FlushingBatchSize:    1,
+			FlushingBatchTimeout: "10ms",
+			MaxSegmentSize:       "1MB",
+			DataDirectory:        t.TempDir(),
+		},
+	}
+
+	wal, err := New(cfg)
+	assert.Nil(t, err)
+
+	stats, err := wal.Stats()
+	assert.Nil(t, err)
+	assert.Equal(t, Stats{}, stats)
+
+	startWAL(t, wal)
+
+	start := time.Now()
+	assert.Nil(t, wal.Set(context.Background(), "key", "value"))
+
+	assert.Eventually(t, func() bool {
+		stats, err = wal.Stats()
+		return err == nil && !stats.LastFlush.IsZero()
+	}, time.Second, 5*time.Millisecond)
+	assert.False(t, stats.LastFlush.Before(start))
+	assert.Equal(t, 1, stats.Segments)
+	assert.Regexp(t, `^wal_\d+\.log$`, stats.ActiveSegment)
+	assert.Equal(t, uint64(1), stats.LastLSN)
+}
+
+func TestWAL_Position(t *testing.T) {
+	t.Parallel()
+	logger.MockLogger()
+
+	cfg := &config.WALCfg{
+		WalConfig: &config.WALSettings{
+			FlushingBatchSize:    1,
+			FlushingBatchTimeout: "10ms",
+			MaxSegmentSize:       "1MB",
+			DataDirectory:        t.TempDir(),
+		},
+	}
+
+	wal, err := New(cfg)
+	assert.Nil(t, err)
+
+	position := wal.Position()
+	assert.Equal(t, uint64(0), position.LastLSN())
+	assert.Equal(t, int64(0), position.SizeAfter("", 0))
+
+	startWAL(t, wal)
+
+	// position is updated before requests are acknowledged
+	assert.Nil(t, wal.Set(context.Background(), "key", "value"))
+	assert.Nil(t, wal.Del(context.Background(), "key"))
+	assert.Equal(t, uint64(2), position.LastLSN())
+
+	stats, err := wal.Stats()
+	assert.Nil(t, err)
+	info, err := os.Stat(filepath.Join(cfg.WalConfig.DataDirectory, stats.ActiveSegment))
+	assert.Nil(t, err)
+	assert.Equal(t, info.Size(), position.SizeAfter("", 0))
+	assert.Equal(t, int64(10), position.SizeAfter(stats.ActiveSegment, info.Size()-10))
+	assert.Equal(t, int64(0), position.SizeAfter(stats.ActiveSegment, info.Size()))
+
+	recovered, err := New(cfg)
+	assert.Nil(t, err)
+
+	_, err = recovered.Recover()
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), recovered.Position().LastLSN())
+	assert.Equal(t, info.Size(), recovered.Position().SizeAfter("", 0))
+}
+
+// startWAL runs WAL until the end of test
+func startWAL(t *testing.T, wal *WAL) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		wal.Start(ctx)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
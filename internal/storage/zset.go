@@ -0,0 +1,325 @@
+package storage
+
+import (
+	"fmt"
+
+	"concurrency_go_course/internal/compute"
+)
+
+// zsetEntryOverhead is an approximate size of sorted set member without
+// member data, it is map entry and skiplist node
+const zsetEntryOverhead = 96
+
+// ZMember is a member of sorted set with its score
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// ParseZMembers parses pairs of score and member
+func ParseZMembers(args []string) ([]ZMember, error) {
+	if len(args)%2 != 0 {
+		return nil, fmt.Errorf("expected pairs of score and member, got %d arguments", len(args))
+	}
+
+	members := make([]ZMember, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		score, err := compute.ParseScore(args[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid score %s: %w", args[i], err)
+		}
+		members = append(members, ZMember{Member: args[i+1], Score: score})
+	}
+
+	return members, nil
+}
+
+// zmemberArgs returns pairs of score and member parsed by ParseZMembers
+func zmemberArgs(members []ZMember) []string {
+	args := make([]string, 0, 2*len(members))
+	for _, member := range members {
+		args = append(args, compute.FormatScore(member.Score), member.Member)
+	}
+	return args
+}
+
+// ScoreRange is a range of scores, bounds are inclusive unless they are
+// marked as exclusive
+type ScoreRange struct {
+	Min, Max                   float64
+	MinExclusive, MaxExclusive bool
+}
+
+// NewScoreRange parses bounds of score range, bound starting with "(" is
+// exclusive
+func NewScoreRange(min, max string) (ScoreRange, error) {
+	var (
+		r   ScoreRange
+		err error
+	)
+
+	if r.Min, r.MinExclusive, err = compute.ParseScoreBound(min); err != nil {
+		return ScoreRange{}, fmt.Errorf("invalid min score %s: %w", min, err)
+	}
+	if r.Max, r.MaxExclusive, err = compute.ParseScoreBound(max); err != nil {
+		return ScoreRange{}, fmt.Errorf("invalid max score %s: %w", max, err)
+	}
+
+	return r, nil
+}
+
+// args returns bounds parsed by NewScoreRange
+func (r ScoreRange) args() []string {
+	bound := func(score float64, exclusive bool) string {
+		if exclusive {
+			return "(" + compute.FormatScore(score)
+		}
+		return compute.FormatScore(score)
+	}
+
+	return []string{bound(r.Min, r.MinExclusive), bound(r.Max, r.MaxExclusive)}
+}
+
+func (r ScoreRange) aboveMin(score float64) bool {
+	if r.MinExclusive {
+		return score > r.Min
+	}
+	return score >= r.Min
+}
+
+func (r ScoreRange) belowMax(score float64) bool {
+	if r.MaxExclusive {
+		return score < r.Max
+	}
+	return score <= r.Max
+}
+
+func (r ScoreRange) empty() bool {
+	return r.Min > r.Max || (r.Min == r.Max && (r.MinExclusive || r.MaxExclusive))
+}
+
+// zset is a sorted set, members are ordered by skiplist and their scores
+// are looked up in map
+type zset struct {
+	scores map[string]float64
+	list   *skiplist
+}
+
+func newZSet() *zset {
+	return &zset{
+		scores: make(map[string]float64),
+		list:   newSkiplist(),
+	}
+}
+
+func (z *zset) len() int {
+	return len(z.scores)
+}
+
+// add sets score of member, it returns true if member is new
+func (z *zset) add(member string, score float64) bool {
+	old, found := z.scores[member]
+	if found {
+		if old == score {
+			return false
+		}
+		z.list.delete(old, member)
+	}
+
+	z.scores[member] = score
+	z.list.insert(score, member)
+
+	return !found
+}
+
+// remove deletes member, it returns false if there is no such member
+func (z *zset) remove(member string) bool {
+	score, found := z.scores[member]
+	if !found {
+		return false
+	}
+
+	delete(z.scores, member)
+	z.list.delete(score, member)
+
+	return true
+}
+
+// inRange returns members with score in range in order
+func (z *zset) inRange(r ScoreRange) []ZMember {
+	var members []ZMember
+	for x := z.list.firstInRange(r); x != nil && r.belowMax(x.score); x = x.next() {
+		members = append(members, ZMember{Member: x.member, Score: x.score})
+	}
+	return members
+}
+
+// ZAdd sets scores of members of sorted set, it returns number of added
+// members
+func (s *HashTable) ZAdd(key string, members []ZMember) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	v, found := s.data[key]
+	if found && v.kind != TypeZSet {
+		return 0, ErrWrongType
+	}
+	if !found {
+		v = value{kind: TypeZSet, zset: newZSet()}
+		s.data[key] = v
+		s.bytes += entrySize(key, "")
+	}
+
+	added := 0
+	for _, member := range members {
+		if v.zset.add(member.Member, member.Score) {
+			s.bytes += len(member.Member) + zsetEntryOverhead
+			added++
+		}
+	}
+
+	return added, nil
+}
+
+// ZRem removes members of sorted set, sorted set without members is
+// deleted, it returns number of removed members
+func (s *HashTable) ZRem(key string, members []string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	v, found := s.data[key]
+	if !found {
+		return 0, nil
+	}
+	if v.kind != TypeZSet {
+		return 0, ErrWrongType
+	}
+
+	removed := 0
+	for _, member := range members {
+		if v.zset.remove(member) {
+			s.bytes -= len(member) + zsetEntryOverhead
+			removed++
+		}
+	}
+
+	if v.zset.len() == 0 {
+		s.remove(key)
+	}
+
+	return removed, nil
+}
+
+// ZScore returns score of sorted set member
+func (s *HashTable) ZScore(key, member string) (float64, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	v, found := s.data[key]
+	if !found {
+		return 0, false, nil
+	}
+	if v.kind != TypeZSet {
+		return 0, false, ErrWrongType
+	}
+
+	score, found := v.zset.scores[member]
+	return score, found, nil
+}
+
+// ZRange returns members of sorted set from rank start to stop inclusive
+// in order of score, negative rank is an offset from the end, ranks out of
+// set are limited by its bounds
+func (s *HashTable) ZRange(key string, start, stop int) ([]ZMember, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	v, found := s.data[key]
+	if !found {
+		return nil, nil
+	}
+	if v.kind != TypeZSet {
+		return nil, ErrWrongType
+	}
+
+	length := v.zset.len()
+	if start < 0 {
+		start = max(length+start, 0)
+	}
+	if stop < 0 {
+		stop = length + stop
+	}
+	stop = min(stop, length-1)
+
+	if start > stop {
+		return nil, nil
+	}
+
+	members := make([]ZMember, 0, stop-start+1)
+	for x := v.zset.list.byRank(start); len(members) < cap(members); x = x.next() {
+		members = append(members, ZMember{Member: x.member, Score: x.score})
+	}
+	return members, nil
+}
+
+// ZRangeByScore returns members of sorted set with score in range in order
+// of score
+func (s *HashTable) ZRangeByScore(key string, r ScoreRange) ([]ZMember, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	v, found := s.data[key]
+	if !found {
+		return nil, nil
+	}
+	if v.kind != TypeZSet {
+		return nil, ErrWrongType
+	}
+
+	return v.zset.inRange(r), nil
+}
+
+// ZRemRangeByScore removes members of sorted set with score in range,
+// sorted set without members is deleted, it returns number of removed
+// members
+func (s *HashTable) ZRemRangeByScore(key string, r ScoreRange) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	v, found := s.data[key]
+	if !found {
+		return 0, nil
+	}
+	if v.kind != TypeZSet {
+		return 0, ErrWrongType
+	}
+
+	members := v.zset.inRange(r)
+	for _, member := range members {
+		v.zset.remove(member.Member)
+		s.bytes -= len(member.Member) + zsetEntryOverhead
+	}
+
+	if v.zset.len() == 0 {
+		s.remove(key)
+	}
+
+	return len(members), nil
+}
+
+// ZCard returns number of sorted set members, it is zero if key does not
+// exist
+func (s *HashTable) ZCard(key string) (int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	v, found := s.data[key]
+	if !found {
+		return 0, nil
+	}
+	if v.kind != TypeZSet {
+		return 0, ErrWrongType
+	}
+
+	return v.zset.len(), nil
+}
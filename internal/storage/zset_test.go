@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashTable_ZSet(t *testing.T) {
+	t.Parallel()
+
+	t.Run("add, score and remove members", func(t *testing.T) {
+		table := NewHashTable()
+
+		added, err := table.ZAdd("board", []ZMember{{Member: "alice", Score: 30}, {Member: "bob", Score: 10}})
+		require.NoError(t, err)
+		require.Equal(t, 2, added)
+
+		// score of existing member is updated
+		added, err = table.ZAdd("board", []ZMember{{Member: "bob", Score: 40}, {Member: "carol", Score: 20}})
+		require.NoError(t, err)
+		require.Equal(t, 1, added)
+		require.Equal(t, TypeZSet, table.Type("board"))
+
+		score, found, err := table.ZScore("board", "bob")
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, float64(40), score)
+
+		members, err := table.ZRange("board", 0, -1)
+		require.NoError(t, err)
+		require.Equal(t, []ZMember{{"carol", 20}, {"alice", 30}, {"bob", 40}}, members)
+
+		removed, err := table.ZRem("board", []string{"alice", "dave"})
+		require.NoError(t, err)
+		require.Equal(t, 1, removed)
+
+		count, err := table.ZCard("board")
+		require.NoError(t, err)
+		require.Equal(t, 2, count)
+	})
+
+	t.Run("remove by score", func(t *testing.T) {
+		table := NewHashTable()
+
+		_, err := table.ZAdd("window", []ZMember{{"a", 1}, {"b", 2}, {"c", 3}})
+		require.NoError(t, err)
+
+		removed, err := table.ZRemRangeByScore("window", ScoreRange{Min: math.Inf(-1), Max: 2, MaxExclusive: true})
+		require.NoError(t, err)
+		require.Equal(t, 1, removed)
+
+		members, err := table.ZRangeByScore("window", ScoreRange{Min: math.Inf(-1), Max: math.Inf(1)})
+		require.NoError(t, err)
+		require.Equal(t, []ZMember{{"b", 2}, {"c", 3}}, members)
+
+		// sorted set without members is deleted
+		removed, err = table.ZRemRangeByScore("window", ScoreRange{Min: 0, Max: 10})
+		require.NoError(t, err)
+		require.Equal(t, 2, removed)
+		require.Equal(t, TypeNone, table.Type("window"))
+		require.Equal(t, 0, table.Bytes())
+	})
+
+	t.Run("wrong type", func(t *testing.T) {
+		table := NewHashTable()
+		table.Set("key", "value")
+
+		_, err := table.ZAdd("key", []ZMember{{"a", 1}})
+		require.ErrorIs(t, err, ErrWrongType)
+
+		_, _, err = table.ZScore("key", "a")
+		require.ErrorIs(t, err, ErrWrongType)
+
+		_, err = table.ZRangeByScore("key", ScoreRange{Max: 1})
+		require.ErrorIs(t, err, ErrWrongType)
+
+		_, err = table.ZCard("key")
+		require.ErrorIs(t, err, ErrWrongType)
+	})
+
+	t.Run("bytes", func(t *testing.T) {
+		table := NewHashTable()
+
+		_, err := table.ZAdd("board", []ZMember{{"alice", 1}, {"bob", 2}})
+		require.NoError(t, err)
+		require.Equal(t, entrySize("board", "")+8+2*zsetEntryOverhead, table.Bytes())
+
+		// replacing sorted set by string releases its members
+		table.Set("board", "value")
+		require.Equal(t, entrySize("board", "value"), table.Bytes())
+	})
+}
+
+func TestHashTable_ZRange(t *testing.T) {
+	t.Parallel()
+
+	table := NewHashTable()
+	_, err := table.ZAdd("board", []ZMember{{"a", 1}, {"b", 2}, {"c", 3}, {"d", 4}})
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		start, stop int
+		expected    []string
+	}{
+		"all":                {start: 0, stop: -1, expected: []string{"a", "b", "c", "d"}},
+		"top two":            {start: -2, stop: -1, expected: []string{"c", "d"}},
+		"stop after the end": {start: 2, stop: 100, expected: []string{"c", "d"}},
+		"start after stop":   {start: 3, stop: 1},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			members, err := table.ZRange("board", test.start, test.stop)
+			require.NoError(t, err)
+
+			var names []string
+			for _, member := range members {
+				names = append(names, member.Member)
+			}
+			assert.Equal(t, test.expected, names)
+		})
+	}
+}
+
+func TestScoreRange(t *testing.T) {
+	t.Parallel()
+
+	r, err := NewScoreRange("(1.5", "+inf")
+	require.NoError(t, err)
+	assert.Equal(t, ScoreRange{Min: 1.5, Max: math.Inf(1), MinExclusive: true}, r)
+	assert.Equal(t, []string{"(1.5", "inf"}, r.args())
+
+	_, err = NewScoreRange("low", "10")
+	assert.Error(t, err)
+
+	members, err := ParseZMembers(zmemberArgs([]ZMember{{"a", 0.1}, {"b", math.Inf(-1)}}))
+	require.NoError(t, err)
+	assert.Equal(t, []ZMember{{"a", 0.1}, {"b", math.Inf(-1)}}, members)
+
+	_, err = ParseZMembers([]string{"nan", "a"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,43 @@
+package watch
+
+import (
+	"concurrency_go_course/internal/storage"
+)
+
+// engine publishes changes applied to wrapped engine, so local writes,
+// WAL recovery, replication stream and consensus log are all observed
+type engine struct {
+	storage.Engine
+	hub *Hub
+}
+
+// NewEngine returns engine which publishes changes to hub, snapshot
+// restore replaces data without events
+func NewEngine(e storage.Engine, hub *Hub) storage.Engine {
+	return &engine{
+		Engine: e,
+		hub:    hub,
+	}
+}
+
+// Set sets value and publishes set event
+func (e *engine) Set(key string, value string) {
+	e.Engine.Set(key, value)
+	e.hub.Publish(Event{Type: EventSet, Key: key, Value: value})
+}
+
+// Delete deletes key and publishes delete event
+func (e *engine) Delete(key string) {
+	e.Engine.Delete(key)
+	e.hub.Publish(Event{Type: EventDelete, Key: key})
+}
+
+// DeleteExpired deletes expired key and publishes delete event if key was
+// deleted
+func (e *engine) DeleteExpired(key string, deadline int64) bool {
+	deleted := e.Engine.DeleteExpired(key, deadline)
+	if deleted {
+		e.hub.Publish(Event{Type: EventDelete, Key: key})
+	}
+	return deleted
+}
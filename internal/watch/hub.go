@@ -0,0 +1,126 @@
+// Package watch delivers key change events to subscribers
+package watch
+
+import (
+	"strings"
+	"sync"
+)
+
+// Event types
+const (
+	EventSet    = "set"
+	EventDelete = "del"
+)
+
+// defaultBufferSize is a number of events buffered for each subscriber
+const defaultBufferSize = 256
+
+// Event is a change of one key
+type Event struct {
+	Type  string `json:"type"`
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+}
+
+// Hub fans out events to subscribers, slow subscribers which do not drain
+// their buffer are dropped so writes are never blocked
+type Hub struct {
+	mutex       sync.RWMutex
+	subscribers map[*Subscription]struct{}
+	bufferSize  int
+}
+
+// NewHub returns new hub
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[*Subscription]struct{}),
+		bufferSize:  defaultBufferSize,
+	}
+}
+
+// Subscription receives events for keys with prefix, empty prefix matches
+// all keys
+type Subscription struct {
+	hub     *Hub
+	prefix  string
+	events  chan Event
+	dropped bool
+}
+
+// Subscribe returns new subscription, it must be closed by caller
+func (h *Hub) Subscribe(prefix string) *Subscription {
+	sub := &Subscription{
+		hub:    h,
+		prefix: prefix,
+		events: make(chan Event, h.bufferSize),
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Publish sends event to matching subscribers
+func (h *Hub) Publish(event Event) {
+	var slow []*Subscription
+
+	h.mutex.RLock()
+	for sub := range h.subscribers {
+		if !strings.HasPrefix(event.Key, sub.prefix) {
+			continue
+		}
+
+		select {
+		case sub.events <- event:
+		default:
+			slow = append(slow, sub)
+		}
+	}
+	h.mutex.RUnlock()
+
+	if len(slow) == 0 {
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for _, sub := range slow {
+		if _, ok := h.subscribers[sub]; ok {
+			sub.dropped = true
+			h.remove(sub)
+		}
+	}
+}
+
+// Events returns channel with events, it is closed when subscription is
+// closed or dropped
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Dropped returns true if subscription was dropped because its buffer
+// was full, it must be called after events channel is closed
+func (s *Subscription) Dropped() bool {
+	s.hub.mutex.RLock()
+	defer s.hub.mutex.RUnlock()
+
+	return s.dropped
+}
+
+// Close unsubscribes from hub
+func (s *Subscription) Close() {
+	s.hub.mutex.Lock()
+	defer s.hub.mutex.Unlock()
+
+	if _, ok := s.hub.subscribers[s]; ok {
+		s.hub.remove(s)
+	}
+}
+
+func (h *Hub) remove(sub *Subscription) {
+	delete(h.subscribers, sub)
+	close(sub.events)
+}
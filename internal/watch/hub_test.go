@@ -0,0 +1,65 @@
+package watch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"concurrency_go_course/internal/storage"
+)
+
+func TestHub(t *testing.T) {
+	t.Parallel()
+
+	hub := NewHub()
+	all := hub.Subscribe("")
+	defer all.Close()
+	users := hub.Subscribe("user:")
+	defer users.Close()
+
+	e := NewEngine(storage.NewEngine(4), hub)
+	e.Set("user:1", "alice")
+	e.Set("order:1", "book")
+	e.Delete("user:1")
+
+	assert.Equal(t, Event{Type: EventSet, Key: "user:1", Value: "alice"}, <-all.Events())
+	assert.Equal(t, Event{Type: EventSet, Key: "order:1", Value: "book"}, <-all.Events())
+	assert.Equal(t, Event{Type: EventDelete, Key: "user:1"}, <-all.Events())
+
+	assert.Equal(t, Event{Type: EventSet, Key: "user:1", Value: "alice"}, <-users.Events())
+	assert.Equal(t, Event{Type: EventDelete, Key: "user:1"}, <-users.Events())
+	assert.Empty(t, users.Events())
+
+	value, ok, _ := e.Get("order:1")
+	assert.True(t, ok)
+	assert.Equal(t, "book", value)
+}
+
+func TestHubDropSlowSubscriber(t *testing.T) {
+	t.Parallel()
+
+	hub := NewHub()
+	hub.bufferSize = 2
+
+	sub := hub.Subscribe("")
+	for range 3 {
+		hub.Publish(Event{Type: EventSet, Key: "key"})
+	}
+
+	count := 0
+	for range sub.Events() {
+		count++
+	}
+	assert.Equal(t, 2, count)
+	assert.True(t, sub.Dropped())
+
+	// closing dropped subscription is safe
+	sub.Close()
+
+	sub = hub.Subscribe("")
+	sub.Close()
+	_, ok := <-sub.Events()
+	require.False(t, ok)
+	assert.False(t, sub.Dropped())
+}
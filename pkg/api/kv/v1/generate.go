@@ -0,0 +1,3 @@
+package kvv1
+
+//go:generate protoc -I ../../../../api/proto --go_out=../../../.. --go_opt=module=concurrency_go_course --go-grpc_out=../../../.. --go-grpc_opt=module=concurrency_go_course kv/v1/kv.proto
@@ -0,0 +1,456 @@
+// Package client is a Go client of database text protocol with connection
+// pooling, retries and routing of reads to replicas
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"concurrency_go_course/internal/compute"
+	"concurrency_go_course/internal/network"
+)
+
+const (
+	defaultPoolSize    = 4
+	defaultDialTimeout = 5 * time.Second
+	defaultTimeout     = 5 * time.Second
+	defaultMaxRetries  = 3
+	defaultMinBackoff  = 50 * time.Millisecond
+	defaultMaxBackoff  = time.Second
+)
+
+// Errors returned by server are wrapped with message from server
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrReadOnlyReplica  = errors.New("read-only replica")
+	ErrInvalidArgument  = errors.New("invalid argument")
+	ErrTooLarge         = errors.New("too large")
+	ErrInternal         = errors.New("internal error")
+	ErrAuthRequired     = errors.New("authentication required")
+	ErrAuthFailed       = errors.New("authentication failed")
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrWrongType        = errors.New("wrong type")
+	ErrClosed           = errors.New("client is closed")
+)
+
+// Options is a client configuration
+type Options struct {
+	// Master receives writes, and reads if there are no replicas
+	Master string
+	// Replicas receive reads in round-robin order, master is the last
+	// candidate for retried reads
+	Replicas []string
+
+	// PoolSize limits number of connections per address
+	PoolSize int
+	// DialTimeout limits connection establishment
+	DialTimeout time.Duration
+	// Timeout is used for calls with context without deadline
+	Timeout time.Duration
+
+	// MaxRetries limits retries of reads and of writes which were not
+	// sent, MaxRetries < 0 disables retries
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// TLSConfig enables TLS, server name is taken from address if it is
+	// not set
+	TLSConfig *tls.Config
+	// Username and Password are sent with AUTH on every new connection
+	Username string
+	Password string
+}
+
+// Client is a database client, it is safe for concurrent use
+type Client struct {
+	master   *pool
+	replicas []*pool
+	next     atomic.Uint64
+	options  Options
+	dialer   *net.Dialer
+	closed   atomic.Bool
+}
+
+// New returns new client, connections are established on first calls
+func New(options Options) (*Client, error) {
+	if options.Master == "" {
+		return nil, fmt.Errorf("master address is empty")
+	}
+
+	if options.PoolSize <= 0 {
+		options.PoolSize = defaultPoolSize
+	}
+	if options.DialTimeout <= 0 {
+		options.DialTimeout = defaultDialTimeout
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = defaultTimeout
+	}
+	if options.MaxRetries == 0 {
+		options.MaxRetries = defaultMaxRetries
+	}
+	if options.MinBackoff <= 0 {
+		options.MinBackoff = defaultMinBackoff
+	}
+	if options.MaxBackoff < options.MinBackoff {
+		options.MaxBackoff = max(defaultMaxBackoff, options.MinBackoff)
+	}
+
+	if options.Username != "" && (!validArg(options.Username) || !validArg(options.Password)) {
+		return nil, fmt.Errorf("%w: username and password must be set without whitespace", ErrInvalidArgument)
+	}
+
+	c := &Client{
+		options: options,
+		dialer:  &net.Dialer{Timeout: options.DialTimeout},
+	}
+
+	c.master = newPool(options.Master, options.PoolSize, c.dial)
+	for _, address := range options.Replicas {
+		c.replicas = append(c.replicas, newPool(address, options.PoolSize, c.dial))
+	}
+
+	return c, nil
+}
+
+// Get returns value by key from replica
+func (c *Client) Get(ctx context.Context, key string) (string, error) {
+	results, err := c.Pipeline().Get(key).Exec(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return results[0].Value, results[0].Err
+}
+
+// Set sets value for key on master
+func (c *Client) Set(ctx context.Context, key, value string) error {
+	results, err := c.Pipeline().Set(key, value).Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	return results[0].Err
+}
+
+// Del deletes key on master
+func (c *Client) Del(ctx context.Context, key string) error {
+	results, err := c.Pipeline().Del(key).Exec(ctx)
+	if err != nil {
+		return err
+	}
+
+	return results[0].Err
+}
+
+// MGet returns values of existing keys, missing keys are not in result
+func (c *Client) MGet(ctx context.Context, keys ...string) (map[string]string, error) {
+	pipeline := c.Pipeline()
+	for _, key := range keys {
+		pipeline.Get(key)
+	}
+
+	results, err := pipeline.Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(keys))
+	for i, result := range results {
+		if errors.Is(result.Err, ErrNotFound) {
+			continue
+		}
+
+		if result.Err != nil {
+			return nil, result.Err
+		}
+
+		values[keys[i]] = result.Value
+	}
+
+	return values, nil
+}
+
+// Close closes idle connections, connections in use are closed when
+// calls finish
+func (c *Client) Close() error {
+	c.closed.Store(true)
+
+	c.master.close()
+	for _, replica := range c.replicas {
+		replica.close()
+	}
+
+	return nil
+}
+
+// Result is a result of one command of pipeline, position is set if
+// command was answered by replica
+type Result struct {
+	Value    string
+	Err      error
+	Position *Position
+}
+
+// Position is an applied position of replica, lag is not known until
+// replica synced with master
+type Position struct {
+	AppliedLSN uint64
+	Lag        time.Duration
+	LagKnown   bool
+}
+
+// Pipeline sends commands on one connection without waiting for responses,
+// commands are executed in order but not atomically
+type Pipeline struct {
+	client   *Client
+	requests []string
+	write    bool
+	err      error
+}
+
+// Pipeline returns empty pipeline
+func (c *Client) Pipeline() *Pipeline {
+	return &Pipeline{client: c}
+}
+
+// Get adds GET command
+func (p *Pipeline) Get(key string) *Pipeline {
+	return p.add(compute.CommandGet, key)
+}
+
+// Set adds SET command, pipeline with writes is sent to master
+func (p *Pipeline) Set(key, value string) *Pipeline {
+	p.write = true
+	return p.add(compute.CommandSet, key, value)
+}
+
+// Del adds DEL command, pipeline with writes is sent to master
+func (p *Pipeline) Del(key string) *Pipeline {
+	p.write = true
+	return p.add(compute.CommandDelete, key)
+}
+
+func (p *Pipeline) add(command string, args ...string) *Pipeline {
+	for _, arg := range args {
+		if !validArg(arg) {
+			p.err = fmt.Errorf("%w: %s argument %q is empty or contains whitespace",
+				ErrInvalidArgument, command, arg)
+		}
+	}
+
+	p.requests = append(p.requests, command+" "+strings.Join(args, " "))
+	return p
+}
+
+// Exec sends commands and returns their results in order, error is
+// returned if commands were not executed
+func (p *Pipeline) Exec(ctx context.Context) ([]Result, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	if len(p.requests) == 0 {
+		return nil, nil
+	}
+
+	return p.client.execute(ctx, p.requests, p.write)
+}
+
+// execute sends requests with retries, reads are retried after any
+// connection error, writes only if they were not sent
+func (c *Client) execute(ctx context.Context, requests []string, write bool) ([]Result, error) {
+	if c.closed.Load() {
+		return nil, ErrClosed
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.options.Timeout)
+		defer cancel()
+	}
+
+	candidates := c.candidates(write)
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		var results []Result
+		results, err = c.send(ctx, candidates[attempt%len(candidates)], requests)
+		if err == nil {
+			return results, nil
+		}
+
+		var dialErr *dialError
+		retryable := !write || errors.As(err, &dialErr)
+		if !retryable || ctx.Err() != nil || errors.Is(err, ErrClosed) || errors.Is(err, ErrAuthFailed) ||
+			c.options.MaxRetries < 0 || attempt >= c.options.MaxRetries {
+			return nil, err
+		}
+
+		timer := time.NewTimer(c.retryDelay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("%w: last error: %w", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+// candidates returns master for writes, and replicas in round-robin order
+// followed by master for reads
+func (c *Client) candidates(write bool) []*pool {
+	if write || len(c.replicas) == 0 {
+		return []*pool{c.master}
+	}
+
+	start := int(c.next.Add(1) % uint64(len(c.replicas)))
+	candidates := make([]*pool, 0, len(c.replicas)+1)
+	candidates = append(candidates, c.replicas[start:]...)
+	candidates = append(candidates, c.replicas[:start]...)
+	return append(candidates, c.master)
+}
+
+// dial establishes connection and authenticates it, connection errors are
+// returned as *dialError because no command was sent
+func (c *Client) dial(ctx context.Context, address string) (*network.TCPClient, error) {
+	var conn net.Conn
+	var err error
+	if c.options.TLSConfig != nil {
+		tlsDialer := &tls.Dialer{NetDialer: c.dialer, Config: c.options.TLSConfig}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = c.dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return nil, &dialError{err: err}
+	}
+
+	client := network.NewClientWithConn(conn)
+	if c.options.Username == "" {
+		return client, nil
+	}
+
+	future := client.QueryAsync([]byte(compute.CommandAuth + " " + c.options.Username + " " + c.options.Password))
+	select {
+	case <-future.Done():
+	case <-ctx.Done():
+		client.Close()
+		return nil, ctx.Err()
+	}
+
+	if _, err := future.Wait(); err != nil {
+		client.Close()
+
+		var respErr *network.ResponseError
+		if errors.As(err, &respErr) {
+			return nil, responseError(respErr)
+		}
+		return nil, &dialError{err: err}
+	}
+
+	return client, nil
+}
+
+// send pipelines requests on one connection, connection is discarded if
+// response was not read completely
+func (c *Client) send(ctx context.Context, p *pool, requests []string) ([]Result, error) {
+	conn, err := p.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	broken := true
+	defer func() {
+		p.put(conn, broken)
+	}()
+
+	futures := make([]*network.Future, 0, len(requests))
+	for _, request := range requests {
+		futures = append(futures, conn.QueryAsync([]byte(request)))
+	}
+
+	results := make([]Result, 0, len(requests))
+	for _, future := range futures {
+		select {
+		case <-future.Done():
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		value, err := future.Wait()
+		if err != nil {
+			var respErr *network.ResponseError
+			if !errors.As(err, &respErr) {
+				return nil, err
+			}
+			err = responseError(respErr)
+		}
+
+		result := Result{Value: string(value), Err: err}
+		if position := future.Position(); position != nil {
+			result.Position = &Position{
+				AppliedLSN: position.AppliedLSN,
+				Lag:        position.Lag,
+				LagKnown:   position.LagKnown,
+			}
+		}
+		results = append(results, result)
+	}
+
+	broken = false
+	return results, nil
+}
+
+// retryDelay returns jittered exponential delay in [delay/2, delay]
+func (c *Client) retryDelay(attempt int) time.Duration {
+	delay := c.options.MinBackoff
+	for i := 0; i < attempt && delay < c.options.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, c.options.MaxBackoff)
+
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+func responseError(err *network.ResponseError) error {
+	var sentinel error
+	switch err.Code {
+	case network.CodeNotFound:
+		sentinel = ErrNotFound
+	case network.CodeReadOnlyReplica:
+		sentinel = ErrReadOnlyReplica
+	case network.CodeParseError:
+		sentinel = ErrInvalidArgument
+	case network.CodeTooLarge:
+		sentinel = ErrTooLarge
+	case network.CodeAuthRequired:
+		sentinel = ErrAuthRequired
+	case network.CodeAuthFailed:
+		sentinel = ErrAuthFailed
+	case network.CodePermissionDenied:
+		sentinel = ErrPermissionDenied
+	case network.CodeWrongType:
+		sentinel = ErrWrongType
+	default:
+		sentinel = ErrInternal
+	}
+
+	return fmt.Errorf("%w: %s", sentinel, err.Message)
+}
+
+// validArg reports whether argument may be sent in text protocol, which
+// splits request by whitespace
+func validArg(arg string) bool {
+	return arg != "" && !strings.ContainsFunc(arg, unicode.IsSpace)
+}
@@ -0,0 +1,326 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"concurrency_go_course/internal/app"
+	"concurrency_go_course/internal/auth"
+	"concurrency_go_course/internal/compute"
+	"concurrency_go_course/internal/config"
+	"concurrency_go_course/internal/database"
+	"concurrency_go_course/internal/network"
+	"concurrency_go_course/internal/network/tlstest"
+	"concurrency_go_course/internal/replication"
+	"concurrency_go_course/internal/storage"
+	"concurrency_go_course/internal/storage/wal"
+	"concurrency_go_course/pkg/logger"
+)
+
+func runTestServer(t *testing.T, addr string, tlsCfg *config.TLSConfig, handler network.TCPHandler) {
+	t.Helper()
+
+	cfg := &config.Config{
+		Network: &config.NetworkConfig{
+			MaxConnections: 100,
+			MaxMessageSize: "1KB",
+			IdleTimeout:    "5m",
+		},
+	}
+
+	server, err := network.NewServerWithTLS(cfg, addr, tlsCfg)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.Run(ctx, handler)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func runTestDatabase(t *testing.T, addr, replicaType string, requests ...wal.Request) {
+	t.Helper()
+
+	store, err := storage.New(storage.NewEngine(4), nil, replicaType, nil)
+	require.NoError(t, err)
+	store.Restore(requests)
+
+	db := database.NewDatabase(store, compute.NewCompute(compute.NewRequestParser()))
+	if replicaType == replication.ReplicaTypeSlave {
+		db = database.NewReplicaDatabase(store, compute.NewCompute(compute.NewRequestParser()),
+			syncedReplica{lsn: uint64(len(requests))})
+	}
+	runTestServer(t, addr, nil, app.QueryHandler(db))
+}
+
+// syncedReplica is a replica which applied lsn and has no lag
+type syncedReplica struct {
+	lsn uint64
+}
+
+func (r syncedReplica) AppliedLSN() uint64 {
+	return r.lsn
+}
+
+func (r syncedReplica) Lag() (time.Duration, bool) {
+	return 0, true
+}
+
+func (r syncedReplica) WaitLSN(context.Context, uint64) error {
+	return nil
+}
+
+func (r syncedReplica) WaitLag(context.Context, time.Duration) error {
+	return nil
+}
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	master := "127.0.0.1:7301"
+	replica := "127.0.0.1:7302"
+	runTestDatabase(t, master, replication.ReplicaTypeMaster)
+	// replica has own value to check that reads are routed to it
+	runTestDatabase(t, replica, replication.ReplicaTypeSlave,
+		wal.Request{Command: compute.CommandSet, Args: []string{"replicated", "replica"}})
+
+	c, err := New(Options{Master: master, Replicas: []string{replica}, PoolSize: 2})
+	require.NoError(t, err)
+	defer c.Close()
+
+	ctx := context.Background()
+
+	t.Run("writes go to master", func(t *testing.T) {
+		require.NoError(t, c.Set(ctx, "key", "value"))
+		require.NoError(t, c.Del(ctx, "key"))
+	})
+
+	t.Run("reads go to replica", func(t *testing.T) {
+		value, err := c.Get(ctx, "replicated")
+		require.NoError(t, err)
+		assert.Equal(t, "replica", value)
+
+		_, err = c.Get(ctx, "missing")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("mget skips missing keys", func(t *testing.T) {
+		values, err := c.MGet(ctx, "replicated", "missing")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"replicated": "replica"}, values)
+	})
+
+	t.Run("pipeline with writes goes to master", func(t *testing.T) {
+		results, err := c.Pipeline().
+			Set("pipelined", "1").
+			Get("pipelined").
+			Get("replicated").
+			Del("pipelined").
+			Exec(ctx)
+		require.NoError(t, err)
+		require.Len(t, results, 4)
+
+		assert.NoError(t, results[0].Err)
+		assert.Equal(t, "1", results[1].Value)
+		assert.ErrorIs(t, results[2].Err, ErrNotFound)
+		assert.NoError(t, results[3].Err)
+		assert.Nil(t, results[1].Position)
+	})
+
+	t.Run("replica reports position", func(t *testing.T) {
+		results, err := c.Pipeline().Get("replicated").Get("missing").Exec(ctx)
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+
+		position := &Position{AppliedLSN: 1, LagKnown: true}
+		assert.Equal(t, Result{Value: "replica", Position: position}, results[0])
+		assert.ErrorIs(t, results[1].Err, ErrNotFound)
+		assert.Equal(t, position, results[1].Position)
+	})
+
+	t.Run("invalid argument", func(t *testing.T) {
+		err := c.Set(ctx, "key", "two words")
+		assert.ErrorIs(t, err, ErrInvalidArgument)
+
+		_, err = c.Get(ctx, "")
+		assert.ErrorIs(t, err, ErrInvalidArgument)
+	})
+
+	t.Run("concurrent calls share bounded pool", func(t *testing.T) {
+		errs := make(chan error, 20)
+		for range 20 {
+			go func() {
+				_, err := c.Get(ctx, "replicated")
+				errs <- err
+			}()
+		}
+
+		for range 20 {
+			assert.NoError(t, <-errs)
+		}
+	})
+}
+
+func TestClientReadOnlyReplica(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	// replica configured as master receives writes
+	replica := "127.0.0.1:7303"
+	runTestDatabase(t, replica, replication.ReplicaTypeSlave)
+
+	c, err := New(Options{Master: replica})
+	require.NoError(t, err)
+	defer c.Close()
+
+	err = c.Set(context.Background(), "key", "value")
+	assert.ErrorIs(t, err, ErrReadOnlyReplica)
+}
+
+func TestClientAuth(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	addr := "127.0.0.1:7306"
+	certs := tlstest.Generate(t)
+
+	hash, err := auth.HashPassword("secret")
+	require.NoError(t, err)
+	users, err := auth.NewUsers(auth.Config{Users: []auth.User{{Name: "alice", PasswordHash: hash}}})
+	require.NoError(t, err)
+
+	store, err := storage.New(storage.NewEngine(4), nil, replication.ReplicaTypeMaster, nil)
+	require.NoError(t, err)
+	db := database.NewDatabase(store, compute.NewCompute(compute.NewRequestParser()))
+
+	runTestServer(t, addr, certs.Server(), app.ConnectionHandler(users, app.QueryHandler(db)))
+
+	tlsConfig, err := network.ClientTLSConfig(certs.Client())
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		username string
+		password string
+		err      error
+	}{
+		"authenticated":   {username: "alice", password: "secret"},
+		"wrong password":  {username: "alice", password: "wrong", err: ErrAuthFailed},
+		"unauthenticated": {err: ErrAuthRequired},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, err := New(Options{
+				Master:     addr,
+				TLSConfig:  tlsConfig,
+				Username:   test.username,
+				Password:   test.password,
+				MinBackoff: time.Millisecond,
+			})
+			require.NoError(t, err)
+			defer c.Close()
+
+			err = c.Set(context.Background(), "key", "value")
+			if test.err != nil {
+				assert.ErrorIs(t, err, test.err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+
+	_, err = New(Options{Master: addr, Username: "alice"})
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+}
+
+func TestClientRetries(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	master := "127.0.0.1:7304"
+	runTestDatabase(t, master, replication.ReplicaTypeMaster,
+		wal.Request{Command: compute.CommandSet, Args: []string{"key", "master"}})
+
+	// nothing listens on replica address
+	c, err := New(Options{
+		Master:     master,
+		Replicas:   []string{"127.0.0.1:7399"},
+		MinBackoff: time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer c.Close()
+
+	value, err := c.Get(context.Background(), "key")
+	require.NoError(t, err)
+	assert.Equal(t, "master", value)
+
+	// write is not retried on replica and master is down
+	down, err := New(Options{Master: "127.0.0.1:7399", MaxRetries: 2, MinBackoff: time.Millisecond})
+	require.NoError(t, err)
+	defer down.Close()
+
+	err = down.Set(context.Background(), "key", "value")
+	assert.ErrorContains(t, err, "unable to connect")
+}
+
+func TestClientDeadline(t *testing.T) {
+	t.Parallel()
+
+	logger.MockLogger()
+
+	addr := "127.0.0.1:7305"
+	runTestServer(t, addr, nil, func(ctx context.Context, _ []byte) []byte {
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+		}
+		return network.NewResponse([]byte("late")).Encode()
+	})
+
+	c, err := New(Options{Master: addr, MaxRetries: -1})
+	require.NoError(t, err)
+	defer c.Close()
+
+	t.Run("context deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := c.Get(ctx, "key")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+
+	t.Run("default timeout", func(t *testing.T) {
+		c, err := New(Options{Master: addr, Timeout: 50 * time.Millisecond, MaxRetries: -1})
+		require.NoError(t, err)
+		defer c.Close()
+
+		_, err = c.Get(context.Background(), "key")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("closed client", func(t *testing.T) {
+		c, err := New(Options{Master: addr})
+		require.NoError(t, err)
+		c.Close()
+
+		_, err = c.Get(context.Background(), "key")
+		assert.ErrorIs(t, err, ErrClosed)
+	})
+}
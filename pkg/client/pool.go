@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"sync"
+
+	"concurrency_go_course/internal/network"
+)
+
+// dialFunc establishes connection to address, it returns *dialError if
+// request may be retried
+type dialFunc func(ctx context.Context, address string) (*network.TCPClient, error)
+
+// pool is a bounded pool of connections to one address, connections are
+// dialed lazily
+type pool struct {
+	address string
+	dial    dialFunc
+
+	// slots limits number of connections in use and idle
+	slots chan struct{}
+
+	mutex  sync.Mutex
+	idle   []*network.TCPClient
+	closed bool
+}
+
+func newPool(address string, size int, dial dialFunc) *pool {
+	return &pool{
+		address: address,
+		dial:    dial,
+		slots:   make(chan struct{}, size),
+	}
+}
+
+// get returns idle connection or dials new one, it waits for free slot
+// until context is done
+func (p *pool) get(ctx context.Context) (*network.TCPClient, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		<-p.slots
+		return nil, ErrClosed
+	}
+
+	if n := len(p.idle); n > 0 {
+		conn := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mutex.Unlock()
+		return conn, nil
+	}
+	p.mutex.Unlock()
+
+	conn, err := p.dial(ctx, p.address)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// put returns connection to pool, broken connection is closed
+func (p *pool) put(conn *network.TCPClient, broken bool) {
+	defer func() {
+		<-p.slots
+	}()
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if broken || p.closed {
+		conn.Close()
+		return
+	}
+
+	p.idle = append(p.idle, conn)
+}
+
+// close closes idle connections, connections in use are closed on put
+func (p *pool) close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.closed = true
+	for _, conn := range p.idle {
+		conn.Close()
+	}
+	p.idle = nil
+}
+
+// dialError means request was not sent, so it is safe to retry any command
+type dialError struct {
+	err error
+}
+
+func (e *dialError) Error() string {
+	return "unable to connect: " + e.err.Error()
+}
+
+func (e *dialError) Unwrap() error {
+	return e.err
+}
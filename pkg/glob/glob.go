@@ -0,0 +1,106 @@
+// Package glob matches strings with glob patterns
+package glob
+
+// Match matches s with glob pattern, * matches any sequence and ? matches
+// one character
+func Match(pattern, s string) bool {
+	p, k := []rune(pattern), []rune(s)
+
+	// position of last * in pattern and position of s it currently covers
+	star, covered := -1, 0
+	i, j := 0, 0
+	for j < len(k) {
+		switch {
+		case i < len(p) && (p[i] == '?' || p[i] == k[j]):
+			i++
+			j++
+		case i < len(p) && p[i] == '*':
+			star, covered = i, j
+			i++
+		case star >= 0:
+			// * takes one more character
+			covered++
+			i, j = star+1, covered
+		default:
+			return false
+		}
+	}
+
+	for i < len(p) && p[i] == '*' {
+		i++
+	}
+
+	return i == len(p)
+}
+
+// Covers reports whether every string matched by sub pattern is matched by
+// pattern, * and ? of sub are matched only by * and ? of pattern
+func Covers(pattern, sub string) bool {
+	p, q := []rune(pattern), []rune(sub)
+
+	memo := make(map[[2]int]bool)
+	var covers func(i, j int) bool
+	covers = func(i, j int) bool {
+		if j == len(q) {
+			for i < len(p) && p[i] == '*' {
+				i++
+			}
+			return i == len(p)
+		}
+		if i == len(p) {
+			return false
+		}
+
+		if ok, found := memo[[2]int{i, j}]; found {
+			return ok
+		}
+
+		var ok bool
+		switch {
+		case p[i] == '*':
+			// * of pattern is empty or takes one more symbol of sub
+			ok = covers(i+1, j) || covers(i, j+1)
+		case q[j] == '*':
+			ok = false
+		case p[i] == '?' || (p[i] == q[j] && q[j] != '?'):
+			ok = covers(i+1, j+1)
+		}
+
+		memo[[2]int{i, j}] = ok
+		return ok
+	}
+
+	return covers(0, 0)
+}
+
+// Intersects reports whether there is a string matched by both patterns
+func Intersects(a, b string) bool {
+	p, q := []rune(a), []rune(b)
+
+	memo := make(map[[2]int]bool)
+	var intersects func(i, j int) bool
+	intersects = func(i, j int) bool {
+		if i == len(p) && j == len(q) {
+			return true
+		}
+
+		if ok, found := memo[[2]int{i, j}]; found {
+			return ok
+		}
+
+		var ok bool
+		switch {
+		case i < len(p) && p[i] == '*':
+			ok = intersects(i+1, j) || (j < len(q) && intersects(i, j+1))
+		case j < len(q) && q[j] == '*':
+			ok = intersects(i, j+1) || (i < len(p) && intersects(i+1, j))
+		case i < len(p) && j < len(q) && (p[i] == '?' || q[j] == '?' || p[i] == q[j]):
+			ok = intersects(i+1, j+1)
+		}
+
+		memo[[2]int{i, j}] = ok
+		return ok
+	}
+
+	return intersects(0, 0)
+}
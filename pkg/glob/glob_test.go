@@ -0,0 +1,87 @@
+package glob
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern string
+		key     string
+		match   bool
+	}{
+		{pattern: "billing:*", key: "billing:1", match: true},
+		{pattern: "billing:*", key: "billing:", match: true},
+		{pattern: "billing:*", key: "billing", match: false},
+		{pattern: "billing:*", key: "shop:billing:1", match: false},
+		{pattern: "*:orders", key: "shop:orders", match: true},
+		{pattern: "*:orders", key: "shop:orders:1", match: false},
+		{pattern: "a*b*c", key: "aXXbYYc", match: true},
+		{pattern: "a*b*c", key: "aXXbYY", match: false},
+		{pattern: "user:?", key: "user:1", match: true},
+		{pattern: "user:?", key: "user:12", match: false},
+		{pattern: "*", key: "any/key", match: true},
+		{pattern: "exact", key: "exact", match: true},
+		{pattern: "ключ:*", key: "ключ:1", match: true},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.match, Match(test.pattern, test.key), "%s %s", test.pattern, test.key)
+	}
+}
+
+func TestCovers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern string
+		sub     string
+		covers  bool
+	}{
+		{pattern: "billing:*", sub: "billing:*", covers: true},
+		{pattern: "billing:*", sub: "billing:1", covers: true},
+		{pattern: "billing:*", sub: "billing:eu:*", covers: true},
+		{pattern: "billing:*", sub: "billing*", covers: false},
+		{pattern: "billing:*", sub: "*", covers: false},
+		{pattern: "billing:?", sub: "billing:*", covers: false},
+		{pattern: "billing:?", sub: "billing:?", covers: true},
+		{pattern: "user:1", sub: "user:?", covers: false},
+		{pattern: "*", sub: "any:*:?", covers: true},
+		{pattern: "*:orders", sub: "shop:*:orders", covers: true},
+		{pattern: "a*b", sub: "a*", covers: false},
+		{pattern: "", sub: "", covers: true},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.covers, Covers(test.pattern, test.sub), "%s %s", test.pattern, test.sub)
+	}
+}
+
+func TestIntersects(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		a, b       string
+		intersects bool
+	}{
+		{a: "billing:*", b: "billing:secret:*", intersects: true},
+		{a: "billing:*", b: "shop:*", intersects: false},
+		{a: "*", b: "__keyevent__:*", intersects: true},
+		{a: "__key*", b: "__keyevent__:*", intersects: true},
+		{a: "__keyspace__:*", b: "__keyevent__:*", intersects: false},
+		{a: "news.?", b: "__keyspace__:*", intersects: false},
+		{a: "*:orders", b: "shop:*", intersects: true},
+		{a: "user:?", b: "user:12", intersects: false},
+		{a: "a*c", b: "*b*", intersects: true},
+		{a: "", b: "*", intersects: true},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.intersects, Intersects(test.a, test.b), "%s %s", test.a, test.b)
+		assert.Equal(t, test.intersects, Intersects(test.b, test.a), "%s %s", test.b, test.a)
+	}
+}
@@ -0,0 +1,405 @@
+// Package metrics is a minimal implementation of counters, gauges and
+// histograms exposed in Prometheus text format
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DurationBuckets are default histogram buckets for durations in seconds
+var DurationBuckets = []float64{
+	0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01,
+	0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10,
+}
+
+// ExponentialBuckets returns count buckets, the first bucket is start and
+// every next bucket is multiplied by factor
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+
+	return buckets
+}
+
+// Collector is a metric family which may be registered in registry
+type Collector interface {
+	Name() string
+	write(buffer *bytes.Buffer)
+}
+
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+// Name returns metric name
+func (d *desc) Name() string {
+	return d.name
+}
+
+func (d *desc) writeHeader(buffer *bytes.Buffer, metricType string) {
+	fmt.Fprintf(buffer, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, metricType)
+}
+
+// vec stores children of metric by label values
+type vec[T any] struct {
+	desc
+
+	mutex    sync.RWMutex
+	children map[string]*child[T]
+	create   func() *T
+}
+
+type child[T any] struct {
+	values []string
+	metric *T
+}
+
+func newVec[T any](name, help string, labels []string, create func() *T) vec[T] {
+	return vec[T]{
+		desc:     desc{name: name, help: help, labels: labels},
+		children: make(map[string]*child[T]),
+		create:   create,
+	}
+}
+
+func (v *vec[T]) with(values []string) *T {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+
+	v.mutex.RLock()
+	c, ok := v.children[key]
+	v.mutex.RUnlock()
+	if ok {
+		return c.metric
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if c, ok = v.children[key]; !ok {
+		c = &child[T]{values: slices.Clone(values), metric: v.create()}
+		v.children[key] = c
+	}
+
+	return c.metric
+}
+
+func (v *vec[T]) delete(values []string) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	delete(v.children, strings.Join(values, "\xff"))
+}
+
+// sorted returns children sorted by label values, so output is stable
+func (v *vec[T]) sorted() []*child[T] {
+	v.mutex.RLock()
+	children := make([]*child[T], 0, len(v.children))
+	for _, c := range v.children {
+		children = append(children, c)
+	}
+	v.mutex.RUnlock()
+
+	sort.Slice(children, func(i, j int) bool {
+		return slices.Compare(children[i].values, children[j].values) < 0
+	})
+
+	return children
+}
+
+// value is a float value updated without locks
+type value struct {
+	bits atomic.Uint64
+}
+
+func (v *value) add(delta float64) {
+	for {
+		old := v.bits.Load()
+		if v.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+func (v *value) set(x float64) {
+	v.bits.Store(math.Float64bits(x))
+}
+
+func (v *value) get() float64 {
+	return math.Float64frombits(v.bits.Load())
+}
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	value value
+}
+
+// Inc increments counter
+func (c *Counter) Inc() {
+	c.value.add(1)
+}
+
+// Add adds non-negative delta to counter
+func (c *Counter) Add(delta float64) {
+	if delta < 0 {
+		return
+	}
+	c.value.add(delta)
+}
+
+// Value returns current value of counter
+func (c *Counter) Value() float64 {
+	return c.value.get()
+}
+
+// CounterVec is a counter partitioned by labels
+type CounterVec struct {
+	vec[Counter]
+}
+
+// NewCounterVec returns new counter with labels
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{newVec(name, help, labels, func() *Counter { return &Counter{} })}
+}
+
+// WithLabelValues returns counter for label values in order of labels
+func (c *CounterVec) WithLabelValues(values ...string) *Counter {
+	return c.with(values)
+}
+
+func (c *CounterVec) write(buffer *bytes.Buffer) {
+	c.writeHeader(buffer, "counter")
+	for _, child := range c.sorted() {
+		writeSample(buffer, c.name, c.labels, child.values, child.metric.Value())
+	}
+}
+
+// Gauge is a value which may go up and down
+type Gauge struct {
+	value value
+}
+
+// Set sets gauge value
+func (g *Gauge) Set(x float64) {
+	g.value.set(x)
+}
+
+// Inc increments gauge
+func (g *Gauge) Inc() {
+	g.value.add(1)
+}
+
+// Dec decrements gauge
+func (g *Gauge) Dec() {
+	g.value.add(-1)
+}
+
+// Value returns current value of gauge
+func (g *Gauge) Value() float64 {
+	return g.value.get()
+}
+
+// GaugeVec is a gauge partitioned by labels
+type GaugeVec struct {
+	vec[Gauge]
+}
+
+// NewGaugeVec returns new gauge with labels
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{newVec(name, help, labels, func() *Gauge { return &Gauge{} })}
+}
+
+// WithLabelValues returns gauge for label values in order of labels
+func (g *GaugeVec) WithLabelValues(values ...string) *Gauge {
+	return g.with(values)
+}
+
+// DeleteLabelValues removes gauge for label values, so it is not exposed
+func (g *GaugeVec) DeleteLabelValues(values ...string) {
+	g.delete(values)
+}
+
+func (g *GaugeVec) write(buffer *bytes.Buffer) {
+	g.writeHeader(buffer, "gauge")
+	for _, child := range g.sorted() {
+		writeSample(buffer, g.name, g.labels, child.values, child.metric.Value())
+	}
+}
+
+// Histogram counts observations in buckets
+type Histogram struct {
+	buckets []float64
+
+	mutex  sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+// Observe adds observation to histogram
+func (h *Histogram) Observe(x float64) {
+	i := sort.SearchFloat64s(h.buckets, x)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += x
+}
+
+// ObserveSince adds duration since start in seconds to histogram
+func (h *Histogram) ObserveSince(start time.Time) {
+	h.Observe(time.Since(start).Seconds())
+}
+
+// snapshot returns cumulative bucket counts, total count and sum
+func (h *Histogram) snapshot() ([]uint64, uint64, float64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	cumulative := make([]uint64, len(h.counts))
+	var total uint64
+	for i, count := range h.counts {
+		total += count
+		cumulative[i] = total
+	}
+
+	return cumulative, h.count, h.sum
+}
+
+// HistogramVec is a histogram partitioned by labels
+type HistogramVec struct {
+	vec[Histogram]
+}
+
+// NewHistogramVec returns new histogram with labels, buckets are upper
+// bounds in increasing order
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("buckets of metric %s are not sorted", name))
+	}
+
+	return &HistogramVec{newVec(name, help, labels, func() *Histogram { return newHistogram(buckets) })}
+}
+
+// WithLabelValues returns histogram for label values in order of labels
+func (h *HistogramVec) WithLabelValues(values ...string) *Histogram {
+	return h.with(values)
+}
+
+func (h *HistogramVec) write(buffer *bytes.Buffer) {
+	h.writeHeader(buffer, "histogram")
+
+	labels := append(slices.Clone(h.labels), "le")
+	for _, child := range h.sorted() {
+		counts, count, sum := child.metric.snapshot()
+		values := append(slices.Clone(child.values), "")
+
+		for i, bound := range child.metric.buckets {
+			values[len(values)-1] = formatFloat(bound)
+			writeSample(buffer, h.name+"_bucket", labels, values, float64(counts[i]))
+		}
+		values[len(values)-1] = "+Inf"
+		writeSample(buffer, h.name+"_bucket", labels, values, float64(count))
+
+		writeSample(buffer, h.name+"_sum", h.labels, child.values, sum)
+		writeSample(buffer, h.name+"_count", h.labels, child.values, float64(count))
+	}
+}
+
+// GaugeFunc is a gauge which values are collected on every scrape
+type GaugeFunc struct {
+	desc
+	collect func(emit func(value float64, labelValues ...string))
+}
+
+// NewGaugeFunc returns gauge which calls collect on scrape, collect emits
+// value for every set of label values
+func NewGaugeFunc(name, help string, labels []string,
+	collect func(emit func(value float64, labelValues ...string)),
+) *GaugeFunc {
+	return &GaugeFunc{
+		desc:    desc{name: name, help: help, labels: labels},
+		collect: collect,
+	}
+}
+
+func (g *GaugeFunc) write(buffer *bytes.Buffer) {
+	g.writeHeader(buffer, "gauge")
+	g.collect(func(value float64, labelValues ...string) {
+		if len(labelValues) != len(g.labels) {
+			return
+		}
+		writeSample(buffer, g.name, g.labels, labelValues, value)
+	})
+}
+
+func writeSample(buffer *bytes.Buffer, name string, labels, values []string, value float64) {
+	buffer.WriteString(name)
+	if len(labels) != 0 {
+		buffer.WriteByte('{')
+		for i, label := range labels {
+			if i != 0 {
+				buffer.WriteByte(',')
+			}
+			fmt.Fprintf(buffer, "%s=\"%s\"", label, escapeLabel(values[i]))
+		}
+		buffer.WriteByte('}')
+	}
+	buffer.WriteByte(' ')
+	buffer.WriteString(formatFloat(value))
+	buffer.WriteByte('\n')
+}
+
+func formatFloat(x float64) string {
+	switch {
+	case math.IsInf(x, 1):
+		return "+Inf"
+	case math.IsInf(x, -1):
+		return "-Inf"
+	case math.IsNaN(x):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(x, 'g', -1, 64)
+	}
+}
+
+var (
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
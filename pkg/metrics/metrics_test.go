@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryScrape(t *testing.T) {
+	t.Parallel()
+
+	registry := NewRegistry()
+
+	commands := NewCounterVec("commands_total", "Number of commands.", "command", "status")
+	registry.Register(commands)
+	commands.WithLabelValues("SET", "ok").Inc()
+	commands.WithLabelValues("GET", "ok").Add(2)
+	commands.WithLabelValues("GET", "not_found").Inc()
+	commands.WithLabelValues("GET", "ok").Add(-1)
+
+	connections := NewGaugeVec("connections_active", "Number of connections.", "address")
+	registry.Register(connections)
+	connections.WithLabelValues("127.0.0.1:3223").Inc()
+	connections.WithLabelValues("127.0.0.1:3223").Inc()
+	connections.WithLabelValues("127.0.0.1:3223").Dec()
+	connections.WithLabelValues(`a"b\c`).Set(5)
+	connections.WithLabelValues("removed").Set(1)
+	connections.DeleteLabelValues("removed")
+
+	latency := NewHistogramVec("latency_seconds", "Latency\nof commands.", []float64{0.1, 1})
+	registry.Register(latency)
+	latency.WithLabelValues().Observe(0.05)
+	latency.WithLabelValues().Observe(0.1)
+	latency.WithLabelValues().Observe(0.5)
+	latency.WithLabelValues().Observe(3)
+
+	registry.Register(NewGaugeFunc("keys", "Number of keys.", []string{"partition"},
+		func(emit func(float64, ...string)) {
+			emit(3, "0")
+			emit(1.5, "1")
+			emit(1, "ignored", "extra")
+		}))
+
+	server := httptest.NewServer(registry)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/metrics")
+	require.NoError(t, err)
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, ContentType, resp.Header.Get("Content-Type"))
+	assert.Equal(t, `# HELP commands_total Number of commands.
+# TYPE commands_total counter
+commands_total{command="GET",status="not_found"} 1
+commands_total{command="GET",status="ok"} 2
+commands_total{command="SET",status="ok"} 1
+# HELP connections_active Number of connections.
+# TYPE connections_active gauge
+connections_active{address="127.0.0.1:3223"} 1
+connections_active{address="a\"b\\c"} 5
+# HELP keys Number of keys.
+# TYPE keys gauge
+keys{partition="0"} 3
+keys{partition="1"} 1.5
+# HELP latency_seconds Latency\nof commands.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 2
+latency_seconds_bucket{le="1"} 3
+latency_seconds_bucket{le="+Inf"} 4
+latency_seconds_sum 3.65
+latency_seconds_count 4
+`, string(body))
+}
+
+func TestRegisterReplaces(t *testing.T) {
+	t.Parallel()
+
+	registry := NewRegistry()
+
+	first := NewGaugeVec("value", "Value.")
+	first.WithLabelValues().Set(1)
+	registry.Register(first)
+
+	second := NewGaugeVec("value", "Value.")
+	second.WithLabelValues().Set(2)
+	registry.Register(second)
+
+	server := httptest.NewServer(registry)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "# HELP value Value.\n# TYPE value gauge\nvalue 2\n", string(body))
+}
+
+func TestLabelValuesMismatch(t *testing.T) {
+	t.Parallel()
+
+	counter := NewCounterVec("commands_total", "Number of commands.", "command")
+	assert.Panics(t, func() {
+		counter.WithLabelValues("GET", "ok")
+	})
+	assert.Panics(t, func() {
+		NewHistogramVec("latency_seconds", "Latency.", []float64{1, 0.1})
+	})
+}
+
+func TestExponentialBuckets(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []float64{1, 4, 16, 64}, ExponentialBuckets(1, 4, 4))
+}
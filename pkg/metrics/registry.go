@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// ContentType is a content type of Prometheus text format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Default is a registry of metrics exposed by server
+var Default = NewRegistry()
+
+// Registry is a set of metrics exposed together
+type Registry struct {
+	mutex      sync.RWMutex
+	collectors map[string]Collector
+}
+
+// NewRegistry returns new empty registry
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]Collector)}
+}
+
+// Register adds collector to registry, collector with the same name is
+// replaced, so components created again expose their latest state
+func (r *Registry) Register(collector Collector) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.collectors[collector.Name()] = collector
+}
+
+// WriteTo writes all metrics sorted by name in Prometheus text format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mutex.RLock()
+	collectors := make([]Collector, 0, len(r.collectors))
+	for _, collector := range r.collectors {
+		collectors = append(collectors, collector)
+	}
+	r.mutex.RUnlock()
+
+	sort.Slice(collectors, func(i, j int) bool {
+		return collectors[i].Name() < collectors[j].Name()
+	})
+
+	var buffer bytes.Buffer
+	for _, collector := range collectors {
+		collector.write(&buffer)
+	}
+
+	return buffer.WriteTo(w)
+}
+
+// ServeHTTP writes metrics of registry
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	_, _ = r.WriteTo(w)
+}
+
+// Register adds collector to default registry and returns it, so metrics
+// may be declared as package variables
+func Register[T Collector](collector T) T {
+	Default.Register(collector)
+	return collector
+}
+
+// Handler returns HTTP handler of default registry
+func Handler() http.Handler {
+	return Default
+}
@@ -40,3 +40,16 @@ func (s *Semaphore) Release() {
 	s.count--
 	s.cond.Signal()
 }
+
+// TryAcquire acquires semaphore if it does not block, returns false otherwise
+func (s *Semaphore) TryAcquire() bool {
+	s.cond.L.Lock()
+	defer s.cond.L.Unlock()
+
+	if s.count >= s.max {
+		return false
+	}
+
+	s.count++
+	return true
+}
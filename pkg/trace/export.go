@@ -0,0 +1,349 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Exporter sends batches of finished spans
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+const (
+	queueSize     = 2048
+	batchSize     = 256
+	flushInterval = time.Second
+)
+
+// processor collects finished spans and exports them in batches in
+// background, spans are dropped if queue is full
+type processor struct {
+	exporter Exporter
+
+	queue chan SpanData
+	done  chan struct{}
+	once  sync.Once
+
+	mutex   sync.RWMutex
+	stopped bool
+}
+
+func newProcessor(exporter Exporter) *processor {
+	p := &processor{
+		exporter: exporter,
+		queue:    make(chan SpanData, queueSize),
+		done:     make(chan struct{}),
+	}
+
+	go p.run()
+
+	return p
+}
+
+func (p *processor) add(data SpanData) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if p.stopped {
+		return
+	}
+
+	select {
+	case p.queue <- data:
+	default:
+	}
+}
+
+func (p *processor) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]SpanData, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		_ = p.exporter.Export(context.Background(), batch)
+		batch = make([]SpanData, 0, batchSize)
+	}
+
+	for {
+		select {
+		case data, ok := <-p.queue:
+			if !ok {
+				flush()
+				return
+			}
+
+			batch = append(batch, data)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// shutdown exports queued spans and stops exporter, spans added later are
+// dropped
+func (p *processor) shutdown(ctx context.Context) error {
+	p.once.Do(func() {
+		p.mutex.Lock()
+		p.stopped = true
+		close(p.queue)
+		p.mutex.Unlock()
+	})
+
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return p.exporter.Shutdown(ctx)
+}
+
+// FileExporter appends every batch to file as a line of OpenTelemetry
+// protocol JSON
+type FileExporter struct {
+	service string
+
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// NewFileExporter opens or creates file of spans
+func NewFileExporter(service, path string) (*FileExporter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open trace file: %w", err)
+	}
+
+	return &FileExporter{service: service, file: file}, nil
+}
+
+// Export writes batch of spans
+func (e *FileExporter) Export(_ context.Context, spans []SpanData) error {
+	data, err := Encode(e.service, spans)
+	if err != nil {
+		return err
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	_, err = e.file.Write(append(data, '\n'))
+	return err
+}
+
+// Shutdown closes file
+func (e *FileExporter) Shutdown(_ context.Context) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.file.Close()
+}
+
+// HTTPExporter posts batches of spans to OpenTelemetry protocol HTTP
+// endpoint, such as http://localhost:4318/v1/traces
+type HTTPExporter struct {
+	service  string
+	endpoint string
+	client   *http.Client
+}
+
+// NewHTTPExporter returns exporter to endpoint
+func NewHTTPExporter(service, endpoint string) *HTTPExporter {
+	return &HTTPExporter{
+		service:  service,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Export posts batch of spans
+func (e *HTTPExporter) Export(ctx context.Context, spans []SpanData) error {
+	data, err := Encode(e.service, spans)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("create export request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := e.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("export spans: %w", err)
+	}
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("export spans: unexpected status %s", response.Status)
+	}
+
+	return nil
+}
+
+// Shutdown closes idle connections
+func (e *HTTPExporter) Shutdown(_ context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// scopeName is an instrumentation scope of exported spans
+const scopeName = "concurrency_go_course"
+
+// statusCodeError is a status code of failed span
+const statusCodeError = 2
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              Kind            `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Links             []otlpLink      `json:"links,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpLink struct {
+	TraceID string `json:"traceId"`
+	SpanID  string `json:"spanId"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+// Encode returns spans of service in OpenTelemetry protocol JSON encoding
+func Encode(service string, spans []SpanData) ([]byte, error) {
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		encoded = append(encoded, encodeSpan(span))
+	}
+
+	data, err := json.Marshal(otlpTraces{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: []otlpAttribute{encodeAttribute(String("service.name", service))},
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: scopeName},
+				Spans: encoded,
+			}},
+		}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encode spans: %w", err)
+	}
+
+	return data, nil
+}
+
+func encodeSpan(span SpanData) otlpSpan {
+	encoded := otlpSpan{
+		TraceID:           span.Context.TraceID.String(),
+		SpanID:            span.Context.SpanID.String(),
+		Name:              span.Name,
+		Kind:              span.Kind,
+		StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+	}
+
+	if span.ParentSpanID.IsValid() {
+		encoded.ParentSpanID = span.ParentSpanID.String()
+	}
+
+	for _, attribute := range span.Attributes {
+		encoded.Attributes = append(encoded.Attributes, encodeAttribute(attribute))
+	}
+
+	for _, link := range span.Links {
+		encoded.Links = append(encoded.Links, otlpLink{
+			TraceID: link.TraceID.String(),
+			SpanID:  link.SpanID.String(),
+		})
+	}
+
+	if span.Error != "" {
+		encoded.Status = &otlpStatus{Code: statusCodeError, Message: span.Error}
+	}
+
+	return encoded
+}
+
+func encodeAttribute(attribute Attribute) otlpAttribute {
+	var value otlpValue
+	switch v := attribute.Value.(type) {
+	case string:
+		value.StringValue = &v
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		value.IntValue = &s
+	case float64:
+		value.DoubleValue = &v
+	case bool:
+		value.BoolValue = &v
+	default:
+		s := fmt.Sprint(v)
+		value.StringValue = &s
+	}
+
+	return otlpAttribute{Key: attribute.Key, Value: value}
+}
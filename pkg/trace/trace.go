@@ -0,0 +1,359 @@
+// Package trace is a minimal tracer which exports spans in OpenTelemetry
+// protocol JSON encoding and propagates context in W3C traceparent format
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceID identifies trace
+type TraceID [16]byte
+
+// String returns hex encoded trace id
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// IsValid returns false for zero trace id
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+// SpanID identifies span in trace
+type SpanID [8]byte
+
+// String returns hex encoded span id
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// IsValid returns false for zero span id
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// SpanContext is a part of span which is propagated to child spans and
+// other nodes
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid returns true if trace and span ids are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent returns context in W3C traceparent format, it is empty for
+// invalid context
+func (sc SpanContext) Traceparent() string {
+	if !sc.IsValid() {
+		return ""
+	}
+
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ErrInvalidTraceparent is returned for malformed traceparent
+var ErrInvalidTraceparent = errors.New("invalid traceparent")
+
+// ParseTraceparent parses context in W3C traceparent format
+func ParseTraceparent(traceparent string) (SpanContext, error) {
+	parts := strings.Split(traceparent, "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, fmt.Errorf("%w %q", ErrInvalidTraceparent, traceparent)
+	}
+
+	var sc SpanContext
+	_, errTrace := hex.Decode(sc.TraceID[:], []byte(parts[1]))
+	_, errSpan := hex.Decode(sc.SpanID[:], []byte(parts[2]))
+	flags, errFlags := hex.DecodeString(parts[3])
+	if errTrace != nil || errSpan != nil || errFlags != nil || !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("%w %q", ErrInvalidTraceparent, traceparent)
+	}
+	sc.Sampled = flags[0]&1 == 1
+
+	return sc, nil
+}
+
+// Kind is a role of span in trace
+type Kind int
+
+// Kinds of spans, values match OpenTelemetry protocol
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+// Attribute is a key and value of span, value is string, int64, float64
+// or bool
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// String returns attribute with string value
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int returns attribute with integer value
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: int64(value)}
+}
+
+// Int64 returns attribute with integer value
+func Int64(key string, value int64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Bool returns attribute with boolean value
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// SpanData is a finished span passed to exporter
+type SpanData struct {
+	Name         string
+	Context      SpanContext
+	ParentSpanID SpanID
+	Kind         Kind
+	Start        time.Time
+	End          time.Time
+	Attributes   []Attribute
+	Links        []SpanContext
+	// Error is a status message of failed span, it is empty if span
+	// succeeded
+	Error string
+}
+
+// Span is a timed operation, methods of nil span do nothing, so callers
+// do not check whether tracing is enabled
+type Span struct {
+	tracer *Tracer
+	ended  atomic.Bool
+
+	mutex sync.Mutex
+	data  SpanData
+}
+
+// Context returns context of span
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+
+	return s.data.Context
+}
+
+// SetAttributes adds attributes to span
+func (s *Span) SetAttributes(attributes ...Attribute) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.data.Attributes = append(s.data.Attributes, attributes...)
+}
+
+// RecordError marks span as failed, nil error is ignored
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.data.Error = err.Error()
+}
+
+// End finishes span, span is exported if it is sampled
+func (s *Span) End() {
+	if s == nil || s.ended.Swap(true) {
+		return
+	}
+
+	s.mutex.Lock()
+	s.data.End = time.Now()
+	data := s.data
+	s.mutex.Unlock()
+
+	if data.Context.Sampled {
+		s.tracer.processor.add(data)
+	}
+}
+
+// Option is an option of started span
+type Option func(*SpanData)
+
+// WithKind sets kind of span, spans are internal by default
+func WithKind(kind Kind) Option {
+	return func(data *SpanData) {
+		data.Kind = kind
+	}
+}
+
+// WithAttributes adds attributes to span
+func WithAttributes(attributes ...Attribute) Option {
+	return func(data *SpanData) {
+		data.Attributes = append(data.Attributes, attributes...)
+	}
+}
+
+// WithLinks links span with spans of other traces, such as requests
+// handled in one batch
+func WithLinks(links ...SpanContext) Option {
+	return func(data *SpanData) {
+		for _, link := range links {
+			if link.IsValid() {
+				data.Links = append(data.Links, link)
+			}
+		}
+	}
+}
+
+// Tracer starts spans and exports finished spans in batches
+type Tracer struct {
+	sampleRatio float64
+	processor   *processor
+}
+
+// NewTracer returns tracer, sampleRatio is a share of new traces which are
+// exported, child spans follow decision of parent
+func NewTracer(exporter Exporter, sampleRatio float64) *Tracer {
+	return &Tracer{
+		sampleRatio: clampRatio(sampleRatio),
+		processor:   newProcessor(exporter),
+	}
+}
+
+// Start starts span, parent is a span or remote context of ctx
+func (t *Tracer) Start(ctx context.Context, name string, options ...Option) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+
+	span := &Span{
+		tracer: t,
+		data: SpanData{
+			Name:  name,
+			Kind:  KindInternal,
+			Start: time.Now(),
+		},
+	}
+
+	if parent.IsValid() {
+		span.data.Context.TraceID = parent.TraceID
+		span.data.Context.Sampled = parent.Sampled
+		span.data.ParentSpanID = parent.SpanID
+	} else {
+		span.data.Context.TraceID = newTraceID()
+		span.data.Context.Sampled = t.sample(span.data.Context.TraceID)
+	}
+	span.data.Context.SpanID = newSpanID()
+
+	for _, option := range options {
+		option(&span.data)
+	}
+
+	return context.WithValue(ctx, parentKey{}, span), span
+}
+
+// sample decides by trace id, so the same trace is sampled on every node
+func (t *Tracer) sample(id TraceID) bool {
+	if t.sampleRatio >= 1 {
+		return true
+	}
+
+	return float64(binary.BigEndian.Uint64(id[8:])>>11) < t.sampleRatio*float64(uint64(1)<<53)
+}
+
+// Shutdown exports buffered spans and stops exporter
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	return t.processor.shutdown(ctx)
+}
+
+var defaultTracer atomic.Pointer[Tracer]
+
+// SetDefault sets tracer used by Start, nil disables tracing
+func SetDefault(tracer *Tracer) {
+	defaultTracer.Store(tracer)
+}
+
+// Start starts span of default tracer, it returns nil span if tracing is
+// disabled
+func Start(ctx context.Context, name string, options ...Option) (context.Context, *Span) {
+	tracer := defaultTracer.Load()
+	if tracer == nil {
+		return ctx, nil
+	}
+
+	return tracer.Start(ctx, name, options...)
+}
+
+// parentKey is a key of span or remote span context, the latest one is
+// a parent of new spans
+type parentKey struct{}
+
+// SpanFromContext returns span of context, nil if there is no span
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(parentKey{}).(*Span)
+	return span
+}
+
+// ContextWithRemote returns context with parent received from other node,
+// it replaces span of ctx as parent
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, parentKey{}, sc)
+}
+
+// SpanContextFromContext returns context of parent of new spans
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	switch parent := ctx.Value(parentKey{}).(type) {
+	case *Span:
+		return parent.Context()
+	case SpanContext:
+		return parent
+	default:
+		return SpanContext{}
+	}
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		_, _ = rand.Read(id[:])
+	}
+	return id
+}
+
+// clampRatio returns ratio in range [0, 1]
+func clampRatio(ratio float64) float64 {
+	return math.Min(math.Max(ratio, 0), 1)
+}
@@ -0,0 +1,211 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryExporter struct {
+	mutex sync.Mutex
+	spans []SpanData
+}
+
+func (e *memoryExporter) Export(_ context.Context, spans []SpanData) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *memoryExporter) Shutdown(_ context.Context) error {
+	return nil
+}
+
+func TestParseTraceparent(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		traceparent string
+		sampled     bool
+		err         error
+	}{
+		"sampled": {
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			sampled:     true,
+		},
+		"not sampled": {
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+		},
+		"empty": {
+			err: ErrInvalidTraceparent,
+		},
+		"unknown version": {
+			traceparent: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			err:         ErrInvalidTraceparent,
+		},
+		"zero trace id": {
+			traceparent: "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			err:         ErrInvalidTraceparent,
+		},
+		"not hex": {
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+			err:         ErrInvalidTraceparent,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			sc, err := ParseTraceparent(test.traceparent)
+			if test.err != nil {
+				assert.ErrorIs(t, err, test.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.sampled, sc.Sampled)
+			assert.Equal(t, test.traceparent, sc.Traceparent())
+		})
+	}
+}
+
+func TestTracerStart(t *testing.T) {
+	t.Parallel()
+
+	exporter := &memoryExporter{}
+	tracer := NewTracer(exporter, 1)
+
+	ctx, root := tracer.Start(context.Background(), "root", WithKind(KindServer))
+	_, child := tracer.Start(ctx, "child", WithAttributes(String("key", "value"), Int("size", 3)))
+	child.RecordError(errors.New("failed"))
+	child.End()
+	root.End()
+	root.End()
+
+	remote := ContextWithRemote(ctx, SpanContext{TraceID: TraceID{1}, SpanID: SpanID{2}, Sampled: true})
+	_, server := tracer.Start(remote, "server")
+	server.End()
+
+	require.NoError(t, tracer.Shutdown(context.Background()))
+	require.Len(t, exporter.spans, 3)
+
+	childData, rootData, serverData := exporter.spans[0], exporter.spans[1], exporter.spans[2]
+	assert.Equal(t, "root", rootData.Name)
+	assert.Equal(t, KindServer, rootData.Kind)
+	assert.False(t, rootData.ParentSpanID.IsValid())
+
+	assert.Equal(t, KindInternal, childData.Kind)
+	assert.Equal(t, rootData.Context.TraceID, childData.Context.TraceID)
+	assert.Equal(t, rootData.Context.SpanID, childData.ParentSpanID)
+	assert.Equal(t, []Attribute{String("key", "value"), Int("size", 3)}, childData.Attributes)
+	assert.Equal(t, "failed", childData.Error)
+
+	assert.Equal(t, TraceID{1}, serverData.Context.TraceID)
+	assert.Equal(t, SpanID{2}, serverData.ParentSpanID)
+}
+
+func TestTracerSample(t *testing.T) {
+	t.Parallel()
+
+	exporter := &memoryExporter{}
+	tracer := NewTracer(exporter, 0)
+
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, child := tracer.Start(ctx, "child")
+	child.End()
+	root.End()
+
+	assert.False(t, root.Context().Sampled)
+	assert.False(t, child.Context().Sampled)
+
+	require.NoError(t, tracer.Shutdown(context.Background()))
+	assert.Empty(t, exporter.spans)
+}
+
+func TestStartDisabled(t *testing.T) {
+	ctx, span := Start(context.Background(), "span")
+	assert.Nil(t, span)
+	assert.Nil(t, SpanFromContext(ctx))
+
+	span.SetAttributes(String("key", "value"))
+	span.RecordError(errors.New("failed"))
+	span.End()
+	assert.False(t, span.Context().IsValid())
+}
+
+func TestFileExporter(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "spans.json")
+	exporter, err := NewFileExporter("node", path)
+	require.NoError(t, err)
+
+	tracer := NewTracer(exporter, 1)
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, child := tracer.Start(ctx, "child", WithLinks(root.Context(), SpanContext{}))
+	child.SetAttributes(Bool("ok", true))
+	child.End()
+	root.End()
+	require.NoError(t, tracer.Shutdown(context.Background()))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, 1, strings.Count(string(data), "\n"))
+
+	var traces otlpTraces
+	require.NoError(t, json.Unmarshal(data, &traces))
+	require.Len(t, traces.ResourceSpans, 1)
+
+	resource := traces.ResourceSpans[0]
+	assert.Equal(t, "service.name", resource.Resource.Attributes[0].Key)
+	assert.Equal(t, "node", *resource.Resource.Attributes[0].Value.StringValue)
+	require.Len(t, resource.ScopeSpans[0].Spans, 2)
+
+	span := resource.ScopeSpans[0].Spans[0]
+	assert.Equal(t, "child", span.Name)
+	assert.Equal(t, root.Context().TraceID.String(), span.TraceID)
+	assert.Equal(t, root.Context().SpanID.String(), span.ParentSpanID)
+	assert.Equal(t, []otlpLink{{TraceID: span.TraceID, SpanID: span.ParentSpanID}}, span.Links)
+	assert.True(t, *span.Attributes[0].Value.BoolValue)
+	assert.Empty(t, resource.ScopeSpans[0].Spans[1].ParentSpanID)
+}
+
+func TestHTTPExporter(t *testing.T) {
+	t.Parallel()
+
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		bodies <- body
+	}))
+	defer server.Close()
+
+	tracer := NewTracer(NewHTTPExporter("node", server.URL+"/v1/traces"), 1)
+	_, span := tracer.Start(context.Background(), "span")
+	span.RecordError(errors.New("failed"))
+	span.End()
+	require.NoError(t, tracer.Shutdown(context.Background()))
+
+	var traces otlpTraces
+	require.NoError(t, json.Unmarshal(<-bodies, &traces))
+
+	encoded := traces.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	assert.Equal(t, "span", encoded.Name)
+	assert.Equal(t, &otlpStatus{Code: statusCodeError, Message: "failed"}, encoded.Status)
+}